@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"time"
 
@@ -8,6 +9,9 @@ import (
 )
 
 func main() {
+	configFile := flag.String("sim-config", "", "YAML simulation file to run instead of the built-in scenario")
+	flag.Parse()
+
 	config := kubernetes.SimulationConfig{
 		NodeCount: 50,
 		Duration:  4 * time.Hour,
@@ -29,6 +33,14 @@ func main() {
 			},
 		},
 	}
+	if *configFile != "" {
+		loaded, err := kubernetes.LoadSimulationConfig(*configFile)
+		if err != nil {
+			fmt.Printf("Failed to load simulation config: %v\n", err)
+			return
+		}
+		config = loaded
+	}
 
 	engine, err := kubernetes.NewSimulationEngine(config)
 	if err != nil {
@@ -36,7 +48,7 @@ func main() {
 		return
 	}
 
-	fmt.Println("Running simulation: 50 nodes, 4h duration, seed=42...")
+	fmt.Printf("Running simulation: %d nodes, %v duration, seed=%d...\n", config.NodeCount, config.Duration, config.Seed)
 	result, err := engine.Run()
 	if err != nil {
 		fmt.Printf("Simulation failed: %v\n", err)
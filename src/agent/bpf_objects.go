@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultBPFObjectDir is where the agent image ships the compiled BPF objects.
+const DefaultBPFObjectDir = "/ebpf"
+
+// eventsMapName is the ring buffer map declared in headers/common.h that every
+// program writes kernel events to.
+const eventsMapName = "events"
+
+// validRingBufferSize reports whether size is usable as BPF ring buffer
+// max_entries: a power of two and a multiple of the page size.
+func validRingBufferSize(size int) bool {
+	return size > 0 && size&(size-1) == 0 && size%os.Getpagesize() == 0
+}
+
+// Attach kinds supported by the loader, named after their ELF section prefix.
+const (
+	attachTracepoint = "tracepoint"
+	attachKprobe     = "kprobe"
+	attachKretprobe  = "kretprobe"
+	attachTPBTF      = "tp_btf"
+)
+
+// attachPoint describes where a BPF program hooks into the kernel, as
+// declared by the SEC() annotation in src/ebpf/*.c.
+type attachPoint struct {
+	Kind   string // one of the attach* constants
+	Group  string // tracepoint category (e.g. "sched"); empty for other kinds
+	Symbol string // tracepoint name, kernel function, or BTF tracepoint name
+}
+
+// parseAttachPoint decodes an ELF section name such as
+// "tracepoint/sched/sched_switch" or "kretprobe/vfs_read".
+func parseAttachPoint(section string) (attachPoint, error) {
+	kind, rest, ok := strings.Cut(section, "/")
+	if !ok || rest == "" {
+		return attachPoint{}, fmt.Errorf("unsupported section %q", section)
+	}
+
+	switch kind {
+	case attachTracepoint:
+		group, name, ok := strings.Cut(rest, "/")
+		if !ok || group == "" || name == "" {
+			return attachPoint{}, fmt.Errorf("tracepoint section %q must be tracepoint/<group>/<name>", section)
+		}
+		return attachPoint{Kind: kind, Group: group, Symbol: name}, nil
+	case attachKprobe, attachKretprobe, attachTPBTF:
+		return attachPoint{Kind: kind, Symbol: rest}, nil
+	default:
+		return attachPoint{}, fmt.Errorf("unsupported section %q", section)
+	}
+}
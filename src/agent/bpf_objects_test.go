@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseAttachPoint(t *testing.T) {
+	tests := []struct {
+		section string
+		want    attachPoint
+	}{
+		{"tracepoint/sched/sched_switch", attachPoint{Kind: attachTracepoint, Group: "sched", Symbol: "sched_switch"}},
+		{"tracepoint/syscalls/sys_exit_write", attachPoint{Kind: attachTracepoint, Group: "syscalls", Symbol: "sys_exit_write"}},
+		{"kprobe/tcp_retransmit_skb", attachPoint{Kind: attachKprobe, Symbol: "tcp_retransmit_skb"}},
+		{"kretprobe/vfs_read", attachPoint{Kind: attachKretprobe, Symbol: "vfs_read"}},
+		{"tp_btf/sched_switch", attachPoint{Kind: attachTPBTF, Symbol: "sched_switch"}},
+	}
+	for _, tt := range tests {
+		got, err := parseAttachPoint(tt.section)
+		if err != nil {
+			t.Errorf("parseAttachPoint(%q): unexpected error: %v", tt.section, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAttachPoint(%q) = %+v, want %+v", tt.section, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "license", "tracepoint/sched", "kprobe/", "xdp/eth0"} {
+		if _, err := parseAttachPoint(bad); err == nil {
+			t.Errorf("parseAttachPoint(%q): expected error", bad)
+		}
+	}
+}
+
+func TestValidRingBufferSize(t *testing.T) {
+	page := os.Getpagesize()
+	for _, size := range []int{page, 64 * 1024, 256 * 1024, 16 * 1024 * 1024} {
+		if size%page == 0 && !validRingBufferSize(size) {
+			t.Errorf("validRingBufferSize(%d) = false, want true", size)
+		}
+	}
+	for _, size := range []int{0, -4096, 1000, 3 * page, page / 2} {
+		if validRingBufferSize(size) {
+			t.Errorf("validRingBufferSize(%d) = true, want false", size)
+		}
+	}
+}
@@ -35,6 +35,7 @@ type CgroupResolver struct {
 	nodeName    string
 	kubeletURL  string
 	client      *http.Client
+	lastRefresh time.Time // last successful refresh, guarded by mu
 }
 
 // NewCgroupResolver creates a new CgroupResolver.
@@ -186,6 +187,20 @@ func (cr *CgroupResolver) CacheSize() int {
 	return len(cr.cache)
 }
 
+// LastRefresh returns the time of the last successful cache refresh, or the
+// zero time if none has succeeded yet.
+func (cr *CgroupResolver) LastRefresh() time.Time {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.lastRefresh
+}
+
+func (cr *CgroupResolver) markRefreshed() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.lastRefresh = time.Now()
+}
+
 // StartRefresh begins periodic cache refresh from kubelet API.
 // Blocks until ctx is cancelled.
 func (cr *CgroupResolver) StartRefresh(ctx context.Context) error {
@@ -194,6 +209,8 @@ func (cr *CgroupResolver) StartRefresh(ctx context.Context) error {
 	// Initial refresh
 	if err := cr.refresh(); err != nil {
 		log.Printf("CgroupResolver: initial refresh failed: %v", err)
+	} else {
+		cr.markRefreshed()
 	}
 
 	ticker := time.NewTicker(cr.refreshRate)
@@ -207,6 +224,8 @@ func (cr *CgroupResolver) StartRefresh(ctx context.Context) error {
 		case <-ticker.C:
 			if err := cr.refresh(); err != nil {
 				log.Printf("CgroupResolver: refresh failed (keeping stale cache): %v", err)
+			} else {
+				cr.markRefreshed()
 			}
 		}
 	}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Forwarder defaults.
+const (
+	defaultForwardBatchSize     = 100
+	defaultForwardFlushInterval = 1 * time.Second
+	defaultRetryMinBackoff      = 500 * time.Millisecond
+	defaultRetryMaxBackoff      = 30 * time.Second
+)
+
+// statusError is a non-2xx/3xx response from the server. Permanent errors
+// will not succeed on retry.
+type statusError struct {
+	status    int
+	permanent bool
+}
+
+func (e *statusError) Error() string {
+	if e.permanent {
+		return fmt.Sprintf("server rejected events (status %d)", e.status)
+	}
+	return fmt.Sprintf("server error (status %d)", e.status)
+}
+
+// Forward failure reasons reported to the observe hook.
+const (
+	forwardFailureNetwork  = "network"
+	forwardFailureServer   = "server_error"
+	forwardFailureRejected = "rejected"
+)
+
+// forwardFailureReason classifies a send error.
+func forwardFailureReason(err error) string {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return forwardFailureNetwork
+	}
+	if se.permanent {
+		return forwardFailureRejected
+	}
+	return forwardFailureServer
+}
+
+// Forwarder batches enriched events into the spool and delivers spooled
+// batches to the server in order. A batch is acknowledged (and removed from
+// the spool) only after the server accepts it; transient failures are retried
+// with exponential backoff.
+type Forwarder struct {
+	client        *http.Client
+	url           string
+	spool         *Spool
+	batchSize     int
+	flushInterval time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+
+	// observe, if set, is called after every delivery attempt.
+	observe func(d time.Duration, err error)
+
+	attempted   atomic.Bool
+	lastAttempt atomic.Bool // true if the most recent attempt reached the server
+}
+
+// NewForwarder creates a Forwarder that posts to serverURL's event endpoint.
+func NewForwarder(serverURL string, spool *Spool) *Forwarder {
+	return &Forwarder{
+		client:        &http.Client{Timeout: 10 * time.Second},
+		url:           fmt.Sprintf("%s/api/ebpf/events", serverURL),
+		spool:         spool,
+		batchSize:     defaultForwardBatchSize,
+		flushInterval: defaultForwardFlushInterval,
+		minBackoff:    defaultRetryMinBackoff,
+		maxBackoff:    defaultRetryMaxBackoff,
+	}
+}
+
+// Run spools events from eventCh and delivers them until ctx is cancelled.
+// Events still buffered at shutdown are spooled and sent on the next start.
+func (f *Forwarder) Run(ctx context.Context, eventCh <-chan EnrichedEvent) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f.deliver(ctx)
+	}()
+
+	f.batch(ctx, eventCh)
+	wg.Wait()
+}
+
+// batch accumulates events and appends them to the spool by size or interval.
+func (f *Forwarder) batch(ctx context.Context, eventCh <-chan EnrichedEvent) {
+	var batch []EnrichedEvent
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.spoolBatch(batch)
+			return
+
+		case evt := <-eventCh:
+			batch = append(batch, evt)
+			if len(batch) >= f.batchSize {
+				f.spoolBatch(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			f.spoolBatch(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// spoolBatch marshals a batch and appends it to the spool.
+func (f *Forwarder) spoolBatch(events []EnrichedEvent) {
+	if len(events) == 0 {
+		return
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		log.Printf("Failed to marshal events: %v", err)
+		return
+	}
+	if err := f.spool.Append(data); err != nil {
+		log.Printf("Failed to spool %d events: %v", len(events), err)
+	}
+}
+
+// deliver sends spooled batches oldest first, acknowledging each on success.
+func (f *Forwarder) deliver(ctx context.Context) {
+	backoff := f.minBackoff
+	for {
+		entry, ok, err := f.spool.Next()
+		if err != nil {
+			log.Printf("Failed to read spooled batch: %v", err)
+			if !f.wait(ctx, backoff) {
+				return
+			}
+			continue
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-f.spool.Notify():
+			}
+			continue
+		}
+
+		start := time.Now()
+		err = f.send(ctx, entry.Data)
+		if ctx.Err() == nil {
+			f.recordAttempt(time.Since(start), err)
+		}
+		if err != nil {
+			if forwardFailureReason(err) != forwardFailureRejected {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Failed to send events to server (retrying in %v): %v", backoff, err)
+				if !f.wait(ctx, backoff) {
+					return
+				}
+				backoff = min(backoff*2, f.maxBackoff)
+				continue
+			}
+			log.Printf("Dropping spooled batch: %v", err)
+		}
+
+		if err := f.spool.Ack(entry); err != nil {
+			log.Printf("Failed to acknowledge spooled batch: %v", err)
+		}
+		backoff = f.minBackoff
+	}
+}
+
+// send posts one JSON batch. 4xx responses other than 408 and 429 are
+// permanent; everything else is worth retrying.
+func (f *Forwarder) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 400:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return &statusError{status: resp.StatusCode}
+	case resp.StatusCode < 500:
+		return &statusError{status: resp.StatusCode, permanent: true}
+	default:
+		return &statusError{status: resp.StatusCode}
+	}
+}
+
+// recordAttempt tracks server reachability and reports the attempt.
+func (f *Forwarder) recordAttempt(d time.Duration, err error) {
+	// A rejected batch still means the server answered
+	f.lastAttempt.Store(err == nil || forwardFailureReason(err) == forwardFailureRejected)
+	f.attempted.Store(true)
+	if f.observe != nil {
+		f.observe(d, err)
+	}
+}
+
+// ServerReachable reports whether the most recent delivery attempt reached the
+// server. It is true before the first attempt.
+func (f *Forwarder) ServerReachable() bool {
+	return !f.attempted.Load() || f.lastAttempt.Load()
+}
+
+// wait sleeps for d plus up to 20% jitter. Returns false if ctx was cancelled.
+func (f *Forwarder) wait(ctx context.Context, d time.Duration) bool {
+	if d > 0 {
+		d += time.Duration(rand.Int64N(int64(d)/5 + 1))
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
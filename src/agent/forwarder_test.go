@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestForwarder returns a Forwarder with fast batching and backoff.
+func newTestForwarder(t *testing.T, serverURL string) (*Forwarder, *Spool) {
+	t.Helper()
+	spool := mustOpenSpool(t, t.TempDir(), 1<<20, 0)
+	f := NewForwarder(serverURL, spool)
+	f.flushInterval = 10 * time.Millisecond
+	f.minBackoff = time.Millisecond
+	f.maxBackoff = 5 * time.Millisecond
+	return f, spool
+}
+
+// recordingServer fails the first `failures` requests with status, then
+// accepts and records batches.
+type recordingServer struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	attempts int
+	batches  [][]EnrichedEvent
+}
+
+func (rs *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.attempts++
+	if rs.attempts <= rs.failures {
+		w.WriteHeader(rs.status)
+		return
+	}
+	var batch []EnrichedEvent
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rs.batches = append(rs.batches, batch)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (rs *recordingServer) snapshot() (int, [][]EnrichedEvent) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.attempts, append([][]EnrichedEvent(nil), rs.batches...)
+}
+
+func TestForwarderRetriesUntilAccepted(t *testing.T) {
+	rs := &recordingServer{failures: 3, status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	f, spool := newTestForwarder(t, srv.URL)
+	defer spool.Close()
+
+	eventCh := make(chan EnrichedEvent, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx, eventCh)
+		close(done)
+	}()
+
+	eventCh <- EnrichedEvent{PID: 1, Comm: "kubelet", NodeName: "node-01"}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, batches := rs.snapshot(); len(batches) > 0 && spool.Pending() == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	attempts, batches := rs.snapshot()
+	if attempts != 4 {
+		t.Fatalf("attempts = %d, want 4 (3 failures + 1 success)", attempts)
+	}
+	if len(batches) != 1 || len(batches[0]) != 1 || batches[0][0].Comm != "kubelet" {
+		t.Fatalf("unexpected delivered batches: %+v", batches)
+	}
+	if spool.Pending() != 0 {
+		t.Fatalf("Pending() = %d after delivery, want 0", spool.Pending())
+	}
+}
+
+func TestForwarderDropsPermanentlyRejectedBatch(t *testing.T) {
+	rs := &recordingServer{failures: 1, status: http.StatusBadRequest}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	f, spool := newTestForwarder(t, srv.URL)
+	defer spool.Close()
+	spool.Append([]byte(`[{"pid":1,"comm":"bad"}]`))
+	spool.Append([]byte(`[{"pid":2,"comm":"good"}]`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.deliver(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for spool.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	attempts, batches := rs.snapshot()
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2 (400 must not be retried)", attempts)
+	}
+	if len(batches) != 1 || batches[0][0].Comm != "good" {
+		t.Fatalf("unexpected delivered batches: %+v", batches)
+	}
+}
+
+// TestForwarderReplaysSpoolAfterRestart verifies batches spooled while the
+// server was down are delivered by a new forwarder on the same directory.
+func TestForwarderReplaysSpoolAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	spool := mustOpenSpool(t, dir, 1<<20, 0)
+	f := NewForwarder("http://127.0.0.1:1", spool)
+	f.spoolBatch([]EnrichedEvent{{PID: 1, Comm: "containerd"}})
+	f.spoolBatch([]EnrichedEvent{{PID: 2, Comm: "kubelet"}})
+	spool.Close()
+
+	rs := &recordingServer{}
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	spool = mustOpenSpool(t, dir, 1<<20, 0)
+	defer spool.Close()
+	f = NewForwarder(srv.URL, spool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.deliver(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for spool.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	_, batches := rs.snapshot()
+	if len(batches) != 2 || batches[0][0].Comm != "containerd" || batches[1][0].Comm != "kubelet" {
+		t.Fatalf("unexpected replayed batches: %+v", batches)
+	}
+}
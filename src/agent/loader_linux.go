@@ -3,12 +3,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/ringbuf"
 	"golang.org/x/sys/unix"
 )
 
@@ -19,6 +24,7 @@ type BPFLoaderIface interface {
 	Load() error
 	Close() error
 	Programs() map[string]*ebpf.Program
+	OpenReader() (RecordReader, error)
 }
 
 // Compile-time check: *BPFLoader must satisfy BPFLoaderIface.
@@ -26,21 +32,32 @@ var _ BPFLoaderIface = (*BPFLoader)(nil)
 
 // BPFLoader manages the lifecycle of eBPF programs.
 type BPFLoader struct {
-	mu       sync.Mutex
-	programs map[string]*ebpf.Program
-	links    []link.Link
-	closed   bool
+	// ObjectDir is the directory holding the compiled src/ebpf/*.c objects.
+	ObjectDir string
+	// RingBufferSize overrides the size in bytes of the shared events ring
+	// buffer. Zero keeps the size declared in common.h.
+	RingBufferSize int
+
+	mu          sync.Mutex
+	programs    map[string]*ebpf.Program
+	links       []link.Link
+	collections []*ebpf.Collection
+	events      *ebpf.Map
+	closed      bool
 }
 
 // NewBPFLoader creates a new BPFLoader instance.
 func NewBPFLoader() *BPFLoader {
 	return &BPFLoader{
-		programs: make(map[string]*ebpf.Program),
+		ObjectDir: DefaultBPFObjectDir,
+		programs:  make(map[string]*ebpf.Program),
 	}
 }
 
-// Load compiles and loads all BPF programs from the generated objects.
+// Load loads every BPF object in ObjectDir, attaches all of its programs,
+// and wires them to a single shared events ring buffer.
 // Returns an error if kernel version < 5.8 or capabilities are missing.
+// On failure, anything already loaded is released.
 func (l *BPFLoader) Load() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -51,13 +68,130 @@ func (l *BPFLoader) Load() error {
 	if err := l.checkCapabilities(); err != nil {
 		return err
 	}
+	if l.RingBufferSize != 0 && !validRingBufferSize(l.RingBufferSize) {
+		return fmt.Errorf("ring buffer size %d must be a power of two multiple of the page size", l.RingBufferSize)
+	}
 
-	// Load programs from bpf2go-generated objects.
-	// Each generated loader provides a LoadXxx() function and an XxxObjects struct.
-	// In a real build these would be generated; here we show the pattern.
+	paths, err := filepath.Glob(filepath.Join(l.ObjectDir, "*.o"))
+	if err != nil {
+		return fmt.Errorf("list BPF objects: %w", err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no BPF objects found in %s", l.ObjectDir)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := l.loadObject(path); err != nil {
+			l.releaseLocked()
+			return err
+		}
+	}
+	if l.events == nil {
+		l.releaseLocked()
+		return fmt.Errorf("no BPF object in %s defines the %q map", l.ObjectDir, eventsMapName)
+	}
 	return nil
 }
 
+// loadObject loads one ELF object and attaches its programs. The first object
+// creates the events ring buffer; later objects reuse it via MapReplacements so
+// every program writes to the same buffer. Caller holds l.mu.
+func (l *BPFLoader) loadObject(path string) error {
+	spec, err := ebpf.LoadCollectionSpec(path)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", path, err)
+	}
+
+	var opts ebpf.CollectionOptions
+	if eventsSpec, ok := spec.Maps[eventsMapName]; ok {
+		if l.RingBufferSize != 0 {
+			eventsSpec.MaxEntries = uint32(l.RingBufferSize)
+		}
+		if l.events != nil {
+			opts.MapReplacements = map[string]*ebpf.Map{eventsMapName: l.events}
+		}
+	}
+
+	coll, err := ebpf.NewCollectionWithOptions(spec, opts)
+	if err != nil {
+		return fmt.Errorf("create collection from %s: %w", path, err)
+	}
+	l.collections = append(l.collections, coll)
+	if l.events == nil {
+		l.events = coll.DetachMap(eventsMapName)
+	}
+
+	object := strings.TrimSuffix(filepath.Base(path), ".o")
+	for name, progSpec := range spec.Programs {
+		prog := coll.DetachProgram(name)
+		l.programs[object+"/"+name] = prog
+
+		lnk, err := attachProgram(progSpec.SectionName, prog)
+		if err != nil {
+			return fmt.Errorf("attach %s/%s: %w", object, name, err)
+		}
+		l.links = append(l.links, lnk)
+	}
+	return nil
+}
+
+// attachProgram attaches prog at the hook named by its ELF section.
+func attachProgram(section string, prog *ebpf.Program) (link.Link, error) {
+	ap, err := parseAttachPoint(section)
+	if err != nil {
+		return nil, err
+	}
+	switch ap.Kind {
+	case attachTracepoint:
+		return link.Tracepoint(ap.Group, ap.Symbol, prog, nil)
+	case attachKprobe:
+		return link.Kprobe(ap.Symbol, prog, nil)
+	case attachKretprobe:
+		return link.Kretprobe(ap.Symbol, prog, nil)
+	case attachTPBTF:
+		return link.AttachTracing(link.TracingOptions{Program: prog})
+	}
+	return nil, fmt.Errorf("unsupported attach kind %q", ap.Kind)
+}
+
+// OpenReader opens a reader on the shared events ring buffer. Load must have
+// succeeded first.
+func (l *BPFLoader) OpenReader() (RecordReader, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.events == nil {
+		return nil, fmt.Errorf("events ring buffer not loaded")
+	}
+	rd, err := ringbuf.NewReader(l.events)
+	if err != nil {
+		return nil, fmt.Errorf("open ring buffer reader: %w", err)
+	}
+	return &ringbufReader{rd: rd}, nil
+}
+
+// ringbufReader adapts *ringbuf.Reader to RecordReader. The record buffer is
+// reused between reads.
+type ringbufReader struct {
+	rd  *ringbuf.Reader
+	rec ringbuf.Record
+}
+
+func (r *ringbufReader) Read() ([]byte, error) {
+	if err := r.rd.ReadInto(&r.rec); err != nil {
+		if errors.Is(err, ringbuf.ErrClosed) {
+			return nil, ErrReaderClosed
+		}
+		return nil, err
+	}
+	return r.rec.RawSample, nil
+}
+
+func (r *ringbufReader) Close() error {
+	return r.rd.Close()
+}
+
 // Close detaches all programs and closes map file descriptors.
 func (l *BPFLoader) Close() error {
 	l.mu.Lock()
@@ -67,6 +201,14 @@ func (l *BPFLoader) Close() error {
 		return nil
 	}
 
+	firstErr := l.releaseLocked()
+	l.closed = true
+	return firstErr
+}
+
+// releaseLocked detaches links and closes programs, collections, and the
+// events map, returning the first error. Caller holds l.mu.
+func (l *BPFLoader) releaseLocked() error {
 	var firstErr error
 	for _, lnk := range l.links {
 		if err := lnk.Close(); err != nil && firstErr == nil {
@@ -76,13 +218,25 @@ func (l *BPFLoader) Close() error {
 	l.links = nil
 
 	for name, prog := range l.programs {
-		if err := prog.Close(); err != nil && firstErr == nil {
-			firstErr = err
+		if prog != nil {
+			if err := prog.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
 		}
 		delete(l.programs, name)
 	}
 
-	l.closed = true
+	for _, coll := range l.collections {
+		coll.Close()
+	}
+	l.collections = nil
+
+	if l.events != nil {
+		if err := l.events.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		l.events = nil
+	}
 	return firstErr
 }
 
@@ -14,6 +14,7 @@ type BPFLoaderIface interface {
 	Load() error
 	Close() error
 	Programs() map[string]*BPFProgram
+	OpenReader() (RecordReader, error)
 }
 
 // Compile-time check: *BPFLoader must satisfy BPFLoaderIface.
@@ -45,6 +46,10 @@ func (p *BPFProgram) Close() error {
 // BPFLoader manages the lifecycle of eBPF programs.
 // On non-Linux platforms, it returns errors indicating eBPF is unsupported.
 type BPFLoader struct {
+	// ObjectDir and RingBufferSize mirror the Linux loader; they are unused here.
+	ObjectDir      string
+	RingBufferSize int
+
 	mu       sync.Mutex
 	programs map[string]*BPFProgram
 	closed   bool
@@ -53,7 +58,8 @@ type BPFLoader struct {
 // NewBPFLoader creates a new BPFLoader instance.
 func NewBPFLoader() *BPFLoader {
 	return &BPFLoader{
-		programs: make(map[string]*BPFProgram),
+		ObjectDir: DefaultBPFObjectDir,
+		programs:  make(map[string]*BPFProgram),
 	}
 }
 
@@ -62,6 +68,11 @@ func (l *BPFLoader) Load() error {
 	return fmt.Errorf("eBPF not supported on this platform")
 }
 
+// OpenReader returns an error on non-Linux platforms.
+func (l *BPFLoader) OpenReader() (RecordReader, error) {
+	return nil, fmt.Errorf("eBPF not supported on this platform")
+}
+
 // Close releases all resources. On non-Linux, clears the programs map.
 func (l *BPFLoader) Close() error {
 	l.mu.Lock()
@@ -1,15 +1,13 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"flag"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
@@ -23,6 +21,10 @@ func main() {
 	cgroupRefresh := flag.Duration("cgroup-refresh", 30*time.Second, "Cgroup-to-pod cache refresh interval")
 	nodeName := flag.String("node-name", "", "Node name (defaults to hostname)")
 	kubeletURL := flag.String("kubelet-url", "http://localhost:10255", "Kubelet API URL for cgroup resolution")
+	bpfDir := flag.String("bpf-dir", DefaultBPFObjectDir, "Directory containing compiled BPF objects (*.o)")
+	spoolDir := flag.String("spool-dir", DefaultSpoolDir, "Directory for the on-disk event spool")
+	metricsAddr := flag.String("metrics-addr", "", "Listen address for /metrics, /healthz and /readyz (empty = disabled)")
+	spoolSizeMB := flag.Int("spool-size-mb", DefaultSpoolMaxBytes/(1024*1024), "Maximum on-disk event spool size in MB")
 	flag.Parse()
 
 	if *nodeName == "" {
@@ -38,6 +40,8 @@ func main() {
 	log.Printf("  poll-interval:    %v", *pollInterval)
 	log.Printf("  ring-buffer-size: %d KB", *ringBufferSize)
 	log.Printf("  cgroup-refresh:   %v", *cgroupRefresh)
+	log.Printf("  bpf-dir:          %s", *bpfDir)
+	log.Printf("  spool-dir:        %s (max %d MB)", *spoolDir, *spoolSizeMB)
 
 	// Set up context with signal handling for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -51,16 +55,56 @@ func main() {
 	eventCh := make(chan EnrichedEvent, 1024)
 	pm := NewProbeManager(resolver, eventCh, *pollInterval)
 
+	spoolBytes := int64(*spoolSizeMB) * 1024 * 1024
+	spool, err := OpenSpool(*spoolDir, spoolBytes, 0)
+	if err != nil {
+		fallback := filepath.Join(os.TempDir(), "earthworm-spool")
+		log.Printf("Event spool at %s unavailable (%v); falling back to %s", *spoolDir, err, fallback)
+		if spool, err = OpenSpool(fallback, spoolBytes, 0); err != nil {
+			log.Fatalf("failed to open event spool: %v", err)
+		}
+	}
+	forwarder := NewForwarder(*serverURL, spool)
+
 	loader := NewBPFLoader()
+	loader.ObjectDir = *bpfDir
+	loader.RingBufferSize = *ringBufferSize * 1024
 	if err := loader.Load(); err != nil {
 		log.Printf("BPF loader failed (continuing without eBPF): %v", err)
+	} else if reader, err := loader.OpenReader(); err != nil {
+		log.Printf("Ring buffer reader failed (continuing without eBPF): %v", err)
 	} else {
-		log.Println("BPF programs loaded successfully")
+		pm.SetReader(reader)
+		log.Printf("BPF programs loaded successfully (%d programs)", len(loader.Programs()))
 	}
 
 	// Start background goroutines
 	var wg sync.WaitGroup
 
+	// Optional metrics and health listener
+	if *metricsAddr != "" {
+		telemetry := newAgentTelemetry(pm, resolver, spool, forwarder, func() []string {
+			names := make([]string, 0)
+			for name := range loader.Programs() {
+				names = append(names, name)
+			}
+			return names
+		})
+		srv := &http.Server{Addr: *metricsAddr, Handler: telemetry.Handler()}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			srv.Close()
+		}()
+		go func() {
+			log.Printf("Metrics and health endpoints listening on %s", *metricsAddr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Metrics listener error: %v", err)
+			}
+		}()
+	}
+
 	// Cgroup resolver refresh loop
 	wg.Add(1)
 	go func() {
@@ -79,11 +123,11 @@ func main() {
 		}
 	}()
 
-	// Event forwarder — batches events and sends to server via HTTP POST
+	// Event forwarder — spools batches to disk and sends them to the server via HTTP POST
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		forwardEvents(ctx, *serverURL, eventCh)
+		forwarder.Run(ctx, eventCh)
 	}()
 
 	// Wait for shutdown signal
@@ -109,6 +153,10 @@ func main() {
 		log.Println("Shutdown timeout exceeded, forcing exit")
 	}
 
+	if err := spool.Close(); err != nil {
+		log.Printf("Spool close error: %v", err)
+	}
+
 	// Clean up BPF resources
 	if err := loader.Close(); err != nil {
 		log.Printf("BPF loader cleanup error: %v", err)
@@ -116,60 +164,3 @@ func main() {
 
 	log.Println("Earthworm agent stopped")
 }
-
-// forwardEvents batches enriched events and sends them to the server.
-func forwardEvents(ctx context.Context, serverURL string, eventCh <-chan EnrichedEvent) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	batchSize := 100
-	flushInterval := 1 * time.Second
-
-	var batch []EnrichedEvent
-	ticker := time.NewTicker(flushInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			// Flush remaining events
-			if len(batch) > 0 {
-				sendBatch(client, serverURL, batch)
-			}
-			return
-
-		case evt := <-eventCh:
-			batch = append(batch, evt)
-			if len(batch) >= batchSize {
-				sendBatch(client, serverURL, batch)
-				batch = batch[:0]
-			}
-
-		case <-ticker.C:
-			if len(batch) > 0 {
-				sendBatch(client, serverURL, batch)
-				batch = batch[:0]
-			}
-		}
-	}
-}
-
-// sendBatch sends a batch of events to the server via HTTP POST.
-func sendBatch(client *http.Client, serverURL string, events []EnrichedEvent) {
-	url := fmt.Sprintf("%s/api/ebpf/events", serverURL)
-
-	data, err := json.Marshal(events)
-	if err != nil {
-		log.Printf("Failed to marshal events: %v", err)
-		return
-	}
-
-	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
-	if err != nil {
-		log.Printf("Failed to send events to server: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		log.Printf("Server rejected events (status %d)", resp.StatusCode)
-	}
-}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"earthworm/src/metrics"
+)
+
+// agentTelemetry serves the agent's Prometheus metrics and health endpoints.
+// Values owned by other components are copied into the registry on scrape.
+type agentTelemetry struct {
+	registry  *metrics.Registry
+	pm        *ProbeManager
+	resolver  *CgroupResolver
+	spool     *Spool
+	forwarder *Forwarder
+	programs  func() []string // names of loaded BPF programs
+
+	eventsDecoded      *metrics.CounterVec
+	decodeErrors       *metrics.CounterVec
+	eventsDropped      *metrics.CounterVec
+	forwardBatch       *metrics.HistogramVec
+	forwardFailures    *metrics.CounterVec
+	spoolPending       *metrics.GaugeVec
+	spoolBytes         *metrics.GaugeVec
+	spoolEvicted       *metrics.CounterVec
+	cgroupCacheEntries *metrics.GaugeVec
+	cgroupRefreshAge   *metrics.GaugeVec
+	programLoaded      *metrics.GaugeVec
+	serverReachable    *metrics.GaugeVec
+}
+
+// newAgentTelemetry registers the agent metrics and hooks the forwarder's
+// delivery attempts into the batch latency histogram.
+func newAgentTelemetry(pm *ProbeManager, resolver *CgroupResolver, spool *Spool, forwarder *Forwarder, programs func() []string) *agentTelemetry {
+	r := metrics.NewRegistry()
+	t := &agentTelemetry{
+		registry:  r,
+		pm:        pm,
+		resolver:  resolver,
+		spool:     spool,
+		forwarder: forwarder,
+		programs:  programs,
+
+		eventsDecoded: r.NewCounterVec("earthworm_agent_events_decoded_total",
+			"Ring buffer records decoded, by event type.", "event_type"),
+		decodeErrors: r.NewCounterVec("earthworm_agent_decode_errors_total",
+			"Ring buffer records that failed to decode or enrich."),
+		eventsDropped: r.NewCounterVec("earthworm_agent_events_dropped_total",
+			"Decoded events dropped because the forward queue was full."),
+		forwardBatch: r.NewHistogramVec("earthworm_agent_forward_batch_duration_seconds",
+			"Latency of batch deliveries to the server.", nil),
+		forwardFailures: r.NewCounterVec("earthworm_agent_forward_failures_total",
+			"Failed batch deliveries, by reason (network, server_error, rejected).", "reason"),
+		spoolPending: r.NewGaugeVec("earthworm_agent_spool_pending_batches",
+			"Batches in the on-disk spool awaiting delivery."),
+		spoolBytes: r.NewGaugeVec("earthworm_agent_spool_bytes",
+			"On-disk size of the event spool."),
+		spoolEvicted: r.NewCounterVec("earthworm_agent_spool_evicted_batches_total",
+			"Undelivered batches discarded because the spool was full."),
+		cgroupCacheEntries: r.NewGaugeVec("earthworm_agent_cgroup_cache_entries",
+			"Entries in the cgroup-to-pod cache."),
+		cgroupRefreshAge: r.NewGaugeVec("earthworm_agent_cgroup_cache_refresh_age_seconds",
+			"Seconds since the cgroup cache was last refreshed successfully."),
+		programLoaded: r.NewGaugeVec("earthworm_agent_bpf_program_loaded",
+			"1 for every BPF program loaded and attached.", "program"),
+		serverReachable: r.NewGaugeVec("earthworm_agent_server_reachable",
+			"1 if the most recent delivery attempt reached the server."),
+	}
+	if forwarder != nil {
+		forwarder.observe = t.observeForward
+	}
+	r.OnCollect(t.collect)
+	return t
+}
+
+// observeForward records one delivery attempt.
+func (t *agentTelemetry) observeForward(d time.Duration, err error) {
+	t.forwardBatch.Observe(d.Seconds())
+	if err != nil {
+		t.forwardFailures.Inc(forwardFailureReason(err))
+	}
+}
+
+func (t *agentTelemetry) collect() {
+	if t.pm != nil {
+		for eventType, n := range t.pm.DecodedEvents() {
+			t.eventsDecoded.Set(float64(n), eventType)
+		}
+		t.decodeErrors.Set(float64(t.pm.DecodeErrors()))
+		t.eventsDropped.Set(float64(t.pm.DroppedEvents()))
+	}
+	if t.spool != nil {
+		t.spoolPending.Set(float64(t.spool.Pending()))
+		t.spoolBytes.Set(float64(t.spool.Size()))
+		t.spoolEvicted.Set(float64(t.spool.EvictedBatches()))
+	}
+	if t.resolver != nil {
+		t.cgroupCacheEntries.Set(float64(t.resolver.CacheSize()))
+		if last := t.resolver.LastRefresh(); !last.IsZero() {
+			t.cgroupRefreshAge.Set(time.Since(last).Seconds())
+		}
+	}
+	for _, name := range t.loadedPrograms() {
+		t.programLoaded.Set(1, name)
+	}
+	if t.forwarder != nil {
+		reachable := 0.0
+		if t.forwarder.ServerReachable() {
+			reachable = 1
+		}
+		t.serverReachable.Set(reachable)
+	}
+}
+
+func (t *agentTelemetry) loadedPrograms() []string {
+	if t.programs == nil {
+		return nil
+	}
+	return t.programs()
+}
+
+// healthStatus is the JSON body of /healthz and /readyz.
+type healthStatus struct {
+	Status string          `json:"status"` // "ok" or "unavailable"
+	Checks map[string]bool `json:"checks"`
+}
+
+// Handler serves /metrics, /healthz (the process is serving) and /readyz
+// (BPF programs attached and the server reachable). Liveness does not
+// require BPF: the agent keeps running without it where loading fails, and
+// restarting it would not help.
+func (t *agentTelemetry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", t.registry.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, map[string]bool{})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, map[string]bool{
+			"bpfAttached":     len(t.loadedPrograms()) > 0,
+			"serverReachable": t.forwarder != nil && t.forwarder.ServerReachable(),
+		})
+	})
+	return mux
+}
+
+// writeHealth responds 200 when every check passes and 503 otherwise.
+func writeHealth(w http.ResponseWriter, checks map[string]bool) {
+	status := healthStatus{Status: "ok", Checks: checks}
+	code := http.StatusOK
+	for _, ok := range checks {
+		if !ok {
+			status.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
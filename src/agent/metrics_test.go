@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTelemetry wires telemetry around a ProbeManager that has processed
+// one syscall event and one undecodable record.
+func newTestTelemetry(t *testing.T, programs []string) (*agentTelemetry, *Forwarder) {
+	t.Helper()
+	resolver := NewCgroupResolver("node-01", "", 0)
+	resolver.UpdateCache(42, PodIdentity{PodName: "api-0", Namespace: "prod", NodeName: "node-01"})
+	resolver.markRefreshed()
+
+	pm := NewProbeManager(resolver, make(chan EnrichedEvent, 10), 0)
+	var comm [TaskCommLen]byte
+	copy(comm[:], "kubelet")
+	pm.ProcessRawEvent(mustMarshal(t, &KernelEvent{
+		Timestamp: 1_000, PID: 10, PPID: 1, TGID: 10, CgroupID: 42, Comm: comm,
+		EventType: EventTypeSyscall, SyscallNr: 1, EntryTs: 100, ExitTs: 200,
+	}))
+	if err := pm.ProcessRawEvent([]byte("truncated")); err == nil {
+		t.Fatal("expected decode error for truncated record")
+	} else {
+		pm.recordDecodeError(err)
+	}
+
+	spool := mustOpenSpool(t, t.TempDir(), 1<<20, 0)
+	t.Cleanup(func() { spool.Close() })
+	spool.Append([]byte(`[]`))
+
+	f := NewForwarder("http://127.0.0.1:1", spool)
+	tel := newAgentTelemetry(pm, resolver, spool, f, func() []string { return programs })
+	return tel, f
+}
+
+func serveGet(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestAgentMetricsEndpoint(t *testing.T) {
+	tel, f := newTestTelemetry(t, []string{"trace_sys_enter"})
+	f.recordAttempt(20*time.Millisecond, nil)
+	f.recordAttempt(5*time.Millisecond, errors.New("connection refused"))
+	f.recordAttempt(5*time.Millisecond, &statusError{status: http.StatusBadRequest, permanent: true})
+
+	code, body := serveGet(t, tel.Handler(), "/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", code)
+	}
+	for _, want := range []string{
+		`earthworm_agent_events_decoded_total{event_type="syscall"} 1`,
+		"earthworm_agent_decode_errors_total 1",
+		"earthworm_agent_events_dropped_total 0",
+		"earthworm_agent_forward_batch_duration_seconds_count 3",
+		`earthworm_agent_forward_failures_total{reason="network"} 1`,
+		`earthworm_agent_forward_failures_total{reason="rejected"} 1`,
+		"earthworm_agent_spool_pending_batches 1",
+		"earthworm_agent_cgroup_cache_entries 1",
+		"earthworm_agent_cgroup_cache_refresh_age_seconds ",
+		`earthworm_agent_bpf_program_loaded{program="trace_sys_enter"} 1`,
+		"earthworm_agent_server_reachable 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics missing %q\n%s", want, body)
+		}
+	}
+}
+
+func TestAgentHealthEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		programs   []string
+		sendErr    error
+		wantHealth int
+		wantReady  int
+	}{
+		{"attached and reachable", []string{"trace_sys_enter"}, nil, http.StatusOK, http.StatusOK},
+		{"server unreachable", []string{"trace_sys_enter"}, errors.New("connection refused"), http.StatusOK, http.StatusServiceUnavailable},
+		{"server error", []string{"trace_sys_enter"}, &statusError{status: http.StatusBadGateway}, http.StatusOK, http.StatusServiceUnavailable},
+		{"no programs attached", nil, nil, http.StatusOK, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel, f := newTestTelemetry(t, tt.programs)
+			f.recordAttempt(time.Millisecond, tt.sendErr)
+
+			code, body := serveGet(t, tel.Handler(), "/healthz")
+			if code != tt.wantHealth {
+				t.Errorf("/healthz = %d, want %d (%s)", code, tt.wantHealth, body)
+			}
+			code, body = serveGet(t, tel.Handler(), "/readyz")
+			if code != tt.wantReady {
+				t.Errorf("/readyz = %d, want %d (%s)", code, tt.wantReady, body)
+			}
+			var status healthStatus
+			if err := json.Unmarshal([]byte(body), &status); err != nil {
+				t.Fatalf("decode /readyz body: %v", err)
+			}
+			if want := tt.wantReady == http.StatusOK; (status.Status == "ok") != want {
+				t.Errorf("/readyz status = %q", status.Status)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -9,15 +10,35 @@ import (
 	"time"
 )
 
+// ErrReaderClosed is returned by RecordReader.Read once the reader is closed.
+var ErrReaderClosed = errors.New("record reader closed")
+
+// maxReadBackoff caps the wait between retries of a failing ring buffer read.
+const maxReadBackoff = 5 * time.Second
+
+// RecordReader yields raw records from the BPF events ring buffer.
+// Read blocks until a record is available and returns ErrReaderClosed after
+// Close. The returned slice is only valid until the next call to Read.
+type RecordReader interface {
+	Read() ([]byte, error)
+	Close() error
+}
+
 // ProbeManager reads events from BPF ring buffers and forwards them
 // to the CgroupResolver for enrichment.
 type ProbeManager struct {
-	resolver      *CgroupResolver
-	eventCh       chan<- EnrichedEvent
-	pollInterval  time.Duration
-	droppedCnt    atomic.Uint64
-	lastDropLog   time.Time
-	lastDropLogMu sync.Mutex
+	resolver        *CgroupResolver
+	eventCh         chan<- EnrichedEvent
+	pollInterval    time.Duration
+	reader          RecordReader
+	droppedCnt      atomic.Uint64
+	decodeErrCnt    atomic.Uint64
+	lastDropLog     time.Time
+	lastDropLogMu   sync.Mutex
+	lastDecodeLog   time.Time
+	lastDecodeLogMu sync.Mutex
+	decodedMu       sync.Mutex
+	decoded         map[string]uint64 // decoded events by EnrichedEvent.EventType
 }
 
 // NewProbeManager creates a new ProbeManager.
@@ -29,13 +50,78 @@ func NewProbeManager(resolver *CgroupResolver, eventCh chan<- EnrichedEvent, pol
 		resolver:     resolver,
 		eventCh:      eventCh,
 		pollInterval: pollInterval,
+		decoded:      make(map[string]uint64),
 	}
 }
 
-// Start begins polling the ring buffer. Blocks until ctx is cancelled.
-// On non-Linux platforms, this simulates the polling loop without actual BPF.
+// SetReader sets the ring buffer reader consumed by Start. Must be called
+// before Start; the ProbeManager takes ownership and closes it on shutdown.
+func (pm *ProbeManager) SetReader(r RecordReader) {
+	pm.reader = r
+}
+
+// Start consumes records from the ring buffer reader and feeds each one into
+// ProcessRawEvent. Blocks until ctx is cancelled or the reader is closed.
+// Without a reader (eBPF unavailable), it idles until ctx is cancelled.
 func (pm *ProbeManager) Start(ctx context.Context) error {
-	log.Printf("ProbeManager: starting with poll interval %v", pm.pollInterval)
+	if pm.reader == nil {
+		return pm.idle(ctx)
+	}
+	log.Println("ProbeManager: consuming ring buffer events")
+
+	// Closing the reader is the only way to unblock a pending Read
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		pm.reader.Close()
+	}()
+
+	// Consecutive read failures back off from the poll interval, doubling
+	// up to maxReadBackoff, so a persistent error neither spins nor floods
+	// the log
+	failures := 0
+	for {
+		data, err := pm.reader.Read()
+		if err != nil {
+			if errors.Is(err, ErrReaderClosed) || ctx.Err() != nil {
+				log.Println("ProbeManager: shutting down")
+				return ctx.Err()
+			}
+			failures++
+			backoff := readBackoff(pm.pollInterval, failures)
+			log.Printf("ProbeManager: ring buffer read failed (%d in a row), retrying in %v: %v", failures, backoff, err)
+			select {
+			case <-ctx.Done():
+				log.Println("ProbeManager: shutting down")
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		failures = 0
+		if err := pm.ProcessRawEvent(data); err != nil {
+			pm.recordDecodeError(err)
+		}
+	}
+}
+
+// readBackoff returns the wait before retrying after the given number of
+// consecutive read failures.
+func readBackoff(base time.Duration, failures int) time.Duration {
+	backoff := base
+	for i := 1; i < failures && backoff < maxReadBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxReadBackoff)
+}
+
+// idle waits for ctx cancellation when no ring buffer reader is attached.
+func (pm *ProbeManager) idle(ctx context.Context) error {
+	log.Printf("ProbeManager: no ring buffer reader attached, idling (poll interval %v)", pm.pollInterval)
 
 	ticker := time.NewTicker(pm.pollInterval)
 	defer ticker.Stop()
@@ -46,8 +132,6 @@ func (pm *ProbeManager) Start(ctx context.Context) error {
 			log.Println("ProbeManager: shutting down")
 			return ctx.Err()
 		case <-ticker.C:
-			// On Linux, this would read from ringbuf.Reader.
-			// The actual ring buffer reading is platform-specific.
 		}
 	}
 }
@@ -89,6 +173,7 @@ func (pm *ProbeManager) processExtendedEvent(data []byte) error {
 	if err != nil {
 		return fmt.Errorf("enrich extended event: %w", err)
 	}
+	pm.recordDecoded(enriched.EventType)
 
 	select {
 	case pm.eventCh <- enriched:
@@ -102,6 +187,7 @@ func (pm *ProbeManager) processExtendedEvent(data []byte) error {
 // ProcessEvent enriches a decoded KernelEvent and forwards it to the event channel.
 func (pm *ProbeManager) ProcessEvent(evt *KernelEvent) error {
 	enriched := pm.resolver.Enrich(evt)
+	pm.recordDecoded(enriched.EventType)
 
 	select {
 	case pm.eventCh <- enriched:
@@ -117,6 +203,44 @@ func (pm *ProbeManager) DroppedEvents() uint64 {
 	return pm.droppedCnt.Load()
 }
 
+// DecodedEvents returns the number of events decoded so far, by event type.
+func (pm *ProbeManager) DecodedEvents() map[string]uint64 {
+	pm.decodedMu.Lock()
+	defer pm.decodedMu.Unlock()
+	out := make(map[string]uint64, len(pm.decoded))
+	for k, v := range pm.decoded {
+		out[k] = v
+	}
+	return out
+}
+
+func (pm *ProbeManager) recordDecoded(eventType string) {
+	pm.decodedMu.Lock()
+	pm.decoded[eventType]++
+	pm.decodedMu.Unlock()
+}
+
+// DecodeErrors returns the count of ring buffer records that failed to decode
+// or enrich.
+func (pm *ProbeManager) DecodeErrors() uint64 {
+	return pm.decodeErrCnt.Load()
+}
+
+// recordDecodeError increments the decode error counter and logs at most once
+// per 10 seconds.
+func (pm *ProbeManager) recordDecodeError(err error) {
+	pm.decodeErrCnt.Add(1)
+
+	pm.lastDecodeLogMu.Lock()
+	defer pm.lastDecodeLogMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(pm.lastDecodeLog) >= 10*time.Second {
+		pm.lastDecodeLog = now
+		log.Printf("ProbeManager: failed to process record: %v (total: %d)", err, pm.decodeErrCnt.Load())
+	}
+}
+
 // recordDrop increments the drop counter and logs at most once per 10 seconds.
 func (pm *ProbeManager) recordDrop() {
 	pm.droppedCnt.Add(1)
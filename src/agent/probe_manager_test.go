@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"pgregory.net/rapid"
 )
@@ -153,3 +158,183 @@ func TestConditionalFlagCorrectness(t *testing.T) {
 		}
 	})
 }
+
+// fakeRecordReader replays a fixed set of records, then blocks until closed,
+// mimicking a ring buffer that has been drained.
+type fakeRecordReader struct {
+	mu      sync.Mutex
+	records [][]byte
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeRecordReader(records ...[]byte) *fakeRecordReader {
+	return &fakeRecordReader{records: records, closed: make(chan struct{})}
+}
+
+func (r *fakeRecordReader) Read() ([]byte, error) {
+	r.mu.Lock()
+	if len(r.records) > 0 {
+		rec := r.records[0]
+		r.records = r.records[1:]
+		r.mu.Unlock()
+		return rec, nil
+	}
+	r.mu.Unlock()
+
+	<-r.closed
+	return nil, ErrReaderClosed
+}
+
+func (r *fakeRecordReader) Close() error {
+	r.once.Do(func() { close(r.closed) })
+	return nil
+}
+
+func mustMarshal(t *testing.T, m interface{ MarshalBinary() ([]byte, error) }) []byte {
+	t.Helper()
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
+
+// TestProbeManagerConsumesReader replays raw ring buffer records through
+// Start and verifies each is decoded, enriched, and forwarded in order.
+func TestProbeManagerConsumesReader(t *testing.T) {
+	resolver := NewCgroupResolver("node-01", "", 0)
+	resolver.UpdateCache(42, PodIdentity{PodName: "api-0", Namespace: "prod", ContainerName: "api", NodeName: "node-01"})
+
+	var comm [TaskCommLen]byte
+	copy(comm[:], "kubelet")
+	syscallEvt := &KernelEvent{
+		Timestamp: 1_000, PID: 10, PPID: 1, TGID: 10, CgroupID: 42, Comm: comm,
+		EventType: EventTypeSyscall, SyscallNr: 1, EntryTs: 100, ExitTs: 2_000_000_100, SlowSyscall: 1,
+	}
+	var vfs VFSPayload
+	copy(vfs.FilePath[:], "/var/lib/kubelet/config.yaml")
+	vfs.LatencyNs = 250_000_000
+	vfs.SlowIO = 1
+	vfsPayload := mustMarshal(t, &vfs)
+	vfsEvt := &ExtendedEvent{
+		Timestamp: 2_000, PID: 11, PPID: 1, TGID: 11, CgroupID: 7, Comm: comm,
+		EventType: EventTypeVFS, PayloadLen: uint16(len(vfsPayload)), Payload: vfsPayload,
+	}
+
+	reader := newFakeRecordReader(
+		mustMarshal(t, syscallEvt),
+		[]byte("truncated"),
+		mustMarshal(t, vfsEvt),
+	)
+	eventCh := make(chan EnrichedEvent, 10)
+	pm := NewProbeManager(resolver, eventCh, 0)
+	pm.SetReader(reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pm.Start(ctx) }()
+
+	var got []EnrichedEvent
+	for len(got) < 2 {
+		select {
+		case evt := <-eventCh:
+			got = append(got, evt)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %d", len(got))
+		}
+	}
+
+	if got[0].EventType != "syscall" || got[0].PodName != "api-0" || got[0].Namespace != "prod" || !got[0].SlowSyscall {
+		t.Errorf("unexpected syscall event: %+v", got[0])
+	}
+	if got[1].EventType != "filesystem_io" || !got[1].HostLevel || got[1].NodeName != "node-01" ||
+		got[1].FilePath != "/var/lib/kubelet/config.yaml" || !got[1].SlowIO {
+		t.Errorf("unexpected vfs event: %+v", got[1])
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Start returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+
+	if pm.DecodeErrors() != 1 {
+		t.Errorf("DecodeErrors() = %d, want 1", pm.DecodeErrors())
+	}
+	if pm.DroppedEvents() != 0 {
+		t.Errorf("DroppedEvents() = %d, want 0", pm.DroppedEvents())
+	}
+}
+
+// TestProbeManagerReaderClosed verifies Start returns when the reader is
+// closed out from under it.
+func TestProbeManagerReaderClosed(t *testing.T) {
+	reader := newFakeRecordReader()
+	pm := NewProbeManager(NewCgroupResolver("node-01", "", 0), make(chan EnrichedEvent, 1), 0)
+	pm.SetReader(reader)
+
+	done := make(chan error, 1)
+	go func() { done <- pm.Start(context.Background()) }()
+	reader.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start returned %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after reader closed")
+	}
+}
+
+// failingRecordReader fails every Read until closed, counting the calls.
+type failingRecordReader struct {
+	reads  atomic.Int64
+	closed atomic.Bool
+}
+
+func (r *failingRecordReader) Read() ([]byte, error) {
+	r.reads.Add(1)
+	if r.closed.Load() {
+		return nil, ErrReaderClosed
+	}
+	return nil, errors.New("ring buffer corrupted")
+}
+
+func (r *failingRecordReader) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+// TestProbeManagerReadErrorBackoff verifies a persistent read error is
+// retried with backoff rather than in a tight loop.
+func TestProbeManagerReadErrorBackoff(t *testing.T) {
+	reader := &failingRecordReader{}
+	pm := NewProbeManager(NewCgroupResolver("node-01", "", 0), make(chan EnrichedEvent, 1), 20*time.Millisecond)
+	pm.SetReader(reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := pm.Start(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Start returned %v, want context.DeadlineExceeded", err)
+	}
+	// Waits of 20, 40 and 80ms fit in 200ms, then 160ms is cut short
+	if n := reader.reads.Load(); n < 3 || n > 5 {
+		t.Errorf("%d reads in 200ms, want 3 to 5", n)
+	}
+}
+
+func TestReadBackoff(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 6: 3200 * time.Millisecond, 7: maxReadBackoff, 100: maxReadBackoff,
+	} {
+		if got := readBackoff(100*time.Millisecond, failures); got != want {
+			t.Errorf("readBackoff(100ms, %d) = %v, want %v", failures, got, want)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Spool defaults.
+const (
+	DefaultSpoolDir          = "/var/lib/earthworm/spool"
+	DefaultSpoolMaxBytes     = 64 * 1024 * 1024
+	DefaultSpoolSegmentBytes = 4 * 1024 * 1024
+)
+
+const (
+	spoolSegmentSuffix = ".seg"
+	spoolAckFile       = "ack"
+	spoolRecordHeader  = 8 // u32 payload length + u32 CRC-32 of the payload
+)
+
+// errTornRecord marks a record that was only partially written or corrupted,
+// typically by a crash mid-append. Recovery truncates the segment there.
+var errTornRecord = errors.New("torn spool record")
+
+// SpoolEntry is one spooled batch. Pass it back to Ack once delivered.
+type SpoolEntry struct {
+	Data []byte
+
+	seq  uint64
+	next int64
+}
+
+// spoolSegment tracks one on-disk segment file.
+type spoolSegment struct {
+	seq     uint64
+	size    int64
+	records int
+}
+
+// Spool is a bounded, segment-based write-ahead log for event batches.
+//
+// Batches are appended to the newest segment and read back oldest first.
+// The read cursor only advances on Ack and is persisted in the ack file, so
+// unacknowledged batches are replayed after a restart. Fully acknowledged
+// segments are deleted. When the spool exceeds its size bound, the oldest
+// segments are evicted even if unacknowledged.
+type Spool struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	mu       sync.Mutex
+	segments []*spoolSegment // oldest first; the last one is open for writing
+	active   *os.File
+	readSeq  uint64 // cursor: segment and byte offset of the next unacked record
+	readOff  int64
+	readIdx  int // records already acked in the cursor segment
+	evicted  uint64
+	closed   bool
+	notifyCh chan struct{}
+}
+
+// OpenSpool opens (or creates) a spool in dir, recovering any segments and
+// acknowledgement state left by a previous run. maxBytes bounds the total
+// on-disk size; segmentBytes is the rollover size of a single segment.
+func OpenSpool(dir string, maxBytes, segmentBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultSpoolMaxBytes
+	}
+	if segmentBytes <= 0 {
+		segmentBytes = DefaultSpoolSegmentBytes
+	}
+	// Keep at least two segments within the bound so eviction has something
+	// other than the active segment to drop.
+	if segmentBytes > maxBytes/2 {
+		segmentBytes = maxBytes / 2
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+
+	s := &Spool{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: segmentBytes,
+		notifyCh:     make(chan struct{}, 1),
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	if s.Pending() > 0 {
+		log.Printf("Spool: replaying %d unacknowledged batches from %s", s.Pending(), dir)
+		s.signal()
+	}
+	return s, nil
+}
+
+// recover scans existing segments, truncates torn tails, restores the read
+// cursor from the ack file, and opens the newest segment for appending.
+func (s *Spool) recover() error {
+	seqs, err := s.listSegments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		seg, err := s.scanSegment(seq)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	ackSeq, ackOff := s.readAck()
+	switch {
+	case len(s.segments) == 0:
+	case ackSeq < s.segments[0].seq:
+		// Ack predates all segments (or is missing): replay everything
+		s.readSeq, s.readOff, s.readIdx = s.segments[0].seq, 0, 0
+	default:
+		s.readSeq, s.readOff, s.readIdx = ackSeq, ackOff, 0
+		if seg := s.segment(ackSeq); seg != nil {
+			if s.readOff > seg.size {
+				s.readOff = seg.size
+			}
+			idx, err := s.countRecords(ackSeq, s.readOff)
+			if err != nil {
+				return err
+			}
+			s.readIdx = idx
+		} else {
+			// The acked segment is gone; resume at the first later one, or
+			// replay everything if the ack is ahead of all segments
+			s.readSeq, s.readOff = s.segments[0].seq, 0
+			if later := s.segmentAfter(ackSeq); later != nil {
+				s.readSeq = later.seq
+			}
+		}
+		s.dropAckedSegments()
+	}
+
+	next := uint64(1)
+	if n := len(s.segments); n > 0 {
+		next = s.segments[n-1].seq
+	}
+	if len(s.segments) == 0 {
+		s.segments = append(s.segments, &spoolSegment{seq: next})
+		if s.readSeq < next {
+			s.readSeq, s.readOff, s.readIdx = next, 0, 0
+		}
+	}
+	f, err := os.OpenFile(s.segmentPath(next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+	s.active = f
+	return nil
+}
+
+// Append durably writes a batch to the spool. The oldest segments are evicted
+// if the spool grows beyond its size bound.
+func (s *Spool) Append(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("spool closed")
+	}
+
+	recLen := int64(spoolRecordHeader + len(data))
+	tail := s.segments[len(s.segments)-1]
+	if tail.size > 0 && tail.size+recLen > s.segmentBytes {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+		tail = s.segments[len(s.segments)-1]
+	}
+
+	buf := make([]byte, recLen)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[spoolRecordHeader:], data)
+	if _, err := s.active.Write(buf); err != nil {
+		return fmt.Errorf("write spool record: %w", err)
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("sync spool segment: %w", err)
+	}
+	tail.size += recLen
+	tail.records++
+
+	s.evictLocked()
+	s.signal()
+	return nil
+}
+
+// Next returns the oldest unacknowledged batch without removing it.
+// ok is false when the spool is drained.
+func (s *Spool) Next() (entry SpoolEntry, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		seg := s.segment(s.readSeq)
+		if seg == nil {
+			return SpoolEntry{}, false, nil
+		}
+		if s.readOff < seg.size {
+			data, next, err := s.readRecord(seg.seq, s.readOff)
+			if err != nil {
+				return SpoolEntry{}, false, err
+			}
+			return SpoolEntry{Data: data, seq: seg.seq, next: next}, true, nil
+		}
+		later := s.segmentAfter(seg.seq)
+		if later == nil {
+			return SpoolEntry{}, false, nil
+		}
+		s.readSeq, s.readOff, s.readIdx = later.seq, 0, 0
+		s.dropAckedSegments()
+	}
+}
+
+// Ack marks entry as delivered, advancing and persisting the read cursor and
+// deleting segments that are fully acknowledged. Acking an entry that is no
+// longer at the cursor (e.g. it was evicted) is a no-op.
+func (s *Spool) Ack(entry SpoolEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.seq != s.readSeq || entry.next <= s.readOff {
+		return nil
+	}
+	s.readOff = entry.next
+	s.readIdx++
+	if err := s.writeAck(); err != nil {
+		return err
+	}
+	s.dropAckedSegments()
+	return nil
+}
+
+// Pending returns the number of unacknowledged batches.
+func (s *Spool) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, seg := range s.segments {
+		switch {
+		case seg.seq == s.readSeq:
+			n += seg.records - s.readIdx
+		case seg.seq > s.readSeq:
+			n += seg.records
+		}
+	}
+	return n
+}
+
+// Size returns the total on-disk size of all segments in bytes.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totalSizeLocked()
+}
+
+// EvictedBatches returns the number of unacknowledged batches discarded
+// because the spool hit its size bound.
+func (s *Spool) EvictedBatches() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evicted
+}
+
+// Notify returns a channel that receives a value whenever a batch is appended.
+func (s *Spool) Notify() <-chan struct{} {
+	return s.notifyCh
+}
+
+// Close closes the active segment. Spooled data stays on disk for replay.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.active.Close()
+}
+
+func (s *Spool) signal() {
+	select {
+	case s.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// rotateLocked closes the active segment and starts a new one. Caller holds s.mu.
+func (s *Spool) rotateLocked() error {
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("close spool segment: %w", err)
+	}
+	seq := s.segments[len(s.segments)-1].seq + 1
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+	s.active = f
+	s.segments = append(s.segments, &spoolSegment{seq: seq})
+	return nil
+}
+
+// evictLocked deletes the oldest non-active segments until the spool fits
+// within maxBytes. Caller holds s.mu.
+func (s *Spool) evictLocked() {
+	for len(s.segments) > 1 && s.totalSizeLocked() > s.maxBytes {
+		oldest := s.segments[0]
+		lost := 0
+		switch {
+		case oldest.seq == s.readSeq:
+			lost = oldest.records - s.readIdx
+		case oldest.seq > s.readSeq:
+			lost = oldest.records
+		}
+		if err := os.Remove(s.segmentPath(oldest.seq)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Spool: failed to evict segment %d: %v", oldest.seq, err)
+			return
+		}
+		s.segments = s.segments[1:]
+		if s.readSeq <= oldest.seq {
+			s.readSeq, s.readOff, s.readIdx = s.segments[0].seq, 0, 0
+		}
+		if lost > 0 {
+			s.evicted += uint64(lost)
+			log.Printf("Spool: size limit %d bytes reached, evicted %d unacknowledged batches (total: %d)", s.maxBytes, lost, s.evicted)
+		}
+	}
+}
+
+// dropAckedSegments deletes non-active segments entirely behind the read
+// cursor. Caller holds s.mu (or is in recovery).
+func (s *Spool) dropAckedSegments() {
+	for len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if oldest.seq > s.readSeq || (oldest.seq == s.readSeq && s.readOff < oldest.size) {
+			return
+		}
+		if err := os.Remove(s.segmentPath(oldest.seq)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Spool: failed to remove segment %d: %v", oldest.seq, err)
+			return
+		}
+		s.segments = s.segments[1:]
+		if s.readSeq == oldest.seq {
+			s.readSeq, s.readOff, s.readIdx = s.segments[0].seq, 0, 0
+		}
+	}
+}
+
+func (s *Spool) totalSizeLocked() int64 {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	return total
+}
+
+func (s *Spool) segment(seq uint64) *spoolSegment {
+	for _, seg := range s.segments {
+		if seg.seq == seq {
+			return seg
+		}
+	}
+	return nil
+}
+
+// segmentAfter returns the oldest segment newer than seq, or nil.
+func (s *Spool) segmentAfter(seq uint64) *spoolSegment {
+	for _, seg := range s.segments {
+		if seg.seq > seq {
+			return seg
+		}
+	}
+	return nil
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", seq, spoolSegmentSuffix))
+}
+
+// listSegments returns the sequence numbers of existing segments, ascending.
+func (s *Spool) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spool dir: %w", err)
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spoolSegmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// scanSegment validates every record in a segment and truncates it at the
+// first torn record.
+func (s *Spool) scanSegment(seq uint64) (*spoolSegment, error) {
+	path := s.segmentPath(seq)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat spool segment: %w", err)
+	}
+
+	seg := &spoolSegment{seq: seq}
+	r := bufio.NewReader(f)
+	for {
+		n, err := readSpoolRecord(r, info.Size()-seg.size, nil)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Spool: truncating segment %d at offset %d: %v", seq, seg.size, err)
+			if err := os.Truncate(path, seg.size); err != nil {
+				return nil, fmt.Errorf("truncate spool segment: %w", err)
+			}
+			break
+		}
+		seg.size += n
+		seg.records++
+	}
+	return seg, nil
+}
+
+// countRecords returns the number of records in a segment before offset.
+func (s *Spool) countRecords(seq uint64, offset int64) (int, error) {
+	f, err := os.Open(s.segmentPath(seq))
+	if err != nil {
+		return 0, fmt.Errorf("open spool segment: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("stat spool segment: %w", err)
+	}
+
+	r := bufio.NewReader(f)
+	var pos int64
+	count := 0
+	for pos < offset {
+		n, err := readSpoolRecord(r, info.Size()-pos, nil)
+		if err != nil {
+			return count, nil
+		}
+		pos += n
+		count++
+	}
+	return count, nil
+}
+
+// readRecord reads the record at offset and returns its payload and the
+// offset of the following record.
+func (s *Spool) readRecord(seq uint64, offset int64) ([]byte, int64, error) {
+	f, err := os.Open(s.segmentPath(seq))
+	if err != nil {
+		return nil, 0, fmt.Errorf("open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("stat spool segment: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("seek spool segment: %w", err)
+	}
+	var data []byte
+	n, err := readSpoolRecord(bufio.NewReader(f), info.Size()-offset, &data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read spool record: %w", err)
+	}
+	return data, offset + n, nil
+}
+
+// readSpoolRecord reads and verifies one record, returning its encoded size.
+// remaining is the number of bytes left in the segment; a header claiming a
+// longer payload is torn, and is rejected before the payload is allocated.
+// The payload is stored in *out when out is non-nil. Returns io.EOF at a clean
+// end of segment and errTornRecord for a partial or corrupt record.
+func readSpoolRecord(r io.Reader, remaining int64, out *[]byte) (int64, error) {
+	var hdr [spoolRecordHeader]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, errTornRecord
+	}
+	length := binary.LittleEndian.Uint32(hdr[0:4])
+	sum := binary.LittleEndian.Uint32(hdr[4:8])
+	if int64(length) > remaining-spoolRecordHeader {
+		return 0, errTornRecord
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, errTornRecord
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return 0, errTornRecord
+	}
+	if out != nil {
+		*out = data
+	}
+	return int64(spoolRecordHeader) + int64(length), nil
+}
+
+// readAck returns the persisted cursor, or zeros if there is none.
+func (s *Spool) readAck() (uint64, int64) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, spoolAckFile))
+	if err != nil {
+		return 0, 0
+	}
+	var seq uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(raw), "%d %d", &seq, &off); err != nil {
+		log.Printf("Spool: ignoring malformed ack file: %v", err)
+		return 0, 0
+	}
+	return seq, off
+}
+
+// writeAck atomically persists the read cursor. Caller holds s.mu.
+func (s *Spool) writeAck() error {
+	path := filepath.Join(s.dir, spoolAckFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", s.readSeq, s.readOff)), 0o644); err != nil {
+		return fmt.Errorf("write spool ack: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("commit spool ack: %w", err)
+	}
+	return nil
+}
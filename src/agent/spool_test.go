@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustOpenSpool(t *testing.T, dir string, maxBytes, segmentBytes int64) *Spool {
+	t.Helper()
+	s, err := OpenSpool(dir, maxBytes, segmentBytes)
+	if err != nil {
+		t.Fatalf("OpenSpool: %v", err)
+	}
+	return s
+}
+
+// drainSpool reads and acks up to n batches, returning their payloads.
+func drainSpool(t *testing.T, s *Spool, n int) []string {
+	t.Helper()
+	var out []string
+	for len(out) < n {
+		entry, ok, err := s.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if !ok {
+			break
+		}
+		out = append(out, string(entry.Data))
+		if err := s.Ack(entry); err != nil {
+			t.Fatalf("Ack: %v", err)
+		}
+	}
+	return out
+}
+
+func TestSpoolAppendNextAckOrder(t *testing.T) {
+	s := mustOpenSpool(t, t.TempDir(), 1<<20, 256)
+	defer s.Close()
+
+	for i := 0; i < 20; i++ {
+		if err := s.Append([]byte(fmt.Sprintf("batch-%02d", i))); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if s.Pending() != 20 {
+		t.Fatalf("Pending() = %d, want 20", s.Pending())
+	}
+
+	// Next without Ack returns the same batch
+	first, _, _ := s.Next()
+	again, _, _ := s.Next()
+	if string(first.Data) != "batch-00" || string(again.Data) != "batch-00" {
+		t.Fatalf("Next without Ack = %q, %q; want batch-00 twice", first.Data, again.Data)
+	}
+
+	got := drainSpool(t, s, 100)
+	if len(got) != 20 {
+		t.Fatalf("drained %d batches, want 20", len(got))
+	}
+	for i, g := range got {
+		if want := fmt.Sprintf("batch-%02d", i); g != want {
+			t.Fatalf("batch %d = %q, want %q", i, g, want)
+		}
+	}
+	if s.Pending() != 0 {
+		t.Fatalf("Pending() = %d after drain, want 0", s.Pending())
+	}
+
+	// Fully acknowledged segments are truncated away; only the active one remains
+	segs, _ := filepath.Glob(filepath.Join(s.dir, "*"+spoolSegmentSuffix))
+	if len(segs) != 1 {
+		t.Fatalf("expected 1 segment left after ack, got %d", len(segs))
+	}
+}
+
+func TestSpoolReplaysUnackedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	s := mustOpenSpool(t, dir, 1<<20, 128)
+	for i := 0; i < 10; i++ {
+		s.Append([]byte(fmt.Sprintf("batch-%02d", i)))
+	}
+	drainSpool(t, s, 4)
+	s.Close()
+
+	s = mustOpenSpool(t, dir, 1<<20, 128)
+	defer s.Close()
+	if s.Pending() != 6 {
+		t.Fatalf("Pending() after restart = %d, want 6", s.Pending())
+	}
+	select {
+	case <-s.Notify():
+	default:
+		t.Fatal("expected Notify to fire for replayed batches")
+	}
+
+	s.Append([]byte("batch-10"))
+	got := drainSpool(t, s, 100)
+	if len(got) != 7 || got[0] != "batch-04" || got[6] != "batch-10" {
+		t.Fatalf("replayed %v, want batch-04..batch-10", got)
+	}
+}
+
+func TestSpoolTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := mustOpenSpool(t, dir, 1<<20, 1<<16)
+	s.Append([]byte("complete-1"))
+	s.Append([]byte("complete-2"))
+	s.Close()
+
+	// Simulate a crash mid-append: a header promising more bytes than follow
+	path := filepath.Join(dir, fmt.Sprintf("%016d%s", 1, spoolSegmentSuffix))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	f.Write([]byte{0xff, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 'x'})
+	f.Close()
+
+	s = mustOpenSpool(t, dir, 1<<20, 1<<16)
+	defer s.Close()
+	s.Append([]byte("after-crash"))
+
+	got := drainSpool(t, s, 100)
+	if len(got) != 3 || got[0] != "complete-1" || got[1] != "complete-2" || got[2] != "after-crash" {
+		t.Fatalf("got %v after torn-tail recovery", got)
+	}
+}
+
+func TestReadSpoolRecordRejectsLengthBeyondSegment(t *testing.T) {
+	// A corrupt header claiming a 4 GiB payload must be rejected as torn
+	// before the payload is allocated
+	hdr := []byte{0xff, 0xff, 0xff, 0xff, 0x01, 0x02, 0x03, 0x04}
+	var data []byte
+	if _, err := readSpoolRecord(bytes.NewReader(hdr), int64(len(hdr)), &data); err != errTornRecord {
+		t.Fatalf("readSpoolRecord = %v, want errTornRecord", err)
+	}
+	if data != nil {
+		t.Fatalf("payload = %d bytes, want none", len(data))
+	}
+}
+
+func TestSpoolEvictsOldestWhenFull(t *testing.T) {
+	payload := make([]byte, 92) // 100 bytes per record with the header
+	s := mustOpenSpool(t, t.TempDir(), 1000, 200)
+	defer s.Close()
+
+	for i := 0; i < 30; i++ {
+		payload[0] = byte(i)
+		if err := s.Append(payload); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if s.Size() > 1000 {
+			t.Fatalf("spool size %d exceeds bound after append %d", s.Size(), i)
+		}
+	}
+	if s.EvictedBatches() == 0 {
+		t.Fatal("expected batches to be evicted")
+	}
+	if got := s.Pending() + int(s.EvictedBatches()); got != 30 {
+		t.Fatalf("pending+evicted = %d, want 30", got)
+	}
+
+	// The survivors are the newest batches, still in order
+	entry, ok, _ := s.Next()
+	if !ok || int(entry.Data[0]) != int(s.EvictedBatches()) {
+		t.Fatalf("oldest surviving batch = %d, want %d", entry.Data[0], s.EvictedBatches())
+	}
+}
@@ -1,24 +1,59 @@
 import { config } from '../config';
 import type {
+  HeartbeatBucket,
   HeartbeatEvent,
   LeasesByNamespace,
   ChartDataPoint,
 } from '../types/heartbeat';
 
-/**
- * Fetch heartbeats from the API, optionally filtered by time range.
- */
-export async function fetchHeartbeats(from?: Date, to?: Date): Promise<HeartbeatEvent[]> {
+function heartbeatParams(from?: Date, to?: Date, node?: string): URLSearchParams {
   const params = new URLSearchParams();
+  if (node) params.set('node', node);
   if (from) params.set('from', from.toISOString());
   if (to) params.set('to', to.toISOString());
+  return params;
+}
+
+/**
+ * Fetch heartbeats from the API, optionally filtered by time range and node.
+ * The API pages its results; pages are followed until the range is exhausted.
+ */
+export async function fetchHeartbeats(from?: Date, to?: Date, node?: string): Promise<HeartbeatEvent[]> {
+  const params = heartbeatParams(from, to, node);
+  const heartbeats: HeartbeatEvent[] = [];
+  for (;;) {
+    const query = params.toString();
+    const url = `${config.apiBaseUrl}/api/heartbeats${query ? `?${query}` : ''}`;
 
-  const query = params.toString();
-  const url = `${config.apiBaseUrl}/api/heartbeats${query ? `?${query}` : ''}`;
+    const res = await fetch(url);
+    if (!res.ok) {
+      throw new Error(`Failed to fetch heartbeats: ${res.status}`);
+    }
+    const page: HeartbeatEvent[] = await res.json();
+    heartbeats.push(...page);
+    const cursor = res.headers.get('X-Next-Cursor');
+    if (!cursor) {
+      return heartbeats;
+    }
+    params.set('cursor', cursor);
+  }
+}
 
-  const res = await fetch(url);
+/**
+ * Fetch heartbeats downsampled into per-node buckets of step, such as '30s',
+ * for charting long ranges without every heartbeat.
+ */
+export async function fetchHeartbeatBuckets(
+  step: string,
+  from?: Date,
+  to?: Date,
+  node?: string
+): Promise<HeartbeatBucket[]> {
+  const params = heartbeatParams(from, to, node);
+  params.set('step', step);
+  const res = await fetch(`${config.apiBaseUrl}/api/heartbeats?${params.toString()}`);
   if (!res.ok) {
-    throw new Error(`Failed to fetch heartbeats: ${res.status}`);
+    throw new Error(`Failed to fetch heartbeat buckets: ${res.status}`);
   }
   return res.json();
 }
@@ -13,6 +13,17 @@ export interface HeartbeatEvent {
   ebpf?: EbpfMetadata;
 }
 
+/** One node's heartbeats within one step, from /api/heartbeats?step= */
+export interface HeartbeatBucket {
+  nodeName: string;
+  start: string; // RFC 3339
+  count: number;
+  first: string;
+  last: string;
+  minGapSeconds: number;
+  maxGapSeconds: number;
+}
+
 export interface LeasePoint {
   x: number; // index
   y: number; // timestamp ms
@@ -18,7 +18,8 @@ type EbpfEventJSON struct {
 }
 
 // WriteSimulationOutput writes all simulation result files to the given output directory.
-// It creates lease JSON files, eBPF JSON files, and manifest files.
+// It creates lease JSON files, eBPF JSON files, manifest files and the
+// ground truth of the run.
 func WriteSimulationOutput(result *SimulationResult, outputDir string) error {
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
@@ -80,5 +81,15 @@ func WriteSimulationOutput(result *SimulationResult, outputDir string) error {
 		return fmt.Errorf("failed to write ebpf manifest: %w", err)
 	}
 
+	// Write the true NotReady periods for scoring detection against
+	groundTruthPath := filepath.Join(outputDir, "ground_truth.json")
+	groundTruthData, err := json.MarshalIndent(result.GroundTruth, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ground truth: %w", err)
+	}
+	if err := os.WriteFile(groundTruthPath, groundTruthData, 0644); err != nil {
+		return fmt.Errorf("failed to write ground truth: %w", err)
+	}
+
 	return nil
 }
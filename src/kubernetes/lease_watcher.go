@@ -0,0 +1,158 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NodeLeaseNamespace is the namespace where kubelets publish their node heartbeat Leases.
+const NodeLeaseNamespace = "kube-node-lease"
+
+// defaultLeaseResync is the informer resync period. Resyncs replay cached
+// objects as updates; they are filtered out because RenewTime is unchanged.
+const defaultLeaseResync = 10 * time.Minute
+
+// LeaseHeartbeat is a typed node heartbeat derived from a Lease RenewTime change.
+type LeaseHeartbeat struct {
+	NodeName             string
+	Namespace            string
+	HolderIdentity       string
+	LeaseDurationSeconds int32
+	RenewTime            time.Time
+}
+
+// LeaseWatcher watches node Leases through a shared informer and emits a
+// LeaseHeartbeat every time a Lease's RenewTime changes.
+type LeaseWatcher struct {
+	clientset kubernetes.Interface
+	namespace string
+	resync    time.Duration
+	handler   func(LeaseHeartbeat)
+	onDelete  func(nodeName string)
+}
+
+// NewLeaseWatcher creates a watcher for Leases in kube-node-lease. The handler is
+// invoked from the informer goroutine, once per observed renewal.
+func NewLeaseWatcher(clientset kubernetes.Interface, handler func(LeaseHeartbeat)) *LeaseWatcher {
+	return &LeaseWatcher{
+		clientset: clientset,
+		namespace: NodeLeaseNamespace,
+		resync:    defaultLeaseResync,
+		handler:   handler,
+	}
+}
+
+// OnDelete sets a handler invoked with the node name when a node's Lease is
+// deleted, as it is when the Node is removed from the cluster. Call it before
+// Run.
+func (lw *LeaseWatcher) OnDelete(handler func(nodeName string)) {
+	lw.onDelete = handler
+}
+
+// Run starts the Lease informer, waits for its cache to sync, and blocks until
+// ctx is cancelled and the handlers have returned. Leases already present at
+// startup are emitted once.
+func (lw *LeaseWatcher) Run(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(lw.clientset, lw.resync,
+		informers.WithNamespace(lw.namespace))
+	informer := factory.Coordination().V1().Leases().Informer()
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			lease, ok := obj.(*coordinationv1.Lease)
+			if !ok {
+				log.Printf("LeaseWatcher: unexpected type: %T", obj)
+				return
+			}
+			if hb, ok := LeaseHeartbeatFromLease(lease); ok {
+				lw.handler(hb)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldLease, ok1 := oldObj.(*coordinationv1.Lease)
+			newLease, ok2 := newObj.(*coordinationv1.Lease)
+			if !ok1 || !ok2 {
+				log.Printf("LeaseWatcher: unexpected types: %T, %T", oldObj, newObj)
+				return
+			}
+			if !renewTimeChanged(oldLease, newLease) {
+				return
+			}
+			if hb, ok := LeaseHeartbeatFromLease(newLease); ok {
+				lw.handler(hb)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if lw.onDelete == nil {
+				return
+			}
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			lease, ok := obj.(*coordinationv1.Lease)
+			if !ok {
+				log.Printf("LeaseWatcher: unexpected type: %T", obj)
+				return
+			}
+			lw.onDelete(lease.Name)
+		},
+	})
+
+	// Run the informer here rather than through factory.Start, whose
+	// goroutines outlive Run, so that no handler runs after Run returns
+	stopped := make(chan struct{})
+	go func() {
+		informer.Run(ctx.Done())
+		close(stopped)
+	}()
+	defer func() { <-stopped }()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("lease informer cache failed to sync")
+	}
+	log.Printf("LeaseWatcher: watching Leases in namespace %s", lw.namespace)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// LeaseHeartbeatFromLease converts a node Lease into a LeaseHeartbeat.
+// Returns false when the Lease has never been renewed.
+func LeaseHeartbeatFromLease(lease *coordinationv1.Lease) (LeaseHeartbeat, bool) {
+	if lease == nil || lease.Spec.RenewTime == nil {
+		return LeaseHeartbeat{}, false
+	}
+	hb := LeaseHeartbeat{
+		NodeName:  lease.Name,
+		Namespace: lease.Namespace,
+		RenewTime: lease.Spec.RenewTime.Time,
+	}
+	if lease.Spec.HolderIdentity != nil {
+		hb.HolderIdentity = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.LeaseDurationSeconds != nil {
+		hb.LeaseDurationSeconds = *lease.Spec.LeaseDurationSeconds
+	}
+	return hb, true
+}
+
+// renewTimeChanged reports whether the RenewTime differs between two revisions of a Lease.
+func renewTimeChanged(oldLease, newLease *coordinationv1.Lease) bool {
+	oldRenew, newRenew := oldLease.Spec.RenewTime, newLease.Spec.RenewTime
+	if newRenew == nil {
+		return false
+	}
+	if oldRenew == nil {
+		return true
+	}
+	return !oldRenew.Time.Equal(newRenew.Time)
+}
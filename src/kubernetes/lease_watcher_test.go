@@ -0,0 +1,225 @@
+package kubernetes
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// newNodeLease builds a kube-node-lease Lease for the given node and renew time.
+func newNodeLease(nodeName string, renew time.Time) *coordinationv1.Lease {
+	holder := nodeName
+	duration := int32(40)
+	renewTime := metav1.NewMicroTime(renew)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeName,
+			Namespace: NodeLeaseNamespace,
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+// leaseRecorder collects heartbeats emitted by a LeaseWatcher.
+type leaseRecorder struct {
+	mu  sync.Mutex
+	hbs []LeaseHeartbeat
+}
+
+func (r *leaseRecorder) record(hb LeaseHeartbeat) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hbs = append(r.hbs, hb)
+}
+
+func (r *leaseRecorder) snapshot() []LeaseHeartbeat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LeaseHeartbeat(nil), r.hbs...)
+}
+
+// waitForCount polls until at least n heartbeats are recorded or the timeout expires.
+func (r *leaseRecorder) waitForCount(t *testing.T, n int) []LeaseHeartbeat {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if hbs := r.snapshot(); len(hbs) >= n {
+			return hbs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d heartbeats, got %d", n, len(r.snapshot()))
+	return nil
+}
+
+// startFakeWatcher runs a LeaseWatcher against a fake clientset and returns once
+// the informer's watch is established, so later updates are not missed.
+// onDelete may be nil.
+func startFakeWatcher(t *testing.T, client *fake.Clientset, rec *leaseRecorder, onDelete func(string)) context.CancelFunc {
+	t.Helper()
+	watchStarted := make(chan struct{})
+	client.PrependWatchReactor("*", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		close(watchStarted)
+		return true, w, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lw := NewLeaseWatcher(client, rec.record)
+	if onDelete != nil {
+		lw.OnDelete(onDelete)
+	}
+	go lw.Run(ctx)
+
+	select {
+	case <-watchStarted:
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatal("lease informer never started watching")
+	}
+	return cancel
+}
+
+func TestLeaseHeartbeatFromLease_Fields(t *testing.T) {
+	renew := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	hb, ok := LeaseHeartbeatFromLease(newNodeLease("node-01", renew))
+	if !ok {
+		t.Fatal("expected lease with RenewTime to convert")
+	}
+	if hb.NodeName != "node-01" || hb.Namespace != NodeLeaseNamespace {
+		t.Errorf("unexpected identity: %+v", hb)
+	}
+	if hb.HolderIdentity != "node-01" {
+		t.Errorf("HolderIdentity = %q, want node-01", hb.HolderIdentity)
+	}
+	if hb.LeaseDurationSeconds != 40 {
+		t.Errorf("LeaseDurationSeconds = %d, want 40", hb.LeaseDurationSeconds)
+	}
+	if !hb.RenewTime.Equal(renew) {
+		t.Errorf("RenewTime = %v, want %v", hb.RenewTime, renew)
+	}
+}
+
+func TestLeaseHeartbeatFromLease_NoRenewTime(t *testing.T) {
+	lease := newNodeLease("node-01", time.Now())
+	lease.Spec.RenewTime = nil
+	if _, ok := LeaseHeartbeatFromLease(lease); ok {
+		t.Fatal("expected lease without RenewTime to be skipped")
+	}
+}
+
+func TestLeaseWatcher_EmitsOnRenewTimeChange(t *testing.T) {
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(newNodeLease("node-01", base))
+	rec := &leaseRecorder{}
+	cancel := startFakeWatcher(t, client, rec, nil)
+	defer cancel()
+
+	// Initial list emits the existing lease once
+	hbs := rec.waitForCount(t, 1)
+	if hbs[0].NodeName != "node-01" || !hbs[0].RenewTime.Equal(base) {
+		t.Fatalf("unexpected initial heartbeat: %+v", hbs[0])
+	}
+
+	leases := client.CoordinationV1().Leases(NodeLeaseNamespace)
+	renewed := newNodeLease("node-01", base.Add(10*time.Second))
+	if _, err := leases.Update(context.Background(), renewed, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update lease: %v", err)
+	}
+
+	hbs = rec.waitForCount(t, 2)
+	if !hbs[1].RenewTime.Equal(base.Add(10 * time.Second)) {
+		t.Fatalf("RenewTime = %v, want %v", hbs[1].RenewTime, base.Add(10*time.Second))
+	}
+	if hbs[1].HolderIdentity != "node-01" || hbs[1].LeaseDurationSeconds != 40 {
+		t.Fatalf("unexpected renewed heartbeat: %+v", hbs[1])
+	}
+}
+
+func TestLeaseWatcher_IgnoresUpdatesWithoutRenewal(t *testing.T) {
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(newNodeLease("node-01", base))
+	rec := &leaseRecorder{}
+	cancel := startFakeWatcher(t, client, rec, nil)
+	defer cancel()
+
+	rec.waitForCount(t, 1)
+
+	leases := client.CoordinationV1().Leases(NodeLeaseNamespace)
+
+	// Label change without a RenewTime change must not produce a heartbeat
+	relabelled := newNodeLease("node-01", base)
+	relabelled.Labels = map[string]string{"touched": "true"}
+	if _, err := leases.Update(context.Background(), relabelled, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update lease: %v", err)
+	}
+
+	// A new node's lease is emitted as it appears
+	if _, err := leases.Create(context.Background(), newNodeLease("node-02", base), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create lease: %v", err)
+	}
+
+	rec.waitForCount(t, 2)
+	time.Sleep(100 * time.Millisecond)
+	hbs := rec.snapshot()
+	if len(hbs) != 2 {
+		t.Fatalf("expected 2 heartbeats, got %d: %+v", len(hbs), hbs)
+	}
+	if hbs[1].NodeName != "node-02" {
+		t.Fatalf("second heartbeat node = %q, want node-02", hbs[1].NodeName)
+	}
+}
+
+func TestLeaseWatcher_IgnoresOtherNamespaces(t *testing.T) {
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	other := newNodeLease("leader-election", base)
+	other.Namespace = "kube-system"
+	client := fake.NewSimpleClientset(other, newNodeLease("node-01", base))
+	rec := &leaseRecorder{}
+	cancel := startFakeWatcher(t, client, rec, nil)
+	defer cancel()
+
+	rec.waitForCount(t, 1)
+	time.Sleep(100 * time.Millisecond)
+	for _, hb := range rec.snapshot() {
+		if hb.Namespace != NodeLeaseNamespace {
+			t.Fatalf("heartbeat from unexpected namespace: %+v", hb)
+		}
+	}
+}
+
+func TestLeaseWatcher_EmitsDeletes(t *testing.T) {
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(newNodeLease("node-01", base), newNodeLease("node-02", base))
+	rec := &leaseRecorder{}
+	deleted := make(chan string, 1)
+	cancel := startFakeWatcher(t, client, rec, func(nodeName string) { deleted <- nodeName })
+	defer cancel()
+
+	rec.waitForCount(t, 2)
+	if err := client.CoordinationV1().Leases(NodeLeaseNamespace).Delete(context.Background(), "node-02", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete lease: %v", err)
+	}
+	select {
+	case name := <-deleted:
+		if name != "node-02" {
+			t.Fatalf("deleted node = %q, want node-02", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease deletion not emitted")
+	}
+}
@@ -0,0 +1,143 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NodeCondition is the Ready condition of a Node at a point in time.
+type NodeCondition struct {
+	NodeName  string
+	Ready     bool
+	Reason    string
+	Message   string
+	Timestamp time.Time // LastTransitionTime of the Ready condition
+	Labels    map[string]string
+}
+
+// NodeWatcher watches Node objects through a shared informer and emits a
+// NodeCondition whenever a node's Ready condition status or labels change.
+type NodeWatcher struct {
+	clientset kubernetes.Interface
+	resync    time.Duration
+	handler   func(NodeCondition)
+	onDelete  func(nodeName string)
+}
+
+// NewNodeWatcher creates a watcher for Node Ready conditions. The handler is
+// invoked from the informer goroutine.
+func NewNodeWatcher(clientset kubernetes.Interface, handler func(NodeCondition)) *NodeWatcher {
+	return &NodeWatcher{
+		clientset: clientset,
+		resync:    defaultLeaseResync,
+		handler:   handler,
+	}
+}
+
+// OnDelete sets a handler invoked with the node name when a Node is deleted.
+// Call it before Run.
+func (nw *NodeWatcher) OnDelete(handler func(nodeName string)) {
+	nw.onDelete = handler
+}
+
+// Run starts the Node informer, waits for its cache to sync, and blocks until
+// ctx is cancelled and the handlers have returned. Nodes already present at
+// startup are emitted once.
+func (nw *NodeWatcher) Run(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactory(nw.clientset, nw.resync)
+	informer := factory.Core().V1().Nodes().Informer()
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			node, ok := obj.(*corev1.Node)
+			if !ok {
+				log.Printf("NodeWatcher: unexpected type: %T", obj)
+				return
+			}
+			if cond, ok := NodeReadyCondition(node); ok {
+				nw.handler(cond)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok1 := oldObj.(*corev1.Node)
+			newNode, ok2 := newObj.(*corev1.Node)
+			if !ok1 || !ok2 {
+				log.Printf("NodeWatcher: unexpected types: %T, %T", oldObj, newObj)
+				return
+			}
+			newCond, ok := NodeReadyCondition(newNode)
+			if !ok {
+				return
+			}
+			if oldCond, ok := NodeReadyCondition(oldNode); ok && oldCond.Ready == newCond.Ready &&
+				maps.Equal(oldNode.Labels, newNode.Labels) {
+				return
+			}
+			nw.handler(newCond)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if nw.onDelete == nil {
+				return
+			}
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			node, ok := obj.(*corev1.Node)
+			if !ok {
+				log.Printf("NodeWatcher: unexpected type: %T", obj)
+				return
+			}
+			nw.onDelete(node.Name)
+		},
+	})
+
+	// Run the informer here rather than through factory.Start, whose
+	// goroutines outlive Run, so that no handler runs after Run returns
+	stopped := make(chan struct{})
+	go func() {
+		informer.Run(ctx.Done())
+		close(stopped)
+	}()
+	defer func() { <-stopped }()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("node informer cache failed to sync")
+	}
+	log.Println("NodeWatcher: watching Node Ready conditions")
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// NodeReadyCondition extracts the Ready condition from a Node.
+// Status "True" is Ready; "False" and "Unknown" are both treated as NotReady.
+// Returns false when the Node has no Ready condition yet.
+func NodeReadyCondition(node *corev1.Node) (NodeCondition, bool) {
+	if node == nil {
+		return NodeCondition{}, false
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type != corev1.NodeReady {
+			continue
+		}
+		return NodeCondition{
+			NodeName:  node.Name,
+			Ready:     c.Status == corev1.ConditionTrue,
+			Reason:    c.Reason,
+			Message:   c.Message,
+			Timestamp: c.LastTransitionTime.Time,
+			Labels:    node.Labels,
+		}, true
+	}
+	return NodeCondition{}, false
+}
@@ -0,0 +1,152 @@
+package kubernetes
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// newTestNode builds a Node whose Ready condition has the given status.
+func newTestNode(name string, status corev1.ConditionStatus, reason string, ts time.Time) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+				{
+					Type:               corev1.NodeReady,
+					Status:             status,
+					Reason:             reason,
+					LastTransitionTime: metav1.NewTime(ts),
+				},
+			},
+		},
+	}
+}
+
+func TestNodeReadyCondition(t *testing.T) {
+	ts := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	cond, ok := NodeReadyCondition(newTestNode("node-01", corev1.ConditionTrue, "KubeletReady", ts))
+	if !ok || !cond.Ready || cond.Reason != "KubeletReady" || !cond.Timestamp.Equal(ts) {
+		t.Fatalf("unexpected Ready condition: %+v (ok=%v)", cond, ok)
+	}
+
+	for _, status := range []corev1.ConditionStatus{corev1.ConditionFalse, corev1.ConditionUnknown} {
+		cond, ok = NodeReadyCondition(newTestNode("node-01", status, "NodeStatusUnknown", ts))
+		if !ok || cond.Ready {
+			t.Fatalf("status %s: expected NotReady, got %+v", status, cond)
+		}
+	}
+
+	if _, ok := NodeReadyCondition(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "bare"}}); ok {
+		t.Fatal("expected node without Ready condition to be skipped")
+	}
+}
+
+func TestNodeWatcher_EmitsOnReadyOrLabelChange(t *testing.T) {
+	ts := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(newTestNode("node-01", corev1.ConditionTrue, "KubeletReady", ts))
+
+	watchStarted := make(chan struct{})
+	client.PrependWatchReactor("*", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		close(watchStarted)
+		return true, w, nil
+	})
+
+	var mu sync.Mutex
+	var got []NodeCondition
+	snapshot := func() []NodeCondition {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]NodeCondition(nil), got...)
+	}
+	waitFor := func(n int) []NodeCondition {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if c := snapshot(); len(c) >= n {
+				return c
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %d conditions, got %d", n, len(snapshot()))
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deleted := make(chan string, 1)
+	watcher := NewNodeWatcher(client, func(c NodeCondition) {
+		mu.Lock()
+		got = append(got, c)
+		mu.Unlock()
+	})
+	watcher.OnDelete(func(nodeName string) { deleted <- nodeName })
+	go watcher.Run(ctx)
+
+	select {
+	case <-watchStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("node informer never started watching")
+	}
+	waitFor(1)
+
+	nodes := client.CoreV1().Nodes()
+
+	// Heartbeat-only update (Ready and labels unchanged) is ignored
+	same := newTestNode("node-01", corev1.ConditionTrue, "KubeletReady", ts)
+	same.Annotations = map[string]string{"touched": "true"}
+	if _, err := nodes.Update(ctx, same, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update node: %v", err)
+	}
+
+	// Ready → Unknown is emitted
+	down := newTestNode("node-01", corev1.ConditionUnknown, "NodeStatusUnknown", ts.Add(time.Minute))
+	if _, err := nodes.Update(ctx, down, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update node: %v", err)
+	}
+
+	// A label change is emitted with the new labels
+	relabeled := newTestNode("node-01", corev1.ConditionUnknown, "NodeStatusUnknown", ts.Add(time.Minute))
+	relabeled.Labels = map[string]string{"pool": "spot"}
+	if _, err := nodes.Update(ctx, relabeled, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update node: %v", err)
+	}
+
+	waitFor(3)
+	time.Sleep(100 * time.Millisecond)
+	conds := snapshot()
+	if len(conds) != 3 {
+		t.Fatalf("expected 3 conditions, got %d: %+v", len(conds), conds)
+	}
+	if conds[1].Ready || conds[1].Reason != "NodeStatusUnknown" || !conds[1].Timestamp.Equal(ts.Add(time.Minute)) {
+		t.Fatalf("unexpected NotReady condition: %+v", conds[1])
+	}
+	if conds[2].Ready || conds[2].Labels["pool"] != "spot" {
+		t.Fatalf("unexpected relabeled condition: %+v", conds[2])
+	}
+
+	// A deletion is emitted by name
+	if err := nodes.Delete(ctx, "node-01", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete node: %v", err)
+	}
+	select {
+	case name := <-deleted:
+		if name != "node-01" {
+			t.Fatalf("deleted node = %q, want node-01", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("node deletion not emitted")
+	}
+}
@@ -25,29 +25,42 @@ const (
 	CauseOOMKill        NotReadyCause = "oom_kill"
 	CauseDiskPressure   NotReadyCause = "disk_pressure"
 	CauseKubeletRestart NotReadyCause = "kubelet_restart"
+	CauseDNSOutage      NotReadyCause = "dns_outage"
+	CauseSlowDisk       NotReadyCause = "slow_disk"
+	CauseCPUThrottling  NotReadyCause = "cpu_throttling"
+	CauseContainerdHang NotReadyCause = "containerd_hang"
+
+	// CauseNetworkPartition is set only by the network_partition scenario
+	CauseNetworkPartition NotReadyCause = "network_partition"
+	// CauseDrain is set only by the rolling_deployment scenario; drained
+	// nodes never come back
+	CauseDrain NotReadyCause = "drain"
 )
 
-// SimulationConfig holds all parameters for a simulation run
+// SimulationConfig holds all parameters for a simulation run. It can be
+// loaded from YAML with LoadSimulationConfig; durations are written as
+// strings such as "4h" or "500ms".
 type SimulationConfig struct {
-	NodeCount         int
-	Duration          time.Duration
-	BaseInterval      time.Duration            // default 10s
-	JitterStdDev      time.Duration            // default 500ms
-	FileSegmentWindow time.Duration            // default 5min
-	NamespaceRatios   map[string]float64       // e.g. {"kube-system": 0.2, "production": 0.5, "staging": 0.3}
-	NamespaceProfiles map[string]NodeHealthProfile
-	Scenarios         []ScenarioConfig
-	MaxDriftIncrease  time.Duration // default 3s
-	Seed              int64         // 0 means use time-based seed
+	NodeCount         int                          `yaml:"nodeCount"`
+	Duration          time.Duration                `yaml:"duration"`
+	BaseInterval      time.Duration                `yaml:"baseInterval"`      // default 10s
+	JitterStdDev      time.Duration                `yaml:"jitterStdDev"`      // default 500ms
+	FileSegmentWindow time.Duration                `yaml:"fileSegmentWindow"` // default 5min
+	NamespaceRatios   map[string]float64           `yaml:"namespaceRatios"`   // e.g. {"kube-system": 0.2, "production": 0.5, "staging": 0.3}
+	NamespaceProfiles map[string]NodeHealthProfile `yaml:"namespaceProfiles"`
+	Scenarios         []ScenarioConfig             `yaml:"scenarios"`
+	MaxDriftIncrease  time.Duration                `yaml:"maxDriftIncrease"` // default 3s
+	Seed              int64                        `yaml:"seed"`             // 0 means use time-based seed
 }
 
 // ScenarioConfig defines a cluster scenario event
 type ScenarioConfig struct {
-	Type             string        // "rolling_deployment", "network_partition"
-	TriggerAt        time.Duration // offset from simulation start
-	NodeCount        int           // nodes affected
-	StaggerInterval  time.Duration // default 30s
-	ReplacementDelay time.Duration // default 15s
+	Type             string        `yaml:"type"`             // "rolling_deployment", "network_partition"
+	TriggerAt        time.Duration `yaml:"triggerAt"`        // offset from simulation start
+	NodeCount        int           `yaml:"nodeCount"`        // nodes affected
+	StaggerInterval  time.Duration `yaml:"staggerInterval"`  // rolling_deployment: default 30s
+	ReplacementDelay time.Duration `yaml:"replacementDelay"` // rolling_deployment: default 15s
+	Duration         time.Duration `yaml:"duration"`         // network_partition: how long leases stop, default 2m
 }
 
 // NotReadyTransition records a single NotReady transition event
@@ -71,6 +84,9 @@ type SimNode struct {
 	LeaseHistory       []LeaseEvent
 	EbpfEvents         []SimEbpfEvent
 	TransitionHistory  []NotReadyTransition
+
+	// A failure building up while the node is still Ready
+	degradation *degradation
 }
 
 // LeaseEvent is a single lease renewal record
@@ -90,6 +106,35 @@ type SimEbpfEvent struct {
 	CgroupPath string
 	NodeName   string
 	Namespace  string
+
+	// Set on network events: "retransmit", "reset" or "rtt_high"
+	NetEventType string
+	RTTUs        uint32
+
+	// Set on syscalls the agent timed
+	LatencyNs   uint64
+	SlowSyscall bool
+	ReturnValue int64
+
+	// Set on the agent's extended events: "filesystem_io",
+	// "dns_resolution", "cgroup_resource" or "network_audit". The fields
+	// are named as in the EnrichedEvent the agent reports.
+	EventType        string
+	FilePath         string
+	IOLatencyNs      uint64
+	BytesXfer        uint64
+	SlowIO           bool
+	IOOpType         string
+	Domain           string
+	DNSLatencyNs     uint64
+	TimedOut         bool
+	CPUUsageNs       uint64
+	MemoryUsageBytes uint64
+	MemoryLimitBytes uint64
+	MemoryPressure   bool
+	AuditDstAddr     string
+	AuditDstPort     uint16
+	AuditProtocol    string
 }
 
 // LeasePoint matches the existing JSON format {x, y} used by the visualizer
@@ -125,6 +170,7 @@ type SimulationResult struct {
 	EbpfFiles    []EbpfFileOutput
 	Manifest     []string
 	EbpfManifest []string
+	GroundTruth  []GroundTruthEvent
 	Stats        SimulationStats
 }
 
@@ -137,13 +183,20 @@ type drainedNodeInfo struct {
 	replacementName string
 }
 
-// activeScenario tracks the runtime state of a rolling deployment scenario
+// activeScenario tracks the runtime state of a rolling deployment or network
+// partition scenario
 type activeScenario struct {
 	config       ScenarioConfig
 	started      bool
 	drainIndex   int              // how many nodes have been drained so far
 	drainedNodes []drainedNodeInfo
 	nextDrainAt  time.Duration    // elapsed time for next drain
+
+	// network_partition state
+	partitioned    []*SimNode
+	partitionStart time.Time
+	partitionEnd   time.Time
+	healed         bool
 }
 
 // SimulationEngine runs a tick-based simulation producing realistic data
@@ -156,6 +209,14 @@ type SimulationEngine struct {
 	scenarios       []ScenarioConfig
 	activeScenarios []*activeScenario
 	nextNodeID      int // counter for generating unique node names
+
+	// Step state: the next tick, how much of each node's history has been
+	// handed out, and events generated ahead of the simulated clock
+	step         int
+	leaseCursor  map[string]int
+	ebpfCursor   map[string]int
+	pendingLease []LeaseEvent
+	pendingEbpf  []SimEbpfEvent
 }
 
 const (
@@ -164,6 +225,12 @@ const (
 	defaultJitterStdDev   = 500 * time.Millisecond
 	defaultSegmentWindow  = 5 * time.Minute
 	defaultMaxDrift       = 3 * time.Second
+
+	defaultStaggerInterval   = 30 * time.Second
+	defaultReplacementDelay  = 15 * time.Second
+	defaultPartitionDuration = 2 * time.Minute
+	// RTTs above this are reported as rtt_high, as by the agent
+	rttHighUs = 500_000
 )
 
 // ValidateConfig checks and normalizes a SimulationConfig, returning an error
@@ -201,6 +268,11 @@ func ValidateConfig(cfg *SimulationConfig) error {
 			"staging":     0.3,
 		}
 	}
+	for ns, ratio := range cfg.NamespaceRatios {
+		if ratio < 0 {
+			return fmt.Errorf("namespace %q: ratio must not be negative, got %v", ns, ratio)
+		}
+	}
 	normalizeRatios(cfg.NamespaceRatios)
 
 	// Default namespace profiles if not set
@@ -211,7 +283,60 @@ func ValidateConfig(cfg *SimulationConfig) error {
 			"staging":     ProfileVolatile,
 		}
 	}
+	for ns, profile := range cfg.NamespaceProfiles {
+		switch profile {
+		case ProfileStable, ProfileNormal, ProfileDrifting, ProfileVolatile, "":
+		default:
+			return fmt.Errorf("namespace %q: unknown health profile %q", ns, profile)
+		}
+	}
 
+	// Validate scenarios and apply their defaults to a copy, leaving the
+	// caller's slice untouched
+	scenarios := make([]ScenarioConfig, len(cfg.Scenarios))
+	for i, sc := range cfg.Scenarios {
+		if err := validateScenario(&sc, cfg.Duration); err != nil {
+			return fmt.Errorf("scenario %d: %w", i, err)
+		}
+		scenarios[i] = sc
+	}
+	if cfg.Scenarios != nil {
+		cfg.Scenarios = scenarios
+	}
+
+	return nil
+}
+
+// validateScenario checks a scenario against the simulation duration and
+// applies the defaults for its type.
+func validateScenario(sc *ScenarioConfig, duration time.Duration) error {
+	if sc.NodeCount <= 0 {
+		return fmt.Errorf("%s: node count must be greater than 0, got %d", sc.Type, sc.NodeCount)
+	}
+	if sc.TriggerAt < 0 || sc.TriggerAt >= duration {
+		return fmt.Errorf("%s: trigger at %v is outside the simulation duration %v", sc.Type, sc.TriggerAt, duration)
+	}
+	switch sc.Type {
+	case "rolling_deployment":
+		if sc.StaggerInterval < 0 || sc.ReplacementDelay < 0 {
+			return fmt.Errorf("%s: stagger interval and replacement delay must not be negative", sc.Type)
+		}
+		if sc.StaggerInterval == 0 {
+			sc.StaggerInterval = defaultStaggerInterval
+		}
+		if sc.ReplacementDelay == 0 {
+			sc.ReplacementDelay = defaultReplacementDelay
+		}
+	case "network_partition":
+		if sc.Duration < 0 {
+			return fmt.Errorf("%s: duration must not be negative, got %v", sc.Type, sc.Duration)
+		}
+		if sc.Duration == 0 {
+			sc.Duration = defaultPartitionDuration
+		}
+	default:
+		return fmt.Errorf("unknown scenario type %q, expected rolling_deployment or network_partition", sc.Type)
+	}
 	return nil
 }
 
@@ -333,6 +458,10 @@ var allCauses = []NotReadyCause{
 	CauseOOMKill,
 	CauseDiskPressure,
 	CauseKubeletRestart,
+	CauseDNSOutage,
+	CauseSlowDisk,
+	CauseCPUThrottling,
+	CauseContainerdHang,
 }
 
 // notReadyProbabilityPerTick returns the per-second probability of transitioning
@@ -356,13 +485,18 @@ func notReadyProbabilityPerTick(profile NodeHealthProfile) float64 {
 }
 
 // applyHealthTransitions checks each node and probabilistically transitions
-// Ready nodes to NotReady based on their health profile. Nodes already in
-// NotReady are transitioned back to Ready once their NotReadyUntil time passes.
+// Ready nodes to NotReady based on their health profile. Causes with a
+// lead-up first degrade the node for 30–60s while it stays Ready. Nodes
+// already in NotReady are transitioned back to Ready once their
+// NotReadyUntil time passes.
 func (se *SimulationEngine) applyHealthTransitions() {
 	for _, node := range se.nodes {
 		if node.Status == "NotReady" {
-			// Don't recover nodes that were drained in a rolling deployment
-			if node.NotReadyCause == "drain" {
+			// A scenario took the node down while it was degrading
+			node.degradation = nil
+			// Don't recover nodes that were drained in a rolling deployment;
+			// partitioned nodes are healed by their scenario
+			if node.NotReadyCause == CauseDrain || node.NotReadyCause == CauseNetworkPartition {
 				continue
 			}
 			// Check if NotReady duration has elapsed
@@ -373,6 +507,11 @@ func (se *SimulationEngine) applyHealthTransitions() {
 			continue
 		}
 
+		if node.degradation != nil {
+			se.advanceDegradation(node)
+			continue
+		}
+
 		// Stable nodes never go NotReady
 		if node.Profile == ProfileStable {
 			continue
@@ -380,28 +519,45 @@ func (se *SimulationEngine) applyHealthTransitions() {
 
 		prob := notReadyProbabilityPerTick(node.Profile)
 		if se.rng.Float64() < prob {
-			// Transition to NotReady
 			cause := allCauses[se.rng.Intn(len(allCauses))]
 			// Duration between 5 and 120 seconds
 			durationSec := 5 + se.rng.Intn(116) // 5..120 inclusive
 			duration := time.Duration(durationSec) * time.Second
-			node.Status = "NotReady"
-			node.NotReadyCause = cause
-			node.NotReadyUntil = se.clock.Add(duration)
-
-			// Record the transition for test observability
-			node.TransitionHistory = append(node.TransitionHistory, NotReadyTransition{
-				Timestamp: se.clock,
-				Cause:     cause,
-				Duration:  duration,
-			})
-
-			// Generate correlated eBPF event for this NotReady transition
-			se.generateCorrelatedEbpf(node, cause)
+			if hasLeadUp(cause) {
+				lead := minLeadUp + time.Duration(se.rng.Int63n(int64(maxLeadUp-minLeadUp)+1))
+				node.degradation = &degradation{
+					cause:      cause,
+					start:      se.clock,
+					notReadyAt: se.clock.Add(lead),
+					duration:   duration,
+					nextSample: se.clock,
+				}
+				se.advanceDegradation(node)
+				continue
+			}
+			se.markNotReady(node, cause, duration)
 		}
 	}
 }
 
+// markNotReady transitions node to NotReady for duration, records the
+// transition and generates the eBPF events correlated with its cause.
+func (se *SimulationEngine) markNotReady(node *SimNode, cause NotReadyCause, duration time.Duration) {
+	node.Status = "NotReady"
+	node.NotReadyCause = cause
+	node.NotReadyUntil = se.clock.Add(duration)
+
+	// Record the transition for test observability
+	node.TransitionHistory = append(node.TransitionHistory, NotReadyTransition{
+		Timestamp: se.clock,
+		Cause:     cause,
+		Duration:  duration,
+	})
+
+	// Generate correlated eBPF event for this NotReady transition
+	se.generateCorrelatedEbpf(node, cause)
+}
+
 // generateLeaseForNode generates a lease event for a node if it is Ready and
 // enough time has passed since its last lease (baseInterval + jitter).
 // For drifting profiles, the base interval gradually increases over the simulation.
@@ -459,83 +615,89 @@ func (se *SimulationEngine) generateLeaseForNode(node *SimNode, elapsed time.Dur
 	}
 }
 
-// applyScenarios processes rolling deployment scenarios at the current tick.
-// It drains nodes sequentially with the configured stagger interval and
-// introduces replacement nodes with burst leases after the replacement delay.
+// applyScenarios processes the configured scenarios at the current tick.
 func (se *SimulationEngine) applyScenarios(elapsed time.Duration) {
 	for _, as := range se.activeScenarios {
-		if as.config.Type != "rolling_deployment" {
+		switch as.config.Type {
+		case "rolling_deployment":
+			se.applyRollingDeployment(as, elapsed)
+		case "network_partition":
+			se.applyNetworkPartition(as, elapsed)
+		}
+	}
+}
+
+// applyRollingDeployment drains nodes sequentially with the configured stagger
+// interval and introduces replacement nodes with burst leases after the
+// replacement delay.
+func (se *SimulationEngine) applyRollingDeployment(as *activeScenario, elapsed time.Duration) {
+	// Drain nodes sequentially according to stagger interval
+	for as.drainIndex < as.config.NodeCount && elapsed >= as.nextDrainAt {
+		// Find a Ready node to drain (skip already-drained nodes)
+		drained := false
+		for _, node := range se.nodes {
+			if node.Status == "Ready" && !se.isNodeDrained(node.Name) {
+				// Drain this node
+				node.Status = "NotReady"
+				node.NotReadyCause = CauseDrain
+				// Set NotReadyUntil far in the future so it stays drained
+				node.NotReadyUntil = se.clock.Add(se.config.Duration)
+
+				replacementTime := se.clock.Add(as.config.ReplacementDelay)
+				as.drainedNodes = append(as.drainedNodes, drainedNodeInfo{
+					nodeName:      node.Name,
+					drainTime:     se.clock,
+					replacementAt: replacementTime,
+				})
+				as.drainIndex++
+				as.nextDrainAt = as.config.TriggerAt + time.Duration(as.drainIndex)*as.config.StaggerInterval
+				drained = true
+				break
+			}
+		}
+		if !drained {
+			// No more Ready nodes available to drain
+			break
+		}
+	}
+
+	// Check for replacement nodes that should be introduced
+	for i := range as.drainedNodes {
+		di := &as.drainedNodes[i]
+		if di.replaced {
 			continue
 		}
+		if !se.clock.Before(di.replacementAt) {
+			// Create replacement node
+			replacementName := fmt.Sprintf("node-repl-%03d", se.nextNodeID)
+			se.nextNodeID++
 
-		// Drain nodes sequentially according to stagger interval
-		for as.drainIndex < as.config.NodeCount && elapsed >= as.nextDrainAt {
-			// Find a Ready node to drain (skip already-drained nodes)
-			drained := false
+			// Find the drained node to get its namespace
+			ns := "production" // default
 			for _, node := range se.nodes {
-				if node.Status == "Ready" && !se.isNodeDrained(node.Name) {
-					// Drain this node
-					node.Status = "NotReady"
-					node.NotReadyCause = "drain"
-					// Set NotReadyUntil far in the future so it stays drained
-					node.NotReadyUntil = se.clock.Add(se.config.Duration)
-
-					replacementTime := se.clock.Add(as.config.ReplacementDelay)
-					as.drainedNodes = append(as.drainedNodes, drainedNodeInfo{
-						nodeName:      node.Name,
-						drainTime:     se.clock,
-						replacementAt: replacementTime,
-					})
-					as.drainIndex++
-					as.nextDrainAt = as.config.TriggerAt + time.Duration(as.drainIndex)*as.config.StaggerInterval
-					drained = true
+				if node.Name == di.nodeName {
+					ns = node.Namespace
 					break
 				}
 			}
-			if !drained {
-				// No more Ready nodes available to drain
-				break
-			}
-		}
 
-		// Check for replacement nodes that should be introduced
-		for i := range as.drainedNodes {
-			di := &as.drainedNodes[i]
-			if di.replaced {
-				continue
+			newNode := &SimNode{
+				Name:         replacementName,
+				Namespace:    ns,
+				Profile:      ProfileNormal,
+				Status:       "Ready",
+				BaseInterval: se.config.BaseInterval,
 			}
-			if !se.clock.Before(di.replacementAt) {
-				// Create replacement node
-				replacementName := fmt.Sprintf("node-repl-%03d", se.nextNodeID)
-				se.nextNodeID++
-
-				// Find the drained node to get its namespace
-				ns := "production" // default
-				for _, node := range se.nodes {
-					if node.Name == di.nodeName {
-						ns = node.Namespace
-						break
-					}
-				}
 
-				newNode := &SimNode{
-					Name:         replacementName,
-					Namespace:    ns,
-					Profile:      ProfileNormal,
-					Status:       "Ready",
-					BaseInterval: se.config.BaseInterval,
-				}
-
-				// Generate initial burst of 3 leases within 5 seconds
-				se.generateBurstLeases(newNode, se.clock, 3, 5*time.Second)
+			// Generate initial burst of 3 leases within 5 seconds
+			se.generateBurstLeases(newNode, se.clock, 3, 5*time.Second)
 
-				// Generate correlated eBPF fork event for replacement node
-				se.generateReplacementForkEbpf(newNode, se.clock)
+			// Generate correlated eBPF fork event for replacement node
+			se.generateReplacementForkEbpf(newNode, se.clock)
 
-				se.nodes = append(se.nodes, newNode)
-				di.replaced = true
-				di.replacementName = replacementName
-			}
+			se.nodes = append(se.nodes, newNode)
+			di.replaced = true
+			di.replacementName = replacementName
 		}
 	}
 }
@@ -552,6 +714,57 @@ func (se *SimulationEngine) isNodeDrained(name string) bool {
 	return false
 }
 
+// applyNetworkPartition cuts a group of nodes off from the API server at
+// TriggerAt. Their leases stop and their kernels see retransmits, resets and
+// high RTTs until the partition heals after Duration, when each node catches
+// up with a burst of lease renewals.
+func (se *SimulationEngine) applyNetworkPartition(as *activeScenario, elapsed time.Duration) {
+	if !as.started {
+		if elapsed < as.config.TriggerAt {
+			return
+		}
+		as.started = true
+		as.partitionStart = se.clock
+		as.partitionEnd = se.clock.Add(as.config.Duration)
+		for _, node := range se.partitionGroup(as.config.NodeCount) {
+			node.Status = "NotReady"
+			node.NotReadyCause = CauseNetworkPartition
+			node.NotReadyUntil = as.partitionEnd
+			node.TransitionHistory = append(node.TransitionHistory, NotReadyTransition{
+				Timestamp: se.clock,
+				Cause:     CauseNetworkPartition,
+				Duration:  as.config.Duration,
+			})
+			se.generatePartitionEbpf(node, as.partitionStart, as.partitionEnd)
+			as.partitioned = append(as.partitioned, node)
+		}
+		return
+	}
+	if as.healed || se.clock.Before(as.partitionEnd) {
+		return
+	}
+	as.healed = true
+	for _, node := range as.partitioned {
+		node.Status = "Ready"
+		node.NotReadyCause = ""
+		se.generateBurstLeases(node, se.clock, 3, 5*time.Second)
+	}
+}
+
+// partitionGroup picks count Ready nodes that are adjacent in the node list,
+// as nodes behind one rack switch would be, starting at a random node.
+func (se *SimulationEngine) partitionGroup(count int) []*SimNode {
+	var group []*SimNode
+	start := se.rng.Intn(len(se.nodes))
+	for i := 0; i < len(se.nodes) && len(group) < count; i++ {
+		node := se.nodes[(start+i)%len(se.nodes)]
+		if node.Status == "Ready" {
+			group = append(group, node)
+		}
+	}
+	return group
+}
+
 // generateBurstLeases creates count lease events spread within the given window
 // starting from startTime. Used for replacement node initial burst.
 func (se *SimulationEngine) generateBurstLeases(node *SimNode, startTime time.Time, count int, window time.Duration) {
@@ -631,10 +844,17 @@ func (se *SimulationEngine) Run() (*SimulationResult, error) {
 	result.EbpfFiles = se.SegmentEbpfFiles()
 	result.Manifest = GenerateLeaseManifest(result.LeaseFiles)
 	result.EbpfManifest = GenerateEbpfManifest(result.EbpfFiles)
+	result.GroundTruth = se.GroundTruth()
 
 	return result, nil
 }
 
+// Nodes returns the simulated nodes, including replacements, with their
+// lease and NotReady transition histories.
+func (se *SimulationEngine) Nodes() []*SimNode {
+	return se.nodes
+}
+
 // hadNotReadyTransition checks if a node experienced any NotReady transition
 // by looking for gaps in lease history that exceed 2x the base interval.
 func hadNotReadyTransition(node *SimNode, baseInterval time.Duration) bool {
@@ -647,10 +867,15 @@ func hadNotReadyTransition(node *SimNode, baseInterval time.Duration) bool {
 	return false
 }
 
-// generateCorrelatedEbpf produces an eBPF event correlated with a NotReady cause.
+// generateCorrelatedEbpf produces the eBPF events correlated with a NotReady
+// cause at the moment of the transition.
 // - kubelet_restart → syscall "exit", comm "kubelet", 0–2s before the lease gap
 // - oom_kill → syscall "kill", comm "oom_reaper", within 1s of transition
-// - Other causes do not produce correlated eBPF events.
+// - network_blip → 1–4 TCP retransmits within 2s after the transition
+// - disk_pressure → 1–3 containerd writes failing with ENOSPC within 2s before
+// - dns_outage → 1–3 DNS queries timing out within 2s after the transition
+// The escalation before the lease gap of causes with a lead-up is produced
+// by advanceDegradation.
 func (se *SimulationEngine) generateCorrelatedEbpf(node *SimNode, cause NotReadyCause) {
 	switch cause {
 	case CauseKubeletRestart:
@@ -685,8 +910,24 @@ func (se *SimulationEngine) generateCorrelatedEbpf(node *SimNode, cause NotReady
 			NodeName:   node.Name,
 			Namespace:  node.Namespace,
 		})
+	case CauseNetworkBlip:
+		for i, n := 0, 1+se.rng.Intn(4); i < n; i++ {
+			e := se.simEvent(node, se.clock.Add(se.jitterWithin(2*time.Second)), "kubelet", "tcp_retransmit_skb")
+			e.NetEventType = "retransmit"
+			node.EbpfEvents = append(node.EbpfEvents, e)
+		}
+	case CauseDiskPressure:
+		for i, n := 0, 1+se.rng.Intn(3); i < n; i++ {
+			e := se.simEvent(node, se.clock.Add(-se.jitterWithin(2*time.Second)), "containerd", "pwrite64")
+			e.ReturnValue = -errnoENOSPC
+			node.EbpfEvents = append(node.EbpfEvents, e)
+		}
+	case CauseDNSOutage:
+		for i, n := 0, 1+se.rng.Intn(3); i < n; i++ {
+			se.generateDNSQuery(node, se.clock.Add(se.jitterWithin(2*time.Second)), dnsTimeoutNs, true)
+		}
 	default:
-		// network_blip and disk_pressure don't produce correlated eBPF events
+		// Causes with a lead-up have already produced their signature
 	}
 }
 
@@ -721,6 +962,40 @@ func (se *SimulationEngine) generateReplacementForkEbpf(node *SimNode, firstLeas
 	})
 }
 
+// generatePartitionEbpf produces the network events of a node whose
+// connections to the API server fail between start and end:
+// - 5–10 TCP retransmits within the first 5 seconds
+// - a high-RTT sample every 10 seconds
+// - 1–3 connection resets
+func (se *SimulationEngine) generatePartitionEbpf(node *SimNode, start, end time.Time) {
+	span := end.Sub(start)
+	emit := func(ts time.Time, syscall, netEventType string, rttUs uint32) {
+		node.EbpfEvents = append(node.EbpfEvents, SimEbpfEvent{
+			Timestamp:    ts,
+			PID:          uint32(se.rng.Intn(32000) + 1),
+			PPID:         uint32(se.rng.Intn(32000) + 1),
+			Comm:         "kubelet",
+			Syscall:      syscall,
+			CgroupPath:   fmt.Sprintf("/sys/fs/cgroup/kubepods/%s", node.Name),
+			NodeName:     node.Name,
+			Namespace:    node.Namespace,
+			NetEventType: netEventType,
+			RTTUs:        rttUs,
+		})
+	}
+
+	burst := min(5*time.Second, span)
+	for i, n := 0, 5+se.rng.Intn(6); i < n; i++ {
+		emit(start.Add(time.Duration(se.rng.Int63n(int64(burst)))), "tcp_retransmit_skb", "retransmit", 0)
+	}
+	for ts := start; ts.Before(end); ts = ts.Add(10 * time.Second) {
+		emit(ts, "tcp_rcv_established", "rtt_high", uint32(rttHighUs+1+se.rng.Intn(2_500_000)))
+	}
+	for i, n := 0, 1+se.rng.Intn(3); i < n; i++ {
+		emit(start.Add(time.Duration(se.rng.Int63n(int64(span)))), "tcp_send_reset", "reset", 0)
+	}
+}
+
 // Feature: realistic-data-and-visualizations
 // Task 6.1: File segmentation — split simulation output into time-windowed JSON files
 
@@ -0,0 +1,29 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadSimulationConfig reads a YAML simulation file and validates it with
+// ValidateConfig, so the returned config has its defaults applied. Unknown
+// fields are rejected so that a typo can't silently change a scenario.
+func LoadSimulationConfig(filename string) (SimulationConfig, error) {
+	var cfg SimulationConfig
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return cfg, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	if err := ValidateConfig(&cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", filename, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,109 @@
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSimulationConfig(t *testing.T, body string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "sim.yaml")
+	if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadSimulationConfig(t *testing.T) {
+	file := writeSimulationConfig(t, `
+nodeCount: 12
+duration: 90m
+seed: 3
+namespaceRatios:
+  kube-system: 1
+  batch: 3
+namespaceProfiles:
+  batch: drifting
+maxDriftIncrease: 20s
+scenarios:
+  - type: network_partition
+    triggerAt: 10m
+    nodeCount: 4
+  - type: rolling_deployment
+    triggerAt: 1h
+    nodeCount: 2
+    staggerInterval: 1m
+`)
+	cfg, err := LoadSimulationConfig(file)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.NodeCount != 12 || cfg.Duration != 90*time.Minute || cfg.Seed != 3 || cfg.MaxDriftIncrease != 20*time.Second {
+		t.Errorf("config = %+v", cfg)
+	}
+	if cfg.NamespaceRatios["batch"] != 0.75 || cfg.NamespaceProfiles["batch"] != ProfileDrifting {
+		t.Errorf("ratios %v, profiles %v", cfg.NamespaceRatios, cfg.NamespaceProfiles)
+	}
+	if cfg.BaseInterval != 10*time.Second {
+		t.Errorf("BaseInterval = %v, want the default", cfg.BaseInterval)
+	}
+
+	// Scenarios keep their order and get their defaults
+	if len(cfg.Scenarios) != 2 {
+		t.Fatalf("Scenarios = %+v", cfg.Scenarios)
+	}
+	partition, rolling := cfg.Scenarios[0], cfg.Scenarios[1]
+	if partition.Type != "network_partition" || partition.TriggerAt != 10*time.Minute || partition.Duration != defaultPartitionDuration {
+		t.Errorf("partition = %+v", partition)
+	}
+	if rolling.Type != "rolling_deployment" || rolling.StaggerInterval != time.Minute || rolling.ReplacementDelay != defaultReplacementDelay {
+		t.Errorf("rolling = %+v", rolling)
+	}
+}
+
+func TestLoadSimulationConfig_Invalid(t *testing.T) {
+	for name, tt := range map[string]struct{ body, want string }{
+		"unknown field":      {"nodeCount: 5\nduration: 1h\nnodes: 5\n", "nodes"},
+		"bad duration":       {"nodeCount: 5\nduration: an hour\n", "parse"},
+		"no nodes":           {"duration: 1h\n", "node count"},
+		"unknown profile":    {"nodeCount: 5\nduration: 1h\nnamespaceProfiles: {batch: flaky}\n", "flaky"},
+		"negative ratio":     {"nodeCount: 5\nduration: 1h\nnamespaceRatios: {a: 1, b: -1}\n", "ratio"},
+		"unknown scenario":   {"nodeCount: 5\nduration: 1h\nscenarios: [{type: meteor, nodeCount: 1}]\n", "meteor"},
+		"trigger past end":   {"nodeCount: 5\nduration: 1h\nscenarios: [{type: network_partition, triggerAt: 2h, nodeCount: 1}]\n", "trigger at"},
+		"scenario no nodes":  {"nodeCount: 5\nduration: 1h\nscenarios: [{type: rolling_deployment}]\n", "node count"},
+		"negative stagger":   {"nodeCount: 5\nduration: 1h\nscenarios: [{type: rolling_deployment, nodeCount: 1, staggerInterval: -1s}]\n", "stagger interval"},
+		"negative partition": {"nodeCount: 5\nduration: 1h\nscenarios: [{type: network_partition, nodeCount: 1, duration: -1m}]\n", "duration"},
+	} {
+		_, err := LoadSimulationConfig(writeSimulationConfig(t, tt.body))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want mention of %q", name, err, tt.want)
+		}
+	}
+}
+
+// TestLoadSimulationConfig_Examples verifies the example files under
+// docs/simulations load and run.
+func TestLoadSimulationConfig_Examples(t *testing.T) {
+	files, err := filepath.Glob("../../docs/simulations/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example simulations: %v", err)
+	}
+	for _, file := range files {
+		cfg, err := LoadSimulationConfig(file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		engine, err := NewSimulationEngine(cfg)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if _, err := engine.Run(); err != nil {
+			t.Errorf("%s: run: %v", file, err)
+		}
+	}
+}
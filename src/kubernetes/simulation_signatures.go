@@ -0,0 +1,169 @@
+package kubernetes
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// degradation is a failure building up on a node that is still Ready: its
+// kernel signature escalates from start until the node goes NotReady at
+// notReadyAt.
+type degradation struct {
+	cause      NotReadyCause
+	start      time.Time
+	notReadyAt time.Time
+	duration   time.Duration // how long the node then stays NotReady
+	nextSample time.Time
+	cpuUsageNs uint64 // cumulative CPU time of a throttled cgroup
+}
+
+const (
+	minLeadUp            = 30 * time.Second
+	maxLeadUp            = 60 * time.Second
+	leadUpSampleInterval = 5 * time.Second
+
+	// Thresholds above which the agent flags syscalls and VFS operations as
+	// slow, at its defaults
+	slowSyscallNs = 1_000_000_000
+	slowIONs      = 100_000_000
+	// How long a resolver waits before a query times out
+	dnsTimeoutNs = 5_000_000_000
+	errnoENOSPC  = 28
+
+	// The cluster DNS service, and the name kubelet reaches the API server by
+	clusterDNSAddr  = "10.96.0.10"
+	apiServerDomain = "api.cluster.local"
+	// containerd's metadata database, written on every container operation
+	containerdMetaDB = "/var/lib/containerd/io.containerd.metadata.v1.bolt/meta.db"
+
+	// The kubepods cgroup's limits: memory above memoryPressureRatio of the
+	// limit is reported as under pressure
+	kubepodsMemoryLimit = 8 << 30
+	kubepodsCPUs        = 4
+	memoryPressureRatio = 0.8
+)
+
+// hasLeadUp reports whether a cause degrades the node before its lease gap.
+// The others produce their signature at the transition.
+func hasLeadUp(cause NotReadyCause) bool {
+	switch cause {
+	case CauseOOMKill, CauseDNSOutage, CauseSlowDisk, CauseCPUThrottling, CauseContainerdHang:
+		return true
+	}
+	return false
+}
+
+// advanceDegradation moves a degrading node on by one tick: every
+// leadUpSampleInterval it produces the next, more severe sample of the
+// cause's signature, and at notReadyAt it transitions the node to NotReady.
+// - oom_kill → cgroup memory climbing from 70% to 99% of the limit
+// - dns_outage → API server lookups slowing from 1ms to 2s
+// - slow_disk → containerd metadata writes slowing from 2ms to 3s
+// - cpu_throttling → a cgroup at its CPU limit and kubelet syscalls slowing
+// from 20ms to 3s
+// - containerd_hang → containerd futex waits slowing from 20ms to 5s
+func (se *SimulationEngine) advanceDegradation(node *SimNode) {
+	d := node.degradation
+	if !se.clock.Before(d.notReadyAt) {
+		node.degradation = nil
+		se.markNotReady(node, d.cause, d.duration)
+		return
+	}
+	if se.clock.Before(d.nextSample) {
+		return
+	}
+	d.nextSample = d.nextSample.Add(leadUpSampleInterval)
+	progress := float64(se.clock.Sub(d.start)) / float64(d.notReadyAt.Sub(d.start))
+
+	switch d.cause {
+	case CauseOOMKill:
+		usage := uint64(kubepodsMemoryLimit * (0.70 + 0.29*progress))
+		d.cpuUsageNs += uint64(se.rng.Int63n(int64(kubepodsCPUs * leadUpSampleInterval / 2)))
+		se.generateCgroupSample(node, d.cpuUsageNs, usage)
+	case CauseDNSOutage:
+		se.generateDNSQuery(node, se.clock, se.escalate(1_000_000, 2_000_000_000, progress), false)
+	case CauseSlowDisk:
+		latency := se.escalate(2_000_000, 3_000_000_000, progress)
+		e := se.simEvent(node, se.clock, "containerd", "vfs_write")
+		e.EventType = "filesystem_io"
+		e.FilePath = containerdMetaDB
+		e.IOLatencyNs = latency
+		e.BytesXfer = 4096
+		e.SlowIO = latency > slowIONs
+		e.IOOpType = "write"
+		node.EbpfEvents = append(node.EbpfEvents, e)
+	case CauseCPUThrottling:
+		d.cpuUsageNs += uint64(kubepodsCPUs * leadUpSampleInterval)
+		se.generateCgroupSample(node, d.cpuUsageNs, kubepodsMemoryLimit/2)
+		se.generateSlowSyscall(node, "kubelet", "epoll_wait", se.escalate(20_000_000, 3_000_000_000, progress))
+	case CauseContainerdHang:
+		se.generateSlowSyscall(node, "containerd", "futex", se.escalate(20_000_000, 5_000_000_000, progress))
+	}
+}
+
+// escalate returns a value growing geometrically from from to to as
+// progress goes from 0 to 1, with 5% jitter.
+func (se *SimulationEngine) escalate(from, to uint64, progress float64) uint64 {
+	v := float64(from) * math.Pow(float64(to)/float64(from), progress)
+	return uint64(v * (0.95 + 0.1*se.rng.Float64()))
+}
+
+// jitterWithin returns a random offset in [0, d].
+func (se *SimulationEngine) jitterWithin(d time.Duration) time.Duration {
+	return time.Duration(se.rng.Int63n(int64(d) + 1))
+}
+
+// simEvent returns an eBPF event of comm on node with fresh PIDs.
+func (se *SimulationEngine) simEvent(node *SimNode, ts time.Time, comm, syscall string) SimEbpfEvent {
+	return SimEbpfEvent{
+		Timestamp:  ts,
+		PID:        uint32(se.rng.Intn(32000) + 1),
+		PPID:       uint32(se.rng.Intn(32000) + 1),
+		Comm:       comm,
+		Syscall:    syscall,
+		CgroupPath: fmt.Sprintf("/sys/fs/cgroup/kubepods/%s", node.Name),
+		NodeName:   node.Name,
+		Namespace:  node.Namespace,
+	}
+}
+
+// generateSlowSyscall produces a timed syscall, flagged slow above the
+// agent's threshold.
+func (se *SimulationEngine) generateSlowSyscall(node *SimNode, comm, syscall string, latencyNs uint64) {
+	e := se.simEvent(node, se.clock, comm, syscall)
+	e.LatencyNs = latencyNs
+	e.SlowSyscall = latencyNs >= slowSyscallNs
+	node.EbpfEvents = append(node.EbpfEvents, e)
+}
+
+// generateCgroupSample produces a resource sample of the node's kubepods
+// cgroup.
+func (se *SimulationEngine) generateCgroupSample(node *SimNode, cpuUsageNs, memoryUsage uint64) {
+	e := se.simEvent(node, se.clock, "kubelet", "cgroup_stat")
+	e.EventType = "cgroup_resource"
+	e.CPUUsageNs = cpuUsageNs
+	e.MemoryUsageBytes = memoryUsage
+	e.MemoryLimitBytes = kubepodsMemoryLimit
+	e.MemoryPressure = float64(memoryUsage) > memoryPressureRatio*kubepodsMemoryLimit
+	node.EbpfEvents = append(node.EbpfEvents, e)
+}
+
+// generateDNSQuery produces kubelet's lookup of the API server at ts and the
+// audited UDP connection to the cluster DNS service that carries it.
+func (se *SimulationEngine) generateDNSQuery(node *SimNode, ts time.Time, latencyNs uint64, timedOut bool) {
+	audit := se.simEvent(node, ts, "kubelet", "connect")
+	audit.EventType = "network_audit"
+	audit.AuditDstAddr = clusterDNSAddr
+	audit.AuditDstPort = 53
+	audit.AuditProtocol = "udp"
+
+	query := se.simEvent(node, ts, "kubelet", "udp_sendmsg")
+	query.PID = audit.PID
+	query.PPID = audit.PPID
+	query.EventType = "dns_resolution"
+	query.Domain = apiServerDomain
+	query.DNSLatencyNs = latencyNs
+	query.TimedOut = timedOut
+	node.EbpfEvents = append(node.EbpfEvents, audit, query)
+}
@@ -0,0 +1,144 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"pgregory.net/rapid"
+)
+
+// Feature: realistic-data-and-visualizations, Property 28: Kernel signatures escalate before the lease gap
+func TestProperty28_LeadUpSignatures(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		nodeCount := rapid.IntRange(10, 50).Draw(t, "nodeCount")
+		seed := rapid.Int64().Draw(t, "seed")
+
+		engine, err := NewSimulationEngine(SimulationConfig{
+			NodeCount: nodeCount,
+			Duration:  30 * time.Minute,
+			Seed:      seed,
+			NamespaceRatios: map[string]float64{
+				"volatile-ns": 1.0,
+			},
+			NamespaceProfiles: map[string]NodeHealthProfile{
+				"volatile-ns": ProfileVolatile,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create engine: %v", err)
+		}
+		if _, err := engine.Run(); err != nil {
+			t.Fatalf("simulation failed: %v", err)
+		}
+
+		for _, node := range engine.nodes {
+			var recovered time.Time
+			for _, tr := range node.TransitionHistory {
+				from := tr.Timestamp.Add(-maxLeadUp)
+				if from.Before(recovered) {
+					from = recovered
+				}
+				recovered = tr.Timestamp.Add(tr.Duration)
+				if !hasLeadUp(tr.Cause) {
+					continue
+				}
+
+				// The signature's samples before the transition, as the
+				// value that escalates
+				var samples []uint64
+				var slow, audits int
+				for _, evt := range node.EbpfEvents {
+					if evt.Timestamp.Before(from) || !evt.Timestamp.Before(tr.Timestamp) {
+						continue
+					}
+					switch {
+					case tr.Cause == CauseOOMKill && evt.EventType == "cgroup_resource":
+						samples = append(samples, evt.MemoryUsageBytes)
+						if evt.MemoryPressure {
+							slow++
+						}
+					case tr.Cause == CauseDNSOutage && evt.EventType == "dns_resolution":
+						samples = append(samples, evt.DNSLatencyNs)
+						if evt.TimedOut {
+							t.Fatalf("node %q: DNS query timed out before the transition", node.Name)
+						}
+					case tr.Cause == CauseDNSOutage && evt.EventType == "network_audit":
+						if evt.AuditDstAddr != clusterDNSAddr || evt.AuditDstPort != 53 || evt.AuditProtocol != "udp" {
+							t.Fatalf("node %q: DNS query audited as %+v", node.Name, evt)
+						}
+						audits++
+					case tr.Cause == CauseSlowDisk && evt.EventType == "filesystem_io":
+						samples = append(samples, evt.IOLatencyNs)
+						if evt.SlowIO {
+							slow++
+						}
+					case tr.Cause == CauseCPUThrottling && evt.Comm == "kubelet" && evt.LatencyNs > 0,
+						tr.Cause == CauseContainerdHang && evt.Comm == "containerd" && evt.LatencyNs > 0:
+						samples = append(samples, evt.LatencyNs)
+						if evt.SlowSyscall {
+							slow++
+						}
+					}
+				}
+
+				if len(samples) < int(minLeadUp/leadUpSampleInterval) {
+					t.Fatalf("node %q: %s at %v has %d samples before it", node.Name, tr.Cause, tr.Timestamp, len(samples))
+				}
+				for i := 1; i < len(samples); i++ {
+					if samples[i] <= samples[i-1] {
+						t.Fatalf("node %q: %s signature does not escalate: %v", node.Name, tr.Cause, samples)
+					}
+				}
+				switch tr.Cause {
+				case CauseDNSOutage:
+					if audits != len(samples) {
+						t.Fatalf("node %q: %d DNS queries but %d audited connections", node.Name, len(samples), audits)
+					}
+				case CauseOOMKill:
+					if slow < 2 {
+						t.Fatalf("node %q: %d memory pressure samples before the OOM kill", node.Name, slow)
+					}
+				default:
+					if slow == 0 {
+						t.Fatalf("node %q: %s signature never crosses the slow threshold: %v", node.Name, tr.Cause, samples)
+					}
+				}
+			}
+		}
+	})
+}
+
+// TestCorrelatedEbpfAtTransition checks the signatures produced when a node
+// goes NotReady without a lead-up, and the DNS timeouts that end an outage.
+func TestCorrelatedEbpfAtTransition(t *testing.T) {
+	engine, err := NewSimulationEngine(SimulationConfig{NodeCount: 1, Duration: time.Minute, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := engine.nodes[0]
+	for _, tc := range []struct {
+		cause NotReadyCause
+		check func(SimEbpfEvent) bool
+	}{
+		{CauseNetworkBlip, func(e SimEbpfEvent) bool {
+			return e.NetEventType == "retransmit" && !e.Timestamp.Before(engine.clock) && e.Timestamp.Sub(engine.clock) <= 2*time.Second
+		}},
+		{CauseDiskPressure, func(e SimEbpfEvent) bool {
+			return e.Comm == "containerd" && e.ReturnValue == -errnoENOSPC && !e.Timestamp.After(engine.clock)
+		}},
+		{CauseDNSOutage, func(e SimEbpfEvent) bool {
+			return e.EventType == "network_audit" || e.EventType == "dns_resolution" && e.TimedOut && e.DNSLatencyNs == dnsTimeoutNs
+		}},
+	} {
+		node.EbpfEvents = nil
+		engine.generateCorrelatedEbpf(node, tc.cause)
+		if len(node.EbpfEvents) == 0 {
+			t.Errorf("%s: no events", tc.cause)
+		}
+		for _, e := range node.EbpfEvents {
+			if !tc.check(e) {
+				t.Errorf("%s: unexpected event %+v", tc.cause, e)
+			}
+		}
+	}
+}
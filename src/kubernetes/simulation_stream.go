@@ -0,0 +1,104 @@
+package kubernetes
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// SimTick holds the events of one simulated second. Events generated ahead
+// of the simulated clock, such as a replacement node's burst leases, are
+// held back until the tick their timestamp falls in, so each tick only
+// carries events that have already happened at Time.
+type SimTick struct {
+	Time    time.Time
+	Elapsed time.Duration // since the start of the simulation
+	Leases  []LeaseEvent
+	Ebpf    []SimEbpfEvent
+}
+
+// Step advances the simulation by one second and returns that second's
+// events, ordered by timestamp. It returns false once the simulation is
+// over; the last tick also carries any events timestamped past the end.
+// An engine is either stepped, directly or through Stream, or Run, not both.
+func (se *SimulationEngine) Step() (SimTick, bool) {
+	totalSeconds := int(se.config.Duration.Seconds())
+	if se.step > totalSeconds {
+		return SimTick{}, false
+	}
+	if se.step == 0 {
+		se.startTime = se.clock
+		se.leaseCursor = make(map[string]int)
+		se.ebpfCursor = make(map[string]int)
+	}
+
+	elapsed := time.Duration(se.step) * time.Second
+	se.clock = se.startTime.Add(elapsed)
+	se.tick(elapsed)
+	se.step++
+
+	for _, node := range se.nodes {
+		se.pendingLease = append(se.pendingLease, node.LeaseHistory[se.leaseCursor[node.Name]:]...)
+		se.leaseCursor[node.Name] = len(node.LeaseHistory)
+		se.pendingEbpf = append(se.pendingEbpf, node.EbpfEvents[se.ebpfCursor[node.Name]:]...)
+		se.ebpfCursor[node.Name] = len(node.EbpfEvents)
+	}
+
+	last := se.step > totalSeconds
+	t := SimTick{Time: se.clock, Elapsed: elapsed}
+	t.Leases, se.pendingLease = releaseDue(se.pendingLease, se.clock, last, func(l LeaseEvent) time.Time { return l.Timestamp })
+	t.Ebpf, se.pendingEbpf = releaseDue(se.pendingEbpf, se.clock, last, func(e SimEbpfEvent) time.Time { return e.Timestamp })
+	return t, true
+}
+
+// releaseDue splits pending into the events due at now, sorted by timestamp,
+// and those still ahead of it. With all set every event is due.
+func releaseDue[E any](pending []E, now time.Time, all bool, ts func(E) time.Time) (due, ahead []E) {
+	for _, e := range pending {
+		if all || !ts(e).After(now) {
+			due = append(due, e)
+		} else {
+			ahead = append(ahead, e)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return ts(due[i]).Before(ts(due[j])) })
+	return due, ahead
+}
+
+// Stream steps the simulation in the background and sends each tick on the
+// returned channel, which is closed when the simulation ends or ctx is
+// cancelled. compression is the number of simulated seconds played per
+// wall-clock second: 1 replays in real time, 60 plays an hour in a minute,
+// and 0 or less runs as fast as the receiver consumes ticks.
+func (se *SimulationEngine) Stream(ctx context.Context, compression float64) <-chan SimTick {
+	ticks := make(chan SimTick)
+	go func() {
+		defer close(ticks)
+		var pace <-chan time.Time
+		if compression > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / compression))
+			defer ticker.Stop()
+			pace = ticker.C
+		}
+		for {
+			t, ok := se.Step()
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case ticks <- t:
+			}
+			if pace == nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-pace:
+			}
+		}
+	}()
+	return ticks
+}
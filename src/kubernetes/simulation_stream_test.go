@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pgregory.net/rapid"
+)
+
+func streamTestConfig(seed int64, duration time.Duration) SimulationConfig {
+	return SimulationConfig{
+		NodeCount: 12,
+		Duration:  duration,
+		Seed:      seed,
+		NamespaceRatios: map[string]float64{
+			"kube-system": 0.5,
+			"staging":     0.5,
+		},
+		NamespaceProfiles: map[string]NodeHealthProfile{
+			"kube-system": ProfileStable,
+			"staging":     ProfileVolatile,
+		},
+		Scenarios: []ScenarioConfig{
+			{Type: "rolling_deployment", TriggerAt: time.Minute, NodeCount: 2},
+			{Type: "network_partition", TriggerAt: 2 * time.Minute, NodeCount: 3, Duration: time.Minute},
+		},
+	}
+}
+
+// Feature: realistic-data-and-visualizations, Property 27: Stepping yields the events of a full run, none ahead of the clock
+func TestProperty27_StepMatchesRun(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		seed := rapid.Int64Range(1, 1<<40).Draw(t, "seed")
+		duration := time.Duration(rapid.IntRange(200, 400).Draw(t, "durationSec")) * time.Second
+
+		ran, err := NewSimulationEngine(streamTestConfig(seed, duration))
+		if err != nil {
+			t.Fatalf("failed to create engine: %v", err)
+		}
+		result, err := ran.Run()
+		if err != nil {
+			t.Fatalf("simulation failed: %v", err)
+		}
+		stepped, err := NewSimulationEngine(streamTestConfig(seed, duration))
+		if err != nil {
+			t.Fatalf("failed to create engine: %v", err)
+		}
+
+		var leases, ebpf, ticks int
+		var lastLease time.Time
+		for {
+			tick, ok := stepped.Step()
+			if !ok {
+				break
+			}
+			ticks++
+			final := tick.Elapsed == duration
+			for _, l := range tick.Leases {
+				if l.Timestamp.After(tick.Time) && !final {
+					t.Fatalf("lease at %v released at %v", l.Timestamp, tick.Time)
+				}
+				if l.Timestamp.Before(lastLease) {
+					t.Fatalf("lease at %v released after one at %v", l.Timestamp, lastLease)
+				}
+				lastLease = l.Timestamp
+			}
+			for _, e := range tick.Ebpf {
+				if e.Timestamp.After(tick.Time) && !final {
+					t.Fatalf("eBPF event at %v released at %v", e.Timestamp, tick.Time)
+				}
+			}
+			leases += len(tick.Leases)
+			ebpf += len(tick.Ebpf)
+		}
+		if want := int(duration.Seconds()) + 1; ticks != want {
+			t.Fatalf("got %d ticks, want %d", ticks, want)
+		}
+		if leases != result.Stats.TotalLeases || ebpf != result.Stats.TotalEbpfEvents {
+			t.Fatalf("stepped %d leases and %d eBPF events, Run produced %d and %d",
+				leases, ebpf, result.Stats.TotalLeases, result.Stats.TotalEbpfEvents)
+		}
+		if _, ok := stepped.Step(); ok {
+			t.Fatal("Step returned a tick after the end of the simulation")
+		}
+	})
+}
+
+func TestStream_Compression(t *testing.T) {
+	config := streamTestConfig(1, 30*time.Second)
+	config.Scenarios = nil
+	engine, err := NewSimulationEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var ticks int
+	for range engine.Stream(context.Background(), 100) {
+		ticks++
+	}
+	if ticks != 31 {
+		t.Errorf("got %d ticks, want 31", ticks)
+	}
+	// 30 simulated seconds at 100x take 300ms
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("stream took %v, want about 300ms", elapsed)
+	}
+}
+
+func TestStream_Cancel(t *testing.T) {
+	engine, err := NewSimulationEngine(streamTestConfig(1, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := engine.Stream(ctx, 0)
+	for i := 0; i < 10; i++ {
+		<-ticks
+	}
+	cancel()
+	for range ticks {
+	}
+	if engine.step > 12 {
+		t.Errorf("simulation kept running after cancel, at step %d", engine.step)
+	}
+}
@@ -1347,6 +1347,10 @@ func TestProperty2_NotReadyCauseValidity(t *testing.T) {
 			CauseOOMKill:        true,
 			CauseDiskPressure:   true,
 			CauseKubeletRestart: true,
+			CauseDNSOutage:      true,
+			CauseSlowDisk:       true,
+			CauseCPUThrottling:  true,
+			CauseContainerdHang: true,
 		}
 
 		for _, node := range engine.nodes {
@@ -1876,3 +1880,195 @@ func TestFileOutput_LeasesByNamespaceFormat(t *testing.T) {
 		t.Fatalf("round-trip namespace count mismatch: %d vs %d", len(roundTripped), len(lf.Data))
 	}
 }
+
+// --- Property-Based Tests: Network Partition Scenario ---
+
+// runPartitionScenario runs a cluster of stable nodes with a single
+// network_partition scenario triggered after one minute.
+func runPartitionScenario(t *rapid.T, nodeCount, partitionCount int, partition time.Duration, seed int64) *SimulationEngine {
+	engine, err := NewSimulationEngine(SimulationConfig{
+		NodeCount: nodeCount,
+		Duration:  time.Minute + partition + 2*time.Minute,
+		Seed:      seed,
+		NamespaceRatios: map[string]float64{
+			"default": 1.0,
+		},
+		NamespaceProfiles: map[string]NodeHealthProfile{
+			"default": ProfileStable, // stable so only the partition stops leases
+		},
+		Scenarios: []ScenarioConfig{
+			{
+				Type:      "network_partition",
+				TriggerAt: time.Minute,
+				NodeCount: partitionCount,
+				Duration:  partition,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	if _, err := engine.Run(); err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	return engine
+}
+
+// Feature: realistic-data-and-visualizations, Property 24: Partition stops leases and heals with a catch-up burst
+func TestProperty24_PartitionStopsLeasesAndHealsWithBurst(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		nodeCount := rapid.IntRange(10, 30).Draw(t, "nodeCount")
+		partitionCount := rapid.IntRange(2, 6).Draw(t, "partitionCount")
+		partitionSec := rapid.IntRange(30, 300).Draw(t, "partitionSec")
+		seed := rapid.Int64().Draw(t, "seed")
+
+		partition := time.Duration(partitionSec) * time.Second
+		engine := runPartitionScenario(t, nodeCount, partitionCount, partition, seed)
+
+		as := engine.activeScenarios[0]
+		if len(as.partitioned) != partitionCount {
+			t.Fatalf("partitioned %d nodes, expected %d", len(as.partitioned), partitionCount)
+		}
+		if !as.healed || as.partitionEnd.Sub(as.partitionStart) != partition {
+			t.Fatalf("partition [%v, %v) healed=%v, expected %v long and healed",
+				as.partitionStart, as.partitionEnd, as.healed, partition)
+		}
+
+		for _, node := range as.partitioned {
+			burst := 0
+			for _, lease := range node.LeaseHistory {
+				if lease.Timestamp.After(as.partitionStart) && lease.Timestamp.Before(as.partitionEnd) {
+					t.Fatalf("partitioned node %q has lease at %v within partition [%v, %v)",
+						node.Name, lease.Timestamp, as.partitionStart, as.partitionEnd)
+				}
+				if !lease.Timestamp.Before(as.partitionEnd) && lease.Timestamp.Sub(as.partitionEnd) <= 5*time.Second {
+					burst++
+				}
+			}
+			if burst < 3 {
+				t.Fatalf("partitioned node %q has %d leases within 5s of healing, expected at least 3", node.Name, burst)
+			}
+
+			last := node.TransitionHistory[len(node.TransitionHistory)-1]
+			if last.Cause != CauseNetworkPartition || !last.Timestamp.Equal(as.partitionStart) || last.Duration != partition {
+				t.Fatalf("partitioned node %q: last transition %+v, expected network_partition at %v for %v",
+					node.Name, last, as.partitionStart, partition)
+			}
+			if node.Status != "Ready" {
+				t.Fatalf("partitioned node %q is %s after healing", node.Name, node.Status)
+			}
+		}
+	})
+}
+
+// Feature: realistic-data-and-visualizations, Property 25: Correlated network eBPF events during a partition
+func TestProperty25_CorrelatedNetworkEbpfDuringPartition(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		nodeCount := rapid.IntRange(10, 30).Draw(t, "nodeCount")
+		partitionCount := rapid.IntRange(2, 6).Draw(t, "partitionCount")
+		partitionSec := rapid.IntRange(30, 300).Draw(t, "partitionSec")
+		seed := rapid.Int64().Draw(t, "seed")
+
+		partition := time.Duration(partitionSec) * time.Second
+		engine := runPartitionScenario(t, nodeCount, partitionCount, partition, seed)
+		as := engine.activeScenarios[0]
+
+		partitioned := make(map[string]bool)
+		for _, node := range as.partitioned {
+			partitioned[node.Name] = true
+		}
+
+		syscalls := map[string]string{
+			"retransmit": "tcp_retransmit_skb",
+			"reset":      "tcp_send_reset",
+			"rtt_high":   "tcp_rcv_established",
+		}
+		for _, node := range engine.nodes {
+			counts := make(map[string]int)
+			for _, evt := range node.EbpfEvents {
+				if evt.NetEventType == "" {
+					continue
+				}
+				if !partitioned[node.Name] {
+					t.Fatalf("node %q outside the partition has network event %+v", node.Name, evt)
+				}
+				if evt.Timestamp.Before(as.partitionStart) || !evt.Timestamp.Before(as.partitionEnd) {
+					t.Fatalf("node %q: %s event at %v outside partition [%v, %v)",
+						node.Name, evt.NetEventType, evt.Timestamp, as.partitionStart, as.partitionEnd)
+				}
+				if evt.Syscall != syscalls[evt.NetEventType] {
+					t.Fatalf("node %q: %s event has syscall %q", node.Name, evt.NetEventType, evt.Syscall)
+				}
+				switch evt.NetEventType {
+				case "retransmit":
+					if evt.Timestamp.Sub(as.partitionStart) >= 5*time.Second {
+						t.Fatalf("node %q: retransmit %v after the partition started", node.Name, evt.Timestamp.Sub(as.partitionStart))
+					}
+				case "rtt_high":
+					if evt.RTTUs <= rttHighUs {
+						t.Fatalf("node %q: rtt_high event with RTT %dus", node.Name, evt.RTTUs)
+					}
+				}
+				counts[evt.NetEventType]++
+			}
+			if !partitioned[node.Name] {
+				continue
+			}
+			wantRTT := int((partition + 10*time.Second - 1) / (10 * time.Second))
+			if counts["retransmit"] < 5 || counts["retransmit"] > 10 || counts["reset"] < 1 || counts["reset"] > 3 || counts["rtt_high"] != wantRTT {
+				t.Fatalf("node %q: network events %v, expected 5–10 retransmits, 1–3 resets and %d rtt_high",
+					node.Name, counts, wantRTT)
+			}
+		}
+	})
+}
+
+// Feature: realistic-data-and-visualizations, Property 26: Partitioned nodes are one adjacent group cut off at once
+func TestProperty26_PartitionGroupIsAdjacent(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		nodeCount := rapid.IntRange(10, 30).Draw(t, "nodeCount")
+		partitionCount := rapid.IntRange(1, 12).Draw(t, "partitionCount")
+		seed := rapid.Int64().Draw(t, "seed")
+
+		engine := runPartitionScenario(t, nodeCount, partitionCount, time.Minute, seed)
+		as := engine.activeScenarios[0]
+
+		index := make(map[string]int)
+		for i, node := range engine.nodes {
+			index[node.Name] = i
+		}
+		for i, node := range as.partitioned {
+			if i > 0 && index[node.Name] != (index[as.partitioned[i-1].Name]+1)%nodeCount {
+				t.Fatalf("partitioned nodes %q and %q are not adjacent", as.partitioned[i-1].Name, node.Name)
+			}
+			if !node.TransitionHistory[0].Timestamp.Equal(as.partitionStart) {
+				t.Fatalf("node %q cut off at %v, partition started at %v",
+					node.Name, node.TransitionHistory[0].Timestamp, as.partitionStart)
+			}
+		}
+	})
+}
+
+func TestNetworkPartition_DurationDefaultAndValidation(t *testing.T) {
+	engine, err := NewSimulationEngine(SimulationConfig{
+		NodeCount: 5,
+		Duration:  10 * time.Minute,
+		Seed:      42,
+		Scenarios: []ScenarioConfig{{Type: "network_partition", TriggerAt: time.Minute, NodeCount: 2}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	if got := engine.activeScenarios[0].config.Duration; got != defaultPartitionDuration {
+		t.Errorf("partition duration = %v, expected default %v", got, defaultPartitionDuration)
+	}
+
+	_, err = NewSimulationEngine(SimulationConfig{
+		NodeCount: 5,
+		Duration:  10 * time.Minute,
+		Scenarios: []ScenarioConfig{{Type: "network_partition", NodeCount: 2, Duration: -time.Second}},
+	})
+	if err == nil {
+		t.Error("expected error for negative partition duration")
+	}
+}
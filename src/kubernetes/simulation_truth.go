@@ -0,0 +1,59 @@
+package kubernetes
+
+import (
+	"sort"
+	"time"
+)
+
+// GroundTruthEvent is one period during which a simulated node was truly
+// NotReady and renewed no leases.
+type GroundTruthEvent struct {
+	NodeName  string            `json:"nodeName"`
+	Namespace string            `json:"namespace"`
+	Profile   NodeHealthProfile `json:"profile"`
+	Cause     NotReadyCause     `json:"cause"`
+	Start     time.Time         `json:"start"`
+	End       time.Time         `json:"end"` // the end of the simulation for drained nodes
+}
+
+// Duration returns how long the node was NotReady.
+func (e GroundTruthEvent) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// GroundTruth returns the NotReady periods of the simulation so far, ordered
+// by start time: every transition in the nodes' TransitionHistory and every
+// node drained by a rolling deployment.
+func (se *SimulationEngine) GroundTruth() []GroundTruthEvent {
+	byName := make(map[string]*SimNode, len(se.nodes))
+	var events []GroundTruthEvent
+	for _, node := range se.nodes {
+		byName[node.Name] = node
+		for _, tr := range node.TransitionHistory {
+			events = append(events, GroundTruthEvent{
+				NodeName:  node.Name,
+				Namespace: node.Namespace,
+				Profile:   node.Profile,
+				Cause:     tr.Cause,
+				Start:     tr.Timestamp,
+				End:       tr.Timestamp.Add(tr.Duration),
+			})
+		}
+	}
+	end := se.startTime.Add(se.config.Duration)
+	for _, as := range se.activeScenarios {
+		for _, di := range as.drainedNodes {
+			node := byName[di.nodeName]
+			events = append(events, GroundTruthEvent{
+				NodeName:  node.Name,
+				Namespace: node.Namespace,
+				Profile:   node.Profile,
+				Cause:     CauseDrain,
+				Start:     di.drainTime,
+				End:       end,
+			})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestGroundTruth verifies the ground truth covers every recorded transition
+// and every drained node, in start order, and is written with the output.
+func TestGroundTruth(t *testing.T) {
+	engine, err := NewSimulationEngine(streamTestConfig(3, 10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := engine.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var transitions int
+	for _, node := range engine.Nodes() {
+		transitions += len(node.TransitionHistory)
+	}
+	causes := make(map[NotReadyCause]int)
+	for i, e := range result.GroundTruth {
+		causes[e.Cause]++
+		if i > 0 && e.Start.Before(result.GroundTruth[i-1].Start) {
+			t.Fatalf("event %d starts before the previous one", i)
+		}
+		if e.Duration() <= 0 {
+			t.Errorf("event %+v has no duration", e)
+		}
+	}
+	if causes[CauseDrain] != 2 || causes[CauseNetworkPartition] != 3 {
+		t.Errorf("causes = %v, want 2 drains and 3 partitioned nodes", causes)
+	}
+	if len(result.GroundTruth) != transitions+causes[CauseDrain] {
+		t.Errorf("%d ground truth events for %d transitions and %d drains", len(result.GroundTruth), transitions, causes[CauseDrain])
+	}
+	for _, e := range result.GroundTruth {
+		if e.Cause == CauseDrain && !e.End.Equal(engine.startTime.Add(10*time.Minute)) {
+			t.Errorf("drain of %s ends at %v, want the end of the simulation", e.NodeName, e.End)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := WriteSimulationOutput(result, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "ground_truth.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []GroundTruthEvent
+	if err := json.Unmarshal(data, &written); err != nil || len(written) != len(result.GroundTruth) {
+		t.Fatalf("ground_truth.json holds %d events (%v), want %d", len(written), err, len(result.GroundTruth))
+	}
+}
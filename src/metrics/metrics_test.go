@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextFormat(t *testing.T) {
+	r := NewRegistry()
+	alerts := r.NewCounterVec("test_alerts_total", "Alerts dispatched.", "severity")
+	clients := r.NewGaugeVec("test_clients", "Connected clients.")
+	gaps := r.NewHistogramVec("test_gap_seconds", "Heartbeat gaps.", []float64{1, 5}, "node")
+
+	alerts.Inc("warning")
+	alerts.Add(2, "critical")
+	clients.Set(3)
+	gaps.Observe(0.5, `node "a"`)
+	gaps.Observe(5, `node "a"`)
+	gaps.Observe(9, `node "a"`)
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+
+	want := `# HELP test_alerts_total Alerts dispatched.
+# TYPE test_alerts_total counter
+test_alerts_total{severity="critical"} 2
+test_alerts_total{severity="warning"} 1
+# HELP test_clients Connected clients.
+# TYPE test_clients gauge
+test_clients 3
+# HELP test_gap_seconds Heartbeat gaps.
+# TYPE test_gap_seconds histogram
+test_gap_seconds_bucket{node="node \"a\"",le="1"} 1
+test_gap_seconds_bucket{node="node \"a\"",le="5"} 2
+test_gap_seconds_bucket{node="node \"a\"",le="+Inf"} 3
+test_gap_seconds_sum{node="node \"a\""} 14.5
+test_gap_seconds_count{node="node \"a\""} 3
+`
+	if sb.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestOnCollectRunsBeforeScrape(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_queue_length", "Queue length.")
+	n := 0
+	r.OnCollect(func() {
+		n++
+		g.Set(float64(n * 10))
+	})
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "test_queue_length 10\n") {
+		t.Fatalf("collect hook value missing:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: expected 405, got %d", rec.Code)
+	}
+}
+
+func TestHistogramDelete(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_gap_seconds", "Gap.", []float64{1}, "node")
+	h.Observe(0.5, "a")
+	h.Observe(2, "b")
+	h.Delete("a")
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	if strings.Contains(sb.String(), `node="a"`) || !strings.Contains(sb.String(), `test_gap_seconds_count{node="b"} 1`) || h.Count("a") != 0 {
+		t.Fatalf("unexpected output after Delete:\n%s", sb.String())
+	}
+}
+
+func TestGaugeDeleteAndLabelValidation(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_last_seen", "Last seen.", "node")
+	g.Set(1, "a")
+	g.Set(2, "b")
+	g.Delete("a")
+
+	var sb strings.Builder
+	r.WriteText(&sb)
+	if strings.Contains(sb.String(), `node="a"`) || !strings.Contains(sb.String(), `test_last_seen{node="b"} 2`) {
+		t.Fatalf("unexpected output after Delete:\n%s", sb.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for wrong label count")
+		}
+	}()
+	g.Set(1)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Detector modes, selected by EARTHWORM_DETECTOR_MODE.
+const (
+	detectorModeThreshold = "threshold" // fixed warning/critical gaps only
+	detectorModeAdaptive  = "adaptive"  // per-node learned baselines only
+	detectorModeBoth      = "both"      // the more severe of the two
+)
+
+const alertTypeIntervalDrift = "interval_drift"
+
+// adaptiveClipZ bounds how far a single interval can pull the baseline, in
+// deviations, so that gaps are flagged without being learned as normal.
+const adaptiveClipZ = 3
+
+// Scale factors from a mean and a median absolute deviation to a normal
+// standard deviation.
+const (
+	madToStdDev = 1.2533
+	madScale    = 1.4826
+)
+
+// AdaptiveConfig tunes the AdaptiveDetector.
+type AdaptiveConfig struct {
+	// Alpha is the EWMA weight of the newest interval in the node's current
+	// interval and jitter estimates.
+	Alpha float64
+	// BaselineAlpha is the EWMA weight for the long-term baseline the
+	// current interval is compared against to detect drift.
+	BaselineAlpha float64
+	// WarmupSamples is how many intervals a node must report before it
+	// can alert. Until then the fixed thresholds apply to its gaps.
+	WarmupSamples int
+	// WarningZ and CriticalZ are the gap scores, in deviations above the
+	// node's mean interval, that raise a warning or critical gap alert.
+	WarningZ  float64
+	CriticalZ float64
+	// DriftZ is the score of the current mean interval against the
+	// baseline beyond which the node's renewal interval is drifting.
+	DriftZ float64
+	// MinDeviation floors the jitter estimate so that nodes renewing like
+	// clockwork don't alert on a second of delay.
+	MinDeviation time.Duration
+}
+
+// DefaultAdaptiveConfig returns the configuration used when none is given.
+func DefaultAdaptiveConfig() AdaptiveConfig {
+	return AdaptiveConfig{
+		Alpha:         0.1,
+		BaselineAlpha: 0.01,
+		WarmupSamples: 20,
+		WarningZ:      4,
+		CriticalZ:     8,
+		DriftZ:        6,
+		MinDeviation:  500 * time.Millisecond,
+	}
+}
+
+// intervalBaseline is the learned renewal behaviour of one node. Intervals
+// are in seconds.
+type intervalBaseline struct {
+	last      time.Time
+	namespace string    // of the last heartbeat
+	warmup    []float64 // intervals seen while warming up
+	mean      float64   // EWMA of the interval, 0 until warmed up
+	absDev    float64   // EWMA of |interval - mean|
+	baseline  float64   // slow EWMA of the interval
+}
+
+// AdaptiveDetector learns each node's renewal interval and jitter and flags
+// intervals that are unusually long for that node, and nodes whose interval
+// drifts away from its long-term baseline. Alerts carry a z-score: how many
+// of the node's own deviations the observation is from normal.
+type AdaptiveDetector struct {
+	cfg AdaptiveConfig
+
+	mu    sync.Mutex
+	nodes map[string]*intervalBaseline
+}
+
+// NewAdaptiveDetector creates a detector with no learned baselines.
+func NewAdaptiveDetector(cfg AdaptiveConfig) *AdaptiveDetector {
+	return &AdaptiveDetector{cfg: cfg, nodes: make(map[string]*intervalBaseline)}
+}
+
+// Observe feeds a heartbeat into the node's baseline. It returns a gap alert
+// when the interval since the previous heartbeat is anomalous and a drift
+// alert while the node's interval has drifted; either may be nil.
+// Heartbeats must be observed in timestamp order; older ones are ignored.
+func (d *AdaptiveDetector) Observe(hb Heartbeat) (gap, drift *Alert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, ok := d.nodes[hb.NodeName]
+	if !ok {
+		d.nodes[hb.NodeName] = &intervalBaseline{last: hb.Timestamp, namespace: hb.Namespace}
+		return nil, nil
+	}
+	if !hb.Timestamp.After(b.last) {
+		return nil, nil
+	}
+	x := hb.Timestamp.Sub(b.last).Seconds()
+	b.last = hb.Timestamp
+	b.namespace = hb.Namespace
+
+	// Warm up on the median and median absolute deviation, which an outage
+	// among the first intervals does not skew
+	if b.mean == 0 {
+		b.warmup = append(b.warmup, x)
+		if len(b.warmup) >= d.cfg.WarmupSamples {
+			b.mean = median(b.warmup)
+			devs := make([]float64, len(b.warmup))
+			for i, v := range b.warmup {
+				devs[i] = math.Abs(v - b.mean)
+			}
+			b.absDev = median(devs) * madScale / madToStdDev
+			b.baseline = b.mean
+			b.warmup = nil
+		}
+		return nil, nil
+	}
+
+	sigma := d.sigma(b)
+	if z := (x - b.mean) / sigma; z >= d.cfg.WarningZ {
+		severity := "warning"
+		if z >= d.cfg.CriticalZ {
+			severity = "critical"
+		}
+		gap = d.alert(hb, alertTypeHeartbeatGap, severity, x, b.mean, z)
+	}
+
+	// Clip the interval so a single gap only nudges the estimates
+	clipped := math.Max(b.mean-adaptiveClipZ*sigma, math.Min(x, b.mean+adaptiveClipZ*sigma))
+	b.absDev += d.cfg.Alpha * (math.Abs(clipped-b.mean) - b.absDev)
+	b.mean += d.cfg.Alpha * (clipped - b.mean)
+	b.baseline += d.cfg.BaselineAlpha * (clipped - b.baseline)
+
+	// The EWMA of independent intervals with deviation sigma has deviation
+	// sigma·√(α/(2-α)); a larger difference from the baseline is drift
+	meanSigma := d.sigma(b) * math.Sqrt(d.cfg.Alpha/(2-d.cfg.Alpha))
+	if z := (b.mean - b.baseline) / meanSigma; math.Abs(z) >= d.cfg.DriftZ {
+		drift = d.alert(hb, alertTypeIntervalDrift, "warning", b.mean, b.baseline, z)
+	}
+	return gap, drift
+}
+
+// Forget drops the baseline of a node that left the cluster.
+func (d *AdaptiveDetector) Forget(nodeName string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.nodes, nodeName)
+}
+
+// OpenGap returns a gap alert for a node whose interval since its last
+// heartbeat is already anomalous at now, or nil. Unlike Observe it scores the
+// gap while it is still open, so a node that stopped renewing alerts without
+// waiting for its next heartbeat. The alert starts when the interval reached
+// WarningZ deviations. warm is false while the node has no baseline yet,
+// during which it never alerts.
+func (d *AdaptiveDetector) OpenGap(nodeName string, now time.Time) (alert *Alert, warm bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, ok := d.nodes[nodeName]
+	if !ok || b.mean == 0 {
+		return nil, false
+	}
+	if !now.After(b.last) {
+		return nil, true
+	}
+	x := now.Sub(b.last).Seconds()
+	sigma := d.sigma(b)
+	z := (x - b.mean) / sigma
+	if z < d.cfg.WarningZ {
+		return nil, true
+	}
+	severity := "warning"
+	if z >= d.cfg.CriticalZ {
+		severity = "critical"
+	}
+	alert = d.alert(Heartbeat{NodeName: nodeName, Namespace: b.namespace, Timestamp: now}, alertTypeHeartbeatGap, severity, x, b.mean, z)
+	alert.StartsAt = b.last.Add(time.Duration((b.mean + d.cfg.WarningZ*sigma) * float64(time.Second)))
+	return alert, true
+}
+
+// sigma returns the node's jitter as a standard deviation in seconds.
+func (d *AdaptiveDetector) sigma(b *intervalBaseline) float64 {
+	return math.Max(madToStdDev*b.absDev, d.cfg.MinDeviation.Seconds())
+}
+
+func (d *AdaptiveDetector) alert(hb Heartbeat, alertType, severity string, interval, baseline, z float64) *Alert {
+	return &Alert{
+		NodeName:        hb.NodeName,
+		Namespace:       hb.Namespace,
+		Gap:             interval,
+		Severity:        severity,
+		Timestamp:       hb.Timestamp,
+		Type:            alertType,
+		ZScore:          math.Round(z*100) / 100,
+		BaselineSeconds: math.Round(baseline*1000) / 1000,
+	}
+}
+
+// median returns the median of vs, reordering it.
+func median(vs []float64) float64 {
+	sort.Float64s(vs)
+	n := len(vs)
+	if n%2 == 1 {
+		return vs[n/2]
+	}
+	return (vs[n/2-1] + vs[n/2]) / 2
+}
+
+// severityRank orders alert severities; higher is worse.
+func severityRank(severity string) int {
+	switch severity {
+	case "critical":
+		return 2
+	case "warning":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// worseAlert returns the more severe of two gap alerts for the same
+// heartbeat, keeping the adaptive z-score when the fixed alert wins.
+func worseAlert(fixed, adaptive *Alert) *Alert {
+	switch {
+	case fixed == nil:
+		return adaptive
+	case adaptive == nil:
+		return fixed
+	case severityRank(adaptive.Severity) > severityRank(fixed.Severity):
+		return adaptive
+	}
+	merged := *fixed
+	merged.ZScore = adaptive.ZScore
+	merged.BaselineSeconds = adaptive.BaselineSeconds
+	return &merged
+}
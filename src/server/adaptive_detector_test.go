@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"earthworm/src/kubernetes"
+)
+
+// simulateProfiles runs a seeded simulation with one namespace per health
+// profile and returns its nodes.
+func simulateProfiles(t *testing.T, duration, drift time.Duration) []*kubernetes.SimNode {
+	t.Helper()
+	engine, err := kubernetes.NewSimulationEngine(kubernetes.SimulationConfig{
+		NodeCount:        24,
+		Duration:         duration,
+		Seed:             42,
+		MaxDriftIncrease: drift,
+		NamespaceRatios:  map[string]float64{"kube-system": 1, "batch": 1, "staging": 1},
+		NamespaceProfiles: map[string]kubernetes.NodeHealthProfile{
+			"kube-system": kubernetes.ProfileStable,
+			"batch":       kubernetes.ProfileDrifting,
+			"staging":     kubernetes.ProfileVolatile,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return engine.Nodes()
+}
+
+// replayLeases feeds a node's lease history through the detector and returns
+// the alerts it raised.
+func replayLeases(d *AdaptiveDetector, node *kubernetes.SimNode) (gaps, drifts []Alert) {
+	for _, l := range node.LeaseHistory {
+		gap, drift := d.Observe(Heartbeat{NodeName: l.NodeName, Namespace: l.Namespace, Timestamp: l.Timestamp, Status: "Ready"})
+		if gap != nil {
+			gaps = append(gaps, *gap)
+		}
+		if drift != nil {
+			drifts = append(drifts, *drift)
+		}
+	}
+	return gaps, drifts
+}
+
+// TestAdaptiveDetectorCatchesDrift replays drifting nodes whose renewal
+// interval creeps from 10s to 30s. Fixed 30s/40s thresholds see nothing until
+// renewals are three times slower than normal; the adaptive detector reports
+// the drift while the interval is still well inside them.
+func TestAdaptiveDetectorCatchesDrift(t *testing.T) {
+	fixed := Thresholds{Warning: 30 * time.Second, Critical: 40 * time.Second}
+	d := NewAdaptiveDetector(DefaultAdaptiveConfig())
+
+	var drifting int
+	for _, node := range simulateProfiles(t, 2*time.Hour, 20*time.Second) {
+		if node.Profile != kubernetes.ProfileDrifting {
+			continue
+		}
+		drifting++
+		_, drifts := replayLeases(d, node)
+		if len(drifts) == 0 {
+			t.Errorf("%s: drift not detected", node.Name)
+			continue
+		}
+		first := drifts[0]
+		if first.Type != alertTypeIntervalDrift || first.ZScore < DefaultAdaptiveConfig().DriftZ {
+			t.Errorf("%s: unexpected drift alert %+v", node.Name, first)
+		}
+		if first.Gap > 15 {
+			t.Errorf("%s: drift reported late, at a %.1fs mean interval", node.Name, first.Gap)
+		}
+
+		// By then the fixed thresholds had not flagged a single renewal
+		for i := 1; i < len(node.LeaseHistory) && node.LeaseHistory[i].Timestamp.Before(first.Timestamp); i++ {
+			interval := node.LeaseHistory[i].Timestamp.Sub(node.LeaseHistory[i-1].Timestamp)
+			if interval > 15*time.Second {
+				continue // a NotReady outage, not drift
+			}
+			if sev := fixed.severityFor(interval); sev != "" {
+				t.Fatalf("%s: fixed thresholds flagged a %v interval", node.Name, interval)
+			}
+		}
+	}
+	if drifting == 0 {
+		t.Fatal("simulation produced no drifting nodes")
+	}
+}
+
+// TestAdaptiveDetectorVolatileAndStable verifies NotReady outages on volatile
+// nodes raise z-scored gap alerts while stable nodes stay quiet.
+func TestAdaptiveDetectorVolatileAndStable(t *testing.T) {
+	d := NewAdaptiveDetector(DefaultAdaptiveConfig())
+	warningZ := DefaultAdaptiveConfig().WarningZ
+
+	var outages int
+	for _, node := range simulateProfiles(t, 4*time.Hour, 0) {
+		gaps, drifts := replayLeases(d, node)
+		switch node.Profile {
+		case kubernetes.ProfileStable:
+			if len(gaps) != 0 || len(drifts) != 0 {
+				t.Errorf("%s: stable node alerted: gaps %+v drifts %+v", node.Name, gaps, drifts)
+			}
+		case kubernetes.ProfileVolatile:
+			if len(drifts) != 0 {
+				t.Errorf("%s: volatile node reported drift: %+v", node.Name, drifts)
+			}
+			for _, tr := range node.TransitionHistory {
+				if tr.Duration < 30*time.Second {
+					continue
+				}
+				outages++
+				end := tr.Timestamp.Add(tr.Duration)
+				found := false
+				for _, a := range gaps {
+					if !a.Timestamp.Before(end) && a.Timestamp.Sub(end) < 15*time.Second {
+						found = true
+						if a.ZScore < warningZ || a.Gap < tr.Duration.Seconds() {
+							t.Errorf("%s: gap alert %+v for a %v outage", node.Name, a, tr.Duration)
+						}
+					}
+				}
+				if !found {
+					t.Errorf("%s: %v outage at %v not alerted", node.Name, tr.Duration, tr.Timestamp)
+				}
+			}
+		}
+	}
+	if outages == 0 {
+		t.Fatal("simulation produced no volatile outages")
+	}
+}
+
+func TestAdaptiveDetectorWarmupAndSeverity(t *testing.T) {
+	d := NewAdaptiveDetector(DefaultAdaptiveConfig())
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	ts := base
+	observe := func(interval time.Duration) (*Alert, *Alert) {
+		ts = ts.Add(interval)
+		return d.Observe(Heartbeat{NodeName: "node-01", Namespace: "kube-node-lease", Timestamp: ts})
+	}
+
+	// Irregular intervals during warm-up never alert
+	d.Observe(Heartbeat{NodeName: "node-01", Timestamp: ts})
+	for i := 0; i < 20; i++ {
+		interval := 10 * time.Second
+		if i == 3 {
+			interval = time.Minute
+		}
+		if gap, drift := observe(interval); gap != nil || drift != nil {
+			t.Fatalf("alert during warm-up at %d: %+v %+v", i, gap, drift)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		observe(10 * time.Second)
+	}
+
+	if gap, _ := observe(11 * time.Second); gap != nil {
+		t.Errorf("1s late renewal alerted: %+v", gap)
+	}
+	gap, _ := observe(13 * time.Second)
+	if gap == nil || gap.Severity != "warning" {
+		t.Fatalf("13s interval: got %+v, want warning", gap)
+	}
+	gap, _ = observe(40 * time.Second)
+	if gap == nil || gap.Severity != "critical" || gap.ZScore < 8 || gap.BaselineSeconds < 9 || gap.BaselineSeconds > 11 {
+		t.Fatalf("40s interval: got %+v, want critical with z-score", gap)
+	}
+	// Out-of-order heartbeats are ignored
+	if gap, drift := d.Observe(Heartbeat{NodeName: "node-01", Timestamp: base}); gap != nil || drift != nil {
+		t.Errorf("stale heartbeat alerted: %+v %+v", gap, drift)
+	}
+}
+
+// TestIngestHeartbeatAdaptiveMode verifies that in adaptive mode a gap the
+// fixed thresholds rate a warning fires as critical for a node that renews
+// like clockwork, with its z-score, while the gap is still open.
+func TestIngestHeartbeatAdaptiveMode(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origManager, origTracker, origAdaptive, origMode := alertManager, nodeTracker, adaptiveDetector, cfg.DetectorMode
+	defer func() {
+		alertManager, nodeTracker, adaptiveDetector, cfg.DetectorMode = origManager, origTracker, origAdaptive, origMode
+	}()
+
+	alertManager = NewAlertManager(testAlertTimings(), func(Alert) {})
+	nodeTracker = NewNodeStateTracker(detector, nil, nil)
+	adaptiveDetector = NewAdaptiveDetector(DefaultAdaptiveConfig())
+	cfg.DetectorMode = detectorModeAdaptive
+
+	ctx := context.Background()
+	ts := time.Now().Add(-time.Hour)
+	for i := 0; i < 40; i++ {
+		ts = ts.Add(10 * time.Second)
+		if err := ingestHeartbeat(ctx, Heartbeat{NodeName: "node-01", Namespace: "default", Timestamp: ts, Status: "Ready"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if list := alertManager.Alerts(); len(list.Active) != 0 {
+		t.Fatalf("regular renewals alerted: %+v", list.Active)
+	}
+
+	sweepHeartbeats(ts.Add(10 * time.Second))
+	if list := alertManager.Alerts(); len(list.Active) != 0 {
+		t.Fatalf("on-time sweep alerted: %+v", list.Active)
+	}
+	sweepHeartbeats(ts.Add(15 * time.Second))
+	list := alertManager.Alerts()
+	if len(list.Active) != 1 {
+		t.Fatalf("Alerts() = %+v, want one active", list)
+	}
+	a := list.Active[0]
+	if a.Type != alertTypeHeartbeatGap || a.Severity != "critical" || a.ZScore < 8 || a.BaselineSeconds != 10 {
+		t.Errorf("alert = %+v, want critical heartbeat gap with z-score", a)
+	}
+	// 10s mean plus WarningZ deviations of the 500ms floor
+	if !a.StartsAt.Equal(ts.Add(12 * time.Second)) {
+		t.Errorf("alert starts at %v, want %v", a.StartsAt, ts.Add(12*time.Second))
+	}
+}
+
+// TestIngestHeartbeatAdaptiveWarmup verifies that in adaptive mode a node
+// whose baseline is still warming up falls back to the fixed thresholds
+// rather than going unwatched.
+func TestIngestHeartbeatAdaptiveWarmup(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origManager, origTracker, origAdaptive, origMode := alertManager, nodeTracker, adaptiveDetector, cfg.DetectorMode
+	defer func() {
+		alertManager, nodeTracker, adaptiveDetector, cfg.DetectorMode = origManager, origTracker, origAdaptive, origMode
+	}()
+
+	alertManager = NewAlertManager(testAlertTimings(), func(Alert) {})
+	nodeTracker = NewNodeStateTracker(detector, nil, nil)
+	adaptiveDetector = NewAdaptiveDetector(DefaultAdaptiveConfig())
+	cfg.DetectorMode = detectorModeAdaptive
+
+	ctx := context.Background()
+	ts := time.Now().Add(-time.Hour)
+	for i := 0; i < DefaultAdaptiveConfig().WarmupSamples/2; i++ {
+		ts = ts.Add(10 * time.Second)
+		if err := ingestHeartbeat(ctx, Heartbeat{NodeName: "node-01", Namespace: "default", Timestamp: ts, Status: "Ready"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, warm := adaptiveDetector.OpenGap("node-01", ts.Add(time.Minute)); warm {
+		t.Fatal("baseline warm after half the warm-up")
+	}
+
+	sweepHeartbeats(ts.Add(45 * time.Second))
+	list := alertManager.Alerts()
+	if len(list.Active) != 1 {
+		t.Fatalf("Alerts() = %+v, want the fixed thresholds' alert", list)
+	}
+	if a := list.Active[0]; a.Severity != "critical" || a.ZScore != 0 || !a.StartsAt.Equal(ts.Add(10*time.Second)) {
+		t.Errorf("alert = %+v, want a fixed-threshold critical alert", a)
+	}
+}
@@ -1,50 +1,97 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 )
 
-// AlertDispatcher sends alerts via webhook and WebSocket broadcast.
+// AlertDispatcher broadcasts alerts to WebSocket clients and sends them to
+// the receivers selected by its router.
 type AlertDispatcher struct {
-	webhookURL  string
 	wsBroadcast func(Alert)
 	httpClient  *http.Client
+
+	mu     sync.Mutex
+	router *AlertRouter
+	counts map[AlertDispatch]uint64
 }
 
-// NewAlertDispatcher creates a new dispatcher.
+// NewAlertDispatcher creates a new dispatcher. A non-empty webhookURL
+// becomes the default receiver, posting the raw Alert JSON.
 func NewAlertDispatcher(webhookURL string, wsBroadcast func(Alert)) *AlertDispatcher {
-	return &AlertDispatcher{
-		webhookURL:  webhookURL,
+	d := &AlertDispatcher{
 		wsBroadcast: wsBroadcast,
 		httpClient:  &http.Client{Timeout: 5 * time.Second},
+		counts:      make(map[AlertDispatch]uint64),
+	}
+	if webhookURL != "" {
+		wh, _ := NewWebhookNotifier(legacyWebhookReceiver, webhookURL, "", "", nil, d.httpClient)
+		d.router, _ = NewAlertRouter([]Notifier{wh}, nil, legacyWebhookReceiver)
 	}
+	return d
+}
+
+// SetRouter replaces the receivers and routing rules used for new alerts.
+func (d *AlertDispatcher) SetRouter(r *AlertRouter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.router = r
 }
 
-// Dispatch sends the alert to the webhook (if configured) and broadcasts to WS clients.
+// AlertDispatch identifies a kind of dispatched alert notification: Severity
+// is warning or critical and Status is firing or resolved.
+type AlertDispatch struct {
+	Severity string
+	Status   string
+}
+
+// AlertCounts returns the number of alerts dispatched so far, by severity
+// and status.
+func (d *AlertDispatcher) AlertCounts() map[AlertDispatch]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[AlertDispatch]uint64, len(d.counts))
+	for k, v := range d.counts {
+		out[k] = v
+	}
+	return out
+}
+
+// Dispatch broadcasts the alert to WS clients and sends it to every routed receiver.
 func (d *AlertDispatcher) Dispatch(alert Alert) {
+	d.mu.Lock()
+	status := alert.Status
+	if status == "" {
+		status = alertStatusFiring // sent straight to the dispatcher, without an AlertManager
+	}
+	d.counts[AlertDispatch{alert.Severity, status}]++
+	router := d.router
+	d.mu.Unlock()
+
 	// Always broadcast to WebSocket clients
 	if d.wsBroadcast != nil {
 		d.wsBroadcast(alert)
 	}
 
-	// Send to webhook if configured
-	if d.webhookURL != "" {
-		go func() {
-			body, err := json.Marshal(alert)
-			if err != nil {
-				log.Printf("Failed to marshal alert for webhook: %v", err)
-				return
-			}
-			resp, err := d.httpClient.Post(d.webhookURL, "application/json", bytes.NewReader(body))
-			if err != nil {
-				log.Printf("Failed to send alert to webhook %s: %v", d.webhookURL, err)
-				return
-			}
-			resp.Body.Close()
-		}()
+	if router == nil {
+		return
+	}
+	for _, n := range router.Route(alert) {
+		go d.notify(n, alert)
+	}
+}
+
+// notify sends the alert to one receiver, recording the outcome.
+func (d *AlertDispatcher) notify(n Notifier, alert Alert) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.httpClient.Timeout)
+	defer cancel()
+	if err := n.Notify(ctx, alert); err != nil {
+		notificationsTotal.Inc(n.Name(), "failure")
+		log.Printf("Failed to send alert for node %s to receiver %s: %v", alert.NodeName, n.Name(), err)
+		return
 	}
+	notificationsTotal.Inc(n.Name(), "success")
 }
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	alertTypeHeartbeatGap = "heartbeat_gap"
+
+	alertStatusFiring   = "firing"
+	alertStatusResolved = "resolved"
+
+	defaultAlertFlushInterval = time.Second
+)
+
+// AlertTimings controls when the AlertManager notifies.
+type AlertTimings struct {
+	// GroupWait delays the first notification for a node so that alerts
+	// arriving together (or a warning escalating to critical) go out once.
+	GroupWait time.Duration
+	// GroupInterval is the minimum time between notifications for a node
+	// whose alerts changed since the last one.
+	GroupInterval time.Duration
+	// RepeatInterval re-sends alerts that are still firing unchanged.
+	RepeatInterval time.Duration
+	// ResolveTimeout is how long heartbeats must stay healthy before a
+	// firing alert resolves. A new gap within it keeps the alert firing.
+	ResolveTimeout time.Duration
+	// ResolvedRetention is how long resolved alerts stay listed.
+	ResolvedRetention time.Duration
+}
+
+// DefaultAlertTimings returns the timings used when none are configured.
+func DefaultAlertTimings() AlertTimings {
+	return AlertTimings{
+		GroupWait:         30 * time.Second,
+		GroupInterval:     5 * time.Minute,
+		RepeatInterval:    4 * time.Hour,
+		ResolveTimeout:    time.Minute,
+		ResolvedRetention: time.Hour,
+	}
+}
+
+// alertFingerprint identifies an alert by subject and alert type.
+func alertFingerprint(subject, alertType string) string {
+	sum := sha256.Sum256([]byte(alertType + "\x00" + subject))
+	return hex.EncodeToString(sum[:8])
+}
+
+// clusterAlertSubject is the subject of alerts about the whole cluster.
+const clusterAlertSubject = "cluster"
+
+// alertSubject is what an alert is about: its node, or for alerts about a
+// group of nodes the label they share, or the whole cluster.
+func alertSubject(a Alert) string {
+	switch {
+	case a.NodeName != "":
+		return a.NodeName
+	case a.CommonLabel != "":
+		return a.CommonLabel
+	default:
+		return clusterAlertSubject
+	}
+}
+
+// trackedAlert is the manager's state for one fingerprint.
+type trackedAlert struct {
+	alert       Alert
+	recoveredAt time.Time // first healthy observation since the last fire; zero while unhealthy
+	resolvedAt  time.Time // when the alert resolved; zero while firing
+
+	// What the last notification said, "" if never notified.
+	notifiedStatus   string
+	notifiedSeverity string
+}
+
+// pending reports whether the alert changed since it was last notified.
+// An alert that resolved before it was ever announced is not worth sending.
+func (ta *trackedAlert) pending() bool {
+	if ta.notifiedStatus == "" {
+		return ta.alert.Status == alertStatusFiring
+	}
+	return ta.alert.Status != ta.notifiedStatus || ta.alert.Severity != ta.notifiedSeverity
+}
+
+// alertGroup holds the alerts of one subject. Notifications are timed per group.
+type alertGroup struct {
+	alerts       map[string]*trackedAlert // by fingerprint
+	changedAt    time.Time                // first unnotified change; zero if none
+	lastNotified time.Time
+}
+
+// AlertManager sits between the anomaly detector and the dispatcher. It
+// deduplicates alerts by fingerprint, tracks each through firing → resolved,
+// and decides when to notify according to AlertTimings.
+type AlertManager struct {
+	mu       sync.Mutex
+	timings  AlertTimings
+	groups   map[string]*alertGroup // by alertSubject
+	notify   func(Alert)
+	silences *Silences
+
+	// flushedAt is the time of the last Flush, the manager's clock. It
+	// follows the simulated clock in sim mode.
+	flushedAt time.Time
+}
+
+// NewAlertManager creates a manager that sends notifications through notify.
+func NewAlertManager(timings AlertTimings, notify func(Alert)) *AlertManager {
+	return &AlertManager{
+		timings: timings,
+		groups:  make(map[string]*alertGroup),
+		notify:  notify,
+	}
+}
+
+// SetSilences makes the manager hold back firing notifications for alerts
+// matched by an active silence.
+func (m *AlertManager) SetSilences(s *Silences) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.silences = s
+}
+
+// silencedByLocked returns the active silences matching a. Caller holds m.mu.
+func (m *AlertManager) silencedByLocked(a Alert, now time.Time) []string {
+	if m.silences == nil {
+		return nil
+	}
+	return m.silences.Silencing(a, now)
+}
+
+// inhibitedByLocked returns the fingerprint of a correlated outage covering
+// the per-node alert a: one listing a's node that had not ended when a
+// started. Caller holds m.mu.
+func (m *AlertManager) inhibitedByLocked(a Alert) string {
+	if a.NodeName == "" {
+		return ""
+	}
+	for _, g := range m.groups {
+		for fp, ta := range g.alerts {
+			outage := ta.alert
+			if outage.Type != alertTypeCorrelatedOutage || !slices.Contains(outage.AffectedNodes, a.NodeName) {
+				continue
+			}
+			if outage.EndsAt == nil || !a.StartsAt.After(*outage.EndsAt) {
+				return fp
+			}
+		}
+	}
+	return ""
+}
+
+// Fire records an occurrence of the alert at now. Repeated occurrences update
+// the existing alert; a resolved alert with the same fingerprint fires again.
+// A new incident starts at the alert's StartsAt, or its Timestamp if unset.
+func (m *AlertManager) Fire(alert Alert, now time.Time) {
+	if alert.Type == "" {
+		alert.Type = alertTypeHeartbeatGap
+	}
+	subject := alertSubject(alert)
+	alert.Fingerprint = alertFingerprint(subject, alert.Type)
+	alert.Status = alertStatusFiring
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[subject]
+	if !ok {
+		g = &alertGroup{alerts: make(map[string]*trackedAlert)}
+		m.groups[subject] = g
+	}
+	ta, ok := g.alerts[alert.Fingerprint]
+	switch {
+	case !ok:
+		ta = &trackedAlert{}
+		g.alerts[alert.Fingerprint] = ta
+		if alert.StartsAt.IsZero() {
+			alert.StartsAt = alert.Timestamp
+		}
+	case ta.alert.Status == alertStatusFiring || ta.notifiedStatus == alertStatusFiring:
+		// Same incident; a resolution not yet announced is withdrawn
+		alert.StartsAt = ta.alert.StartsAt
+		if ta.alert.Status == alertStatusFiring && ta.alert.Severity == "critical" {
+			alert.Severity = "critical" // keep the worst severity while firing
+		}
+	default:
+		if alert.StartsAt.IsZero() {
+			alert.StartsAt = alert.Timestamp
+		}
+	}
+	ta.alert = alert
+	ta.recoveredAt = time.Time{}
+	ta.resolvedAt = time.Time{}
+	m.markChangedLocked(g, ta, now)
+}
+
+// Resolve records a healthy observation for the alert of the given type about
+// subject, usually a node name (see alertSubject). The alert resolves once
+// ResolveTimeout passes without another Fire.
+func (m *AlertManager) Resolve(subject, alertType string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.groups[subject]
+	if !ok {
+		return
+	}
+	ta, ok := g.alerts[alertFingerprint(subject, alertType)]
+	if !ok || ta.alert.Status != alertStatusFiring || !ta.recoveredAt.IsZero() {
+		return
+	}
+	ta.recoveredAt = now
+}
+
+// markChangedLocked starts the group's wait if the alert now needs a
+// notification. Caller holds m.mu.
+func (m *AlertManager) markChangedLocked(g *alertGroup, ta *trackedAlert, now time.Time) {
+	if ta.pending() && g.changedAt.IsZero() {
+		g.changedAt = now
+	}
+}
+
+// Flush resolves recovered alerts, sends due notifications and forgets
+// resolved alerts past their retention.
+func (m *AlertManager) Flush(now time.Time) {
+	var out []Alert
+
+	m.mu.Lock()
+	if now.After(m.flushedAt) {
+		m.flushedAt = now
+	}
+	for node, g := range m.groups {
+		for fp, ta := range g.alerts {
+			if ta.alert.Status == alertStatusFiring && !ta.recoveredAt.IsZero() &&
+				now.Sub(ta.recoveredAt) >= m.timings.ResolveTimeout {
+				endsAt := ta.recoveredAt
+				ta.alert.Status = alertStatusResolved
+				ta.alert.EndsAt = &endsAt
+				ta.resolvedAt = now
+				m.markChangedLocked(g, ta, now)
+			}
+			if ta.alert.Status == alertStatusResolved && !ta.pending() &&
+				now.Sub(ta.resolvedAt) >= m.timings.ResolvedRetention {
+				delete(g.alerts, fp)
+			}
+		}
+		if len(g.alerts) == 0 {
+			delete(m.groups, node)
+			continue
+		}
+		out = append(out, m.dueLocked(g, now)...)
+	}
+	m.mu.Unlock()
+
+	for _, a := range out {
+		m.notify(a)
+	}
+}
+
+// dueLocked returns the group's alerts to notify at now and records them as
+// notified. Caller holds m.mu.
+func (m *AlertManager) dueLocked(g *alertGroup, now time.Time) []Alert {
+	changed := !g.changedAt.IsZero()
+	var due bool
+	switch {
+	case changed && g.lastNotified.IsZero():
+		due = now.Sub(g.changedAt) >= m.timings.GroupWait
+	case changed:
+		due = now.Sub(g.lastNotified) >= m.timings.GroupInterval
+	case !g.lastNotified.IsZero():
+		due = now.Sub(g.lastNotified) >= m.timings.RepeatInterval
+	}
+	if !due {
+		return nil
+	}
+
+	var out []Alert
+	held := false
+	for _, ta := range g.alerts {
+		// On a change, send what changed; on a repeat, everything still firing
+		if !(changed && ta.pending()) && !(!changed && ta.alert.Status == alertStatusFiring) {
+			continue
+		}
+		// Silences and correlated outages hold back firing notifications
+		// only, so incidents that were announced still get resolved
+		if ta.alert.Status == alertStatusFiring &&
+			(len(m.silencedByLocked(ta.alert, now)) > 0 || m.inhibitedByLocked(ta.alert) != "") {
+			held = held || ta.pending()
+			continue
+		}
+		out = append(out, ta.alert)
+		ta.notifiedStatus = ta.alert.Status
+		ta.notifiedSeverity = ta.alert.Severity
+	}
+	g.changedAt = time.Time{}
+	if held {
+		g.changedAt = now // retry once the silence or outage ends
+	}
+	if len(out) > 0 {
+		g.lastNotified = now
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+// Run flushes every interval until ctx is cancelled.
+func (m *AlertManager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultAlertFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.Flush(now)
+		}
+	}
+}
+
+// AlertList is the response body of GET /api/alerts.
+type AlertList struct {
+	Active   []Alert `json:"active"`
+	Resolved []Alert `json:"resolved"`
+}
+
+// Alerts returns firing and retained resolved alerts, newest first. Firing
+// alerts matched by an active silence list the silences in SilencedBy, and
+// those covered by a correlated outage name it in InhibitedBy. Silences are
+// evaluated at the last Flush, the time notifications were last decided, or
+// the current time if the manager has not flushed yet.
+func (m *AlertManager) Alerts() AlertList {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.flushedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	list := AlertList{Active: []Alert{}, Resolved: []Alert{}}
+	for _, g := range m.groups {
+		for _, ta := range g.alerts {
+			a := ta.alert
+			if a.Status == alertStatusFiring {
+				a.SilencedBy = m.silencedByLocked(a, now)
+				a.InhibitedBy = m.inhibitedByLocked(a)
+				list.Active = append(list.Active, a)
+			} else {
+				list.Resolved = append(list.Resolved, a)
+			}
+		}
+	}
+	byStart := func(s []Alert) {
+		sort.Slice(s, func(i, j int) bool {
+			if !s[i].StartsAt.Equal(s[j].StartsAt) {
+				return s[i].StartsAt.After(s[j].StartsAt)
+			}
+			return s[i].Fingerprint < s[j].Fingerprint
+		})
+	}
+	byStart(list.Active)
+	byStart(list.Resolved)
+	return list
+}
+
+// alertsHandler serves GET /api/alerts.
+func alertsHandler(m *AlertManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Alerts())
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// notifyRecorder collects alerts sent by an AlertManager.
+type notifyRecorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (r *notifyRecorder) notify(a Alert) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, a)
+}
+
+// take returns and clears the recorded alerts.
+func (r *notifyRecorder) take() []Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.alerts
+	r.alerts = nil
+	return out
+}
+
+func testAlertTimings() AlertTimings {
+	return AlertTimings{
+		GroupWait:         30 * time.Second,
+		GroupInterval:     5 * time.Minute,
+		RepeatInterval:    time.Hour,
+		ResolveTimeout:    time.Minute,
+		ResolvedRetention: 10 * time.Minute,
+	}
+}
+
+func gapAlert(node, severity string, gap float64, ts time.Time) Alert {
+	return Alert{NodeName: node, Namespace: "kube-node-lease", Gap: gap, Severity: severity, Timestamp: ts}
+}
+
+func TestAlertManagerGroupWaitAndEscalation(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	m.Fire(gapAlert("node-01", "warning", 15, base), base)
+	m.Fire(gapAlert("node-01", "critical", 45, base.Add(10*time.Second)), base.Add(10*time.Second))
+	m.Fire(gapAlert("node-01", "warning", 12, base.Add(20*time.Second)), base.Add(20*time.Second))
+
+	m.Flush(base.Add(29 * time.Second))
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("notified during group wait: %+v", got)
+	}
+
+	m.Flush(base.Add(30 * time.Second))
+	got := rec.take()
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	a := got[0]
+	if a.Status != alertStatusFiring || a.Severity != "critical" || a.Type != alertTypeHeartbeatGap {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+	if a.Fingerprint != alertFingerprint("node-01", alertTypeHeartbeatGap) || !a.StartsAt.Equal(base) {
+		t.Errorf("fingerprint/startsAt = %s/%v", a.Fingerprint, a.StartsAt)
+	}
+
+	// Further occurrences of the same alert are deduplicated
+	m.Fire(gapAlert("node-01", "critical", 50, base.Add(time.Minute)), base.Add(time.Minute))
+	m.Flush(base.Add(10 * time.Minute))
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("duplicate alert was re-sent: %+v", got)
+	}
+}
+
+func TestAlertManagerFlappingStaysFiring(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	m.Fire(gapAlert("node-01", "critical", 45, base), base)
+	m.Flush(base.Add(30 * time.Second))
+	if len(rec.take()) != 1 {
+		t.Fatal("expected initial notification")
+	}
+
+	// Recover and fail again inside ResolveTimeout, several times
+	now := base.Add(30 * time.Second)
+	for i := 0; i < 5; i++ {
+		m.Resolve("node-01", alertTypeHeartbeatGap, now)
+		now = now.Add(30 * time.Second)
+		m.Flush(now)
+		m.Fire(gapAlert("node-01", "critical", 45, now), now)
+		now = now.Add(10 * time.Second)
+		m.Flush(now)
+	}
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("flapping node produced %d notifications", len(got))
+	}
+	if list := m.Alerts(); len(list.Active) != 1 || len(list.Resolved) != 0 {
+		t.Fatalf("Alerts() = %+v, want one active", list)
+	}
+}
+
+func TestAlertManagerResolvedNotification(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	m.Fire(gapAlert("node-01", "critical", 45, base), base)
+	m.Flush(base.Add(30 * time.Second))
+	rec.take()
+
+	recovered := base.Add(time.Minute)
+	m.Resolve("node-01", alertTypeHeartbeatGap, recovered)
+	m.Resolve("node-01", alertTypeHeartbeatGap, recovered.Add(10*time.Second)) // later heartbeats don't move EndsAt
+
+	m.Flush(recovered.Add(59 * time.Second))
+	if list := m.Alerts(); len(list.Active) != 1 {
+		t.Fatalf("resolved before ResolveTimeout: %+v", list)
+	}
+
+	// Resolved at recovered+60s, but the group interval since the first
+	// notification (30s + 5m) still applies
+	m.Flush(recovered.Add(time.Minute))
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("resolved notification sent inside group interval: %+v", got)
+	}
+	m.Flush(base.Add(30*time.Second + 5*time.Minute))
+	got := rec.take()
+	if len(got) != 1 || got[0].Status != alertStatusResolved {
+		t.Fatalf("got %+v, want one resolved notification", got)
+	}
+	if got[0].EndsAt == nil || !got[0].EndsAt.Equal(recovered) {
+		t.Errorf("EndsAt = %v, want %v", got[0].EndsAt, recovered)
+	}
+
+	list := m.Alerts()
+	if len(list.Active) != 0 || len(list.Resolved) != 1 {
+		t.Fatalf("Alerts() = %+v, want one resolved", list)
+	}
+
+	// Forgotten after retention
+	m.Flush(base.Add(30*time.Second + 5*time.Minute + 10*time.Minute))
+	if list := m.Alerts(); len(list.Resolved) != 0 {
+		t.Fatalf("resolved alert retained past retention: %+v", list)
+	}
+}
+
+func TestAlertManagerRepeatInterval(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	m.Fire(gapAlert("node-01", "critical", 45, base), base)
+	m.Flush(base.Add(30 * time.Second))
+	rec.take()
+
+	m.Flush(base.Add(30*time.Second + 59*time.Minute))
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("repeated before RepeatInterval: %+v", got)
+	}
+	m.Flush(base.Add(30*time.Second + time.Hour))
+	if got := rec.take(); len(got) != 1 || got[0].Status != alertStatusFiring {
+		t.Fatalf("got %+v, want one repeated firing notification", got)
+	}
+}
+
+func TestAlertManagerResolvedBeforeNotifiedIsNotSent(t *testing.T) {
+	rec := &notifyRecorder{}
+	timings := testAlertTimings()
+	timings.ResolveTimeout = 0
+	m := NewAlertManager(timings, rec.notify)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	m.Fire(gapAlert("node-01", "warning", 15, base), base)
+	m.Resolve("node-01", alertTypeHeartbeatGap, base.Add(5*time.Second))
+	m.Flush(base.Add(5 * time.Second))
+	m.Flush(base.Add(time.Minute))
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("alert resolved within group wait was notified: %+v", got)
+	}
+	if list := m.Alerts(); len(list.Resolved) != 1 || list.Resolved[0].Fingerprint == "" {
+		t.Fatalf("Alerts() = %+v, want the resolved alert listed", list)
+	}
+}
+
+func TestAlertsHandler(t *testing.T) {
+	m := NewAlertManager(testAlertTimings(), func(Alert) {})
+	base := time.Now()
+	m.Fire(gapAlert("node-01", "critical", 45, base), base)
+	m.Fire(gapAlert("node-02", "warning", 15, base), base)
+
+	rec := httptest.NewRecorder()
+	alertsHandler(m)(rec, httptest.NewRequest(http.MethodGet, "/api/alerts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var list AlertList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Active) != 2 || list.Resolved == nil {
+		t.Fatalf("list = %+v", list)
+	}
+	for _, a := range list.Active {
+		if a.Fingerprint != alertFingerprint(a.NodeName, alertTypeHeartbeatGap) || a.Status != alertStatusFiring {
+			t.Errorf("unexpected alert %+v", a)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	alertsHandler(m)(rec, httptest.NewRequest(http.MethodPost, "/api/alerts", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+// TestIngestHeartbeatFeedsAlertManager verifies a node that stops renewing
+// fires its gap alert from the sweep while the gap is open, starting when the
+// gap crossed the warning threshold, and that its next heartbeat resolves it.
+func TestIngestHeartbeatFeedsAlertManager(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origManager, origTracker := alertManager, nodeTracker
+	defer func() { alertManager, nodeTracker = origManager, origTracker }()
+
+	rec := &notifyRecorder{}
+	alertManager = NewAlertManager(testAlertTimings(), rec.notify)
+	nodeTracker = NewNodeStateTracker(detector, nil, nil)
+
+	base := time.Now().Add(-2 * time.Minute)
+	ctx := context.Background()
+	if err := ingestHeartbeat(ctx, Heartbeat{NodeName: "node-01", Namespace: "default", Timestamp: base, Status: "Ready"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sweepHeartbeats(base.Add(5 * time.Second))
+	if list := alertManager.Alerts(); len(list.Active) != 0 {
+		t.Fatalf("alert within thresholds: %+v", list.Active)
+	}
+	sweepHeartbeats(base.Add(15 * time.Second))
+	list := alertManager.Alerts()
+	if len(list.Active) != 1 || list.Active[0].Severity != "warning" || !list.Active[0].StartsAt.Equal(base.Add(10*time.Second)) {
+		t.Fatalf("Alerts() = %+v, want a warning for node-01 starting at the 10s threshold", list)
+	}
+
+	// The node never renews again: the alert escalates without a heartbeat
+	sweepHeartbeats(base.Add(45 * time.Second))
+	alertManager.Flush(base.Add(45 * time.Second))
+	got := rec.take()
+	if len(got) != 1 || got[0].Severity != "critical" || got[0].Gap != 45 || !got[0].StartsAt.Equal(base.Add(10*time.Second)) {
+		t.Fatalf("notified %+v, want one critical alert started at the warning threshold", got)
+	}
+
+	// Arrival only counts toward resolving
+	if err := ingestHeartbeat(ctx, Heartbeat{NodeName: "node-01", Namespace: "default", Timestamp: base.Add(50 * time.Second), Status: "Ready"}); err != nil {
+		t.Fatal(err)
+	}
+	sweepHeartbeats(base.Add(55 * time.Second))
+	alertManager.Flush(time.Now().Add(testAlertTimings().GroupInterval))
+	got = rec.take()
+	if len(got) != 1 || got[0].Status != alertStatusResolved {
+		t.Fatalf("notified %+v, want the alert resolved", got)
+	}
+}
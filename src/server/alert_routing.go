@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"earthworm/src/kubernetes"
+)
+
+// legacyWebhookReceiver is the receiver created from EARTHWORM_WEBHOOK_URL.
+const legacyWebhookReceiver = "webhook"
+
+// AlertRoute sends matching alerts to a receiver. Empty match fields match
+// everything.
+type AlertRoute struct {
+	Receiver    string   `yaml:"receiver" json:"receiver"`
+	Severities  []string `yaml:"severities,omitempty" json:"severities,omitempty"`
+	NodePattern string   `yaml:"nodePattern,omitempty" json:"nodePattern,omitempty"` // glob, e.g. "prod-*"
+	Namespaces  []string `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+	// Continue evaluates later routes after this one matches.
+	Continue bool `yaml:"continue,omitempty" json:"continue,omitempty"`
+}
+
+// Matches reports whether the alert satisfies every match field of the route.
+func (r AlertRoute) Matches(a Alert) bool {
+	if len(r.Severities) > 0 && !slices.Contains(r.Severities, a.Severity) {
+		return false
+	}
+	if r.NodePattern != "" {
+		if ok, _ := path.Match(r.NodePattern, a.NodeName); !ok {
+			return false
+		}
+	}
+	if len(r.Namespaces) > 0 && !slices.Contains(r.Namespaces, a.Namespace) {
+		return false
+	}
+	return true
+}
+
+// AlertRouter selects receivers for an alert. Routes are evaluated in order;
+// the first match wins unless it sets Continue. Alerts matching no route go
+// to the default receiver, if any.
+type AlertRouter struct {
+	receivers       map[string]Notifier
+	routes          []AlertRoute
+	defaultReceiver string
+}
+
+// NewAlertRouter validates that every route and the default refer to a
+// known receiver.
+func NewAlertRouter(receivers []Notifier, routes []AlertRoute, defaultReceiver string) (*AlertRouter, error) {
+	r := &AlertRouter{
+		receivers:       make(map[string]Notifier, len(receivers)),
+		routes:          routes,
+		defaultReceiver: defaultReceiver,
+	}
+	for _, n := range receivers {
+		if _, dup := r.receivers[n.Name()]; dup {
+			return nil, fmt.Errorf("duplicate receiver %q", n.Name())
+		}
+		r.receivers[n.Name()] = n
+	}
+	for i, route := range routes {
+		if _, ok := r.receivers[route.Receiver]; !ok {
+			return nil, fmt.Errorf("route %d: unknown receiver %q", i, route.Receiver)
+		}
+		if _, err := path.Match(route.NodePattern, ""); err != nil {
+			return nil, fmt.Errorf("route %d: invalid nodePattern %q: %w", i, route.NodePattern, err)
+		}
+	}
+	if defaultReceiver != "" {
+		if _, ok := r.receivers[defaultReceiver]; !ok {
+			return nil, fmt.Errorf("unknown default receiver %q", defaultReceiver)
+		}
+	}
+	return r, nil
+}
+
+// Route returns the receivers the alert should be sent to, without duplicates.
+func (r *AlertRouter) Route(a Alert) []Notifier {
+	var out []Notifier
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, r.receivers[name])
+		}
+	}
+	for _, route := range r.routes {
+		if !route.Matches(a) {
+			continue
+		}
+		add(route.Receiver)
+		if !route.Continue {
+			return out
+		}
+	}
+	if len(out) == 0 && r.defaultReceiver != "" {
+		add(r.defaultReceiver)
+	}
+	return out
+}
+
+// ReceiverConfig configures one named receiver.
+type ReceiverConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // slack, pagerduty, opsgenie or webhook
+	URL  string `yaml:"url"`
+
+	RoutingKey string `yaml:"routingKey"` // pagerduty
+	APIKey     string `yaml:"apiKey"`     // opsgenie
+
+	// webhook only
+	Template    string            `yaml:"template"`
+	ContentType string            `yaml:"contentType"`
+	Headers     map[string]string `yaml:"headers"`
+}
+
+// AlertRoutingConfig is the YAML file named by EARTHWORM_ALERT_ROUTES.
+type AlertRoutingConfig struct {
+	Receivers       []ReceiverConfig `yaml:"receivers"`
+	Routes          []AlertRoute     `yaml:"routes"`
+	DefaultReceiver string           `yaml:"defaultReceiver"`
+}
+
+// LoadAlertRoutingConfig reads and parses a routing config file.
+func LoadAlertRoutingConfig(filename string) (AlertRoutingConfig, error) {
+	var c AlertRoutingConfig
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// rejectNamespaces returns an error for the first route that matches on
+// namespaces. Alerts about heartbeats from node Leases are all in
+// kube-node-lease, so such a route could never select anything.
+func (c AlertRoutingConfig) rejectNamespaces() error {
+	for i, route := range c.Routes {
+		if len(route.Namespaces) > 0 {
+			return fmt.Errorf("route %d to %q matches on namespaces, but with -lease-watch every alert is in %s; match on nodePattern instead",
+				i, route.Receiver, kubernetes.NodeLeaseNamespace)
+		}
+	}
+	return nil
+}
+
+// Build creates the receivers and router described by the config.
+func (c AlertRoutingConfig) Build(client *http.Client) (*AlertRouter, error) {
+	receivers := make([]Notifier, 0, len(c.Receivers))
+	for _, rc := range c.Receivers {
+		n, err := newNotifier(rc, client)
+		if err != nil {
+			return nil, err
+		}
+		receivers = append(receivers, n)
+	}
+	return NewAlertRouter(receivers, c.Routes, c.DefaultReceiver)
+}
+
+// newNotifier constructs the notifier for a receiver config.
+func newNotifier(rc ReceiverConfig, client *http.Client) (Notifier, error) {
+	if rc.Name == "" {
+		return nil, fmt.Errorf("receiver with type %q has no name", rc.Type)
+	}
+	switch rc.Type {
+	case "slack":
+		if rc.URL == "" {
+			return nil, fmt.Errorf("receiver %q: slack requires url", rc.Name)
+		}
+		return NewSlackNotifier(rc.Name, rc.URL, client), nil
+	case "pagerduty":
+		if rc.RoutingKey == "" {
+			return nil, fmt.Errorf("receiver %q: pagerduty requires routingKey", rc.Name)
+		}
+		return NewPagerDutyNotifier(rc.Name, rc.URL, rc.RoutingKey, client), nil
+	case "opsgenie":
+		if rc.APIKey == "" {
+			return nil, fmt.Errorf("receiver %q: opsgenie requires apiKey", rc.Name)
+		}
+		return NewOpsgenieNotifier(rc.Name, rc.URL, rc.APIKey, client), nil
+	case "webhook":
+		if rc.URL == "" {
+			return nil, fmt.Errorf("receiver %q: webhook requires url", rc.Name)
+		}
+		return NewWebhookNotifier(rc.Name, rc.URL, rc.Template, rc.ContentType, rc.Headers, client)
+	default:
+		return nil, fmt.Errorf("receiver %q: unknown type %q", rc.Name, rc.Type)
+	}
+}
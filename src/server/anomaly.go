@@ -5,7 +5,9 @@ import (
 	"time"
 )
 
-// Alert represents an anomaly alert for a node.
+// Alert represents an anomaly alert for a node, or for a group of nodes when
+// NodeName is empty. Type, Fingerprint, Status, EndsAt, SilencedBy and
+// InhibitedBy are filled in by the AlertManager, and StartsAt when unset.
 type Alert struct {
 	NodeName     string          `json:"nodeName"`
 	Namespace    string          `json:"namespace"`
@@ -13,13 +15,35 @@ type Alert struct {
 	Severity     string          `json:"severity"` // "warning" or "critical"
 	Timestamp    time.Time       `json:"timestamp"`
 	KernelEvents []EnrichedEvent `json:"kernelEvents,omitempty"`
+	Type         string          `json:"type,omitempty"`        // e.g. "heartbeat_gap"
+	Fingerprint  string          `json:"fingerprint,omitempty"` // stable per node and type
+	Status       string          `json:"status,omitempty"`      // "firing" or "resolved"
+	StartsAt     time.Time       `json:"startsAt,omitzero"`
+	EndsAt       *time.Time      `json:"endsAt,omitempty"`
+	RootCause    string          `json:"rootCause,omitempty"`  // from the causal chain, when one was built
+	SilencedBy   []string        `json:"silencedBy,omitempty"` // IDs of active silences matching the alert
+
+	// Set by the adaptive detector: how many of the node's own deviations
+	// the observation is from its learned baseline interval
+	ZScore          float64 `json:"zScore,omitempty"`
+	BaselineSeconds float64 `json:"baselineSeconds,omitempty"`
+
+	// A correlated outage alert lists the nodes involved and the label they
+	// share, e.g. "topology.kubernetes.io/zone=us-east-1a", or "" for an
+	// outage across the cluster. Per-node alerts it covers carry its
+	// fingerprint in InhibitedBy.
+	AffectedNodes []string `json:"affectedNodes,omitempty"`
+	CommonLabel   string   `json:"commonLabel,omitempty"`
+	InhibitedBy   string   `json:"inhibitedBy,omitempty"`
 }
 
-// AnomalyDetector evaluates heartbeat gaps against thresholds.
+// AnomalyDetector evaluates heartbeat gaps against thresholds. The global
+// thresholds apply unless a ThresholdPolicy resolves others for the node.
 type AnomalyDetector struct {
 	store             Store
 	warningThreshold  time.Duration
 	criticalThreshold time.Duration
+	policy            *ThresholdPolicy
 }
 
 // NewAnomalyDetector creates a new detector with the given thresholds.
@@ -31,39 +55,82 @@ func NewAnomalyDetector(store Store, warningSeconds, criticalSeconds int) *Anoma
 	}
 }
 
-// Evaluate checks the gap between the incoming event and the latest stored event for the same node.
-// Returns an Alert if the gap exceeds a threshold, or nil if normal.
-// When correlated kernel events exist in the preceding 120s window, they are included in the alert.
-func (ad *AnomalyDetector) Evaluate(event Heartbeat) *Alert {
-	latest, err := ad.store.GetLatestByNode(context.Background(), event.NodeName)
-	if err != nil || latest == nil {
-		return nil
+// SetPolicy makes the detector resolve thresholds per node through p.
+// Call it before the detector is used.
+func (ad *AnomalyDetector) SetPolicy(p *ThresholdPolicy) {
+	ad.policy = p
+}
+
+// Thresholds returns the detector's global thresholds.
+func (ad *AnomalyDetector) Thresholds() Thresholds {
+	return Thresholds{Warning: ad.warningThreshold, Critical: ad.criticalThreshold}
+}
+
+// thresholdsFor returns the thresholds that apply to a node's heartbeats in namespace.
+func (ad *AnomalyDetector) thresholdsFor(nodeName, namespace string) Thresholds {
+	if ad.policy == nil {
+		return ad.Thresholds()
 	}
+	return ad.policy.Resolve(nodeName, namespace).Thresholds()
+}
 
-	gap := event.Timestamp.Sub(latest.Timestamp)
-	if gap <= ad.warningThreshold {
+// OpenGap returns the alert for a node whose latest stored heartbeat is
+// overdue at now, or nil while it is within its thresholds or has none. The
+// alert starts when the gap crossed the warning threshold. Kernel events are
+// left for the caller to attach.
+func (ad *AnomalyDetector) OpenGap(nodeName string, now time.Time) *Alert {
+	latest, err := ad.store.GetLatestByNode(context.Background(), nodeName)
+	if err != nil || latest == nil {
 		return nil
 	}
+	return ad.gapAlert(nodeName, latest.Namespace, latest.Timestamp, now)
+}
 
-	severity := "warning"
-	if gap > ad.criticalThreshold {
-		severity = "critical"
+// gapAlert returns the alert for a gap from lastSeen to now, or nil if
+// normal, without kernel events.
+func (ad *AnomalyDetector) gapAlert(nodeName, namespace string, lastSeen, now time.Time) *Alert {
+	thresholds := ad.thresholdsFor(nodeName, namespace)
+	gap := now.Sub(lastSeen)
+	severity := thresholds.severityFor(gap)
+	if severity == "" {
+		return nil
 	}
 
 	alert := &Alert{
-		NodeName:  event.NodeName,
-		Namespace: event.Namespace,
+		NodeName:  nodeName,
+		Namespace: namespace,
 		Gap:       gap.Seconds(),
 		Severity:  severity,
-		Timestamp: event.Timestamp,
+		Timestamp: now,
+		Type:      alertTypeHeartbeatGap,
+		StartsAt:  lastSeen.Add(thresholds.Warning),
 	}
+	return alert
+}
 
-	// Attach correlated kernel events from the preceding 120s window
-	from := event.Timestamp.Add(-120 * time.Second)
-	kernelEvents, err := ad.store.GetKernelEvents(context.Background(), event.NodeName, from, event.Timestamp)
+// attachKernelEvents adds the node's kernel events from the 120s before the alert.
+func (ad *AnomalyDetector) attachKernelEvents(alert *Alert) {
+	from := alert.Timestamp.Add(-120 * time.Second)
+	kernelEvents, err := ad.store.GetKernelEvents(context.Background(), alert.NodeName, from, alert.Timestamp)
 	if err == nil && len(kernelEvents) > 0 {
 		alert.KernelEvents = kernelEvents
 	}
+}
+
+// CheckGap returns the gap between now and the node's latest stored heartbeat,
+// together with its severity ("" when within the warning threshold).
+// ok is false when the node has no stored heartbeat.
+func (ad *AnomalyDetector) CheckGap(nodeName string, now time.Time) (gap time.Duration, severity string, ok bool) {
+	latest, err := ad.store.GetLatestByNode(context.Background(), nodeName)
+	if err != nil || latest == nil {
+		return 0, "", false
+	}
+	gap = now.Sub(latest.Timestamp)
+	return gap, ad.thresholdsFor(nodeName, latest.Namespace).severityFor(gap), true
+}
 
-	return alert
+// OverdueAt returns the time at which a node whose last heartbeat in
+// namespace was at lastSeen crossed its critical threshold.
+func (ad *AnomalyDetector) OverdueAt(nodeName, namespace string, lastSeen time.Time) time.Time {
+	return lastSeen.Add(ad.thresholdsFor(nodeName, namespace).Critical)
 }
@@ -72,15 +72,12 @@ func TestProperty14_EnhancedAlertWithKernelEvents(t *testing.T) {
 
 		det := NewAnomalyDetector(memStore, warningS, criticalS)
 
-		incoming := Heartbeat{
-			NodeName:  nodeName,
-			Namespace: namespace,
-			Timestamp: alertTime,
-			Status:    "NotReady",
+		// As openGapAlert raises it
+		alert := det.OpenGap(nodeName, alertTime)
+		if alert != nil {
+			det.attachKernelEvents(alert)
 		}
 
-		alert := det.Evaluate(incoming)
-
 		// Alert should be generated (gap > critical threshold)
 		if alert == nil {
 			t.Fatal("expected alert, got nil")
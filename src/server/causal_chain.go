@@ -51,6 +51,12 @@ func (ccb *CausalChainBuilder) OnNotReady(nodeName string, transitionTime time.T
 	return &chain, nil
 }
 
+// Latest returns the node's most recent stored causal chain, or nil if it
+// has none.
+func (ccb *CausalChainBuilder) Latest(ctx context.Context, nodeName string) (*CausalChain, error) {
+	return ccb.store.GetLatestCausalChain(ctx, nodeName)
+}
+
 // buildChain constructs a CausalChain from the given events.
 func (ccb *CausalChainBuilder) buildChain(nodeName string, transitionTime time.Time, events []EnrichedEvent) CausalChain {
 	// Sort events chronologically
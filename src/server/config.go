@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all configurable parameters for the Earthworm server.
@@ -13,11 +14,59 @@ type Config struct {
 	LogFilePath        string
 	CORSOrigins        []string
 	StoreType          string
+	MemoryMaxAgeS      int // 0 keeps records until MemoryMaxEntries evicts them
+	MemoryMaxEntries   int // per node and record type; 0 is unbounded
+	MemoryMaxTotal     int // records across all nodes and record types; 0 is unbounded
 	RedisAddr          string
+	RedisTTLS          int
+	RedisMaxLen        int // members per sorted set; 0 is unbounded
+	SQLitePath         string
+	SQLiteRetentionS   int
+	PostgresDSN        string
+	PostgresRetentionS int
+	PostgresTimescale  bool
 	WarningThresholdS  int
 	CriticalThresholdS int
+	ThresholdPolicy    string
+	DetectorMode       string // threshold, adaptive or both
+	AdaptiveWarningZ   float64
+	AdaptiveCriticalZ  float64
 	WebhookURL         string
+	AlertRoutesFile    string
 	TopologyWindowS    int
+
+	// Redis connection (see RedisOptions). RedisAddr holds the Sentinel
+	// addresses when RedisMasterName is set; RedisClusterAddrs selects
+	// cluster mode. Zero pool sizes and timeouts keep the client defaults.
+	RedisURL           string
+	RedisMasterName    string
+	RedisClusterAddrs  []string
+	RedisDB            int
+	RedisUsername      string
+	RedisPassword      string
+	RedisPasswordFile  string
+	RedisTLS           bool
+	RedisTLSCAFile     string
+	RedisTLSCertFile   string
+	RedisTLSKeyFile    string
+	RedisPoolSize      int
+	RedisMinIdleConns  int
+	RedisDialTimeoutS  int
+	RedisReadTimeoutS  int
+	RedisWriteTimeoutS int
+
+	// Alert lifecycle timings, in seconds (see AlertTimings)
+	AlertGroupWaitS      int
+	AlertGroupIntervalS  int
+	AlertRepeatIntervalS int
+	AlertResolveTimeoutS int
+
+	// Correlated outage detection (see OutageConfig); OutageMinNodes 0
+	// disables it
+	OutageLabels   []string
+	OutageWindowS  int
+	OutageMinNodes int
+	OutageFraction float64
 }
 
 // LoadConfig reads configuration from environment variables with sensible defaults.
@@ -27,12 +76,34 @@ func LoadConfig() Config {
 		LogFilePath:        "earthworm.log",
 		CORSOrigins:        []string{"*"},
 		StoreType:          "memory",
+		MemoryMaxAgeS:      int(defaultMemoryMaxAge / time.Second),
+		MemoryMaxEntries:   defaultMemoryMaxEntries,
+		MemoryMaxTotal:     defaultMemoryMaxTotalEntries,
 		RedisAddr:          "localhost:6379",
+		RedisTTLS:          int(defaultTTL / time.Second),
+		SQLitePath:         "earthworm.db",
+		SQLiteRetentionS:   int(defaultTTL / time.Second),
+		PostgresDSN:        "postgres://localhost:5432/earthworm",
+		PostgresRetentionS: int(defaultPostgresRetention / time.Second),
 		WarningThresholdS:  10,
 		CriticalThresholdS: 40,
+		DetectorMode:       detectorModeThreshold,
 		WebhookURL:         "",
 		TopologyWindowS:    300,
 	}
+	adaptive := DefaultAdaptiveConfig()
+	cfg.AdaptiveWarningZ = adaptive.WarningZ
+	cfg.AdaptiveCriticalZ = adaptive.CriticalZ
+	outage := DefaultOutageConfig()
+	cfg.OutageLabels = outage.LabelKeys
+	cfg.OutageWindowS = int(outage.Window / time.Second)
+	cfg.OutageMinNodes = outage.MinNodes
+	cfg.OutageFraction = outage.Fraction
+	defaults := DefaultAlertTimings()
+	cfg.AlertGroupWaitS = int(defaults.GroupWait / time.Second)
+	cfg.AlertGroupIntervalS = int(defaults.GroupInterval / time.Second)
+	cfg.AlertRepeatIntervalS = int(defaults.RepeatInterval / time.Second)
+	cfg.AlertResolveTimeoutS = int(defaults.ResolveTimeout / time.Second)
 
 	if v := os.Getenv("EARTHWORM_PORT"); v != "" {
 		if p, err := strconv.Atoi(v); err == nil {
@@ -48,9 +119,97 @@ func LoadConfig() Config {
 	if v := os.Getenv("EARTHWORM_STORE"); v != "" {
 		cfg.StoreType = v
 	}
+	for env, field := range map[string]*int{
+		"EARTHWORM_MEMORY_MAX_AGE_S":         &cfg.MemoryMaxAgeS,
+		"EARTHWORM_MEMORY_MAX_ENTRIES":       &cfg.MemoryMaxEntries,
+		"EARTHWORM_MEMORY_MAX_TOTAL_ENTRIES": &cfg.MemoryMaxTotal,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				*field = n
+			} else {
+				log.Printf("%s=%q is not a non-negative integer, using default %d", env, v, *field)
+			}
+		}
+	}
 	if v := os.Getenv("EARTHWORM_REDIS_ADDR"); v != "" {
 		cfg.RedisAddr = v
 	}
+	if v := os.Getenv("EARTHWORM_REDIS_TTL_S"); v != "" {
+		if t, err := strconv.Atoi(v); err == nil && t > 0 {
+			cfg.RedisTTLS = t
+		} else {
+			log.Printf("EARTHWORM_REDIS_TTL_S=%q is not a positive integer, using default %d", v, cfg.RedisTTLS)
+		}
+	}
+	for env, field := range map[string]*string{
+		"EARTHWORM_REDIS_URL":           &cfg.RedisURL,
+		"EARTHWORM_REDIS_MASTER_NAME":   &cfg.RedisMasterName,
+		"EARTHWORM_REDIS_USERNAME":      &cfg.RedisUsername,
+		"EARTHWORM_REDIS_PASSWORD":      &cfg.RedisPassword,
+		"EARTHWORM_REDIS_PASSWORD_FILE": &cfg.RedisPasswordFile,
+		"EARTHWORM_REDIS_TLS_CA_FILE":   &cfg.RedisTLSCAFile,
+		"EARTHWORM_REDIS_TLS_CERT_FILE": &cfg.RedisTLSCertFile,
+		"EARTHWORM_REDIS_TLS_KEY_FILE":  &cfg.RedisTLSKeyFile,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	if v := os.Getenv("EARTHWORM_REDIS_CLUSTER_ADDRS"); v != "" {
+		cfg.RedisClusterAddrs = strings.Split(v, ",")
+	}
+	if v := os.Getenv("EARTHWORM_REDIS_TLS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.RedisTLS = b
+		} else {
+			log.Printf("EARTHWORM_REDIS_TLS=%q is not a boolean, using default %t", v, cfg.RedisTLS)
+		}
+	}
+	for env, field := range map[string]*int{
+		"EARTHWORM_REDIS_MAX_LEN":         &cfg.RedisMaxLen,
+		"EARTHWORM_REDIS_DB":              &cfg.RedisDB,
+		"EARTHWORM_REDIS_POOL_SIZE":       &cfg.RedisPoolSize,
+		"EARTHWORM_REDIS_MIN_IDLE_CONNS":  &cfg.RedisMinIdleConns,
+		"EARTHWORM_REDIS_DIAL_TIMEOUT_S":  &cfg.RedisDialTimeoutS,
+		"EARTHWORM_REDIS_READ_TIMEOUT_S":  &cfg.RedisReadTimeoutS,
+		"EARTHWORM_REDIS_WRITE_TIMEOUT_S": &cfg.RedisWriteTimeoutS,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				*field = n
+			} else {
+				log.Printf("%s=%q is not a non-negative integer, using default %d", env, v, *field)
+			}
+		}
+	}
+	if v := os.Getenv("EARTHWORM_SQLITE_PATH"); v != "" {
+		cfg.SQLitePath = v
+	}
+	if v := os.Getenv("EARTHWORM_SQLITE_RETENTION_S"); v != "" {
+		if t, err := strconv.Atoi(v); err == nil && t > 0 {
+			cfg.SQLiteRetentionS = t
+		} else {
+			log.Printf("EARTHWORM_SQLITE_RETENTION_S=%q is not a positive integer, using default %d", v, cfg.SQLiteRetentionS)
+		}
+	}
+	if v := os.Getenv("EARTHWORM_POSTGRES_DSN"); v != "" {
+		cfg.PostgresDSN = v
+	}
+	if v := os.Getenv("EARTHWORM_POSTGRES_RETENTION_S"); v != "" {
+		if t, err := strconv.Atoi(v); err == nil && t > 0 {
+			cfg.PostgresRetentionS = t
+		} else {
+			log.Printf("EARTHWORM_POSTGRES_RETENTION_S=%q is not a positive integer, using default %d", v, cfg.PostgresRetentionS)
+		}
+	}
+	if v := os.Getenv("EARTHWORM_POSTGRES_TIMESCALE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.PostgresTimescale = b
+		} else {
+			log.Printf("EARTHWORM_POSTGRES_TIMESCALE=%q is not a boolean, using default %t", v, cfg.PostgresTimescale)
+		}
+	}
 	if v := os.Getenv("EARTHWORM_WARNING_THRESHOLD"); v != "" {
 		if t, err := strconv.Atoi(v); err == nil {
 			cfg.WarningThresholdS = t
@@ -61,9 +220,71 @@ func LoadConfig() Config {
 			cfg.CriticalThresholdS = t
 		}
 	}
+	if v := os.Getenv("EARTHWORM_THRESHOLD_POLICY"); v != "" {
+		cfg.ThresholdPolicy = v
+	}
+	if v := os.Getenv("EARTHWORM_DETECTOR_MODE"); v != "" {
+		switch v {
+		case detectorModeThreshold, detectorModeAdaptive, detectorModeBoth:
+			cfg.DetectorMode = v
+		default:
+			log.Printf("EARTHWORM_DETECTOR_MODE=%q is not threshold, adaptive or both, using default %s", v, cfg.DetectorMode)
+		}
+	}
+	for env, field := range map[string]*float64{
+		"EARTHWORM_ADAPTIVE_WARNING_Z":  &cfg.AdaptiveWarningZ,
+		"EARTHWORM_ADAPTIVE_CRITICAL_Z": &cfg.AdaptiveCriticalZ,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if z, err := strconv.ParseFloat(v, 64); err == nil && z > 0 {
+				*field = z
+			} else {
+				log.Printf("%s=%q is not a positive number, using default %g", env, v, *field)
+			}
+		}
+	}
 	if v := os.Getenv("EARTHWORM_WEBHOOK_URL"); v != "" {
 		cfg.WebhookURL = v
 	}
+	if v := os.Getenv("EARTHWORM_ALERT_ROUTES"); v != "" {
+		cfg.AlertRoutesFile = v
+	}
+	for env, field := range map[string]*int{
+		"EARTHWORM_ALERT_GROUP_WAIT_S":      &cfg.AlertGroupWaitS,
+		"EARTHWORM_ALERT_GROUP_INTERVAL_S":  &cfg.AlertGroupIntervalS,
+		"EARTHWORM_ALERT_REPEAT_INTERVAL_S": &cfg.AlertRepeatIntervalS,
+		"EARTHWORM_ALERT_RESOLVE_TIMEOUT_S": &cfg.AlertResolveTimeoutS,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if t, err := strconv.Atoi(v); err == nil && t >= 0 {
+				*field = t
+			} else {
+				log.Printf("%s=%q is not a non-negative integer, using default %d", env, v, *field)
+			}
+		}
+	}
+	if v := os.Getenv("EARTHWORM_OUTAGE_LABELS"); v != "" {
+		cfg.OutageLabels = strings.Split(v, ",")
+	}
+	for env, field := range map[string]*int{
+		"EARTHWORM_OUTAGE_WINDOW_S":  &cfg.OutageWindowS,
+		"EARTHWORM_OUTAGE_MIN_NODES": &cfg.OutageMinNodes,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				*field = n
+			} else {
+				log.Printf("%s=%q is not a non-negative integer, using default %d", env, v, *field)
+			}
+		}
+	}
+	if v := os.Getenv("EARTHWORM_OUTAGE_FRACTION"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+			cfg.OutageFraction = f
+		} else {
+			log.Printf("EARTHWORM_OUTAGE_FRACTION=%q is not in (0,1], using default %g", v, cfg.OutageFraction)
+		}
+	}
 	if v := os.Getenv("EARTHWORM_TOPOLOGY_WINDOW_S"); v != "" {
 		if t, err := strconv.Atoi(v); err == nil {
 			if t >= 10 && t <= 86400 {
@@ -76,3 +297,31 @@ func LoadConfig() Config {
 
 	return cfg
 }
+
+// AdaptiveConfig returns the adaptive detector configuration.
+func (c Config) AdaptiveConfig() AdaptiveConfig {
+	a := DefaultAdaptiveConfig()
+	a.WarningZ = c.AdaptiveWarningZ
+	a.CriticalZ = c.AdaptiveCriticalZ
+	return a
+}
+
+// OutageConfig returns the correlated outage detector configuration.
+func (c Config) OutageConfig() OutageConfig {
+	return OutageConfig{
+		Window:    time.Duration(c.OutageWindowS) * time.Second,
+		MinNodes:  c.OutageMinNodes,
+		Fraction:  c.OutageFraction,
+		LabelKeys: c.OutageLabels,
+	}
+}
+
+// AlertTimings returns the configured alert lifecycle timings.
+func (c Config) AlertTimings() AlertTimings {
+	t := DefaultAlertTimings()
+	t.GroupWait = time.Duration(c.AlertGroupWaitS) * time.Second
+	t.GroupInterval = time.Duration(c.AlertGroupIntervalS) * time.Second
+	t.RepeatInterval = time.Duration(c.AlertRepeatIntervalS) * time.Second
+	t.ResolveTimeout = time.Duration(c.AlertResolveTimeoutS) * time.Second
+	return t
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 )
 
 // TestLoadConfig_Defaults verifies that LoadConfig returns correct defaults
@@ -179,3 +180,148 @@ func TestLoadConfig_PartialEnvVars(t *testing.T) {
 		t.Errorf("CriticalThresholdS should be default: got %d", cfg.CriticalThresholdS)
 	}
 }
+
+func TestLoadConfig_DetectorMode(t *testing.T) {
+	os.Setenv("EARTHWORM_DETECTOR_MODE", "both")
+	os.Setenv("EARTHWORM_ADAPTIVE_CRITICAL_Z", "10.5")
+	os.Setenv("EARTHWORM_ADAPTIVE_WARNING_Z", "-1")
+	defer func() {
+		os.Unsetenv("EARTHWORM_DETECTOR_MODE")
+		os.Unsetenv("EARTHWORM_ADAPTIVE_CRITICAL_Z")
+		os.Unsetenv("EARTHWORM_ADAPTIVE_WARNING_Z")
+	}()
+
+	cfg := LoadConfig()
+	if cfg.DetectorMode != detectorModeBoth {
+		t.Errorf("DetectorMode = %q, want both", cfg.DetectorMode)
+	}
+	if a := cfg.AdaptiveConfig(); a.CriticalZ != 10.5 || a.WarningZ != DefaultAdaptiveConfig().WarningZ {
+		t.Errorf("AdaptiveConfig = %+v, want critical 10.5 and default warning", a)
+	}
+
+	os.Setenv("EARTHWORM_DETECTOR_MODE", "magic")
+	if cfg = LoadConfig(); cfg.DetectorMode != detectorModeThreshold {
+		t.Errorf("DetectorMode with invalid env = %q, want threshold", cfg.DetectorMode)
+	}
+}
+
+func TestLoadConfig_Outage(t *testing.T) {
+	os.Setenv("EARTHWORM_OUTAGE_LABELS", "topology.kubernetes.io/zone,rack")
+	os.Setenv("EARTHWORM_OUTAGE_WINDOW_S", "60")
+	os.Setenv("EARTHWORM_OUTAGE_FRACTION", "1.5")
+	defer func() {
+		os.Unsetenv("EARTHWORM_OUTAGE_LABELS")
+		os.Unsetenv("EARTHWORM_OUTAGE_WINDOW_S")
+		os.Unsetenv("EARTHWORM_OUTAGE_FRACTION")
+	}()
+
+	o := LoadConfig().OutageConfig()
+	def := DefaultOutageConfig()
+	if o.Window != time.Minute || o.MinNodes != def.MinNodes || o.Fraction != def.Fraction || len(o.LabelKeys) != 2 || o.LabelKeys[1] != "rack" {
+		t.Errorf("OutageConfig = %+v, want 60s window, rack label and default fraction", o)
+	}
+}
+
+func TestLoadConfig_MemoryLimits(t *testing.T) {
+	os.Setenv("EARTHWORM_MEMORY_MAX_AGE_S", "0")
+	os.Setenv("EARTHWORM_MEMORY_MAX_ENTRIES", "many")
+	defer func() {
+		os.Unsetenv("EARTHWORM_MEMORY_MAX_AGE_S")
+		os.Unsetenv("EARTHWORM_MEMORY_MAX_ENTRIES")
+	}()
+
+	cfg := LoadConfig()
+	if cfg.MemoryMaxAgeS != 0 || cfg.MemoryMaxEntries != defaultMemoryMaxEntries || cfg.MemoryMaxTotal != defaultMemoryMaxTotalEntries {
+		t.Errorf("memory limits = %ds, %d entries, %d in all, want no age limit and the default entries", cfg.MemoryMaxAgeS, cfg.MemoryMaxEntries, cfg.MemoryMaxTotal)
+	}
+}
+
+func TestLoadConfig_RedisTrimming(t *testing.T) {
+	os.Setenv("EARTHWORM_REDIS_TTL_S", "86400")
+	os.Setenv("EARTHWORM_REDIS_MAX_LEN", "-1")
+	defer func() {
+		os.Unsetenv("EARTHWORM_REDIS_TTL_S")
+		os.Unsetenv("EARTHWORM_REDIS_MAX_LEN")
+	}()
+
+	cfg := LoadConfig()
+	if cfg.RedisTTLS != 86400 || cfg.RedisMaxLen != 0 {
+		t.Errorf("Redis TTL %ds, max len %d, want 86400s and the unbounded default", cfg.RedisTTLS, cfg.RedisMaxLen)
+	}
+
+	os.Setenv("EARTHWORM_REDIS_TTL_S", "0")
+	os.Setenv("EARTHWORM_REDIS_MAX_LEN", "10000")
+	if cfg = LoadConfig(); cfg.RedisTTLS != int(defaultTTL/time.Second) || cfg.RedisMaxLen != 10000 {
+		t.Errorf("Redis TTL %ds, max len %d, want the default TTL and 10000", cfg.RedisTTLS, cfg.RedisMaxLen)
+	}
+}
+
+func TestLoadConfig_RedisConnection(t *testing.T) {
+	env := map[string]string{
+		"EARTHWORM_REDIS_CLUSTER_ADDRS":  "redis-0:6379,redis-1:6379",
+		"EARTHWORM_REDIS_USERNAME":       "earthworm",
+		"EARTHWORM_REDIS_PASSWORD_FILE":  "/var/run/secrets/redis/password",
+		"EARTHWORM_REDIS_TLS":            "yes",
+		"EARTHWORM_REDIS_TLS_CA_FILE":    "/var/run/secrets/redis/ca.crt",
+		"EARTHWORM_REDIS_POOL_SIZE":      "20",
+		"EARTHWORM_REDIS_READ_TIMEOUT_S": "2",
+		"EARTHWORM_REDIS_DB":             "-1",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	cfg := LoadConfig()
+	if len(cfg.RedisClusterAddrs) != 2 || cfg.RedisUsername != "earthworm" || cfg.RedisPasswordFile != "/var/run/secrets/redis/password" ||
+		cfg.RedisTLSCAFile != "/var/run/secrets/redis/ca.crt" || cfg.RedisPoolSize != 20 || cfg.RedisReadTimeoutS != 2 {
+		t.Errorf("Redis connection config = %+v", cfg)
+	}
+	if cfg.RedisTLS || cfg.RedisDB != 0 {
+		t.Errorf("RedisTLS = %t, RedisDB = %d with invalid env, want the defaults", cfg.RedisTLS, cfg.RedisDB)
+	}
+}
+
+func TestLoadConfig_SQLite(t *testing.T) {
+	os.Setenv("EARTHWORM_SQLITE_PATH", "/var/lib/earthworm/earthworm.db")
+	os.Setenv("EARTHWORM_SQLITE_RETENTION_S", "0")
+	defer func() {
+		os.Unsetenv("EARTHWORM_SQLITE_PATH")
+		os.Unsetenv("EARTHWORM_SQLITE_RETENTION_S")
+	}()
+
+	cfg := LoadConfig()
+	if cfg.SQLitePath != "/var/lib/earthworm/earthworm.db" || cfg.SQLiteRetentionS != int(defaultTTL/time.Second) {
+		t.Errorf("SQLite = %q, %ds, want the path and the default retention", cfg.SQLitePath, cfg.SQLiteRetentionS)
+	}
+
+	os.Setenv("EARTHWORM_SQLITE_RETENTION_S", "86400")
+	if cfg = LoadConfig(); cfg.SQLiteRetentionS != 86400 {
+		t.Errorf("SQLiteRetentionS = %d, want 86400", cfg.SQLiteRetentionS)
+	}
+}
+
+func TestLoadConfig_Postgres(t *testing.T) {
+	os.Setenv("EARTHWORM_POSTGRES_DSN", "postgres://earthworm@db.local/earthworm?sslmode=require")
+	os.Setenv("EARTHWORM_POSTGRES_RETENTION_S", "1209600")
+	os.Setenv("EARTHWORM_POSTGRES_TIMESCALE", "true")
+	defer func() {
+		os.Unsetenv("EARTHWORM_POSTGRES_DSN")
+		os.Unsetenv("EARTHWORM_POSTGRES_RETENTION_S")
+		os.Unsetenv("EARTHWORM_POSTGRES_TIMESCALE")
+	}()
+
+	cfg := LoadConfig()
+	if cfg.PostgresDSN != "postgres://earthworm@db.local/earthworm?sslmode=require" || cfg.PostgresRetentionS != 1209600 || !cfg.PostgresTimescale {
+		t.Errorf("Postgres = %q, %ds, timescale %t", cfg.PostgresDSN, cfg.PostgresRetentionS, cfg.PostgresTimescale)
+	}
+
+	os.Setenv("EARTHWORM_POSTGRES_TIMESCALE", "sometimes")
+	if cfg = LoadConfig(); cfg.PostgresTimescale {
+		t.Error("PostgresTimescale with invalid env = true, want false")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -15,6 +16,7 @@ import (
 type errorStore struct {
 	saveErr           error
 	getByTimeRangeErr error
+	getSilencesErr    error
 }
 
 func (e *errorStore) Save(_ context.Context, _ Heartbeat) error {
@@ -27,6 +29,18 @@ func (e *errorStore) GetLatestByNode(_ context.Context, _ string) (*Heartbeat, e
 	return nil, nil
 }
 func (e *errorStore) Ping(_ context.Context) error { return nil }
+func (e *errorStore) QueryHeartbeats(_ context.Context, _ HeartbeatQuery) (HeartbeatPage, error) {
+	return HeartbeatPage{}, e.getByTimeRangeErr
+}
+func (e *errorStore) DownsampleHeartbeats(_ context.Context, _ HeartbeatQuery, _ time.Duration) ([]HeartbeatBucket, error) {
+	return nil, e.getByTimeRangeErr
+}
+func (e *errorStore) Nodes(_ context.Context) ([]string, error) {
+	return nil, e.getByTimeRangeErr
+}
+func (e *errorStore) HasNode(_ context.Context, _ string) (bool, error) {
+	return false, e.getByTimeRangeErr
+}
 func (e *errorStore) SaveKernelEvent(_ context.Context, _ EnrichedEvent) error {
 	return nil
 }
@@ -36,10 +50,21 @@ func (e *errorStore) GetKernelEvents(_ context.Context, _ string, _, _ time.Time
 func (e *errorStore) GetKernelEventsByType(_ context.Context, _ string, _ string, _, _ time.Time) ([]EnrichedEvent, error) {
 	return nil, nil
 }
+func (e *errorStore) CountKernelEvents(_ context.Context, _ string, _, _ time.Time) (map[string]map[string]int, error) {
+	return nil, nil
+}
 func (e *errorStore) SaveCausalChain(_ context.Context, _ CausalChain) error { return nil }
 func (e *errorStore) GetCausalChains(_ context.Context, _ string, _, _ time.Time) ([]CausalChain, error) {
 	return nil, nil
 }
+func (e *errorStore) GetLatestCausalChain(_ context.Context, _ string) (*CausalChain, error) {
+	return nil, nil
+}
+func (e *errorStore) SaveSilence(_ context.Context, _ Silence) error { return e.saveErr }
+func (e *errorStore) GetSilences(_ context.Context) ([]Silence, error) {
+	return nil, e.getSilencesErr
+}
+func (e *errorStore) DeleteSilence(_ context.Context, _ string) error { return e.saveErr }
 
 // --- Task 13.1: Unit tests for POST/GET heartbeat handlers ---
 // Validates: Requirements 11.1, 11.2
@@ -297,6 +322,95 @@ func TestUnit_GetHeartbeatsHandler_StoreUnreachable_Returns503(t *testing.T) {
 	}
 }
 
+func TestUnit_GetHeartbeatsHandler_FiltersAndPages(t *testing.T) {
+	cleanup := setupTestStore()
+	defer cleanup()
+
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		store.Save(context.Background(), Heartbeat{NodeName: "node-a", Namespace: "kube-node-lease", Status: "Ready", Timestamp: base.Add(time.Duration(i) * time.Second)})
+		store.Save(context.Background(), Heartbeat{NodeName: "node-b", Namespace: "kube-node-lease", Status: "Ready", Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	// Follow the Link header from page to page
+	var got []Heartbeat
+	next := "/api/heartbeats?node=node-a&from=2025-06-15T12:00:01Z&limit=3"
+	for pages := 0; next != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("more pages than expected")
+		}
+		rec := httptest.NewRecorder()
+		getHeartbeatsHandler(rec, httptest.NewRequest(http.MethodGet, next, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", next, rec.Code, rec.Body)
+		}
+		var page []Heartbeat
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		got = append(got, page...)
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			if cursor := rec.Header().Get("X-Next-Cursor"); !strings.Contains(link, "cursor="+cursor) {
+				t.Errorf("Link %q does not carry cursor %q", link, cursor)
+			}
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	if len(got) != 4 || !got[0].Timestamp.Equal(base.Add(time.Second)) || !got[3].Timestamp.Equal(base.Add(4*time.Second)) {
+		t.Fatalf("heartbeats = %+v, want node-a's from 1s to 4s", got)
+	}
+	for _, hb := range got {
+		if hb.NodeName != "node-a" {
+			t.Errorf("got a heartbeat of %s", hb.NodeName)
+		}
+	}
+}
+
+func TestUnit_GetHeartbeatsHandler_Step_ReturnsBuckets(t *testing.T) {
+	cleanup := setupTestStore()
+	defer cleanup()
+
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		store.Save(context.Background(), Heartbeat{NodeName: "node-a", Timestamp: base.Add(time.Duration(i) * 20 * time.Second)})
+	}
+
+	rec := httptest.NewRecorder()
+	getHeartbeatsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/heartbeats?step=1m", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var buckets []HeartbeatBucket
+	if err := json.NewDecoder(rec.Body).Decode(&buckets); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(buckets) != 2 || buckets[0].Count != 3 || buckets[1].Count != 3 || buckets[1].MaxGapSeconds != 20 {
+		t.Errorf("buckets = %+v, want two minutes of 3 heartbeats 20s apart", buckets)
+	}
+}
+
+func TestUnit_GetHeartbeatsHandler_InvalidParams_Returns400(t *testing.T) {
+	cleanup := setupTestStore()
+	defer cleanup()
+
+	for _, query := range []string{
+		"from=yesterday",
+		"to=2025-06-15",
+		"limit=0",
+		"limit=10001",
+		"step=500ms",
+		"step=often",
+		"cursor=bm90IGEgY3Vyc29y",
+	} {
+		rec := httptest.NewRecorder()
+		getHeartbeatsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/heartbeats?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
 // --- Task 5.1: Unit test for server rejecting invalid JSON on /api/ebpf/events ---
 // Validates: Requirements 9.2
 
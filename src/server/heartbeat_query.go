@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Page sizes of heartbeat queries.
+const (
+	defaultHeartbeatLimit = 1000
+	maxHeartbeatLimit     = 10000
+)
+
+// ErrInvalidCursor is returned for a heartbeat cursor the store did not hand
+// out.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// The earliest and latest times Unix nanoseconds can hold close the open
+// ends of store queries.
+var (
+	minStoreTime = time.Unix(0, math.MinInt64)
+	maxStoreTime = time.Unix(0, math.MaxInt64)
+)
+
+// HeartbeatQuery selects heartbeats. Empty NodeName, Namespace and Status
+// match any, and a zero From or To leaves that end of the inclusive range
+// open. Results are ordered by timestamp, then node name.
+//
+// A page holds at most Limit heartbeats, 1000 when zero. Cursor is the
+// NextCursor of the previous page, or empty for the first.
+type HeartbeatQuery struct {
+	NodeName  string
+	Namespace string
+	Status    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Cursor    string
+}
+
+// HeartbeatPage is one page of a heartbeat query. NextCursor is empty on
+// the last page.
+type HeartbeatPage struct {
+	Heartbeats []Heartbeat
+	NextCursor string
+}
+
+// HeartbeatBucket summarizes one node's heartbeats within one step of a
+// downsampled range. Buckets start at multiples of the step since the Unix
+// epoch. A gap is the time since the node's previous heartbeat in the range
+// and counts in the bucket of the later heartbeat; the gaps are zero when
+// none ends in the bucket.
+type HeartbeatBucket struct {
+	NodeName      string    `json:"nodeName"`
+	Start         time.Time `json:"start"`
+	Count         int       `json:"count"`
+	First         time.Time `json:"first"`
+	Last          time.Time `json:"last"`
+	MinGapSeconds float64   `json:"minGapSeconds"`
+	MaxGapSeconds float64   `json:"maxGapSeconds"`
+}
+
+// heartbeatCursor is the position after the last heartbeat of a page: its
+// timestamp and node, and how many heartbeats with both of them have been
+// returned, as heartbeats of equal timestamp can span pages.
+type heartbeatCursor struct {
+	Timestamp int64  `json:"t"` // Unix nanoseconds
+	NodeName  string `json:"n"`
+	Skip      int    `json:"s"`
+}
+
+func (c heartbeatCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cursor decodes the query's cursor, nil for the first page.
+func (q HeartbeatQuery) cursor() (*heartbeatCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c heartbeatCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Skip < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func (q HeartbeatQuery) limit() int {
+	if q.Limit <= 0 {
+		return defaultHeartbeatLimit
+	}
+	return min(q.Limit, maxHeartbeatLimit)
+}
+
+// need returns how many heartbeats at or after the cursor a backend must
+// read, in order, to fill the page and know whether another follows.
+func (q HeartbeatQuery) need(c *heartbeatCursor) int {
+	n := q.limit() + 1
+	if c != nil {
+		n += c.Skip
+	}
+	return n
+}
+
+// bounds returns the range left to read: from the later of From and the
+// cursor to To, with open ends closed.
+func (q HeartbeatQuery) bounds(c *heartbeatCursor) (from, to time.Time) {
+	from, to = q.From, q.To
+	if from.IsZero() || from.Before(minStoreTime) {
+		from = minStoreTime
+	}
+	if to.IsZero() || to.After(maxStoreTime) {
+		to = maxStoreTime
+	}
+	if c != nil && c.Timestamp > from.UnixNano() {
+		from = time.Unix(0, c.Timestamp)
+	}
+	return from, to
+}
+
+// matches reports whether hb passes the query's filters and range.
+func (q HeartbeatQuery) matches(hb Heartbeat) bool {
+	from, to := q.bounds(nil)
+	return (q.NodeName == "" || hb.NodeName == q.NodeName) &&
+		(q.Namespace == "" || hb.Namespace == q.Namespace) &&
+		(q.Status == "" || hb.Status == q.Status) &&
+		!hb.Timestamp.Before(from) && !hb.Timestamp.After(to)
+}
+
+// selects reports whether hb matches the query and is not before the
+// cursor. Heartbeats at the cursor are selected; they are skipped by count.
+func (q HeartbeatQuery) selects(hb Heartbeat, c *heartbeatCursor) bool {
+	return q.matches(hb) && (c == nil || compareHeartbeat(hb, c.Timestamp, c.NodeName) >= 0)
+}
+
+// compareHeartbeat orders hb against the position of a heartbeat at ts, in
+// Unix nanoseconds, of node.
+func compareHeartbeat(hb Heartbeat, ts int64, node string) int {
+	switch t := hb.Timestamp.UnixNano(); {
+	case t < ts:
+		return -1
+	case t > ts:
+		return 1
+	case hb.NodeName < node:
+		return -1
+	case hb.NodeName > node:
+		return 1
+	}
+	return 0
+}
+
+// pageHeartbeats returns the page of q from candidates. Candidates need not
+// be sorted, but heartbeats of equal timestamp and node must come in the
+// same order for every page. Candidates the query does not select are
+// dropped, so backends may read more than they need.
+func pageHeartbeats(candidates []Heartbeat, q HeartbeatQuery, c *heartbeatCursor) HeartbeatPage {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return compareHeartbeat(candidates[i], candidates[j].Timestamp.UnixNano(), candidates[j].NodeName) < 0
+	})
+	skip := 0
+	if c != nil {
+		skip = c.Skip
+	}
+	page := HeartbeatPage{Heartbeats: []Heartbeat{}}
+	for _, hb := range candidates {
+		if !q.selects(hb, c) {
+			continue
+		}
+		if skip > 0 && compareHeartbeat(hb, c.Timestamp, c.NodeName) == 0 {
+			skip--
+			continue
+		}
+		if len(page.Heartbeats) == q.limit() {
+			page.NextCursor = nextHeartbeatCursor(page.Heartbeats, c).encode()
+			break
+		}
+		page.Heartbeats = append(page.Heartbeats, hb)
+	}
+	return page
+}
+
+// nextHeartbeatCursor returns the cursor after the last of hbs, a page read
+// after c.
+func nextHeartbeatCursor(hbs []Heartbeat, c *heartbeatCursor) heartbeatCursor {
+	last := hbs[len(hbs)-1]
+	next := heartbeatCursor{Timestamp: last.Timestamp.UnixNano(), NodeName: last.NodeName}
+	for i := len(hbs) - 1; i >= 0 && compareHeartbeat(hbs[i], next.Timestamp, next.NodeName) == 0; i-- {
+		next.Skip++
+	}
+	if c != nil && c.Timestamp == next.Timestamp && c.NodeName == next.NodeName {
+		next.Skip += c.Skip
+	}
+	return next
+}
+
+// downsampleHeartbeats buckets the heartbeats of hbs that q matches by node
+// and step, ordered by bucket start, then node name.
+func downsampleHeartbeats(hbs []Heartbeat, q HeartbeatQuery, step time.Duration) []HeartbeatBucket {
+	var matched []Heartbeat
+	for _, hb := range hbs {
+		if q.matches(hb) {
+			matched = append(matched, hb)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].NodeName != matched[j].NodeName {
+			return matched[i].NodeName < matched[j].NodeName
+		}
+		return matched[i].Timestamp.Before(matched[j].Timestamp)
+	})
+
+	result := []HeartbeatBucket{}
+	gaps := 0 // in the last bucket
+	for i, hb := range matched {
+		start := bucketStart(hb.Timestamp, step)
+		if n := len(result); n == 0 || result[n-1].NodeName != hb.NodeName || !result[n-1].Start.Equal(start) {
+			result = append(result, HeartbeatBucket{NodeName: hb.NodeName, Start: start, First: hb.Timestamp})
+			gaps = 0
+		}
+		b := &result[len(result)-1]
+		if i > 0 && matched[i-1].NodeName == hb.NodeName {
+			gap := hb.Timestamp.Sub(matched[i-1].Timestamp).Seconds()
+			if gaps == 0 || gap < b.MinGapSeconds {
+				b.MinGapSeconds = gap
+			}
+			if gaps == 0 || gap > b.MaxGapSeconds {
+				b.MaxGapSeconds = gap
+			}
+			gaps++
+		}
+		b.Count++
+		b.Last = hb.Timestamp
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].Start.Equal(result[j].Start) {
+			return result[i].Start.Before(result[j].Start)
+		}
+		return result[i].NodeName < result[j].NodeName
+	})
+	return result
+}
+
+// bucketStart returns the start of the step ts falls in, counting steps
+// from the Unix epoch.
+func bucketStart(ts time.Time, step time.Duration) time.Time {
+	ns := ts.UnixNano()
+	offset := ns % int64(step)
+	if offset < 0 {
+		offset += int64(step)
+	}
+	return time.Unix(0, ns-offset).UTC()
+}
+
+// sqlHeartbeatFilter returns the WHERE conditions selecting q's heartbeats
+// with ts between from and to, and args with their arguments appended.
+// placeholder returns the placeholder of the nth argument.
+func sqlHeartbeatFilter(q HeartbeatQuery, from, to any, args []any, placeholder func(n int) string) (string, []any) {
+	args = append(args, from, to)
+	where := "ts BETWEEN " + placeholder(len(args)-1) + " AND " + placeholder(len(args))
+	for _, f := range []struct{ column, value string }{
+		{"node_name", q.NodeName},
+		{"namespace", q.Namespace},
+		{"status", q.Status},
+	} {
+		if f.value != "" {
+			args = append(args, f.value)
+			where += " AND " + f.column + " = " + placeholder(len(args))
+		}
+	}
+	return where, args
+}
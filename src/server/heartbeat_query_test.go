@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"pgregory.net/rapid"
+)
+
+// Feature: earthworm-improvements, Property 17: Walking heartbeat query pages returns every selected heartbeat once, in order
+func TestProperty17_HeartbeatQueryPages(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	rapid.Check(t, func(t *rapid.T) {
+		s := NewMemoryStore()
+		// Few distinct timestamps, so pages often split heartbeats of one
+		// node and instant
+		n := rapid.IntRange(0, 40).Draw(t, "n")
+		var saved []Heartbeat
+		for i := 0; i < n; i++ {
+			hb := Heartbeat{
+				NodeName:  rapid.SampledFrom([]string{"node-a", "node-b", "node-c"}).Draw(t, "node"),
+				Status:    rapid.SampledFrom([]string{"Ready", "NotReady"}).Draw(t, "status"),
+				Timestamp: base.Add(time.Duration(rapid.IntRange(0, 5).Draw(t, "offset")) * time.Second),
+				EbpfPID:   uint32(i),
+			}
+			s.Save(ctx, hb)
+			saved = append(saved, hb)
+		}
+		q := HeartbeatQuery{Limit: rapid.IntRange(1, 7).Draw(t, "limit")}
+		if rapid.Bool().Draw(t, "filterStatus") {
+			q.Status = "NotReady"
+		}
+		if rapid.Bool().Draw(t, "filterFrom") {
+			q.From = base.Add(2 * time.Second)
+		}
+
+		var want []Heartbeat
+		for _, hb := range saved {
+			if q.matches(hb) {
+				want = append(want, hb)
+			}
+		}
+		sort.SliceStable(want, func(i, j int) bool {
+			return compareHeartbeat(want[i], want[j].Timestamp.UnixNano(), want[j].NodeName) < 0
+		})
+
+		var got []Heartbeat
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatalf("more pages than heartbeats")
+			}
+			page, err := s.QueryHeartbeats(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, page.Heartbeats...)
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if len(got) != len(want) {
+			t.Fatalf("pages hold %d heartbeats, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if got[i].EbpfPID != want[i].EbpfPID {
+				t.Fatalf("heartbeat %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+}
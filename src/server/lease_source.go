@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"earthworm/src/kubernetes"
+)
+
+// heartbeatFromLease converts a node Lease renewal into a Heartbeat.
+// A renewed Lease means the kubelet is alive, so the status is always "Ready".
+func heartbeatFromLease(lh kubernetes.LeaseHeartbeat) Heartbeat {
+	return Heartbeat{
+		NodeName:             lh.NodeName,
+		Namespace:            lh.Namespace,
+		Timestamp:            lh.RenewTime,
+		Status:               "Ready",
+		HolderIdentity:       lh.HolderIdentity,
+		LeaseDurationSeconds: lh.LeaseDurationSeconds,
+	}
+}
+
+// ingestHeartbeat runs a heartbeat through the standard pipeline: gap metrics,
+// node state tracking, persistence, WebSocket broadcast and alerting. Gap
+// alerts fire from sweepHeartbeats while the gap is open, so an arriving
+// heartbeat only counts toward resolving its node's gap alert; drift alerts
+// fire on arrival. Without an AlertManager, drift alerts go straight to the
+// dispatcher.
+// The gap is measured before Save so it is taken from the previous heartbeat
+// for the node rather than the one being ingested.
+func ingestHeartbeat(ctx context.Context, hb Heartbeat) error {
+	return ingestHeartbeatAt(ctx, hb, time.Now())
+}
+
+// ingestHeartbeatAt is ingestHeartbeat for sources that run on their own
+// clock, such as a simulation: the AlertManager sees the heartbeat at now.
+func ingestHeartbeatAt(ctx context.Context, hb Heartbeat, now time.Time) error {
+	if detector != nil {
+		gap, severity, hasPrevious := detector.CheckGap(hb.NodeName, hb.Timestamp)
+		observeHeartbeat(hb, gap, hasPrevious)
+		if severity != "" && nodeTracker != nil {
+			nodeTracker.ObserveAlert(Alert{NodeName: hb.NodeName, Namespace: hb.Namespace, Gap: gap.Seconds(), Severity: severity, Timestamp: hb.Timestamp})
+		}
+	} else {
+		observeHeartbeat(hb, 0, false)
+	}
+	var drift *Alert
+	if adaptiveDetector != nil {
+		_, drift = adaptiveDetector.Observe(hb)
+	}
+
+	if err := store.Save(ctx, hb); err != nil {
+		return fmt.Errorf("save heartbeat: %w", err)
+	}
+	if nodeTracker != nil {
+		nodeTracker.ObserveHeartbeat(hb)
+	}
+	if outageDetector != nil {
+		outageDetector.ObserveHeartbeat(hb)
+	}
+
+	if hub != nil {
+		hub.BroadcastHeartbeat(hb)
+	}
+
+	if alertManager != nil {
+		alertManager.Resolve(hb.NodeName, alertTypeHeartbeatGap, now)
+	}
+	if adaptiveDetector != nil {
+		fireOrResolve(drift, hb.NodeName, alertTypeIntervalDrift, now)
+	}
+	return nil
+}
+
+// fireOrResolve fires alert through the AlertManager at now, or records a
+// healthy observation for the node's alert of alertType when alert is nil.
+// Without an AlertManager, alerts go straight to the dispatcher.
+func fireOrResolve(alert *Alert, nodeName, alertType string, now time.Time) {
+	switch {
+	case alertManager != nil && alert != nil:
+		alertManager.Fire(*alert, now)
+	case alertManager != nil:
+		alertManager.Resolve(nodeName, alertType, now)
+	case alert != nil && dispatcher != nil:
+		dispatcher.Dispatch(*alert)
+	}
+}
+
+// sweepHeartbeats runs the periodic half of the heartbeat pipeline at now:
+// nodes whose heartbeats stopped go NotReady, and every node with an open
+// gap fires its gap alert. The alert starts when the gap crossed its
+// warning threshold and keeps firing, escalating to critical, until the
+// node's next heartbeat resolves it. With an adaptive detector,
+// cfg.DetectorMode decides which gap alert is raised. Gap alerts need an
+// AlertManager to track the open gap.
+func sweepHeartbeats(now time.Time) {
+	if nodeTracker == nil {
+		return
+	}
+	nodeTracker.Sweep(now)
+	if alertManager == nil {
+		return
+	}
+	for _, node := range nodeTracker.Nodes() {
+		if alert := openGapAlert(node, now); alert != nil {
+			alert.RootCause = nodeTracker.RootCause(node)
+			alertManager.Fire(*alert, now)
+		}
+	}
+}
+
+// openGapAlert returns the alert for the node's open heartbeat gap at now,
+// with its kernel events, or nil while its heartbeats are on time.
+func openGapAlert(node string, now time.Time) *Alert {
+	var fixed, adaptive *Alert
+	var warm bool
+	if detector != nil {
+		fixed = detector.OpenGap(node, now)
+	}
+	if adaptiveDetector != nil {
+		adaptive, warm = adaptiveDetector.OpenGap(node, now)
+	}
+	alert := chooseGapAlert(cfg.DetectorMode, fixed, adaptive, warm)
+	if alert != nil && detector != nil {
+		detector.attachKernelEvents(alert)
+	}
+	return alert
+}
+
+// chooseGapAlert returns which of the fixed thresholds' and the adaptive
+// detector's gap alerts the detector mode raises, given whether the node's
+// adaptive baseline is warm. In adaptive mode the fixed thresholds apply
+// until the baseline has warmed up, so that nodes new to the server, or to a
+// restarted one, are not left unwatched.
+func chooseGapAlert(mode string, fixed, adaptive *Alert, warm bool) *Alert {
+	switch {
+	case mode == detectorModeAdaptive && warm:
+		return adaptive
+	case mode == detectorModeBoth:
+		return worseAlert(fixed, adaptive)
+	}
+	return fixed
+}
+
+// runHeartbeatSweeps calls sweepHeartbeats every interval until ctx is
+// cancelled.
+func runHeartbeatSweeps(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweepHeartbeats(now)
+		}
+	}
+}
+
+// forgetNode drops a node that left the cluster from the node state tracker
+// and the detectors, so that its silence is neither a gap nor part of an
+// outage, resolves its alerts and stops exporting its per-node metrics. Its
+// stored records age out as usual.
+func forgetNode(nodeName string, now time.Time) {
+	if nodeTracker != nil {
+		nodeTracker.Forget(nodeName)
+	}
+	if adaptiveDetector != nil {
+		adaptiveDetector.Forget(nodeName)
+	}
+	if outageDetector != nil {
+		outageDetector.Forget(nodeName)
+	}
+	if alertManager != nil {
+		alertManager.Resolve(nodeName, alertTypeHeartbeatGap, now)
+		alertManager.Resolve(nodeName, alertTypeIntervalDrift, now)
+	}
+	heartbeatGapSeconds.Delete(nodeName)
+	nodeLastHeartbeat.Delete(nodeName)
+}
+
+// runLeaseSource watches node Leases through the given clientset and feeds each
+// renewal into ingestHeartbeat, and forgets nodes whose Lease is deleted.
+// Blocks until ctx is cancelled.
+func runLeaseSource(ctx context.Context, clientset k8sclient.Interface) error {
+	watcher := kubernetes.NewLeaseWatcher(clientset, func(lh kubernetes.LeaseHeartbeat) {
+		if err := ingestHeartbeat(ctx, heartbeatFromLease(lh)); err != nil {
+			log.Printf("Failed to ingest lease heartbeat for %s: %v", lh.NodeName, err)
+		}
+	})
+	watcher.OnDelete(func(nodeName string) {
+		log.Printf("Lease of node %s deleted, forgetting the node", nodeName)
+		forgetNode(nodeName, time.Now())
+	})
+	return watcher.Run(ctx)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"earthworm/src/kubernetes"
+)
+
+// newTestNodeLease builds a kube-node-lease Lease renewed at the given time.
+func newTestNodeLease(nodeName string, renew time.Time) *coordinationv1.Lease {
+	holder := nodeName
+	duration := int32(40)
+	renewTime := metav1.NewMicroTime(renew)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName, Namespace: kubernetes.NodeLeaseNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+// waitForHeartbeats polls the global store until it holds n heartbeats.
+func waitForHeartbeats(t *testing.T, n int) []Heartbeat {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hbs, _ := store.GetByTimeRange(context.Background(), time.Time{}, time.Now().Add(24*time.Hour))
+		if len(hbs) >= n {
+			return hbs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d stored heartbeats", n)
+	return nil
+}
+
+func TestHeartbeatFromLease(t *testing.T) {
+	renew := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	hb := heartbeatFromLease(kubernetes.LeaseHeartbeat{
+		NodeName:             "node-01",
+		Namespace:            kubernetes.NodeLeaseNamespace,
+		HolderIdentity:       "node-01",
+		LeaseDurationSeconds: 40,
+		RenewTime:            renew,
+	})
+	if hb.NodeName != "node-01" || hb.Namespace != kubernetes.NodeLeaseNamespace {
+		t.Fatalf("unexpected identity: %+v", hb)
+	}
+	if !hb.Timestamp.Equal(renew) || hb.Status != "Ready" {
+		t.Fatalf("unexpected timestamp/status: %+v", hb)
+	}
+	if hb.HolderIdentity != "node-01" || hb.LeaseDurationSeconds != 40 {
+		t.Fatalf("lease fields not carried over: %+v", hb)
+	}
+}
+
+// TestLeaseSource_FakeClientset drives the lease source from client-go's fake
+// clientset and verifies renewals flow through store, detector, sweep and
+// dispatcher.
+func TestLeaseSource_FakeClientset(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origManager, origTracker := alertManager, nodeTracker
+	defer func() { alertManager, nodeTracker = origManager, origTracker }()
+
+	var mu sync.Mutex
+	var alerts []Alert
+	dispatcher = NewAlertDispatcher("", func(a Alert) {
+		mu.Lock()
+		alerts = append(alerts, a)
+		mu.Unlock()
+	})
+	alertManager = NewAlertManager(AlertTimings{RepeatInterval: time.Hour}, dispatcher.Dispatch)
+	nodeTracker = NewNodeStateTracker(detector, nil, nil)
+
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	client := fake.NewSimpleClientset(newTestNodeLease("node-01", base))
+
+	watchStarted := make(chan struct{})
+	client.PrependWatchReactor("*", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return false, nil, err
+		}
+		close(watchStarted)
+		return true, w, nil
+	})
+
+	// The source must be done with the globals before they are restored
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runLeaseSource(ctx, client)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case <-watchStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease informer never started watching")
+	}
+	waitForHeartbeats(t, 1)
+
+	// 50s without a renewal is beyond the 40s critical threshold
+	sweepHeartbeats(base.Add(50 * time.Second))
+	alertManager.Flush(base.Add(50 * time.Second))
+	leases := client.CoordinationV1().Leases(kubernetes.NodeLeaseNamespace)
+	if _, err := leases.Update(ctx, newTestNodeLease("node-01", base.Add(50*time.Second)), metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update lease: %v", err)
+	}
+
+	hbs := waitForHeartbeats(t, 2)
+	for _, hb := range hbs {
+		if hb.NodeName != "node-01" || hb.HolderIdentity != "node-01" || hb.LeaseDurationSeconds != 40 {
+			t.Fatalf("unexpected stored heartbeat: %+v", hb)
+		}
+	}
+
+	latest, err := store.GetLatestByNode(ctx, "node-01")
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestByNode: %v, %v", latest, err)
+	}
+	if !latest.Timestamp.Equal(base.Add(50 * time.Second)) {
+		t.Fatalf("latest timestamp = %v, want %v", latest.Timestamp, base.Add(50*time.Second))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	if alerts[0].Severity != "critical" || alerts[0].Gap != 50 {
+		t.Fatalf("unexpected alert: %+v", alerts[0])
+	}
+}
+
+// TestForgetNode verifies a deleted node stops alerting: its gap alert
+// resolves and the sweep no longer fires it.
+func TestForgetNode(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origManager, origTracker, origOutage := alertManager, nodeTracker, outageDetector
+	defer func() { alertManager, nodeTracker, outageDetector = origManager, origTracker, origOutage }()
+
+	alertManager = NewAlertManager(testAlertTimings(), func(Alert) {})
+	nodeTracker = NewNodeStateTracker(detector, nil, nil)
+	outageDetector = NewOutageDetector(DefaultOutageConfig(), detector, nil)
+
+	base := time.Now().Add(-time.Hour)
+	for _, ts := range []time.Time{base.Add(-10 * time.Second), base} {
+		if err := ingestHeartbeat(context.Background(), Heartbeat{NodeName: "node-01", Namespace: "kube-node-lease", Timestamp: ts, Status: "Ready"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if heartbeatGapSeconds.Count("node-01") == 0 || nodeLastHeartbeat.Value("node-01") == 0 {
+		t.Fatal("no per-node metrics for node-01")
+	}
+	sweepHeartbeats(base.Add(time.Minute))
+	if list := alertManager.Alerts(); len(list.Active) != 1 {
+		t.Fatalf("Alerts() = %+v, want the gap alert", list)
+	}
+
+	forgetNode("node-01", base.Add(time.Minute))
+	if nodes := nodeTracker.Nodes(); len(nodes) != 0 {
+		t.Errorf("tracker still knows %v", nodes)
+	}
+	sweepHeartbeats(base.Add(2 * time.Minute))
+	alertManager.Flush(base.Add(2 * time.Minute))
+	list := alertManager.Alerts()
+	if len(list.Active) != 0 || len(list.Resolved) != 1 {
+		t.Errorf("Alerts() = %+v, want the gap alert resolved", list)
+	}
+	if firing, _ := outageDetector.Evaluate(base.Add(2 * time.Minute)); len(firing) != 0 {
+		t.Errorf("deleted node in an outage: %+v", firing)
+	}
+	if heartbeatGapSeconds.Count("node-01") != 0 || nodeLastHeartbeat.Value("node-01") != 0 {
+		t.Error("per-node metrics of node-01 still exported")
+	}
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"earthworm/src/kubernetes"
@@ -20,11 +23,18 @@ var (
 	hub          *Hub
 	detector     *AnomalyDetector
 	dispatcher   *AlertDispatcher
+	alertManager *AlertManager // deduplicates and times alerts for the dispatcher
+	silences     *Silences
 	chainBuilder *CausalChainBuilder
 	predEngine   *PredictionEngine
 	replayStore  *ReplayStore
 	topoMap      *NetworkTopologyMap
+	nodeTracker  *NodeStateTracker // Ready/NotReady transitions
 	ebpfEnabled  bool
+
+	thresholdPolicy  *ThresholdPolicy  // per-node thresholds for detector
+	adaptiveDetector *AdaptiveDetector // nil in threshold mode
+	outageDetector   *OutageDetector   // nil when disabled
 )
 
 // Dummy PodInfo slice for correlation testing
@@ -57,24 +67,34 @@ func ebpfEventsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, event := range events {
-		if err := store.SaveKernelEvent(context.Background(), event); err != nil {
+		if err := ingestKernelEvent(context.Background(), event); err != nil {
 			log.Printf("Failed to save kernel event: %v", err)
-			continue
-		}
-		if hub != nil {
-			hub.BroadcastEbpfEvent(event)
-		}
-		if predEngine != nil {
-			predEngine.Analyze(event.NodeName, []EnrichedEvent{event})
-		}
-		if topoMap != nil && event.EventType == "network_audit" {
-			topoMap.Record(event)
 		}
 	}
 
 	w.WriteHeader(http.StatusCreated)
 }
 
+// ingestKernelEvent runs a kernel event through the standard pipeline:
+// persistence, metrics, WebSocket broadcast, failure prediction and the
+// network topology.
+func ingestKernelEvent(ctx context.Context, event EnrichedEvent) error {
+	if err := store.SaveKernelEvent(ctx, event); err != nil {
+		return err
+	}
+	kernelEventsTotal.Inc(event.EventType)
+	if hub != nil {
+		hub.BroadcastEbpfEvent(event)
+	}
+	if predEngine != nil {
+		predEngine.Analyze(event.NodeName, []EnrichedEvent{event})
+	}
+	if topoMap != nil && event.EventType == "network_audit" {
+		topoMap.Record(event)
+	}
+	return nil
+}
+
 // networkTopologyHandler returns the current network topology as a JSON array of ConnectionRecords.
 func networkTopologyHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -100,47 +120,85 @@ func heartbeatHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, fmt.Sprintf("Invalid JSON: %s", err.Error()), http.StatusBadRequest)
 		return
 	}
-	if err := store.Save(context.Background(), hb); err != nil {
+	if err := ingestHeartbeat(context.Background(), hb); err != nil {
 		writeJSONError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Broadcast to WebSocket clients
-	if hub != nil {
-		hub.BroadcastHeartbeat(hb)
-	}
-
-	// Evaluate for anomalies
-	if detector != nil {
-		if alert := detector.Evaluate(hb); alert != nil {
-			if dispatcher != nil {
-				dispatcher.Dispatch(*alert)
-			}
-		}
-	}
-
 	w.WriteHeader(http.StatusCreated)
 }
 
-// getHeartbeatsHandler serves heartbeat data via GET.
+// getHeartbeatsHandler serves heartbeat data via GET, filtered by node,
+// namespace, status and an RFC 3339 from/to range. Heartbeats come as a JSON
+// array in pages of up to limit; the X-Next-Cursor and Link headers point to
+// the next page. With step, such as 30s, it serves per-node buckets instead.
 func getHeartbeatsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	// Use a wide time range to return all heartbeats
-	from := time.Time{}
-	to := time.Now().Add(24 * time.Hour)
-	hbs, err := store.GetByTimeRange(context.Background(), from, to)
+	params := r.URL.Query()
+	q := HeartbeatQuery{
+		NodeName:  params.Get("node"),
+		Namespace: params.Get("namespace"),
+		Status:    params.Get("status"),
+		Cursor:    params.Get("cursor"),
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if v := params.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSONError(w, fmt.Sprintf("%s=%q is not an RFC 3339 time", bound.name, v), http.StatusBadRequest)
+				return
+			}
+			*bound.t = t
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxHeartbeatLimit {
+			writeJSONError(w, fmt.Sprintf("limit=%q is not between 1 and %d", v, maxHeartbeatLimit), http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+
+	if v := params.Get("step"); v != "" {
+		step, err := time.ParseDuration(v)
+		if err != nil || step < time.Second {
+			writeJSONError(w, fmt.Sprintf("step=%q is not a duration of at least 1s", v), http.StatusBadRequest)
+			return
+		}
+		buckets, err := store.DownsampleHeartbeats(r.Context(), q, step)
+		if err != nil {
+			writeJSONError(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buckets)
+		return
+	}
+
+	page, err := store.QueryHeartbeats(r.Context(), q)
+	if errors.Is(err, ErrInvalidCursor) {
+		writeJSONError(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		writeJSONError(w, "Service unavailable", http.StatusServiceUnavailable)
 		return
 	}
-	if hbs == nil {
-		hbs = []Heartbeat{}
+	if page.NextCursor != "" {
+		params.Set("cursor", page.NextCursor)
+		next := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(hbs)
+	json.NewEncoder(w).Encode(page.Heartbeats)
 }
 
 // setCORS adds CORS headers based on configured origins.
@@ -151,8 +209,9 @@ func setCORS(next http.Handler, origins []string) http.Handler {
 			origin = origins[0]
 		}
 		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor, Link")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -168,26 +227,67 @@ func main() {
 	simNodes := flag.Int("sim-nodes", 50, "Number of simulated nodes")
 	simOutput := flag.String("sim-output", "", "Output directory for simulation data files")
 	simSeed := flag.Int64("sim-seed", 0, "Random seed for simulation (0 = time-based)")
+	simSpeed := flag.Float64("sim-speed", 1, "Simulated seconds replayed per wall-clock second (0 = as fast as possible)")
+	simScore := flag.String("sim-score", "", "Score detection on the simulation in-process, write the JSON report to this file (- for stdout) and exit")
+	simConfigFile := flag.String("sim-config", "", "YAML simulation file; implies -sim-mode and replaces -sim-nodes, -sim-duration and -sim-seed")
 	ebpfFlag := flag.Bool("ebpf", false, "Enable eBPF kernel observability (requires Linux 5.8+ with CAP_BPF)")
+	leaseWatch := flag.Bool("lease-watch", false, "Ingest real node heartbeats and Ready conditions by watching Leases in kube-node-lease and Nodes")
+	kubeconfig := flag.String("kubeconfig", "", "Path to kubeconfig for -lease-watch (empty = in-cluster config)")
 	flag.Parse()
 
 	ebpfEnabled = *ebpfFlag
+	// A simulation sweeps and flushes alerts on its own clock (see ingestSimTick)
+	simulated := !*leaseWatch && (*simMode || *simConfigFile != "")
 
 	cfg = LoadConfig()
 
-	logFile, err := os.OpenFile(cfg.LogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+	// Simulations and scoring runs log to stderr unless EARTHWORM_LOG_FILE
+	// is set, so trying one out leaves no log file behind
+	if os.Getenv("EARTHWORM_LOG_FILE") != "" || (*simScore == "" && !simulated) {
+		logFile, err := os.OpenFile(cfg.LogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		log.SetOutput(logFile)
+	}
+
+	// Score the detection pipeline against a simulation's ground truth and exit
+	if *simScore != "" {
+		simConfig, err := simulationConfig(*simConfigFile, *simNodes, *simDuration, *simSeed)
+		if err == nil {
+			err = writeScoreReport(*simScore, simConfig)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Simulation scoring failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	log.SetOutput(logFile)
 
 	// Initialize store based on config
 	switch cfg.StoreType {
 	case "redis":
-		store = NewRedisStore(cfg.RedisAddr)
+		redisStore, err := NewRedisStore(cfg.RedisOptions())
+		if err != nil {
+			log.Fatalf("Failed to open Redis store: %v", err)
+		}
+		store = redisStore
+	case "sqlite":
+		sqliteStore, err := NewSQLiteStore(cfg.SQLitePath, time.Duration(cfg.SQLiteRetentionS)*time.Second)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite store: %v", err)
+		}
+		store = sqliteStore
+	case "postgres":
+		postgresStore, err := NewPostgresStore(cfg.PostgresDSN, time.Duration(cfg.PostgresRetentionS)*time.Second, cfg.PostgresTimescale)
+		if err != nil {
+			log.Fatalf("Failed to open PostgreSQL store: %v", err)
+		}
+		store = postgresStore
 	default:
-		store = NewMemoryStore()
+		store = NewBoundedMemoryStore(time.Duration(cfg.MemoryMaxAgeS)*time.Second, cfg.MemoryMaxEntries, cfg.MemoryMaxTotal)
 	}
+	store = newInstrumentedStore(store)
 
 	// Verify store connectivity
 	if err := store.Ping(context.Background()); err != nil {
@@ -200,7 +300,50 @@ func main() {
 
 	// Initialize anomaly detector and alert dispatcher
 	detector = NewAnomalyDetector(store, cfg.WarningThresholdS, cfg.CriticalThresholdS)
+	if cfg.DetectorMode != detectorModeThreshold {
+		adaptiveDetector = NewAdaptiveDetector(cfg.AdaptiveConfig())
+		log.Printf("Adaptive anomaly detection enabled (mode %s)", cfg.DetectorMode)
+	}
 	dispatcher = NewAlertDispatcher(cfg.WebhookURL, hub.BroadcastAlert)
+	if cfg.AlertRoutesFile != "" {
+		routing, err := LoadAlertRoutingConfig(cfg.AlertRoutesFile)
+		if err != nil {
+			log.Fatalf("Failed to load alert routes: %v", err)
+		}
+		if *leaseWatch {
+			if err := routing.rejectNamespaces(); err != nil {
+				log.Fatalf("Invalid alert routes in %s: %v", cfg.AlertRoutesFile, err)
+			}
+		}
+		router, err := routing.Build(dispatcher.httpClient)
+		if err != nil {
+			log.Fatalf("Invalid alert routes in %s: %v", cfg.AlertRoutesFile, err)
+		}
+		if cfg.WebhookURL != "" {
+			log.Printf("EARTHWORM_ALERT_ROUTES is set; ignoring EARTHWORM_WEBHOOK_URL")
+		}
+		dispatcher.SetRouter(router)
+	}
+	alertManager = NewAlertManager(cfg.AlertTimings(), dispatcher.Dispatch)
+	silences = NewSilences(store)
+	if err := silences.Load(context.Background()); err != nil {
+		log.Fatalf("Failed to load silences: %v", err)
+	}
+	if *leaseWatch {
+		for _, sil := range silences.List(time.Now()) {
+			if sil.State == silenceStateExpired {
+				continue
+			}
+			if err := sil.rejectNamespaces(); err != nil {
+				log.Printf("Silence %s loaded from the store: %v", sil.ID, err)
+			}
+		}
+	}
+	alertManager.SetSilences(silences)
+	go silences.Run(context.Background(), silencePurgeInterval)
+	if !simulated {
+		go alertManager.Run(context.Background(), defaultAlertFlushInterval)
+	}
 
 	// Initialize eBPF components (causal chain builder, prediction engine, replay store)
 	chainBuilder = NewCausalChainBuilder(store, hub)
@@ -208,6 +351,38 @@ func main() {
 	replayStore = NewReplayStore(store, defaultRetention)
 	topoMap = NewNetworkTopologyMap(time.Duration(cfg.TopologyWindowS)*time.Second, hub)
 
+	// Track Ready/NotReady transitions; causal chains are built on every falling edge
+	nodeTracker = NewNodeStateTracker(detector, chainBuilder, hub)
+
+	// Per-node thresholds resolve against the labels the tracker learns from Node objects
+	thresholdPolicy = NewThresholdPolicy(detector.Thresholds(), nodeTracker.Labels)
+	if cfg.ThresholdPolicy != "" {
+		policy, err := LoadThresholdPolicyConfig(cfg.ThresholdPolicy)
+		if err != nil {
+			log.Fatalf("Failed to load threshold policy: %v", err)
+		}
+		if *leaseWatch {
+			if err := policy.rejectNamespaces(); err != nil {
+				log.Fatalf("Threshold policy in %s: %v", cfg.ThresholdPolicy, err)
+			}
+		}
+		if err := thresholdPolicy.Set(policy); err != nil {
+			log.Fatalf("Threshold policy in %s: %v", cfg.ThresholdPolicy, err)
+		}
+	}
+	detector.SetPolicy(thresholdPolicy)
+	if !simulated {
+		go runHeartbeatSweeps(context.Background(), defaultSweepInterval)
+	}
+
+	// Recognise zone- and cluster-wide outages and inhibit their per-node alerts
+	if cfg.OutageMinNodes > 0 {
+		outageDetector = NewOutageDetector(cfg.OutageConfig(), detector, nodeTracker.Labels)
+		if !simulated {
+			go outageDetector.Run(context.Background(), alertManager, defaultSweepInterval)
+		}
+	}
+
 	if ebpfEnabled {
 		log.Println("eBPF kernel observability enabled")
 	} else {
@@ -220,6 +395,7 @@ func main() {
 	topMux.HandleFunc("/ws/heartbeats", func(w http.ResponseWriter, r *http.Request) {
 		ServeWS(hub, w, r)
 	})
+	topMux.Handle("/metrics", metricsRegistry.Handler())
 
 	// API routes go through CORS + logging middleware
 	apiMux := http.NewServeMux()
@@ -229,74 +405,101 @@ func main() {
 	apiMux.HandleFunc("/api/network/topology", networkTopologyHandler)
 	apiMux.HandleFunc("/api/replay", replayHandler(replayStore))
 	apiMux.HandleFunc("/api/predictions/accuracy", predictionAccuracyHandler(predEngine))
+	nodeInventory := NewNodeInventory(store, detector, chainBuilder, predEngine, alertManager)
+	nodeInventory.SetAdaptive(adaptiveDetector, cfg.DetectorMode)
+	apiMux.HandleFunc("/api/nodes", nodesHandler(nodeInventory))
+	apiMux.HandleFunc("/api/nodes/{name}", nodeHandler(nodeInventory))
+	apiMux.HandleFunc("/api/nodes/{name}/transitions", nodeTransitionsHandler(nodeTracker))
+	apiMux.HandleFunc("/api/alerts", alertsHandler(alertManager))
+	// Heartbeats from node Leases are all in one namespace, so namespace matchers are refused
+	var silenceCheck func(Silence) error
+	var thresholdCheck func(ThresholdPolicyConfig) error
+	if *leaseWatch {
+		silenceCheck = Silence.rejectNamespaces
+		thresholdCheck = ThresholdPolicyConfig.rejectNamespaces
+	}
+	apiMux.HandleFunc("/api/silences", silencesHandler(silences, silenceCheck))
+	apiMux.HandleFunc("/api/silences/{id}", silenceHandler(silences))
+	apiMux.HandleFunc("/api/thresholds", thresholdsHandler(thresholdPolicy, thresholdCheck))
+	apiMux.HandleFunc("/api/thresholds/resolve", thresholdResolveHandler(thresholdPolicy))
 	topMux.Handle("/api/", LoggingMiddleware(setCORS(apiMux, cfg.CORSOrigins)))
 
 	handler := http.Handler(topMux)
 
 	log.Printf("Earthworm server running on :%d", cfg.Port)
 
-	var nodes []kubernetes.MockNode
-
-	if *simMode {
-		// Use SimulationEngine for realistic data generation
-		simConfig := kubernetes.SimulationConfig{
-			NodeCount: *simNodes,
-			Duration:  *simDuration,
-			Seed:      *simSeed,
-			Scenarios: []kubernetes.ScenarioConfig{
-				{
-					Type:             "rolling_deployment",
-					TriggerAt:        30 * time.Minute,
-					NodeCount:        5,
-					StaggerInterval:  30 * time.Second,
-					ReplacementDelay: 15 * time.Second,
-				},
-			},
-		}
-
-		engine, err := kubernetes.NewSimulationEngine(simConfig)
+	if *leaseWatch {
+		clientset, err := kubernetes.GetKubeClient(*kubeconfig)
 		if err != nil {
-			log.Fatalf("Failed to create simulation engine: %v", err)
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
 		}
+		go func() {
+			if err := runLeaseSource(context.Background(), clientset); err != nil {
+				log.Fatalf("Lease watcher stopped: %v", err)
+			}
+		}()
+		go func() {
+			if err := runNodeConditionSource(context.Background(), clientset, nodeTracker); err != nil {
+				log.Fatalf("Node watcher stopped: %v", err)
+			}
+		}()
+
+		fmt.Printf("\nServer running on :%d — ingesting node Lease heartbeats from %s\n", cfg.Port, kubernetes.NodeLeaseNamespace)
+		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), handler))
+	}
 
-		fmt.Printf("Running simulation: %d nodes, %v duration, seed=%d\n", *simNodes, *simDuration, *simSeed)
-		result, err := engine.Run()
+	if simulated {
+		// Use SimulationEngine for realistic data generation
+		simConfig, err := simulationConfig(*simConfigFile, *simNodes, *simDuration, *simSeed)
 		if err != nil {
-			log.Fatalf("Simulation failed: %v", err)
+			log.Fatalf("Failed to load simulation config: %v", err)
 		}
 
-		fmt.Printf("Simulation complete: %d leases, %d eBPF events, %d files\n",
-			result.Stats.TotalLeases, result.Stats.TotalEbpfEvents, len(result.LeaseFiles))
-
 		// Write output files if output directory specified
 		if *simOutput != "" {
+			engine, err := kubernetes.NewSimulationEngine(simConfig)
+			if err != nil {
+				log.Fatalf("Failed to create simulation engine: %v", err)
+			}
+			result, err := engine.Run()
+			if err != nil {
+				log.Fatalf("Simulation failed: %v", err)
+			}
 			if err := kubernetes.WriteSimulationOutput(result, *simOutput); err != nil {
 				log.Fatalf("Failed to write simulation output: %v", err)
 			}
-			fmt.Printf("Simulation output written to %s\n", *simOutput)
+			fmt.Printf("Simulation output written to %s: %d leases, %d eBPF events, %d files\n",
+				*simOutput, result.Stats.TotalLeases, result.Stats.TotalEbpfEvents, len(result.LeaseFiles))
 		}
 
-		// Create mock nodes from simulation for live broadcast
-		nodes = make([]kubernetes.MockNode, 0, result.Stats.TotalNodes)
-		for i := 0; i < result.Stats.TotalNodes && i < *simNodes; i++ {
-			nodes = append(nodes, kubernetes.MockNode{
-				Name:      fmt.Sprintf("node-%03d", i),
-				Status:    "Ready",
-				LastLease: time.Now(),
-			})
+		engine, err := kubernetes.NewSimulationEngine(simConfig)
+		if err != nil {
+			log.Fatalf("Failed to create simulation engine: %v", err)
 		}
-	} else {
-		// Generate 50 mock nodes (original behavior)
-		nodes = kubernetes.GenerateMockNodes()
+		fmt.Printf("Streaming simulation: %d nodes, %v duration, seed=%d, %gx speed\n",
+			simConfig.NodeCount, simConfig.Duration, simConfig.Seed, *simSpeed)
+		go func() {
+			if err := runSimulationSource(context.Background(), engine, *simSpeed); err != nil {
+				log.Printf("Simulation stopped: %v", err)
+				return
+			}
+			log.Printf("Simulation finished after %v of simulated time", simConfig.Duration)
+		}()
 
-		// Simulate eBPF activity and print correlation results
-		kubernetes.SimulateEBPFActivity(nodes, podInfos)
+		fmt.Printf("\nServer running on :%d — replaying simulated heartbeats and eBPF events\n", cfg.Port)
+		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), handler))
+	}
 
-		// Print summary of generated nodes
-		fmt.Println("\nSummary of mock nodes:")
-		for _, node := range nodes {
-			fmt.Printf("Node: %s, LastLease: %v, Status: %s\n", node.Name, node.LastLease.Format("15:04:05"), node.Status)
-		}
+	// Generate 50 mock nodes (original behavior)
+	nodes := kubernetes.GenerateMockNodes()
+
+	// Simulate eBPF activity and print correlation results
+	kubernetes.SimulateEBPFActivity(nodes, podInfos)
+
+	// Print summary of generated nodes
+	fmt.Println("\nSummary of mock nodes:")
+	for _, node := range nodes {
+		fmt.Printf("Node: %s, LastLease: %v, Status: %s\n", node.Name, node.LastLease.Format("15:04:05"), node.Status)
 	}
 
 	// Start live heartbeat simulation — broadcasts a heartbeat from a random node every 3 seconds
@@ -312,17 +515,7 @@ func main() {
 				Timestamp: time.Now(),
 				Status:    node.Status,
 			}
-			_ = store.Save(context.Background(), hb)
-			if hub != nil {
-				hub.BroadcastHeartbeat(hb)
-			}
-			if detector != nil {
-				if alert := detector.Evaluate(hb); alert != nil {
-					if dispatcher != nil {
-						dispatcher.Dispatch(*alert)
-					}
-				}
-			}
+			_ = ingestHeartbeat(context.Background(), hb)
 		}
 	}()
 
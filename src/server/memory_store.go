@@ -0,0 +1,483 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryCompactInterval is how often a bounded MemoryStore drops records
+// older than its maximum age.
+const memoryCompactInterval = time.Minute
+
+// Default limits of the memory store the server runs with: a day of
+// history, no more than 100,000 records of a kind per node, and 500,000
+// records in all. A day of heartbeats is under 10,000, so the per-node count
+// mostly bounds kernel events; the total bounds the store however many node
+// names are posted to it. Kernel events, the largest records, take about
+// 500 bytes plus their strings, so the store peaks at a few hundred MB.
+const (
+	defaultMemoryMaxAge          = 24 * time.Hour
+	defaultMemoryMaxEntries      = 100_000
+	defaultMemoryMaxTotalEntries = 500_000
+)
+
+// MemoryStore is an in-memory implementation of the Store interface. Each
+// node's heartbeats, kernel events and causal chains are kept in series
+// sorted by timestamp, so time ranges are found by binary search.
+//
+// A bounded store keeps at most maxEntries records per node and record type
+// and maxTotal records in all, dropping the oldest, and drops records older
+// than maxAge every minute. Silences are kept.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	heartbeats   map[string]*memorySeries[Heartbeat]
+	kernelEvents map[string]*memorySeries[EnrichedEvent]
+	causalChains map[string]*memorySeries[CausalChain]
+	silences     map[string]Silence
+
+	maxAge     time.Duration
+	maxEntries int
+	maxTotal   int
+	total      int // records across all series
+	evictions  map[MemoryEviction]uint64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// MemoryEviction identifies why records of a kind were evicted: Record is
+// heartbeat, kernel_event or causal_chain and Reason is max_age,
+// max_entries or max_total_entries.
+type MemoryEviction struct {
+	Record string
+	Reason string
+}
+
+// NewMemoryStore creates an in-memory store without limits.
+func NewMemoryStore() *MemoryStore {
+	return NewBoundedMemoryStore(0, 0, 0)
+}
+
+// NewBoundedMemoryStore creates an in-memory store that keeps records for at
+// most maxAge, at most maxEntries records per node and record type, and at
+// most maxTotal records in all. A zero limit is no limit.
+func NewBoundedMemoryStore(maxAge time.Duration, maxEntries, maxTotal int) *MemoryStore {
+	m := &MemoryStore{
+		heartbeats:   make(map[string]*memorySeries[Heartbeat]),
+		kernelEvents: make(map[string]*memorySeries[EnrichedEvent]),
+		causalChains: make(map[string]*memorySeries[CausalChain]),
+		silences:     make(map[string]Silence),
+		maxAge:       maxAge,
+		maxEntries:   maxEntries,
+		maxTotal:     maxTotal,
+		evictions:    make(map[MemoryEviction]uint64),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if maxAge > 0 {
+		go compactEvery(memoryCompactInterval, m.Compact, m.stop, m.done)
+	} else {
+		close(m.done)
+	}
+	return m
+}
+
+// Compact drops records older than the maximum age at now, and forgets
+// nodes left without records.
+func (m *MemoryStore) Compact(_ context.Context, now time.Time) error {
+	if m.maxAge <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-m.maxAge)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for record, n := range map[string]int{
+		"heartbeat":    trimSeries(m.heartbeats, cutoff),
+		"kernel_event": trimSeries(m.kernelEvents, cutoff),
+		"causal_chain": trimSeries(m.causalChains, cutoff),
+	} {
+		m.evictions[MemoryEviction{record, "max_age"}] += uint64(n)
+		m.total -= n
+	}
+	return nil
+}
+
+// insertedLocked accounts for a record inserted into a series of kind
+// record, evicted of them by the series' own limit, and keeps the store
+// within maxTotal. Caller holds m.mu.
+func (m *MemoryStore) insertedLocked(record string, evicted int) {
+	m.evictions[MemoryEviction{record, "max_entries"}] += uint64(evicted)
+	m.total += 1 - evicted
+	if m.maxTotal > 0 && m.total > m.maxTotal {
+		m.evictOldestLocked()
+	}
+}
+
+// evictOldestLocked drops the oldest records across every node and record
+// type until the store is a hundredth of maxTotal below it, so that the
+// series are gathered once per batch of inserts rather than on each one.
+// Caller holds m.mu.
+func (m *MemoryStore) evictOldestLocked() {
+	var h memoryHeads
+	h = appendHeads(h, m.heartbeats, "heartbeat")
+	h = appendHeads(h, m.kernelEvents, "kernel_event")
+	h = appendHeads(h, m.causalChains, "causal_chain")
+	heap.Init(&h)
+	for target := m.maxTotal - m.maxTotal/100; m.total > target && h.Len() > 0; m.total-- {
+		head := h[0]
+		head.series.dropOldest(1)
+		m.evictions[MemoryEviction{head.record, "max_total_entries"}]++
+		if head.series.len() == 0 {
+			head.forget()
+			heap.Pop(&h)
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+}
+
+// Evictions returns how many records the store has evicted to stay within
+// its limits.
+func (m *MemoryStore) Evictions() map[MemoryEviction]uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[MemoryEviction]uint64, len(m.evictions))
+	for k, n := range m.evictions {
+		result[k] = n
+	}
+	return result
+}
+
+// Close stops compaction.
+func (m *MemoryStore) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	<-m.done
+	return nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, event Heartbeat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.insertedLocked("heartbeat", insertSeries(m.heartbeats, event.NodeName, event, heartbeatTime, m.maxEntries))
+	return nil
+}
+
+func (m *MemoryStore) GetByTimeRange(_ context.Context, from, to time.Time) ([]Heartbeat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []Heartbeat
+	for _, s := range m.heartbeats {
+		result = append(result, s.between(from, to)...)
+	}
+	// Each node's series is sorted; merge them into one timeline
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Timestamp.Before(result[j].Timestamp) })
+	return result, nil
+}
+
+func (m *MemoryStore) GetLatestByNode(_ context.Context, nodeName string) (*Heartbeat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.heartbeats[nodeName]
+	if !ok || len(s.records) == 0 {
+		return nil, nil
+	}
+	// Copied, because later inserts shift the series
+	latest := s.records[len(s.records)-1]
+	return &latest, nil
+}
+
+func (m *MemoryStore) QueryHeartbeats(_ context.Context, q HeartbeatQuery) (HeartbeatPage, error) {
+	c, err := q.cursor()
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	from, to := q.bounds(c)
+	need := q.need(c)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// The page can come from a single node, so no node's series is read
+	// beyond the first need heartbeats it selects
+	var candidates []Heartbeat
+	for node, s := range m.heartbeats {
+		if q.NodeName != "" && node != q.NodeName {
+			continue
+		}
+		selected := 0
+		for _, hb := range s.between(from, to) {
+			if selected == need {
+				break
+			}
+			if q.selects(hb, c) {
+				candidates = append(candidates, hb)
+				selected++
+			}
+		}
+	}
+	return pageHeartbeats(candidates, q, c), nil
+}
+
+func (m *MemoryStore) DownsampleHeartbeats(_ context.Context, q HeartbeatQuery, step time.Duration) ([]HeartbeatBucket, error) {
+	from, to := q.bounds(nil)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var hbs []Heartbeat
+	for node, s := range m.heartbeats {
+		if q.NodeName == "" || node == q.NodeName {
+			hbs = append(hbs, s.between(from, to)...)
+		}
+	}
+	return downsampleHeartbeats(hbs, q, step), nil
+}
+
+func (m *MemoryStore) Nodes(_ context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]string, 0, len(m.heartbeats))
+	for node := range m.heartbeats {
+		result = append(result, node)
+	}
+	for node := range m.kernelEvents {
+		if _, ok := m.heartbeats[node]; !ok {
+			result = append(result, node)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (m *MemoryStore) HasNode(_ context.Context, nodeName string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, heartbeats := m.heartbeats[nodeName]
+	_, events := m.kernelEvents[nodeName]
+	return heartbeats || events, nil
+}
+
+func (m *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) SaveKernelEvent(_ context.Context, event EnrichedEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.insertedLocked("kernel_event", insertSeries(m.kernelEvents, event.NodeName, event, kernelEventTime, m.maxEntries))
+	return nil
+}
+
+func (m *MemoryStore) GetKernelEvents(_ context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.kernelEvents[nodeName]
+	if !ok {
+		return nil, nil
+	}
+	return append([]EnrichedEvent(nil), s.between(from, to)...), nil
+}
+
+func (m *MemoryStore) GetKernelEventsByType(_ context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.kernelEvents[nodeName]
+	if !ok {
+		return nil, nil
+	}
+	var result []EnrichedEvent
+	for _, e := range s.between(from, to) {
+		if e.EventType == eventType {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) CountKernelEvents(_ context.Context, nodeName string, from, to time.Time) (map[string]map[string]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string]map[string]int)
+	for node, s := range m.kernelEvents {
+		if nodeName != "" && node != nodeName {
+			continue
+		}
+		for _, e := range s.between(from, to) {
+			if result[node] == nil {
+				result[node] = make(map[string]int)
+			}
+			result[node][e.EventType]++
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) SaveCausalChain(_ context.Context, chain CausalChain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.insertedLocked("causal_chain", insertSeries(m.causalChains, chain.NodeName, chain, causalChainTime, m.maxEntries))
+	return nil
+}
+
+func (m *MemoryStore) GetCausalChains(_ context.Context, nodeName string, from, to time.Time) ([]CausalChain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.causalChains[nodeName]
+	if !ok {
+		return nil, nil
+	}
+	return append([]CausalChain(nil), s.between(from, to)...), nil
+}
+
+func (m *MemoryStore) GetLatestCausalChain(_ context.Context, nodeName string) (*CausalChain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.causalChains[nodeName]
+	if !ok || len(s.records) == 0 {
+		return nil, nil
+	}
+	// Copied, because later inserts shift the series
+	latest := s.records[len(s.records)-1]
+	return &latest, nil
+}
+
+func (m *MemoryStore) SaveSilence(_ context.Context, silence Silence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.silences[silence.ID] = silence
+	return nil
+}
+
+func (m *MemoryStore) GetSilences(_ context.Context) ([]Silence, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Silence, 0, len(m.silences))
+	for _, s := range m.silences {
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) DeleteSilence(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.silences, id)
+	return nil
+}
+
+func heartbeatTime(hb Heartbeat) time.Time      { return hb.Timestamp }
+func kernelEventTime(e EnrichedEvent) time.Time { return e.Timestamp }
+func causalChainTime(c CausalChain) time.Time   { return c.Timestamp }
+
+// memorySeries is one node's records of one kind, sorted by timestamp.
+// Records of equal timestamps stay in insertion order.
+type memorySeries[T any] struct {
+	records   []T
+	timestamp func(T) time.Time
+}
+
+// insert adds v after any record with the same timestamp. Records mostly
+// arrive in order, so this is usually an append.
+func (s *memorySeries[T]) insert(v T) {
+	ts := s.timestamp(v)
+	i := sort.Search(len(s.records), func(i int) bool { return s.timestamp(s.records[i]).After(ts) })
+	s.records = append(s.records, v)
+	copy(s.records[i+1:], s.records[i:])
+	s.records[i] = v
+}
+
+// between returns the records in [from, to]. The result shares the series'
+// storage and is only valid while the store's lock is held.
+func (s *memorySeries[T]) between(from, to time.Time) []T {
+	lo := sort.Search(len(s.records), func(i int) bool { return !s.timestamp(s.records[i]).Before(from) })
+	hi := sort.Search(len(s.records), func(i int) bool { return s.timestamp(s.records[i]).After(to) })
+	if lo >= hi {
+		return nil
+	}
+	return s.records[lo:hi]
+}
+
+// dropOldest removes the n oldest records. Reslicing leaves the dropped
+// records in the backing array until append next reallocates it, which
+// copies only the live ones; they are zeroed so they can be collected.
+func (s *memorySeries[T]) dropOldest(n int) {
+	var zero T
+	for i := 0; i < n; i++ {
+		s.records[i] = zero
+	}
+	s.records = s.records[n:]
+}
+
+func (s *memorySeries[T]) len() int { return len(s.records) }
+
+// oldest returns the timestamp of the series' oldest record, which must
+// exist.
+func (s *memorySeries[T]) oldest() time.Time { return s.timestamp(s.records[0]) }
+
+// memoryHead is a series of any record type, as seen by evictOldestLocked.
+type memoryHead struct {
+	series interface {
+		len() int
+		oldest() time.Time
+		dropOldest(n int)
+	}
+	record string // heartbeat, kernel_event or causal_chain
+	forget func() // deletes the series from its map
+}
+
+// memoryHeads is a min-heap of series by their oldest record.
+type memoryHeads []memoryHead
+
+func (h memoryHeads) Len() int           { return len(h) }
+func (h memoryHeads) Less(i, j int) bool { return h[i].series.oldest().Before(h[j].series.oldest()) }
+func (h memoryHeads) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *memoryHeads) Push(x any)        { *h = append(*h, x.(memoryHead)) }
+func (h *memoryHeads) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// appendHeads appends the non-empty series of a record type to h.
+func appendHeads[T any](h memoryHeads, series map[string]*memorySeries[T], record string) memoryHeads {
+	for node, s := range series {
+		if len(s.records) > 0 {
+			h = append(h, memoryHead{series: s, record: record, forget: func() { delete(series, node) }})
+		}
+	}
+	return h
+}
+
+// insertSeries inserts v into the series of node, creating it, and trims the
+// series to maxEntries when positive. It returns how many records it
+// evicted.
+func insertSeries[T any](series map[string]*memorySeries[T], node string, v T, timestamp func(T) time.Time, maxEntries int) int {
+	s, ok := series[node]
+	if !ok {
+		s = &memorySeries[T]{timestamp: timestamp}
+		series[node] = s
+	}
+	s.insert(v)
+	if maxEntries > 0 && len(s.records) > maxEntries {
+		n := len(s.records) - maxEntries
+		s.dropOldest(n)
+		return n
+	}
+	return 0
+}
+
+// trimSeries drops records older than cutoff from every series, deleting
+// series left empty, and returns how many records it dropped.
+func trimSeries[T any](series map[string]*memorySeries[T], cutoff time.Time) int {
+	dropped := 0
+	for node, s := range series {
+		n := sort.Search(len(s.records), func(i int) bool { return !s.timestamp(s.records[i]).Before(cutoff) })
+		s.dropOldest(n)
+		dropped += n
+		if len(s.records) == 0 {
+			delete(series, node)
+		}
+	}
+	return dropped
+}
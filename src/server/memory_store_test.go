@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"pgregory.net/rapid"
+)
+
+// Feature: earthworm-improvements, Property 16: Bounded memory store range queries match a linear scan
+func TestProperty16_MemoryStoreRangeQueries(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	rapid.Check(t, func(t *rapid.T) {
+		maxEntries := rapid.IntRange(0, 20).Draw(t, "maxEntries")
+		s := NewBoundedMemoryStore(0, maxEntries, 0)
+		// Seconds since base, saved in any order and with repeats
+		offsets := rapid.SliceOfN(rapid.IntRange(0, 60), 0, 60).Draw(t, "offsets")
+		nodes := rapid.SliceOfN(rapid.SampledFrom([]string{"node-a", "node-b"}), len(offsets), len(offsets)).Draw(t, "nodes")
+
+		// What the store should hold: every node's newest maxEntries
+		// records, the last saved winning among equal timestamps
+		kept := map[string][]Heartbeat{}
+		for i, offset := range offsets {
+			hb := Heartbeat{NodeName: nodes[i], Timestamp: base.Add(time.Duration(offset) * time.Second), EbpfPID: uint32(i)}
+			if err := s.Save(ctx, hb); err != nil {
+				t.Fatal(err)
+			}
+			series := kept[hb.NodeName]
+			at := len(series)
+			for at > 0 && series[at-1].Timestamp.After(hb.Timestamp) {
+				at--
+			}
+			series = append(series[:at], append([]Heartbeat{hb}, series[at:]...)...)
+			if maxEntries > 0 && len(series) > maxEntries {
+				series = series[len(series)-maxEntries:]
+			}
+			kept[hb.NodeName] = series
+		}
+
+		from := base.Add(time.Duration(rapid.IntRange(0, 60).Draw(t, "from")) * time.Second)
+		to := from.Add(time.Duration(rapid.IntRange(0, 60).Draw(t, "span")) * time.Second)
+		got, _ := s.GetByTimeRange(ctx, from, to)
+		want := 0
+		for _, series := range kept {
+			for _, hb := range series {
+				if !hb.Timestamp.Before(from) && !hb.Timestamp.After(to) {
+					want++
+				}
+			}
+		}
+		if len(got) != want {
+			t.Fatalf("GetByTimeRange(%v, %v) returned %d heartbeats, want %d", from, to, len(got), want)
+		}
+		for i, hb := range got {
+			if hb.Timestamp.Before(from) || hb.Timestamp.After(to) || (i > 0 && hb.Timestamp.Before(got[i-1].Timestamp)) {
+				t.Fatalf("heartbeat %d at %v is out of range or order", i, hb.Timestamp)
+			}
+		}
+		for node, series := range kept {
+			latest, _ := s.GetLatestByNode(ctx, node)
+			if latest == nil || latest.EbpfPID != series[len(series)-1].EbpfPID {
+				t.Fatalf("latest of %s = %+v, want %+v", node, latest, series[len(series)-1])
+			}
+		}
+	})
+}
+
+// TestMemoryStore_MaxEntries verifies each node and record type keeps its
+// newest records, and evictions are counted.
+func TestMemoryStore_MaxEntries(t *testing.T) {
+	ctx := context.Background()
+	s := NewBoundedMemoryStore(0, 3, 0)
+	defer s.Close()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		ts := base.Add(time.Duration(i) * time.Second)
+		s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: ts})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "syscall", Timestamp: ts})
+	}
+	s.Save(ctx, Heartbeat{NodeName: "node-b", Timestamp: base})
+
+	hbs, _ := s.GetByTimeRange(ctx, base, base.Add(time.Minute))
+	if len(hbs) != 4 || !hbs[0].Timestamp.Equal(base) || hbs[0].NodeName != "node-b" || !hbs[1].Timestamp.Equal(base.Add(2*time.Second)) {
+		t.Errorf("heartbeats = %+v, want node-b's and the newest 3 of node-a", hbs)
+	}
+	if events, _ := s.GetKernelEvents(ctx, "node-a", base, base.Add(time.Minute)); len(events) != 3 {
+		t.Errorf("%d kernel events, want 3", len(events))
+	}
+	evictions := s.Evictions()
+	if evictions[MemoryEviction{"heartbeat", "max_entries"}] != 2 || evictions[MemoryEviction{"kernel_event", "max_entries"}] != 2 {
+		t.Errorf("evictions = %v, want 2 heartbeats and 2 kernel events", evictions)
+	}
+}
+
+// TestMemoryStore_MaxTotalEntries verifies the store keeps its newest
+// records across nodes and record types however many nodes post to it.
+func TestMemoryStore_MaxTotalEntries(t *testing.T) {
+	ctx := context.Background()
+	s := NewBoundedMemoryStore(0, 0, 10)
+	defer s.Close()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 20; i++ {
+		ts := base.Add(time.Duration(i) * time.Second)
+		s.Save(ctx, Heartbeat{NodeName: fmt.Sprintf("node-%02d", i), Timestamp: ts})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "syscall", Timestamp: ts})
+	}
+
+	hbs, _ := s.GetByTimeRange(ctx, base, base.Add(time.Minute))
+	events, _ := s.GetKernelEvents(ctx, "node-a", base, base.Add(time.Minute))
+	if len(hbs) != 5 || len(events) != 5 || !hbs[0].Timestamp.Equal(base.Add(15*time.Second)) || !events[0].Timestamp.Equal(base.Add(15*time.Second)) {
+		t.Errorf("kept %d heartbeats and %d kernel events, want the newest 5 of each", len(hbs), len(events))
+	}
+	if nodes, _ := s.Nodes(ctx); len(nodes) != 6 {
+		t.Errorf("nodes = %v, want node-a and the 5 newest heartbeat senders", nodes)
+	}
+	evictions := s.Evictions()
+	if evictions[MemoryEviction{"heartbeat", "max_total_entries"}] != 15 || evictions[MemoryEviction{"kernel_event", "max_total_entries"}] != 15 {
+		t.Errorf("evictions = %v, want 15 heartbeats and 15 kernel events", evictions)
+	}
+}
+
+// TestMemoryStore_Compact verifies compaction drops records older than the
+// maximum age, forgets nodes left without records and keeps silences.
+func TestMemoryStore_Compact(t *testing.T) {
+	ctx := context.Background()
+	s := NewBoundedMemoryStore(time.Hour, 0, 0)
+	defer s.Close()
+	now := time.Now().UTC()
+
+	for _, ts := range []time.Time{now.Add(-2 * time.Hour), now} {
+		s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: ts})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "syscall", Timestamp: ts})
+		s.SaveCausalChain(ctx, CausalChain{NodeName: "node-a", Timestamp: ts})
+	}
+	s.Save(ctx, Heartbeat{NodeName: "node-gone", Timestamp: now.Add(-3 * time.Hour)})
+	s.SaveSilence(ctx, Silence{ID: "s1"})
+
+	if err := s.Compact(ctx, now); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	from := now.Add(-24 * time.Hour)
+	if hbs, _ := s.GetByTimeRange(ctx, from, now); len(hbs) != 1 {
+		t.Errorf("%d heartbeats after compaction, want 1", len(hbs))
+	}
+	if events, _ := s.GetKernelEvents(ctx, "node-a", from, now); len(events) != 1 {
+		t.Errorf("%d kernel events after compaction, want 1", len(events))
+	}
+	if chains, _ := s.GetCausalChains(ctx, "node-a", from, now); len(chains) != 1 {
+		t.Errorf("%d causal chains after compaction, want 1", len(chains))
+	}
+	if latest, _ := s.GetLatestByNode(ctx, "node-gone"); latest != nil {
+		t.Errorf("node-gone still has heartbeat %+v", latest)
+	}
+	if _, ok := s.heartbeats["node-gone"]; ok {
+		t.Error("node-gone was not forgotten")
+	}
+	if silences, _ := s.GetSilences(ctx); len(silences) != 1 {
+		t.Errorf("%d silences after compaction, want 1", len(silences))
+	}
+	if n := s.Evictions()[MemoryEviction{"heartbeat", "max_age"}]; n != 2 {
+		t.Errorf("%d heartbeats evicted by age, want 2", n)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"earthworm/src/metrics"
+)
+
+// heartbeatGapBuckets span normal lease renewals (~10s) through the warning
+// and critical thresholds to long outages.
+var heartbeatGapBuckets = []float64{1, 2.5, 5, 10, 15, 20, 30, 40, 60, 120, 300, 600}
+
+// Prometheus metrics served on /metrics.
+var (
+	metricsRegistry = metrics.NewRegistry()
+
+	heartbeatGapSeconds = metricsRegistry.NewHistogramVec("earthworm_heartbeat_gap_seconds",
+		"Gap between consecutive heartbeats of a node.", heartbeatGapBuckets, "node")
+	nodeLastHeartbeat = metricsRegistry.NewGaugeVec("earthworm_node_last_heartbeat_timestamp_seconds",
+		"Unix time of the latest heartbeat received from a node.", "node")
+	alertsTotal = metricsRegistry.NewCounterVec("earthworm_alerts_total",
+		"Alert notifications dispatched, by severity and status.", "severity", "status")
+	alertsActive = metricsRegistry.NewGaugeVec("earthworm_alerts_active",
+		"Alerts currently firing, by severity.", "severity")
+	notificationsTotal = metricsRegistry.NewCounterVec("earthworm_alert_notifications_total",
+		"Alert notifications sent to receivers, by receiver and result.", "receiver", "result")
+	kernelEventsTotal = metricsRegistry.NewCounterVec("earthworm_kernel_events_total",
+		"Kernel events ingested from agents, by event type.", "event_type")
+	websocketClients = metricsRegistry.NewGaugeVec("earthworm_websocket_clients",
+		"Connected WebSocket clients.")
+	predictionsTotal = metricsRegistry.NewCounterVec("earthworm_predictions_total",
+		"Failure predictions whose outcome is known, by outcome.", "outcome")
+	predictionsPending = metricsRegistry.NewGaugeVec("earthworm_predictions_pending",
+		"Failure predictions still awaiting an outcome.")
+	predictionTPR = metricsRegistry.NewGaugeVec("earthworm_prediction_true_positive_rate",
+		"Fraction of actual failures that were predicted.")
+	predictionFPR = metricsRegistry.NewGaugeVec("earthworm_prediction_false_positive_rate",
+		"Fraction of non-failures that were predicted as failures.")
+	storeOperationSeconds = metricsRegistry.NewHistogramVec("earthworm_store_operation_duration_seconds",
+		"Latency of store operations.", nil, "operation")
+	storeErrorsTotal = metricsRegistry.NewCounterVec("earthworm_store_errors_total",
+		"Store operations that returned an error.", "operation")
+	memoryStoreEvictionsTotal = metricsRegistry.NewCounterVec("earthworm_memory_store_evictions_total",
+		"Records the memory store evicted to stay within its limits, by record type and reason.", "record", "reason")
+)
+
+func init() {
+	metricsRegistry.OnCollect(collectComponentMetrics)
+}
+
+// collectComponentMetrics copies state owned by the hub, dispatcher, alert
+// manager, prediction engine and memory store into their gauges and counters
+// before each scrape.
+func collectComponentMetrics() {
+	if hub != nil {
+		websocketClients.Set(float64(hub.ClientCount()))
+	}
+	if dispatcher != nil {
+		for k, n := range dispatcher.AlertCounts() {
+			alertsTotal.Set(float64(n), k.Severity, k.Status)
+		}
+	}
+	if alertManager != nil {
+		active := map[string]float64{"warning": 0, "critical": 0}
+		for _, a := range alertManager.Alerts().Active {
+			active[a.Severity]++
+		}
+		for severity, n := range active {
+			alertsActive.Set(n, severity)
+		}
+	}
+	if predEngine != nil {
+		acc := predEngine.Accuracy()
+		predictionsTotal.Set(float64(acc.TruePositives), "true_positive")
+		predictionsTotal.Set(float64(acc.FalsePositives), "false_positive")
+		predictionsTotal.Set(float64(acc.TrueNegatives), "true_negative")
+		predictionsTotal.Set(float64(acc.FalseNegatives), "false_negative")
+		predictionsPending.Set(float64(acc.TotalPredictions - acc.TruePositives - acc.FalsePositives - acc.TrueNegatives - acc.FalseNegatives))
+		predictionTPR.Set(acc.TruePositiveRate)
+		predictionFPR.Set(acc.FalsePositiveRate)
+	}
+	memory, _ := store.(*MemoryStore)
+	if instrumented, ok := store.(*instrumentedStore); ok {
+		memory, _ = instrumented.next.(*MemoryStore)
+	}
+	if memory != nil {
+		for k, n := range memory.Evictions() {
+			memoryStoreEvictionsTotal.Set(float64(n), k.Record, k.Reason)
+		}
+	}
+}
+
+// observeHeartbeat records the heartbeat's last-seen time and, when the node
+// has a previous heartbeat, the gap since it.
+func observeHeartbeat(hb Heartbeat, gap time.Duration, hasPrevious bool) {
+	if hasPrevious && gap >= 0 {
+		heartbeatGapSeconds.Observe(gap.Seconds(), hb.NodeName)
+	}
+	nodeLastHeartbeat.Set(float64(hb.Timestamp.UnixNano())/1e9, hb.NodeName)
+}
+
+// instrumentedStore wraps a Store, recording latency and errors per operation.
+type instrumentedStore struct {
+	next Store
+}
+
+// newInstrumentedStore returns s wrapped with latency and error metrics.
+func newInstrumentedStore(s Store) Store {
+	return &instrumentedStore{next: s}
+}
+
+// observe records the duration since start and counts err under operation.
+func (s *instrumentedStore) observe(operation string, start time.Time, err error) {
+	storeOperationSeconds.Observe(time.Since(start).Seconds(), operation)
+	if err != nil {
+		storeErrorsTotal.Inc(operation)
+	}
+}
+
+func (s *instrumentedStore) Save(ctx context.Context, event Heartbeat) error {
+	start := time.Now()
+	err := s.next.Save(ctx, event)
+	s.observe("save", start, err)
+	return err
+}
+
+func (s *instrumentedStore) GetByTimeRange(ctx context.Context, from, to time.Time) ([]Heartbeat, error) {
+	start := time.Now()
+	out, err := s.next.GetByTimeRange(ctx, from, to)
+	s.observe("get_by_time_range", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) GetLatestByNode(ctx context.Context, nodeName string) (*Heartbeat, error) {
+	start := time.Now()
+	out, err := s.next.GetLatestByNode(ctx, nodeName)
+	s.observe("get_latest_by_node", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := s.next.Ping(ctx)
+	s.observe("ping", start, err)
+	return err
+}
+
+func (s *instrumentedStore) QueryHeartbeats(ctx context.Context, q HeartbeatQuery) (HeartbeatPage, error) {
+	start := time.Now()
+	out, err := s.next.QueryHeartbeats(ctx, q)
+	s.observe("query_heartbeats", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) DownsampleHeartbeats(ctx context.Context, q HeartbeatQuery, step time.Duration) ([]HeartbeatBucket, error) {
+	start := time.Now()
+	out, err := s.next.DownsampleHeartbeats(ctx, q, step)
+	s.observe("downsample_heartbeats", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) Nodes(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	out, err := s.next.Nodes(ctx)
+	s.observe("nodes", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) HasNode(ctx context.Context, nodeName string) (bool, error) {
+	start := time.Now()
+	out, err := s.next.HasNode(ctx, nodeName)
+	s.observe("has_node", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) SaveKernelEvent(ctx context.Context, event EnrichedEvent) error {
+	start := time.Now()
+	err := s.next.SaveKernelEvent(ctx, event)
+	s.observe("save_kernel_event", start, err)
+	return err
+}
+
+func (s *instrumentedStore) GetKernelEvents(ctx context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error) {
+	start := time.Now()
+	out, err := s.next.GetKernelEvents(ctx, nodeName, from, to)
+	s.observe("get_kernel_events", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) GetKernelEventsByType(ctx context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error) {
+	start := time.Now()
+	out, err := s.next.GetKernelEventsByType(ctx, nodeName, eventType, from, to)
+	s.observe("get_kernel_events_by_type", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) CountKernelEvents(ctx context.Context, nodeName string, from, to time.Time) (map[string]map[string]int, error) {
+	start := time.Now()
+	out, err := s.next.CountKernelEvents(ctx, nodeName, from, to)
+	s.observe("count_kernel_events", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) SaveCausalChain(ctx context.Context, chain CausalChain) error {
+	start := time.Now()
+	err := s.next.SaveCausalChain(ctx, chain)
+	s.observe("save_causal_chain", start, err)
+	return err
+}
+
+func (s *instrumentedStore) GetCausalChains(ctx context.Context, nodeName string, from, to time.Time) ([]CausalChain, error) {
+	start := time.Now()
+	out, err := s.next.GetCausalChains(ctx, nodeName, from, to)
+	s.observe("get_causal_chains", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) GetLatestCausalChain(ctx context.Context, nodeName string) (*CausalChain, error) {
+	start := time.Now()
+	out, err := s.next.GetLatestCausalChain(ctx, nodeName)
+	s.observe("get_latest_causal_chain", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) SaveSilence(ctx context.Context, silence Silence) error {
+	start := time.Now()
+	err := s.next.SaveSilence(ctx, silence)
+	s.observe("save_silence", start, err)
+	return err
+}
+
+func (s *instrumentedStore) GetSilences(ctx context.Context) ([]Silence, error) {
+	start := time.Now()
+	out, err := s.next.GetSilences(ctx)
+	s.observe("get_silences", start, err)
+	return out, err
+}
+
+func (s *instrumentedStore) DeleteSilence(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.next.DeleteSilence(ctx, id)
+	s.observe("delete_silence", start, err)
+	return err
+}
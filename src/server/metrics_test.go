@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"earthworm/src/metrics"
+)
+
+func scrapeMetrics(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metricsRegistry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Fatalf("Content-Type = %q, want %q", ct, metrics.ContentType)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsEndpoint_ReflectsIngestion(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origManager, origTracker := alertManager, nodeTracker
+	defer func() { alertManager, nodeTracker = origManager, origTracker }()
+	store = newInstrumentedStore(store)
+	detector = NewAnomalyDetector(store, 10, 40)
+	alertManager = NewAlertManager(AlertTimings{RepeatInterval: time.Hour}, dispatcher.Dispatch)
+	nodeTracker = NewNodeStateTracker(detector, nil, nil)
+
+	ctx := context.Background()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	node := "metrics-node-01"
+	for _, offset := range []time.Duration{0, 10 * time.Second, 60 * time.Second} {
+		sweepHeartbeats(base.Add(offset))
+		alertManager.Flush(base.Add(offset))
+		if err := ingestHeartbeat(ctx, Heartbeat{NodeName: node, Namespace: "default", Timestamp: base.Add(offset), Status: "Ready"}); err != nil {
+			t.Fatalf("ingest: %v", err)
+		}
+	}
+
+	body, _ := json.Marshal([]EnrichedEvent{
+		{Timestamp: base, PID: 1, Comm: "kubelet", EventType: "metrics_test_syscall", NodeName: node},
+		{Timestamp: base, PID: 2, Comm: "kubelet", EventType: "metrics_test_syscall", NodeName: node},
+	})
+	rec := httptest.NewRecorder()
+	ebpfEventsHandler(rec, httptest.NewRequest(http.MethodPost, "/api/ebpf/events", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST events: expected 201, got %d", rec.Code)
+	}
+
+	out := scrapeMetrics(t)
+	for _, want := range []string{
+		`earthworm_heartbeat_gap_seconds_count{node="metrics-node-01"} 2`,
+		`earthworm_heartbeat_gap_seconds_bucket{node="metrics-node-01",le="10"} 1`,
+		`earthworm_heartbeat_gap_seconds_sum{node="metrics-node-01"} 60`,
+		`earthworm_node_last_heartbeat_timestamp_seconds{node="metrics-node-01"} 1.74998886e+09`,
+		`earthworm_alerts_total{severity="critical",status="firing"} 1`,
+		`earthworm_kernel_events_total{event_type="metrics_test_syscall"} 2`,
+		`earthworm_websocket_clients 0`,
+		`earthworm_predictions_pending`,
+		"# TYPE earthworm_predictions_pending gauge",
+		`earthworm_prediction_true_positive_rate 0`,
+		`earthworm_store_operation_duration_seconds_count{operation="save"}`,
+		"# TYPE earthworm_store_errors_total counter",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestInstrumentedStore_CountsErrors(t *testing.T) {
+	s := newInstrumentedStore(&errorStore{getByTimeRangeErr: errors.New("boom")})
+	before := storeErrorsTotal.Value("get_by_time_range")
+	if _, err := s.GetByTimeRange(context.Background(), time.Now(), time.Now()); err == nil {
+		t.Fatal("expected error to pass through")
+	}
+	if got := storeErrorsTotal.Value("get_by_time_range"); got != before+1 {
+		t.Fatalf("store errors = %v, want %v", got, before+1)
+	}
+}
+
+func TestAlertDispatcher_CountsByStatus(t *testing.T) {
+	d := NewAlertDispatcher("", nil)
+	d.Dispatch(Alert{NodeName: "node-a", Severity: "critical"})
+	d.Dispatch(Alert{NodeName: "node-a", Severity: "critical", Status: alertStatusFiring})
+	d.Dispatch(Alert{NodeName: "node-a", Severity: "critical", Status: alertStatusResolved})
+	counts := d.AlertCounts()
+	if counts[AlertDispatch{"critical", "firing"}] != 2 || counts[AlertDispatch{"critical", "resolved"}] != 1 {
+		t.Errorf("counts = %v, want 2 firing and 1 resolved", counts)
+	}
+}
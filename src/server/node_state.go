@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	k8sclient "k8s.io/client-go/kubernetes"
+
+	"earthworm/src/kubernetes"
+)
+
+const (
+	statusReady    = "Ready"
+	statusNotReady = "NotReady"
+
+	transitionSourceCondition       = "node_condition"
+	transitionSourceHeartbeatGap    = "heartbeat_gap"
+	transitionSourceHeartbeatStatus = "heartbeat_status"
+
+	defaultTransitionHistory = 100
+	defaultSweepInterval     = 5 * time.Second
+)
+
+// NodeTransition records a single Ready↔NotReady change of a node.
+type NodeTransition struct {
+	NodeName  string    `json:"nodeName"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"` // "node_condition", "heartbeat_gap" or "heartbeat_status"
+	Reason    string    `json:"reason,omitempty"`
+	RootCause string    `json:"rootCause,omitempty"` // set on NotReady transitions with a causal chain
+}
+
+// nodeState is the tracker's view of one node. A node is Ready only when both
+// its Node condition and its heartbeat stream say so.
+type nodeState struct {
+	conditionReady   bool
+	heartbeatReady   bool
+	reportedNotReady bool // heartbeats report a status other than Ready
+	history          []NodeTransition
+	labels           map[string]string // from the Node object, nil until watched
+	namespace        string            // of the latest heartbeat
+}
+
+func (ns *nodeState) status() string {
+	if ns.conditionReady && ns.heartbeatReady {
+		return statusReady
+	}
+	return statusNotReady
+}
+
+// NodeStateTracker combines Node Ready conditions and heartbeat gaps into an
+// explicit Ready/NotReady state per node, and builds a causal chain on every
+// Ready→NotReady transition.
+type NodeStateTracker struct {
+	mu         sync.Mutex
+	nodes      map[string]*nodeState
+	detector   *AnomalyDetector
+	chains     *CausalChainBuilder
+	hub        *Hub
+	maxHistory int
+}
+
+// NewNodeStateTracker creates a tracker. detector, chains and hub may be nil.
+func NewNodeStateTracker(detector *AnomalyDetector, chains *CausalChainBuilder, hub *Hub) *NodeStateTracker {
+	return &NodeStateTracker{
+		nodes:      make(map[string]*nodeState),
+		detector:   detector,
+		chains:     chains,
+		hub:        hub,
+		maxHistory: defaultTransitionHistory,
+	}
+}
+
+// ObserveCondition records a Node Ready condition. The first observation of a
+// node only establishes its initial state and does not emit a transition.
+func (t *NodeStateTracker) ObserveCondition(nodeName string, ready bool, reason string, ts time.Time) {
+	t.mu.Lock()
+	ns, seen := t.nodes[nodeName]
+	if !seen {
+		t.nodes[nodeName] = &nodeState{conditionReady: ready, heartbeatReady: true}
+		t.mu.Unlock()
+		return
+	}
+	before := ns.status()
+	ns.conditionReady = ready
+	tr := t.transitionLocked(nodeName, before, ns.status(), ts, transitionSourceCondition, reason)
+	t.mu.Unlock()
+
+	t.emit(tr)
+}
+
+// ObserveHeartbeat records a received heartbeat. The heartbeat side of the
+// node's state follows the status the heartbeat reports: Ready, or empty as
+// sent by older agents, ends a gap, and any other status is NotReady.
+func (t *NodeStateTracker) ObserveHeartbeat(hb Heartbeat) {
+	ready := hb.Status == "" || hb.Status == statusReady
+	t.mu.Lock()
+	ns, seen := t.nodes[hb.NodeName]
+	if !seen {
+		t.nodes[hb.NodeName] = &nodeState{conditionReady: true, heartbeatReady: ready, reportedNotReady: !ready, namespace: hb.Namespace}
+		t.mu.Unlock()
+		return
+	}
+	ns.namespace = hb.Namespace
+	before := ns.status()
+	source, reason := transitionSourceHeartbeatGap, "heartbeat resumed"
+	if !ready || ns.reportedNotReady {
+		source, reason = transitionSourceHeartbeatStatus, "heartbeat reported "+hb.Status
+	}
+	ns.heartbeatReady = ready
+	ns.reportedNotReady = !ready
+	tr := t.transitionLocked(hb.NodeName, before, ns.status(), hb.Timestamp, source, reason)
+	t.mu.Unlock()
+
+	t.emit(tr)
+}
+
+// ObserveLabels records the labels of a node's Node object.
+func (t *NodeStateTracker) ObserveLabels(nodeName string, labels map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ns, seen := t.nodes[nodeName]
+	if !seen {
+		ns = &nodeState{conditionReady: true, heartbeatReady: true}
+		t.nodes[nodeName] = ns
+	}
+	ns.labels = labels
+}
+
+// Labels returns the last observed labels of a node, nil if unknown.
+func (t *NodeStateTracker) Labels(nodeName string) map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ns, seen := t.nodes[nodeName]; seen {
+		return ns.labels
+	}
+	return nil
+}
+
+// ObserveAlert records an anomaly alert. A critical gap means the node missed
+// heartbeats long enough to be NotReady, so it is marked NotReady as of the
+// moment the gap crossed the critical threshold.
+func (t *NodeStateTracker) ObserveAlert(alert Alert) {
+	if alert.Severity != "critical" {
+		return
+	}
+	lastSeen := alert.Timestamp.Add(-time.Duration(alert.Gap * float64(time.Second)))
+	t.markHeartbeatOverdue(alert.NodeName, lastSeen)
+}
+
+// Sweep checks every known node for a heartbeat gap beyond the critical
+// threshold. This catches nodes that stopped renewing entirely.
+func (t *NodeStateTracker) Sweep(now time.Time) {
+	if t.detector == nil {
+		return
+	}
+	t.mu.Lock()
+	names := make([]string, 0, len(t.nodes))
+	for name, ns := range t.nodes {
+		if ns.heartbeatReady {
+			names = append(names, name)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, name := range names {
+		gap, severity, ok := t.detector.CheckGap(name, now)
+		if !ok || severity != "critical" {
+			continue
+		}
+		t.markHeartbeatOverdue(name, now.Add(-gap))
+	}
+}
+
+// Transitions returns the recorded transition history of a node, oldest first.
+// ok is false when the node has never been observed.
+func (t *NodeStateTracker) Transitions(nodeName string) ([]NodeTransition, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ns, seen := t.nodes[nodeName]
+	if !seen {
+		return nil, false
+	}
+	out := make([]NodeTransition, len(ns.history))
+	copy(out, ns.history)
+	return out, true
+}
+
+// Status returns the current Ready/NotReady status of a node.
+func (t *NodeStateTracker) Status(nodeName string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ns, seen := t.nodes[nodeName]
+	if !seen {
+		return "", false
+	}
+	return ns.status(), true
+}
+
+// RootCause returns the root cause recorded when a currently NotReady node
+// transitioned to NotReady, or "" if the node is Ready or has none.
+func (t *NodeStateTracker) RootCause(nodeName string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ns, seen := t.nodes[nodeName]
+	if !seen || ns.status() != statusNotReady {
+		return ""
+	}
+	for i := len(ns.history) - 1; i >= 0; i-- {
+		if ns.history[i].To == statusNotReady {
+			return ns.history[i].RootCause
+		}
+	}
+	return ""
+}
+
+// Forget drops a node that left the cluster, with its transition history.
+func (t *NodeStateTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.nodes, nodeName)
+}
+
+// Nodes returns the names of all observed nodes in sorted order.
+func (t *NodeStateTracker) Nodes() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.nodes))
+	for name := range t.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// markHeartbeatOverdue flips the heartbeat side of a node's state to NotReady.
+// Thresholds are resolved outside t.mu since resolution may look up the
+// node's labels through the tracker.
+func (t *NodeStateTracker) markHeartbeatOverdue(nodeName string, lastSeen time.Time) {
+	ts := lastSeen
+	if t.detector != nil {
+		t.mu.Lock()
+		var namespace string
+		if ns, seen := t.nodes[nodeName]; seen {
+			namespace = ns.namespace
+		}
+		t.mu.Unlock()
+		ts = t.detector.OverdueAt(nodeName, namespace, lastSeen)
+	}
+
+	t.mu.Lock()
+	ns, seen := t.nodes[nodeName]
+	if !seen {
+		ns = &nodeState{conditionReady: true, heartbeatReady: true}
+		t.nodes[nodeName] = ns
+	}
+	before := ns.status()
+	ns.heartbeatReady = false
+	tr := t.transitionLocked(nodeName, before, ns.status(), ts, transitionSourceHeartbeatGap, "heartbeat overdue")
+	t.mu.Unlock()
+
+	t.emit(tr)
+}
+
+// transitionLocked builds a transition when the status changed. Caller holds t.mu.
+func (t *NodeStateTracker) transitionLocked(nodeName, from, to string, ts time.Time, source, reason string) *NodeTransition {
+	if from == to {
+		return nil
+	}
+	return &NodeTransition{
+		NodeName:  nodeName,
+		From:      from,
+		To:        to,
+		Timestamp: ts,
+		Source:    source,
+		Reason:    reason,
+	}
+}
+
+// emit builds a causal chain on the falling edge, appends the transition to
+// the node's history, and broadcasts it. A nil transition is ignored.
+func (t *NodeStateTracker) emit(tr *NodeTransition) {
+	if tr == nil {
+		return
+	}
+
+	if tr.To == statusNotReady && t.chains != nil {
+		chain, err := t.chains.OnNotReady(tr.NodeName, tr.Timestamp)
+		if err != nil {
+			log.Printf("Failed to build causal chain for %s: %v", tr.NodeName, err)
+		} else if chain != nil {
+			tr.RootCause = chain.RootCause
+		}
+	}
+
+	t.mu.Lock()
+	if ns, ok := t.nodes[tr.NodeName]; ok {
+		ns.history = append(ns.history, *tr)
+		if len(ns.history) > t.maxHistory {
+			ns.history = ns.history[len(ns.history)-t.maxHistory:]
+		}
+	}
+	t.mu.Unlock()
+
+	log.Printf("Node %s transitioned %s → %s (%s: %s)", tr.NodeName, tr.From, tr.To, tr.Source, tr.Reason)
+	if t.hub != nil {
+		t.hub.BroadcastNodeTransition(*tr)
+	}
+}
+
+// runNodeConditionSource watches Node Ready conditions through the given
+// clientset and feeds them into the tracker, and forgets deleted Nodes
+// everywhere through forgetNode. Blocks until ctx is cancelled.
+func runNodeConditionSource(ctx context.Context, clientset k8sclient.Interface, tracker *NodeStateTracker) error {
+	watcher := kubernetes.NewNodeWatcher(clientset, func(c kubernetes.NodeCondition) {
+		tracker.ObserveCondition(c.NodeName, c.Ready, c.Reason, c.Timestamp)
+		tracker.ObserveLabels(c.NodeName, c.Labels)
+	})
+	watcher.OnDelete(func(nodeName string) {
+		log.Printf("Node %s deleted, forgetting the node", nodeName)
+		tracker.Forget(nodeName)
+		forgetNode(nodeName, time.Now())
+	})
+	return watcher.Run(ctx)
+}
+
+// nodeTransitionsHandler serves GET /api/nodes/{name}/transitions.
+func nodeTransitionsHandler(tracker *NodeStateTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.PathValue("name")
+		transitions, ok := tracker.Transitions(name)
+		if !ok {
+			writeJSONError(w, "Node not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transitions)
+	}
+}
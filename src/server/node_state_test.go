@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTracker returns a tracker wired to a fresh MemoryStore with 10s/40s thresholds.
+func newTestTracker() (*NodeStateTracker, *MemoryStore) {
+	ms := NewMemoryStore()
+	det := NewAnomalyDetector(ms, 10, 40)
+	ccb := NewCausalChainBuilder(ms, nil)
+	return NewNodeStateTracker(det, ccb, nil), ms
+}
+
+func TestNodeStateTracker_ConditionFallingEdgeBuildsCausalChain(t *testing.T) {
+	tracker, ms := newTestTracker()
+	ctx := context.Background()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	ms.SaveKernelEvent(ctx, EnrichedEvent{
+		Timestamp:    base.Add(-30 * time.Second),
+		PID:          1234,
+		Comm:         "kubelet",
+		EventType:    "process",
+		ExitCode:     137,
+		CriticalExit: true,
+		NodeName:     "node-01",
+	})
+
+	tracker.ObserveCondition("node-01", true, "KubeletReady", base.Add(-time.Hour))
+	if trs, _ := tracker.Transitions("node-01"); len(trs) != 0 {
+		t.Fatalf("initial observation must not emit a transition, got %+v", trs)
+	}
+
+	tracker.ObserveCondition("node-01", false, "NodeStatusUnknown", base)
+	if rc := tracker.RootCause("node-01"); !strings.HasPrefix(rc, "critical_exit") {
+		t.Fatalf("RootCause() while NotReady = %q, want critical_exit", rc)
+	}
+	tracker.ObserveCondition("node-01", true, "KubeletReady", base.Add(2*time.Minute))
+	if rc := tracker.RootCause("node-01"); rc != "" {
+		t.Fatalf("RootCause() after recovery = %q, want empty", rc)
+	}
+
+	trs, ok := tracker.Transitions("node-01")
+	if !ok || len(trs) != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", trs)
+	}
+	if trs[0].From != statusReady || trs[0].To != statusNotReady || trs[0].Source != transitionSourceCondition {
+		t.Fatalf("unexpected falling edge: %+v", trs[0])
+	}
+	if !strings.HasPrefix(trs[0].RootCause, "critical_exit") {
+		t.Fatalf("RootCause = %q, want critical_exit", trs[0].RootCause)
+	}
+	if trs[1].From != statusNotReady || trs[1].To != statusReady || trs[1].RootCause != "" {
+		t.Fatalf("unexpected rising edge: %+v", trs[1])
+	}
+
+	chains, _ := ms.GetCausalChains(ctx, "node-01", base.Add(-time.Minute), base.Add(time.Minute))
+	if len(chains) != 1 {
+		t.Fatalf("expected exactly 1 causal chain, got %d", len(chains))
+	}
+	if !chains[0].Timestamp.Equal(base) {
+		t.Fatalf("chain timestamp = %v, want %v", chains[0].Timestamp, base)
+	}
+}
+
+func TestNodeStateTracker_SweepDetectsHeartbeatGap(t *testing.T) {
+	tracker, ms := newTestTracker()
+	ctx := context.Background()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	hb := Heartbeat{NodeName: "node-01", Namespace: "default", Timestamp: base, Status: "Ready"}
+	ms.Save(ctx, hb)
+	tracker.ObserveHeartbeat(hb)
+
+	// Within the critical threshold: still Ready
+	tracker.Sweep(base.Add(30 * time.Second))
+	if status, _ := tracker.Status("node-01"); status != statusReady {
+		t.Fatalf("status = %q after 30s, want Ready", status)
+	}
+
+	tracker.Sweep(base.Add(60 * time.Second))
+	tracker.Sweep(base.Add(90 * time.Second)) // repeated sweeps must not duplicate
+	if status, _ := tracker.Status("node-01"); status != statusNotReady {
+		t.Fatalf("status = %q after 60s, want NotReady", status)
+	}
+
+	resumed := Heartbeat{NodeName: "node-01", Namespace: "default", Timestamp: base.Add(95 * time.Second), Status: "Ready"}
+	ms.Save(ctx, resumed)
+	tracker.ObserveHeartbeat(resumed)
+
+	trs, _ := tracker.Transitions("node-01")
+	if len(trs) != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", trs)
+	}
+	if trs[0].To != statusNotReady || trs[0].Source != transitionSourceHeartbeatGap {
+		t.Fatalf("unexpected falling edge: %+v", trs[0])
+	}
+	// Transition time is the moment the gap crossed the 40s critical threshold
+	if !trs[0].Timestamp.Equal(base.Add(40 * time.Second)) {
+		t.Fatalf("falling edge at %v, want %v", trs[0].Timestamp, base.Add(40*time.Second))
+	}
+	if trs[0].RootCause != "unknown_cause" {
+		t.Fatalf("RootCause = %q, want unknown_cause", trs[0].RootCause)
+	}
+	if trs[1].To != statusReady || !trs[1].Timestamp.Equal(base.Add(95*time.Second)) {
+		t.Fatalf("unexpected rising edge: %+v", trs[1])
+	}
+}
+
+// TestNodeStateTracker_SweepUsesNodeThresholds verifies a node's labels select
+// its thresholds, so a control-plane node goes NotReady after its own 15s.
+func TestNodeStateTracker_SweepUsesNodeThresholds(t *testing.T) {
+	ms := NewMemoryStore()
+	det := NewAnomalyDetector(ms, 10, 40)
+	tracker := NewNodeStateTracker(det, nil, nil)
+	policy := NewThresholdPolicy(det.Thresholds(), tracker.Labels)
+	if err := policy.Set(ThresholdPolicyConfig{Rules: []ThresholdRule{
+		{Name: "control-plane", NodeSelector: "node-role.kubernetes.io/control-plane", WarningSeconds: 5, CriticalSeconds: 15},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	det.SetPolicy(policy)
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tracker.ObserveCondition("cp-01", true, "KubeletReady", base.Add(-time.Hour))
+	tracker.ObserveLabels("cp-01", map[string]string{"node-role.kubernetes.io/control-plane": ""})
+	for _, name := range []string{"cp-01", "worker-01"} {
+		hb := Heartbeat{NodeName: name, Namespace: "kube-node-lease", Timestamp: base, Status: "Ready"}
+		ms.Save(context.Background(), hb)
+		tracker.ObserveHeartbeat(hb)
+	}
+
+	tracker.Sweep(base.Add(20 * time.Second))
+	if status, _ := tracker.Status("worker-01"); status != statusReady {
+		t.Fatalf("worker-01 status = %q, want Ready", status)
+	}
+	trs, _ := tracker.Transitions("cp-01")
+	if len(trs) != 1 || trs[0].To != statusNotReady || !trs[0].Timestamp.Equal(base.Add(15*time.Second)) {
+		t.Fatalf("cp-01 transitions = %+v, want NotReady at +15s", trs)
+	}
+}
+
+func TestNodeStateTracker_CriticalAlertProducesTransitionPair(t *testing.T) {
+	tracker, _ := newTestTracker()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Timestamp: base})
+
+	// Warning alerts do not change state
+	tracker.ObserveAlert(Alert{NodeName: "node-01", Severity: "warning", Gap: 15, Timestamp: base.Add(15 * time.Second)})
+	if status, _ := tracker.Status("node-01"); status != statusReady {
+		t.Fatalf("warning alert changed status to %q", status)
+	}
+
+	late := base.Add(100 * time.Second)
+	tracker.ObserveAlert(Alert{NodeName: "node-01", Severity: "critical", Gap: 85, Timestamp: late})
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Timestamp: late})
+
+	trs, _ := tracker.Transitions("node-01")
+	if len(trs) != 2 || trs[0].To != statusNotReady || trs[1].To != statusReady {
+		t.Fatalf("expected NotReady→Ready pair, got %+v", trs)
+	}
+	// Last heartbeat at late-85s = base+15s; overdue 40s later
+	if !trs[0].Timestamp.Equal(base.Add(55 * time.Second)) {
+		t.Fatalf("falling edge at %v, want %v", trs[0].Timestamp, base.Add(55*time.Second))
+	}
+}
+
+func TestNodeStateTracker_BothSourcesMustRecover(t *testing.T) {
+	tracker, _ := newTestTracker()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tracker.ObserveCondition("node-01", true, "KubeletReady", base)
+	tracker.ObserveCondition("node-01", false, "KubeletNotReady", base.Add(time.Second))
+	tracker.ObserveAlert(Alert{NodeName: "node-01", Severity: "critical", Gap: 50, Timestamp: base.Add(60 * time.Second)})
+
+	// Heartbeats resume but the Node condition is still NotReady
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Timestamp: base.Add(60 * time.Second)})
+	if status, _ := tracker.Status("node-01"); status != statusNotReady {
+		t.Fatalf("status = %q, want NotReady while condition is NotReady", status)
+	}
+
+	tracker.ObserveCondition("node-01", true, "KubeletReady", base.Add(70*time.Second))
+	trs, _ := tracker.Transitions("node-01")
+	if len(trs) != 2 {
+		t.Fatalf("expected exactly one falling and one rising edge, got %+v", trs)
+	}
+	if trs[1].To != statusReady || trs[1].Source != transitionSourceCondition {
+		t.Fatalf("unexpected rising edge: %+v", trs[1])
+	}
+}
+
+func TestNodeTransitionsHandler(t *testing.T) {
+	tracker, _ := newTestTracker()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	tracker.ObserveCondition("node-01", true, "KubeletReady", base)
+	tracker.ObserveCondition("node-01", false, "KubeletNotReady", base.Add(time.Minute))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/nodes/{name}/transitions", nodeTransitionsHandler(tracker))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nodes/node-01/transitions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var trs []NodeTransition
+	if err := json.NewDecoder(rec.Body).Decode(&trs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(trs) != 1 || trs[0].NodeName != "node-01" || trs[0].To != statusNotReady || trs[0].Reason != "KubeletNotReady" {
+		t.Fatalf("unexpected transitions: %+v", trs)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nodes/ghost/transitions", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown node, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/nodes/node-01/transitions", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+}
+
+// TestIngestHeartbeat_InvokesCausalChainOnGap verifies the ingestion path calls
+// OnNotReady when a node's heartbeat gap exceeds the critical threshold.
+func TestIngestHeartbeat_InvokesCausalChainOnGap(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origTracker := nodeTracker
+	nodeTracker = NewNodeStateTracker(detector, chainBuilder, hub)
+	defer func() { nodeTracker = origTracker }()
+
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	if err := ingestHeartbeat(ctx, Heartbeat{NodeName: "node-01", Timestamp: base, Status: "Ready"}); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := ingestHeartbeat(ctx, Heartbeat{NodeName: "node-01", Timestamp: base.Add(2 * time.Minute), Status: "Ready"}); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+
+	chains, _ := store.GetCausalChains(ctx, "node-01", base, base.Add(time.Hour))
+	if len(chains) != 1 {
+		t.Fatalf("expected 1 causal chain, got %d", len(chains))
+	}
+	if status, _ := nodeTracker.Status("node-01"); status != statusReady {
+		t.Fatalf("status = %q, want Ready after heartbeat resumed", status)
+	}
+}
+
+func TestNodeStateTracker_HeartbeatStatus(t *testing.T) {
+	tracker, _ := newTestTracker()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Status: "Ready", Timestamp: base})
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Status: "NotReady", Timestamp: base.Add(10 * time.Second)})
+	if status, _ := tracker.Status("node-01"); status != statusNotReady {
+		t.Fatalf("status after a NotReady heartbeat = %q, want NotReady", status)
+	}
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Status: "NotReady", Timestamp: base.Add(20 * time.Second)})
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-01", Status: "Ready", Timestamp: base.Add(30 * time.Second)})
+	if status, _ := tracker.Status("node-01"); status != statusReady {
+		t.Fatalf("status after a Ready heartbeat = %q, want Ready", status)
+	}
+
+	trs, _ := tracker.Transitions("node-01")
+	if len(trs) != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", trs)
+	}
+	if trs[0].To != statusNotReady || trs[0].Source != transitionSourceHeartbeatStatus || trs[0].Reason != "heartbeat reported NotReady" {
+		t.Fatalf("unexpected falling edge: %+v", trs[0])
+	}
+	if trs[1].To != statusReady || trs[1].Source != transitionSourceHeartbeatStatus || !trs[1].Timestamp.Equal(base.Add(30*time.Second)) {
+		t.Fatalf("unexpected rising edge: %+v", trs[1])
+	}
+
+	// A node first seen reporting NotReady starts NotReady
+	tracker.ObserveHeartbeat(Heartbeat{NodeName: "node-02", Status: "NotReady", Timestamp: base})
+	if status, _ := tracker.Status("node-02"); status != statusNotReady {
+		t.Fatalf("status of a new node reporting NotReady = %q, want NotReady", status)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// nodeEventRateWindow is how far back a node's kernel event rates are
+// measured.
+const nodeEventRateWindow = 5 * time.Minute
+
+// NodeSummary is a node's current health, as served by GET /api/nodes.
+type NodeSummary struct {
+	NodeName      string    `json:"nodeName"`
+	Namespace     string    `json:"namespace,omitempty"`
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitzero"`
+	GapSeconds    float64   `json:"gapSeconds,omitempty"` // since the last heartbeat
+	Severity      string    `json:"severity,omitempty"`   // of the open gap's alert, "warning" or "critical"; empty without one
+
+	// Alerts about the node, alone or as part of a correlated outage:
+	// firing, and firing or resolved within the alert manager's retention
+	ActiveAlerts int `json:"activeAlerts"`
+	RecentAlerts int `json:"recentAlerts"`
+
+	RootCause            string    `json:"rootCause,omitempty"` // of the latest causal chain
+	RootCauseAt          time.Time `json:"rootCauseAt,omitzero"`
+	PredictionConfidence float64   `json:"predictionConfidence,omitempty"` // of the latest prediction
+	PredictedAt          time.Time `json:"predictedAt,omitzero"`
+
+	// Kernel events per second over the last 5 minutes, by event type
+	KernelEventRates map[string]float64 `json:"kernelEventRates"`
+}
+
+// NodeInventory summarizes every node seen in heartbeats or kernel events
+// from the store and the detection components. Any component but the store
+// may be nil; its fields are then left empty.
+type NodeInventory struct {
+	store     Store
+	detector  *AnomalyDetector
+	adaptive  *AdaptiveDetector
+	mode      string // detector mode, as in Config.DetectorMode
+	chains    *CausalChainBuilder
+	predictor *PredictionEngine
+	alerts    *AlertManager
+}
+
+// NewNodeInventory creates an inventory over the given components.
+func NewNodeInventory(store Store, detector *AnomalyDetector, chains *CausalChainBuilder, predictor *PredictionEngine, alerts *AlertManager) *NodeInventory {
+	return &NodeInventory{store: store, detector: detector, chains: chains, predictor: predictor, alerts: alerts}
+}
+
+// SetAdaptive makes summaries rate gaps as the given detector mode does,
+// with d as the adaptive detector. Call it before the inventory is used.
+func (inv *NodeInventory) SetAdaptive(d *AdaptiveDetector, mode string) {
+	inv.adaptive, inv.mode = d, mode
+}
+
+// Summaries returns the summary of every node at now, sorted by name. Kernel
+// events are counted for all nodes at once.
+func (inv *NodeInventory) Summaries(ctx context.Context, now time.Time) ([]NodeSummary, error) {
+	nodes, err := inv.store.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := inv.store.CountKernelEvents(ctx, "", now.Add(-nodeEventRateWindow), now)
+	if err != nil {
+		return nil, err
+	}
+	alerts := inv.alertList()
+	result := make([]NodeSummary, 0, len(nodes))
+	for _, node := range nodes {
+		s, err := inv.summarize(ctx, node, alerts, counts[node], now)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// Summary returns the summary of one node at now. ok is false when the node
+// has neither heartbeats nor kernel events.
+func (inv *NodeInventory) Summary(ctx context.Context, nodeName string, now time.Time) (summary NodeSummary, ok bool, err error) {
+	if ok, err := inv.store.HasNode(ctx, nodeName); err != nil || !ok {
+		return NodeSummary{}, false, err
+	}
+	counts, err := inv.store.CountKernelEvents(ctx, nodeName, now.Add(-nodeEventRateWindow), now)
+	if err != nil {
+		return NodeSummary{}, false, err
+	}
+	summary, err = inv.summarize(ctx, nodeName, inv.alertList(), counts[nodeName], now)
+	return summary, err == nil, err
+}
+
+func (inv *NodeInventory) alertList() AlertList {
+	if inv.alerts == nil {
+		return AlertList{}
+	}
+	return inv.alerts.Alerts()
+}
+
+// summarize builds the node's summary from the alerts and its kernel event
+// counts over the last nodeEventRateWindow.
+func (inv *NodeInventory) summarize(ctx context.Context, nodeName string, alerts AlertList, eventCounts map[string]int, now time.Time) (NodeSummary, error) {
+	s := NodeSummary{NodeName: nodeName, KernelEventRates: map[string]float64{}}
+
+	latest, err := inv.store.GetLatestByNode(ctx, nodeName)
+	if err != nil {
+		return NodeSummary{}, err
+	}
+	// Severity is that of the gap alert a sweep at now would raise
+	var fixed, adaptive *Alert
+	var warm bool
+	if latest != nil {
+		s.Namespace = latest.Namespace
+		s.LastHeartbeat = latest.Timestamp
+		s.GapSeconds = now.Sub(latest.Timestamp).Seconds()
+		if inv.detector != nil {
+			fixed = inv.detector.gapAlert(nodeName, latest.Namespace, latest.Timestamp, now)
+		}
+	}
+	if inv.adaptive != nil {
+		adaptive, warm = inv.adaptive.OpenGap(nodeName, now)
+	}
+	if alert := chooseGapAlert(inv.mode, fixed, adaptive, warm); alert != nil {
+		s.Severity = alert.Severity
+	}
+
+	for _, a := range alerts.Active {
+		if alertConcerns(a, nodeName) {
+			s.ActiveAlerts++
+			s.RecentAlerts++
+		}
+	}
+	for _, a := range alerts.Resolved {
+		if alertConcerns(a, nodeName) {
+			s.RecentAlerts++
+		}
+	}
+
+	if inv.chains != nil {
+		chain, err := inv.chains.Latest(ctx, nodeName)
+		if err != nil {
+			return NodeSummary{}, err
+		}
+		if chain != nil {
+			s.RootCause = chain.RootCause
+			s.RootCauseAt = chain.Timestamp
+		}
+	}
+
+	if inv.predictor != nil {
+		if p := inv.predictor.Latest(nodeName); p != nil {
+			s.PredictionConfidence = p.Confidence
+			s.PredictedAt = p.Timestamp
+		}
+	}
+
+	for typ, n := range eventCounts {
+		s.KernelEventRates[typ] = float64(n) / nodeEventRateWindow.Seconds()
+	}
+	return s, nil
+}
+
+// alertConcerns reports whether a is about the node, directly or as one of
+// the nodes of a correlated outage.
+func alertConcerns(a Alert, nodeName string) bool {
+	return a.NodeName == nodeName || slices.Contains(a.AffectedNodes, nodeName)
+}
+
+// nodesHandler serves GET /api/nodes.
+func nodesHandler(inv *NodeInventory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		summaries, err := inv.Summaries(r.Context(), time.Now().UTC())
+		if err != nil {
+			writeJSONError(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summaries)
+	}
+}
+
+// nodeHandler serves GET /api/nodes/{name}.
+func nodeHandler(inv *NodeInventory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		summary, ok, err := inv.Summary(r.Context(), r.PathValue("name"), time.Now().UTC())
+		if err != nil {
+			writeJSONError(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !ok {
+			writeJSONError(w, "Node not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestInventory returns an inventory over a fresh MemoryStore with 10s/40s
+// thresholds, where at now node-a last sent a heartbeat 25s ago, has a
+// causal chain, a prediction, a firing gap alert and is part of an outage,
+// and node-b has only kernel events.
+func newTestInventory(t *testing.T, now time.Time) *NodeInventory {
+	t.Helper()
+	ctx := context.Background()
+	s := NewMemoryStore()
+	s.Save(ctx, Heartbeat{NodeName: "node-a", Namespace: "kube-node-lease", Timestamp: now.Add(-35 * time.Second)})
+	s.Save(ctx, Heartbeat{NodeName: "node-a", Namespace: "kube-node-lease", Timestamp: now.Add(-25 * time.Second)})
+	// Three syscalls and a network event in the window, one syscall before it
+	for _, e := range []EnrichedEvent{
+		{NodeName: "node-b", EventType: "syscall", Timestamp: now.Add(-10 * time.Minute)},
+		{NodeName: "node-b", EventType: "syscall", Timestamp: now.Add(-4 * time.Minute)},
+		{NodeName: "node-b", EventType: "syscall", Timestamp: now.Add(-2 * time.Minute)},
+		{NodeName: "node-b", EventType: "network", Timestamp: now.Add(-time.Minute)},
+		{NodeName: "node-b", EventType: "syscall", Timestamp: now},
+	} {
+		s.SaveKernelEvent(ctx, e)
+	}
+	s.SaveCausalChain(ctx, CausalChain{NodeName: "node-a", Timestamp: now.Add(-time.Hour), RootCause: "memory_pressure"})
+	s.SaveCausalChain(ctx, CausalChain{NodeName: "node-a", Timestamp: now.Add(-time.Minute), RootCause: "disk_io_degradation"})
+
+	predictor := NewPredictionEngine(s, nil)
+	predictor.predictions = []Prediction{
+		{NodeName: "node-a", Confidence: 0.4, Timestamp: now.Add(-2 * time.Minute)},
+		{NodeName: "node-b", Confidence: 0.9, Timestamp: now.Add(-90 * time.Second)},
+		{NodeName: "node-a", Confidence: 0.7, Timestamp: now.Add(-time.Minute)},
+	}
+
+	alerts := NewAlertManager(testAlertTimings(), func(Alert) {})
+	alerts.Fire(gapAlert("node-a", "warning", 25, now), now)
+	alerts.Fire(Alert{Type: alertTypeCorrelatedOutage, Severity: "critical", Timestamp: now, AffectedNodes: []string{"node-a", "node-c"}}, now)
+
+	return NewNodeInventory(s, NewAnomalyDetector(s, 10, 40), NewCausalChainBuilder(s, nil), predictor, alerts)
+}
+
+func TestNodeInventorySummaries(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	summaries, err := newTestInventory(t, now).Summaries(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Summaries: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].NodeName != "node-a" || summaries[1].NodeName != "node-b" {
+		t.Fatalf("summaries = %+v, want node-a and node-b", summaries)
+	}
+
+	a := summaries[0]
+	if !a.LastHeartbeat.Equal(now.Add(-25*time.Second)) || a.GapSeconds != 25 || a.Severity != "warning" || a.Namespace != "kube-node-lease" {
+		t.Errorf("node-a heartbeat fields = %+v", a)
+	}
+	if a.ActiveAlerts != 2 || a.RecentAlerts != 2 {
+		t.Errorf("node-a alerts = %d active, %d recent, want 2 and 2", a.ActiveAlerts, a.RecentAlerts)
+	}
+	if a.RootCause != "disk_io_degradation" || !a.RootCauseAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("node-a root cause = %q at %v, want the latest chain's", a.RootCause, a.RootCauseAt)
+	}
+	if a.PredictionConfidence != 0.7 {
+		t.Errorf("node-a prediction confidence = %v, want the latest prediction's 0.7", a.PredictionConfidence)
+	}
+	if len(a.KernelEventRates) != 0 {
+		t.Errorf("node-a kernel event rates = %v, want none", a.KernelEventRates)
+	}
+
+	b := summaries[1]
+	if !b.LastHeartbeat.IsZero() || b.Severity != "" || b.ActiveAlerts != 0 || b.RootCause != "" || b.PredictionConfidence != 0.9 {
+		t.Errorf("node-b = %+v", b)
+	}
+	window := nodeEventRateWindow.Seconds()
+	if len(b.KernelEventRates) != 2 || math.Abs(b.KernelEventRates["syscall"]-3/window) > 1e-12 || math.Abs(b.KernelEventRates["network"]-1/window) > 1e-12 {
+		t.Errorf("node-b kernel event rates = %v, want 3 syscalls and 1 network event per %vs", b.KernelEventRates, window)
+	}
+}
+
+// TestNodeInventoryAdaptiveSeverity verifies a node's severity follows the
+// detector mode, as its gap alert does.
+func TestNodeInventoryAdaptiveSeverity(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	s := NewMemoryStore()
+	adaptive := NewAdaptiveDetector(DefaultAdaptiveConfig())
+	// node-slow renews every 30s and is 25s in, past the fixed 10s warning;
+	// node-fast renews every second and is 8s in, within it
+	for name, interval := range map[string]time.Duration{"node-slow": 30 * time.Second, "node-fast": time.Second} {
+		last := now.Add(-25 * time.Second)
+		if name == "node-fast" {
+			last = now.Add(-8 * time.Second)
+		}
+		for i := 2 * DefaultAdaptiveConfig().WarmupSamples; i >= 0; i-- {
+			hb := Heartbeat{NodeName: name, Timestamp: last.Add(-time.Duration(i) * interval)}
+			s.Save(ctx, hb)
+			adaptive.Observe(hb)
+		}
+	}
+	inv := NewNodeInventory(s, NewAnomalyDetector(s, 10, 40), nil, nil, nil)
+
+	for _, tc := range []struct {
+		mode       string
+		slow, fast string
+	}{
+		{detectorModeThreshold, "warning", ""},
+		{detectorModeAdaptive, "", "critical"},
+		{detectorModeBoth, "warning", "critical"},
+	} {
+		inv.SetAdaptive(adaptive, tc.mode)
+		for node, want := range map[string]string{"node-slow": tc.slow, "node-fast": tc.fast} {
+			summary, _, err := inv.Summary(ctx, node, now)
+			if err != nil || summary.Severity != want {
+				t.Errorf("%s mode: %s severity = %q, %v, want %q", tc.mode, node, summary.Severity, err, want)
+			}
+		}
+	}
+}
+
+func TestNodeInventoryWithoutComponents(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.Save(context.Background(), Heartbeat{NodeName: "node-a", Timestamp: now.Add(-time.Minute)})
+	summary, ok, err := NewNodeInventory(s, nil, nil, nil, nil).Summary(context.Background(), "node-a", now)
+	if err != nil || !ok {
+		t.Fatalf("Summary = %v, %v", ok, err)
+	}
+	if summary.GapSeconds != 60 || summary.Severity != "" || summary.KernelEventRates == nil {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestNodesHandlers(t *testing.T) {
+	now := time.Now().UTC()
+	inv := newTestInventory(t, now)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/nodes", nodesHandler(inv))
+	mux.HandleFunc("/api/nodes/{name}", nodeHandler(inv))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nodes", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var summaries []NodeSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summaries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].NodeName != "node-a" || summaries[0].RootCause != "disk_io_degradation" {
+		t.Fatalf("unexpected summaries: %+v", summaries)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nodes/node-b", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var summary NodeSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if summary.NodeName != "node-b" || summary.KernelEventRates["network"] == 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nodes/ghost", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown node, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/nodes", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+
+	failing := NewNodeInventory(&errorStore{getByTimeRangeErr: errors.New("connection refused")}, nil, nil, nil, nil)
+	rec = httptest.NewRecorder()
+	nodesHandler(failing)(rec, httptest.NewRequest(http.MethodGet, "/api/nodes", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when the store fails, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	nodeHandler(failing)(rec, httptest.NewRequest(http.MethodGet, "/api/nodes/node-a", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a node when the store fails, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Default endpoints for hosted receivers.
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultOpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// Notifier delivers an alert to one named receiver.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// alertSummary is the one-line description used by chat and paging receivers.
+func alertSummary(a Alert) string {
+	if a.Type == alertTypeCorrelatedOutage {
+		scope := "the cluster"
+		if a.CommonLabel != "" {
+			scope = a.CommonLabel
+		}
+		if a.Status == alertStatusResolved {
+			return fmt.Sprintf("[RESOLVED] Correlated outage of %d nodes in %s", len(a.AffectedNodes), scope)
+		}
+		return fmt.Sprintf("[%s] Correlated outage: %d nodes in %s stopped renewing", strings.ToUpper(a.Severity), len(a.AffectedNodes), scope)
+	}
+	if a.Type == alertTypeIntervalDrift {
+		if a.Status == alertStatusResolved {
+			return fmt.Sprintf("[RESOLVED] Node %s renewal interval drift", a.NodeName)
+		}
+		return fmt.Sprintf("[%s] Node %s renewal interval drifted to %.1fs (baseline %.1fs)", strings.ToUpper(a.Severity), a.NodeName, a.Gap, a.BaselineSeconds)
+	}
+	if a.Status == alertStatusResolved {
+		return fmt.Sprintf("[RESOLVED] Node %s heartbeat gap %.1fs", a.NodeName, a.Gap)
+	}
+	return fmt.Sprintf("[%s] Node %s heartbeat gap %.1fs", strings.ToUpper(a.Severity), a.NodeName, a.Gap)
+}
+
+// alertDedupKey identifies the incident across trigger and resolve calls.
+func alertDedupKey(a Alert) string {
+	if a.Fingerprint != "" {
+		return "earthworm/" + a.Fingerprint
+	}
+	return "earthworm/" + a.NodeName
+}
+
+// postJSON marshals body and POSTs it to url with the given extra headers.
+// Any status outside 2xx is an error.
+func postJSON(ctx context.Context, client *http.Client, url string, body any, headers map[string]string) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	return post(ctx, client, url, "application/json", data, headers)
+}
+
+func post(ctx context.Context, client *http.Client, url, contentType string, data []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// SlackNotifier posts to a Slack incoming webhook.
+type SlackNotifier struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewSlackNotifier creates a notifier for a Slack incoming-webhook URL.
+func NewSlackNotifier(name, url string, client *http.Client) *SlackNotifier {
+	return &SlackNotifier{name: name, url: url, client: client}
+}
+
+func (n *SlackNotifier) Name() string { return n.name }
+
+// slackMessage is the subset of the incoming-webhook payload Earthworm uses.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, a Alert) error {
+	color := "warning"
+	switch {
+	case a.Status == alertStatusResolved:
+		color = "good"
+	case a.Severity == "critical":
+		color = "danger"
+	}
+	msg := slackMessage{
+		Text: alertSummary(a),
+		Attachments: []slackAttachment{{
+			Color: color,
+			Fields: []slackField{
+				{Title: "Node", Value: alertSubject(a), Short: true},
+				{Title: "Namespace", Value: a.Namespace, Short: true},
+				{Title: "Gap", Value: fmt.Sprintf("%.1fs", a.Gap), Short: true},
+				{Title: "Kernel events", Value: fmt.Sprintf("%d", len(a.KernelEvents)), Short: true},
+			},
+		}},
+	}
+	return postJSON(ctx, n.client, n.url, msg, nil)
+}
+
+// PagerDutyNotifier triggers and resolves incidents through the PagerDuty
+// Events API v2.
+type PagerDutyNotifier struct {
+	name       string
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+// NewPagerDutyNotifier creates a notifier for an Events v2 integration.
+// An empty url uses the public PagerDuty endpoint.
+func NewPagerDutyNotifier(name, url, routingKey string, client *http.Client) *PagerDutyNotifier {
+	if url == "" {
+		url = defaultPagerDutyURL
+	}
+	return &PagerDutyNotifier{name: name, url: url, routingKey: routingKey, client: client}
+}
+
+func (n *PagerDutyNotifier) Name() string { return n.name }
+
+// pagerDutyEvent is an Events API v2 trigger or resolve event.
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"` // critical, error, warning or info
+	Timestamp     string         `json:"timestamp"`
+	Component     string         `json:"component"`
+	Group         string         `json:"group,omitempty"`
+	CustomDetails map[string]any `json:"custom_details"`
+}
+
+func (n *PagerDutyNotifier) Notify(ctx context.Context, a Alert) error {
+	severity := "warning"
+	if a.Severity == "critical" {
+		severity = "critical"
+	}
+	action := "trigger"
+	if a.Status == alertStatusResolved {
+		action = "resolve"
+	}
+	evt := pagerDutyEvent{
+		RoutingKey:  n.routingKey,
+		EventAction: action,
+		DedupKey:    alertDedupKey(a),
+		Payload: pagerDutyPayload{
+			Summary:   alertSummary(a),
+			Source:    alertSubject(a),
+			Severity:  severity,
+			Timestamp: a.Timestamp.UTC().Format("2006-01-02T15:04:05.000Z"),
+			Component: "node-heartbeat",
+			Group:     a.Namespace,
+			CustomDetails: map[string]any{
+				"gapSeconds":   a.Gap,
+				"kernelEvents": len(a.KernelEvents),
+			},
+		},
+	}
+	if len(a.AffectedNodes) > 0 {
+		evt.Payload.CustomDetails["affectedNodes"] = a.AffectedNodes
+	}
+	return postJSON(ctx, n.client, n.url, evt, nil)
+}
+
+// OpsgenieNotifier creates and closes alerts through the Opsgenie Alert API.
+type OpsgenieNotifier struct {
+	name   string
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewOpsgenieNotifier creates a notifier for an Opsgenie API integration.
+// An empty url uses the public Opsgenie endpoint.
+func NewOpsgenieNotifier(name, url, apiKey string, client *http.Client) *OpsgenieNotifier {
+	if url == "" {
+		url = defaultOpsgenieURL
+	}
+	return &OpsgenieNotifier{name: name, url: url, apiKey: apiKey, client: client}
+}
+
+func (n *OpsgenieNotifier) Name() string { return n.name }
+
+// opsgenieAlert is the create-alert request body.
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Tags        []string          `json:"tags"`
+	Details     map[string]string `json:"details"`
+}
+
+func (n *OpsgenieNotifier) Notify(ctx context.Context, a Alert) error {
+	auth := map[string]string{"Authorization": "GenieKey " + n.apiKey}
+	if a.Status == alertStatusResolved {
+		closeURL := fmt.Sprintf("%s/%s/close?identifierType=alias", n.url, url.PathEscape(alertDedupKey(a)))
+		return postJSON(ctx, n.client, closeURL, map[string]string{"source": "earthworm", "note": alertSummary(a)}, auth)
+	}
+	priority := "P3"
+	if a.Severity == "critical" {
+		priority = "P1"
+	}
+	description := fmt.Sprintf("No heartbeat from node %s for %.1fs.", a.NodeName, a.Gap)
+	if len(a.AffectedNodes) > 0 {
+		description = fmt.Sprintf("Nodes stopped renewing together: %s.", strings.Join(a.AffectedNodes, ", "))
+	}
+	body := opsgenieAlert{
+		Message:     alertSummary(a),
+		Alias:       alertDedupKey(a),
+		Description: description,
+		Priority:    priority,
+		Source:      "earthworm",
+		Tags:        []string{"earthworm", a.Severity},
+		Details: map[string]string{
+			"node":      alertSubject(a),
+			"namespace": a.Namespace,
+			"gap":       fmt.Sprintf("%.1f", a.Gap),
+		},
+	}
+	return postJSON(ctx, n.client, n.url, body, auth)
+}
+
+// WebhookNotifier posts to an arbitrary URL. With no template the body is the
+// Alert JSON; otherwise the template is executed with the Alert as its data.
+type WebhookNotifier struct {
+	name        string
+	url         string
+	tmpl        *template.Template
+	contentType string
+	headers     map[string]string
+	client      *http.Client
+}
+
+// NewWebhookNotifier creates a generic webhook notifier. tmpl may be empty;
+// contentType defaults to application/json.
+func NewWebhookNotifier(name, url, tmpl, contentType string, headers map[string]string, client *http.Client) (*WebhookNotifier, error) {
+	n := &WebhookNotifier{name: name, url: url, contentType: contentType, headers: headers, client: client}
+	if n.contentType == "" {
+		n.contentType = "application/json"
+	}
+	if tmpl != "" {
+		t, err := template.New(name).Funcs(template.FuncMap{
+			"upper": strings.ToUpper,
+			"json": func(v any) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("receiver %q: parse template: %w", name, err)
+		}
+		n.tmpl = t
+	}
+	return n, nil
+}
+
+func (n *WebhookNotifier) Name() string { return n.name }
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	if n.tmpl == nil {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("marshal alert: %w", err)
+		}
+		return post(ctx, n.client, n.url, n.contentType, data, n.headers)
+	}
+	var buf bytes.Buffer
+	if err := n.tmpl.Execute(&buf, a); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+	return post(ctx, n.client, n.url, n.contentType, buf.Bytes(), n.headers)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capturedRequest is one request received by a receiverStub.
+type capturedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiverStub stands in for a Slack/PagerDuty/Opsgenie/webhook endpoint.
+type receiverStub struct {
+	mu       sync.Mutex
+	status   int
+	requests []capturedRequest
+	got      chan struct{}
+}
+
+func newReceiverStub(t *testing.T, status int) (*receiverStub, *httptest.Server) {
+	t.Helper()
+	rs := &receiverStub{status: status, got: make(chan struct{}, 16)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rs.mu.Lock()
+		rs.requests = append(rs.requests, capturedRequest{header: r.Header.Clone(), body: body})
+		rs.mu.Unlock()
+		w.WriteHeader(rs.status)
+		rs.got <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return rs, srv
+}
+
+func (rs *receiverStub) last(t *testing.T) capturedRequest {
+	t.Helper()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.requests) == 0 {
+		t.Fatal("receiver got no requests")
+	}
+	return rs.requests[len(rs.requests)-1]
+}
+
+func testAlert() Alert {
+	return Alert{
+		NodeName:  "prod-node-01",
+		Namespace: "kube-node-lease",
+		Gap:       45.5,
+		Severity:  "critical",
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	rs, srv := newReceiverStub(t, http.StatusOK)
+	n := NewSlackNotifier("slack", srv.URL, srv.Client())
+	if err := n.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var msg slackMessage
+	if err := json.Unmarshal(rs.last(t).body, &msg); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.Contains(msg.Text, "prod-node-01") || !strings.Contains(msg.Text, "CRITICAL") {
+		t.Errorf("text = %q", msg.Text)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Color != "danger" {
+		t.Errorf("attachments = %+v", msg.Attachments)
+	}
+}
+
+func TestPagerDutyNotifier(t *testing.T) {
+	rs, srv := newReceiverStub(t, http.StatusAccepted)
+	n := NewPagerDutyNotifier("pd", srv.URL, "routing-key-1", srv.Client())
+	if err := n.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var evt pagerDutyEvent
+	if err := json.Unmarshal(rs.last(t).body, &evt); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if evt.RoutingKey != "routing-key-1" || evt.EventAction != "trigger" || evt.DedupKey != "earthworm/prod-node-01" {
+		t.Errorf("event = %+v", evt)
+	}
+	if evt.Payload.Severity != "critical" || evt.Payload.Source != "prod-node-01" || evt.Payload.Timestamp != "2024-05-01T12:00:00.000Z" {
+		t.Errorf("payload = %+v", evt.Payload)
+	}
+
+	resolved := testAlert()
+	resolved.Fingerprint = "abc123"
+	resolved.Status = alertStatusResolved
+	if err := n.Notify(context.Background(), resolved); err != nil {
+		t.Fatalf("Notify resolved: %v", err)
+	}
+	if err := json.Unmarshal(rs.last(t).body, &evt); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if evt.EventAction != "resolve" || evt.DedupKey != "earthworm/abc123" {
+		t.Errorf("resolve event = %+v", evt)
+	}
+}
+
+func TestOpsgenieNotifier(t *testing.T) {
+	rs, srv := newReceiverStub(t, http.StatusAccepted)
+	n := NewOpsgenieNotifier("og", srv.URL, "secret", srv.Client())
+	alert := testAlert()
+	alert.Severity = "warning"
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	req := rs.last(t)
+	if got := req.header.Get("Authorization"); got != "GenieKey secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	var body opsgenieAlert
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Priority != "P3" || body.Alias != "earthworm/prod-node-01" || body.Details["node"] != "prod-node-01" {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestWebhookNotifierTemplate(t *testing.T) {
+	rs, srv := newReceiverStub(t, http.StatusOK)
+	n, err := NewWebhookNotifier("custom", srv.URL,
+		`{"node":"{{ .NodeName }}","sev":"{{ upper .Severity }}","gap":{{ printf "%.0f" .Gap }}}`,
+		"", map[string]string{"X-Token": "abc"}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	if err := n.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	req := rs.last(t)
+	if want := `{"node":"prod-node-01","sev":"CRITICAL","gap":46}`; string(req.body) != want {
+		t.Errorf("body = %s, want %s", req.body, want)
+	}
+	if req.header.Get("X-Token") != "abc" || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.header)
+	}
+}
+
+func TestWebhookNotifierRawAlertAndErrors(t *testing.T) {
+	rs, srv := newReceiverStub(t, http.StatusInternalServerError)
+	n, err := NewWebhookNotifier("raw", srv.URL, "", "", nil, srv.Client())
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier: %v", err)
+	}
+	if err := n.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("expected error for 500 response")
+	}
+	var got Alert
+	if err := json.Unmarshal(rs.last(t).body, &got); err != nil || got.NodeName != "prod-node-01" {
+		t.Errorf("raw body = %s (%v)", rs.last(t).body, err)
+	}
+
+	if _, err := NewWebhookNotifier("bad", srv.URL, "{{ .Missing", "", nil, nil); err == nil {
+		t.Error("expected template parse error")
+	}
+}
+
+// stubNotifier is a named Notifier that accepts every alert.
+type stubNotifier struct{ name string }
+
+func (s stubNotifier) Name() string                        { return s.name }
+func (s stubNotifier) Notify(context.Context, Alert) error { return nil }
+
+func routedNames(ns []Notifier) []string {
+	var out []string
+	for _, n := range ns {
+		out = append(out, n.Name())
+	}
+	return out
+}
+
+func TestAlertRouterRoute(t *testing.T) {
+	router, err := NewAlertRouter(
+		[]Notifier{stubNotifier{"pager"}, stubNotifier{"prod-slack"}, stubNotifier{"team"}, stubNotifier{"catchall"}},
+		[]AlertRoute{
+			{Receiver: "pager", Severities: []string{"critical"}, NodePattern: "prod-*", Continue: true},
+			{Receiver: "prod-slack", NodePattern: "prod-*"},
+			{Receiver: "team", Namespaces: []string{"payments"}},
+		},
+		"catchall",
+	)
+	if err != nil {
+		t.Fatalf("NewAlertRouter: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		alert Alert
+		want  string
+	}{
+		{"critical prod continues", Alert{NodeName: "prod-1", Severity: "critical"}, "pager,prod-slack"},
+		{"warning prod", Alert{NodeName: "prod-1", Severity: "warning"}, "prod-slack"},
+		{"namespace match", Alert{NodeName: "dev-1", Namespace: "payments", Severity: "critical"}, "team"},
+		{"no match uses default", Alert{NodeName: "dev-1", Namespace: "other", Severity: "critical"}, "catchall"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(routedNames(router.Route(tt.alert)), ","); got != tt.want {
+				t.Errorf("Route = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAlertRouterValidation(t *testing.T) {
+	receivers := []Notifier{stubNotifier{"a"}}
+	if _, err := NewAlertRouter(receivers, []AlertRoute{{Receiver: "missing"}}, ""); err == nil {
+		t.Error("expected error for unknown route receiver")
+	}
+	if _, err := NewAlertRouter(receivers, nil, "missing"); err == nil {
+		t.Error("expected error for unknown default receiver")
+	}
+	if _, err := NewAlertRouter(receivers, []AlertRoute{{Receiver: "a", NodePattern: "[bad"}}, ""); err == nil {
+		t.Error("expected error for invalid node pattern")
+	}
+	if _, err := NewAlertRouter([]Notifier{stubNotifier{"a"}, stubNotifier{"a"}}, nil, ""); err == nil {
+		t.Error("expected error for duplicate receiver")
+	}
+}
+
+// TestAlertDispatcherRoutesFromConfig loads a routing file and verifies each
+// alert reaches only the receivers its route selects.
+func TestAlertDispatcherRoutesFromConfig(t *testing.T) {
+	slack, slackSrv := newReceiverStub(t, http.StatusOK)
+	pd, pdSrv := newReceiverStub(t, http.StatusAccepted)
+
+	cfgFile := filepath.Join(t.TempDir(), "routes.yaml")
+	os.WriteFile(cfgFile, []byte(`
+receivers:
+  - name: oncall
+    type: pagerduty
+    url: `+pdSrv.URL+`
+    routingKey: key
+  - name: chat
+    type: slack
+    url: `+slackSrv.URL+`
+routes:
+  - receiver: oncall
+    severities: [critical]
+    continue: true
+defaultReceiver: chat
+`), 0o644)
+
+	routing, err := LoadAlertRoutingConfig(cfgFile)
+	if err != nil {
+		t.Fatalf("LoadAlertRoutingConfig: %v", err)
+	}
+	d := NewAlertDispatcher("", nil)
+	router, err := routing.Build(d.httpClient)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	d.SetRouter(router)
+
+	d.Dispatch(Alert{NodeName: "node-01", Severity: "critical", Timestamp: time.Now()})
+	select {
+	case <-pd.got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pagerduty receiver not called")
+	}
+	select {
+	case <-slack.got:
+		t.Fatal("critical alert matched a route; default receiver must not be used")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	d.Dispatch(Alert{NodeName: "node-01", Severity: "warning", Timestamp: time.Now()})
+	select {
+	case <-slack.got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("default receiver not called for unrouted alert")
+	}
+}
+
+func TestAlertRoutingConfigBuildErrors(t *testing.T) {
+	for _, rc := range []ReceiverConfig{
+		{Type: "slack", URL: "http://x"},
+		{Name: "s", Type: "slack"},
+		{Name: "p", Type: "pagerduty"},
+		{Name: "o", Type: "opsgenie"},
+		{Name: "w", Type: "webhook"},
+		{Name: "e", Type: "email"},
+	} {
+		if _, err := (AlertRoutingConfig{Receivers: []ReceiverConfig{rc}}).Build(http.DefaultClient); err == nil {
+			t.Errorf("expected error for receiver %+v", rc)
+		}
+	}
+}
+
+func TestAlertRoutingConfigRejectNamespaces(t *testing.T) {
+	c := AlertRoutingConfig{Routes: []AlertRoute{{Receiver: "a", NodePattern: "prod-*"}}}
+	if err := c.rejectNamespaces(); err != nil {
+		t.Errorf("rejectNamespaces without namespace matchers: %v", err)
+	}
+	c.Routes = append(c.Routes, AlertRoute{Receiver: "b", Namespaces: []string{"prod"}})
+	if err := c.rejectNamespaces(); err == nil || !strings.Contains(err.Error(), `route 1 to "b"`) {
+		t.Errorf("rejectNamespaces = %v, want an error naming route 1", err)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const alertTypeCorrelatedOutage = "correlated_outage"
+
+// OutageConfig tunes the OutageDetector.
+type OutageConfig struct {
+	// Window is how close together nodes must cross their critical
+	// threshold for their failures to count as one event.
+	Window time.Duration
+	// MinNodes is the fewest nodes that make an outage.
+	MinNodes int
+	// Fraction is the share of a group's nodes, or of the whole cluster,
+	// that must fail within Window.
+	Fraction float64
+	// LabelKeys are the node labels whose values group nodes into failure
+	// domains, e.g. "topology.kubernetes.io/zone".
+	LabelKeys []string
+}
+
+// DefaultOutageConfig returns the configuration used when none is given.
+func DefaultOutageConfig() OutageConfig {
+	return OutageConfig{
+		Window:    2 * time.Minute,
+		MinNodes:  3,
+		Fraction:  0.5,
+		LabelKeys: []string{"topology.kubernetes.io/zone"},
+	}
+}
+
+// outageNode is what the detector knows about one node's renewals.
+type outageNode struct {
+	namespace string
+	lastSeen  time.Time
+}
+
+// failedNode is a node past its critical threshold; failedAt is when it
+// crossed it.
+type failedNode struct {
+	name     string
+	failedAt time.Time
+}
+
+// OutageDetector recognises correlated failures: many nodes, or a large share
+// of a failure domain such as a zone, crossing their critical heartbeat
+// threshold within one sliding window. It raises a single correlated_outage
+// alert per outage, under which the AlertManager inhibits the per-node alerts
+// of the affected nodes.
+type OutageDetector struct {
+	cfg      OutageConfig
+	detector *AnomalyDetector
+	labels   func(nodeName string) map[string]string
+
+	mu     sync.Mutex
+	nodes  map[string]*outageNode
+	active map[string]map[string]bool // affected nodes of outages in progress, by alertSubject
+}
+
+// NewOutageDetector creates a detector that resolves each node's critical
+// threshold through detector. nodeLabels looks up a node's labels and may be
+// nil, in which case only cluster-wide outages are recognised.
+func NewOutageDetector(cfg OutageConfig, detector *AnomalyDetector, nodeLabels func(nodeName string) map[string]string) *OutageDetector {
+	return &OutageDetector{
+		cfg:      cfg,
+		detector: detector,
+		labels:   nodeLabels,
+		nodes:    make(map[string]*outageNode),
+		active:   make(map[string]map[string]bool),
+	}
+}
+
+// ObserveHeartbeat records a node's renewal. Older heartbeats are ignored.
+func (d *OutageDetector) ObserveHeartbeat(hb Heartbeat) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n, ok := d.nodes[hb.NodeName]
+	if !ok {
+		d.nodes[hb.NodeName] = &outageNode{namespace: hb.Namespace, lastSeen: hb.Timestamp}
+		return
+	}
+	if hb.Timestamp.After(n.lastSeen) {
+		n.lastSeen = hb.Timestamp
+		n.namespace = hb.Namespace
+	}
+}
+
+// Forget drops a node that left the cluster, so that it no longer counts as
+// failed. Outages it was part of end once their remaining nodes have renewed.
+func (d *OutageDetector) Forget(nodeName string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.nodes, nodeName)
+	for _, affected := range d.active {
+		delete(affected, nodeName)
+	}
+}
+
+// Evaluate returns the outages in progress at now, each as a critical
+// correlated_outage alert, and the subjects of outages that have ended
+// since the last call.
+//
+// Within a group, the failed nodes counted are the largest set that failed
+// within Window of each other; nodes that failed before or after it are
+// separate events and are left out of the group's population. An outage
+// needs at least MinNodes such nodes making up at least Fraction of the
+// population. Groups are the values of each of LabelKeys; a cluster-wide
+// outage is only reported when no labelled group accounts for its nodes.
+// Once reported, an outage lasts until every node it covered has renewed
+// again, and its AffectedNodes keep all of them.
+func (d *OutageDetector) Evaluate(now time.Time) (firing []Alert, ended []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var all []string
+	failedAt := make(map[string]time.Time)
+	nodeLabels := make(map[string]map[string]string)
+	for name, n := range d.nodes {
+		all = append(all, name)
+		if at := d.detector.OverdueAt(name, n.namespace, n.lastSeen); !now.Before(at) {
+			failedAt[name] = at
+		}
+		if d.labels != nil {
+			nodeLabels[name] = d.labels(name)
+		}
+	}
+	sort.Strings(all)
+
+	found := make(map[string][]string) // by alertSubject
+	var covered []string
+	for _, key := range d.cfg.LabelKeys {
+		groups := make(map[string][]string)
+		for _, name := range all {
+			if v, ok := nodeLabels[name][key]; ok {
+				groups[v] = append(groups[v], name)
+			}
+		}
+		for value, members := range groups {
+			if nodes := d.correlated(members, failedAt); nodes != nil {
+				found[alertSubject(Alert{CommonLabel: key + "=" + value})] = nodes
+				covered = append(covered, nodes...)
+			}
+		}
+	}
+	if nodes := d.correlated(all, failedAt); nodes != nil {
+		if slices.ContainsFunc(nodes, func(n string) bool { return !slices.Contains(covered, n) }) {
+			found[alertSubject(Alert{})] = nodes
+		}
+	}
+
+	for subject, nodes := range found {
+		affected, ok := d.active[subject]
+		if !ok {
+			affected = make(map[string]bool)
+			d.active[subject] = affected
+		}
+		for _, n := range nodes {
+			affected[n] = true
+		}
+		firing = append(firing, d.alert(subject, affected, failedAt, now))
+	}
+	for subject, affected := range d.active {
+		if _, ok := found[subject]; ok {
+			continue
+		}
+		stillDown := false
+		for name := range affected {
+			if _, down := failedAt[name]; down {
+				stillDown = true
+				break
+			}
+		}
+		if stillDown {
+			firing = append(firing, d.alert(subject, affected, failedAt, now))
+			continue
+		}
+		delete(d.active, subject)
+		ended = append(ended, subject)
+	}
+	sort.Slice(firing, func(i, j int) bool { return firing[i].CommonLabel < firing[j].CommonLabel })
+	sort.Strings(ended)
+	return firing, ended
+}
+
+// correlated returns the largest set of members that failed within Window of
+// each other, sorted by name, if it makes an outage; otherwise nil.
+func (d *OutageDetector) correlated(members []string, failedAt map[string]time.Time) []string {
+	var failed []failedNode
+	for _, name := range members {
+		if at, ok := failedAt[name]; ok {
+			failed = append(failed, failedNode{name, at})
+		}
+	}
+	if len(failed) < d.cfg.MinNodes {
+		return nil
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].failedAt.Before(failed[j].failedAt) })
+
+	// Slide a window over the failure times and keep the fullest
+	start, bestStart, bestEnd := 0, 0, 0
+	for end := range failed {
+		for failed[end].failedAt.Sub(failed[start].failedAt) > d.cfg.Window {
+			start++
+		}
+		if end+1-start > bestEnd-bestStart {
+			bestStart, bestEnd = start, end+1
+		}
+	}
+	count := bestEnd - bestStart
+	population := len(members) - (len(failed) - count)
+	if count < d.cfg.MinNodes || float64(count) < d.cfg.Fraction*float64(population) {
+		return nil
+	}
+	nodes := make([]string, 0, count)
+	for _, f := range failed[bestStart:bestEnd] {
+		nodes = append(nodes, f.name)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// alert builds the alert for an outage in progress. Gap is the longest
+// heartbeat gap among the affected nodes still down.
+func (d *OutageDetector) alert(subject string, affected map[string]bool, failedAt map[string]time.Time, now time.Time) Alert {
+	var gap time.Duration
+	for name := range affected {
+		if _, down := failedAt[name]; down {
+			gap = max(gap, now.Sub(d.nodes[name].lastSeen))
+		}
+	}
+	a := Alert{
+		Severity:      "critical",
+		Gap:           gap.Seconds(),
+		Timestamp:     now,
+		Type:          alertTypeCorrelatedOutage,
+		AffectedNodes: slices.Sorted(maps.Keys(affected)),
+	}
+	if subject != clusterAlertSubject {
+		a.CommonLabel = subject
+	}
+	return a
+}
+
+// Sweep evaluates the detector at now and fires or resolves the outages
+// through m.
+func (d *OutageDetector) Sweep(m *AlertManager, now time.Time) {
+	firing, ended := d.Evaluate(now)
+	for _, a := range firing {
+		m.Fire(a, now)
+	}
+	for _, subject := range ended {
+		m.Resolve(subject, alertTypeCorrelatedOutage, now)
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled.
+func (d *OutageDetector) Run(ctx context.Context, m *AlertManager, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Sweep(m, now)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+const zoneLabel = "topology.kubernetes.io/zone"
+
+// outageFleet is a synthetic cluster whose nodes renew their leases every 10s,
+// each at its own offset, and can be stopped and restarted.
+type outageFleet struct {
+	d        *OutageDetector
+	now      time.Time
+	nodes    []string
+	labels   map[string]map[string]string
+	offsets  map[string]time.Duration
+	lastSeen map[string]time.Time
+	stopped  map[string]bool
+}
+
+// newOutageFleet creates count nodes per zone; nodes of zone "" are unlabelled.
+func newOutageFleet(zones map[string]int, now time.Time) *outageFleet {
+	f := &outageFleet{
+		now:      now,
+		labels:   make(map[string]map[string]string),
+		offsets:  make(map[string]time.Duration),
+		lastSeen: make(map[string]time.Time),
+		stopped:  make(map[string]bool),
+	}
+	for _, zone := range slices.Sorted(maps.Keys(zones)) {
+		for i := 1; i <= zones[zone]; i++ {
+			name := fmt.Sprintf("node-%02d", len(f.nodes)+1)
+			if zone != "" {
+				name = fmt.Sprintf("%s-%02d", zone, i)
+				f.labels[name] = map[string]string{zoneLabel: zone}
+			}
+			f.offsets[name] = time.Duration(len(f.nodes)%10) * time.Second
+			f.nodes = append(f.nodes, name)
+		}
+	}
+	f.d = NewOutageDetector(DefaultOutageConfig(), NewAnomalyDetector(NewMemoryStore(), 10, 40),
+		func(node string) map[string]string { return f.labels[node] })
+	return f
+}
+
+func (f *outageFleet) zone(zone string) []string {
+	var out []string
+	for _, n := range f.nodes {
+		if f.labels[n][zoneLabel] == zone {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (f *outageFleet) setStopped(nodes []string, stopped bool) {
+	for _, n := range nodes {
+		f.stopped[n] = stopped
+	}
+}
+
+// run advances the fleet second by second for d. Running nodes renew on
+// their schedule, reporting each heartbeat and the gap since the previous
+// one to renewed; sweep is called every 5s.
+func (f *outageFleet) run(d time.Duration, renewed func(hb Heartbeat, gap time.Duration), sweep func(now time.Time)) {
+	for end := f.now.Add(d); f.now.Before(end); {
+		f.now = f.now.Add(time.Second)
+		for _, n := range f.nodes {
+			if f.stopped[n] || f.now.Unix()%10 != int64(f.offsets[n]/time.Second) {
+				continue
+			}
+			hb := Heartbeat{NodeName: n, Namespace: "kube-node-lease", Timestamp: f.now, Status: "Ready"}
+			f.d.ObserveHeartbeat(hb)
+			if last, ok := f.lastSeen[n]; ok && renewed != nil {
+				renewed(hb, f.now.Sub(last))
+			}
+			f.lastSeen[n] = f.now
+		}
+		if f.now.Unix()%5 == 0 && sweep != nil {
+			sweep(f.now)
+		}
+	}
+}
+
+// evaluate runs the fleet for d and returns every alert and ended subject
+// the detector reported.
+func (f *outageFleet) evaluate(d time.Duration) (firing []Alert, ended []string) {
+	f.run(d, nil, func(now time.Time) {
+		fi, en := f.d.Evaluate(now)
+		firing = append(firing, fi...)
+		ended = append(ended, en...)
+	})
+	return firing, ended
+}
+
+var outageStart = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+// TestOutageDetectorZoneOutage stops a whole zone at once. The detector
+// reports one outage for the zone, not one for the cluster, although the
+// zone is half of it, and ends it when the zone comes back.
+func TestOutageDetectorZoneOutage(t *testing.T) {
+	f := newOutageFleet(map[string]int{"zone-a": 3, "zone-b": 6, "zone-c": 3}, outageStart)
+	if firing, _ := f.evaluate(5 * time.Minute); len(firing) != 0 {
+		t.Fatalf("healthy fleet alerted: %+v", firing)
+	}
+
+	zoneB := f.zone("zone-b")
+	f.setStopped(zoneB, true)
+	stoppedAt := f.now
+	firing, ended := f.evaluate(3 * time.Minute)
+	if len(firing) == 0 || len(ended) != 0 {
+		t.Fatalf("zone outage: firing %d, ended %v", len(firing), ended)
+	}
+	if first := firing[0].Timestamp.Sub(stoppedAt); first < 30*time.Second || first > time.Minute {
+		t.Errorf("outage first reported %v after the zone stopped", first)
+	}
+	for _, a := range firing {
+		if a.Type != alertTypeCorrelatedOutage || a.CommonLabel != zoneLabel+"=zone-b" || a.Severity != "critical" || a.NodeName != "" {
+			t.Fatalf("unexpected alert %+v", a)
+		}
+	}
+	last := firing[len(firing)-1]
+	if !slices.Equal(last.AffectedNodes, zoneB) || last.Gap < 170 {
+		t.Errorf("last alert covers %v with gap %.0fs, want %v for ~3m", last.AffectedNodes, last.Gap, zoneB)
+	}
+
+	f.setStopped(zoneB, false)
+	firing, ended = f.evaluate(time.Minute)
+	if !slices.Equal(ended, []string{zoneLabel + "=zone-b"}) {
+		t.Fatalf("ended = %v after the zone recovered", ended)
+	}
+	if n := len(firing); n == 0 || firing[n-1].Timestamp.After(f.now.Add(-45*time.Second)) {
+		t.Errorf("outage still reported after recovery: %+v", firing)
+	}
+}
+
+// TestOutageDetectorClusterWide stops most of an unlabelled cluster within a
+// minute.
+func TestOutageDetectorClusterWide(t *testing.T) {
+	f := newOutageFleet(map[string]int{"": 10}, outageStart)
+	f.evaluate(5 * time.Minute)
+
+	down := f.nodes[:6]
+	for _, n := range down {
+		f.setStopped([]string{n}, true)
+		f.evaluate(10 * time.Second)
+	}
+	firing, _ := f.evaluate(2 * time.Minute)
+	if len(firing) == 0 {
+		t.Fatal("cluster-wide outage not reported")
+	}
+	last := firing[len(firing)-1]
+	if last.CommonLabel != "" || !slices.Equal(last.AffectedNodes, down) || alertSubject(last) != clusterAlertSubject {
+		t.Errorf("alert = %+v, want cluster outage of %v", last, down)
+	}
+}
+
+// TestOutageDetectorIgnoresUncorrelatedFailures verifies nodes failing one by
+// one, minutes apart, are not an outage even once most of their zone is down.
+func TestOutageDetectorIgnoresUncorrelatedFailures(t *testing.T) {
+	f := newOutageFleet(map[string]int{"zone-a": 4, "zone-b": 4}, outageStart)
+	f.evaluate(5 * time.Minute)
+	for _, n := range f.zone("zone-a")[:3] {
+		f.setStopped([]string{n}, true)
+		if firing, _ := f.evaluate(5 * time.Minute); len(firing) != 0 {
+			t.Fatalf("after %s stopped: %+v", n, firing)
+		}
+	}
+}
+
+// TestOutageDetectorForgetsDeletedNodes scales half of a zone down. Nodes
+// deleted as they stop are no outage; nodes deleted after the detector
+// already reported them end it.
+func TestOutageDetectorForgetsDeletedNodes(t *testing.T) {
+	f := newOutageFleet(map[string]int{"zone-a": 3, "zone-b": 6, "zone-c": 3}, outageStart)
+	f.evaluate(5 * time.Minute)
+
+	removed := f.zone("zone-b")[:3]
+	f.setStopped(removed, true)
+	for _, n := range removed {
+		f.d.Forget(n)
+	}
+	if firing, _ := f.evaluate(5 * time.Minute); len(firing) != 0 {
+		t.Fatalf("scale-down reported as an outage: %+v", firing)
+	}
+
+	// Deleted only after the outage was reported
+	drained := f.zone("zone-b")[3:]
+	f.setStopped(drained, true)
+	if firing, _ := f.evaluate(3 * time.Minute); len(firing) == 0 {
+		t.Fatal("zone outage not reported")
+	}
+	for _, n := range drained {
+		f.d.Forget(n)
+	}
+	firing, ended := f.evaluate(time.Minute)
+	if !slices.Equal(ended, []string{zoneLabel + "=zone-b"}) {
+		t.Fatalf("ended = %v after the nodes were deleted", ended)
+	}
+	if len(firing) > 1 {
+		t.Errorf("outage still reported after deletion: %+v", firing)
+	}
+}
+
+// TestOutageInhibitsPerNodeAlerts drives the AlertManager the way ingest and
+// the sweeps do. A zone going down notifies once, as a correlated outage;
+// the per-node gap alerts the sweep raises while its nodes are down stay
+// listed but are inhibited for good.
+func TestOutageInhibitsPerNodeAlerts(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	f := newOutageFleet(map[string]int{"zone-a": 4, "zone-b": 4, "zone-c": 4}, outageStart)
+	renewed := func(hb Heartbeat, _ time.Duration) {
+		m.Resolve(hb.NodeName, alertTypeHeartbeatGap, hb.Timestamp)
+	}
+	sweep := func(now time.Time) {
+		for _, n := range f.nodes {
+			last, ok := f.lastSeen[n]
+			if gap := now.Sub(last); ok && gap > 40*time.Second {
+				a := gapAlert(n, "critical", gap.Seconds(), now)
+				a.StartsAt = last.Add(10 * time.Second)
+				m.Fire(a, now)
+			}
+		}
+		f.d.Sweep(m, now)
+		m.Flush(now)
+	}
+
+	f.run(5*time.Minute, renewed, sweep)
+	zoneB := f.zone("zone-b")
+	f.setStopped(zoneB, true)
+	f.run(3*time.Minute, renewed, sweep)
+	f.setStopped(zoneB, false)
+	f.run(20*time.Second, renewed, sweep)
+
+	list := m.Alerts()
+	var outage Alert
+	var inhibited []string
+	for _, a := range list.Active {
+		switch {
+		case a.Type == alertTypeCorrelatedOutage:
+			outage = a
+		case a.InhibitedBy != "":
+			inhibited = append(inhibited, a.NodeName)
+		}
+	}
+	sort.Strings(inhibited)
+	if outage.Fingerprint == "" || !slices.Equal(inhibited, zoneB) || len(list.Active) != len(zoneB)+1 {
+		t.Fatalf("Active = %+v, want the outage and inhibited alerts for %v", list.Active, zoneB)
+	}
+	for _, a := range list.Active {
+		if a.Type == alertTypeHeartbeatGap && a.InhibitedBy != outage.Fingerprint {
+			t.Errorf("%s InhibitedBy = %q, want %q", a.NodeName, a.InhibitedBy, outage.Fingerprint)
+		}
+	}
+
+	f.run(10*time.Minute, renewed, sweep)
+	got := rec.take()
+	if len(got) != 2 {
+		t.Fatalf("notified %+v, want the outage firing and resolved", got)
+	}
+	for i, status := range []string{alertStatusFiring, alertStatusResolved} {
+		if got[i].Type != alertTypeCorrelatedOutage || got[i].Status != status || !slices.Equal(got[i].AffectedNodes, zoneB) {
+			t.Errorf("notification %d = %+v, want %s outage of %v", i, got[i], status, zoneB)
+		}
+	}
+	if list := m.Alerts(); len(list.Active) != 0 {
+		t.Errorf("still active: %+v", list.Active)
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// defaultPostgresRetention is how long PostgresStore keeps time-series data
+// by default.
+const defaultPostgresRetention = 30 * 24 * time.Hour
+
+// postgresCompactInterval is how often PostgresStore deletes data older than
+// its retention when TimescaleDB is not doing it.
+const postgresCompactInterval = 10 * time.Minute
+
+// postgresMigrationLock is the advisory lock held while migrating, so that
+// replicas starting together apply each migration once.
+const postgresMigrationLock = 0x6561727468776f72 // "earthwor"
+
+// postgresMigrations are the schema versions of a PostgreSQL store, in
+// order. Version N is postgresMigrations[N-1]; released migrations must never
+// change. Time-series tables have no primary key so that TimescaleDB can
+// turn them into hypertables; id only orders rows with equal timestamps.
+var postgresMigrations = []string{
+	`CREATE TABLE heartbeats (
+		id                     BIGSERIAL   NOT NULL,
+		ts                     TIMESTAMPTZ NOT NULL,
+		node_name              TEXT        NOT NULL,
+		namespace              TEXT        NOT NULL,
+		status                 TEXT        NOT NULL,
+		ebpf_pid               BIGINT      NOT NULL,
+		ebpf_comm              TEXT        NOT NULL,
+		holder_identity        TEXT        NOT NULL,
+		lease_duration_seconds INTEGER     NOT NULL
+	);
+	CREATE INDEX heartbeats_ts ON heartbeats (ts);
+	CREATE INDEX heartbeats_node_ts ON heartbeats (node_name, ts DESC);
+
+	CREATE TABLE kernel_events (
+		id             BIGSERIAL   NOT NULL,
+		ts             TIMESTAMPTZ NOT NULL,
+		node_name      TEXT        NOT NULL,
+		event_type     TEXT        NOT NULL,
+		pid            BIGINT      NOT NULL,
+		ppid           BIGINT      NOT NULL,
+		comm           TEXT        NOT NULL,
+		cgroup_id      NUMERIC(20) NOT NULL,
+		namespace      TEXT        NOT NULL,
+		pod_name       TEXT        NOT NULL,
+		container_name TEXT        NOT NULL,
+		host_level     BOOLEAN     NOT NULL,
+		attrs          JSONB       NOT NULL
+	);
+	CREATE INDEX kernel_events_ts ON kernel_events (ts);
+	CREATE INDEX kernel_events_node_ts ON kernel_events (node_name, ts);
+	CREATE INDEX kernel_events_node_type_ts ON kernel_events (node_name, event_type, ts);
+
+	CREATE TABLE causal_chains (
+		id         BIGSERIAL   NOT NULL,
+		ts         TIMESTAMPTZ NOT NULL,
+		node_name  TEXT        NOT NULL,
+		summary    TEXT        NOT NULL,
+		root_cause TEXT        NOT NULL,
+		events     JSONB       NOT NULL
+	);
+	CREATE INDEX causal_chains_ts ON causal_chains (ts);
+	CREATE INDEX causal_chains_node_ts ON causal_chains (node_name, ts);
+
+	CREATE TABLE silences (
+		id   TEXT  PRIMARY KEY,
+		data JSONB NOT NULL
+	);`,
+}
+
+// postgresTimeSeriesTables are the tables subject to retention.
+var postgresTimeSeriesTables = []string{"heartbeats", "kernel_events", "causal_chains"}
+
+// kernelEventColumns are the EnrichedEvent fields PostgresStore keeps in
+// typed columns, by JSON name; every other field goes in attrs.
+var kernelEventColumns = []string{
+	"timestamp", "nodeName", "eventType", "pid", "ppid", "comm", "cgroupId",
+	"namespace", "podName", "containerName", "hostLevel",
+}
+
+// PostgresStore implements the Store interface in PostgreSQL, optionally
+// with TimescaleDB hypertables. Timestamps are kept to the microsecond.
+// Heartbeats, kernel events and causal chains older than the retention are
+// deleted, by TimescaleDB's retention policy when it is used and otherwise in
+// the background; silences are kept.
+type PostgresStore struct {
+	db        *sql.DB
+	retention time.Duration
+	timescale bool
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewPostgresStore connects to the database at dsn, brings its schema up to
+// date and sets up retention. With timescale, the time-series tables become
+// TimescaleDB hypertables and retention is left to TimescaleDB.
+func NewPostgresStore(dsn string, retention time.Duration, timescale bool) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	s := &PostgresStore{
+		db:        db,
+		retention: retention,
+		timescale: timescale,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	ctx := context.Background()
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate postgres: %w", err)
+	}
+	if timescale {
+		if err := s.setupTimescale(ctx); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("set up timescaledb: %w", err)
+		}
+		close(s.done)
+		return s, nil
+	}
+	if err := s.Compact(ctx, time.Now()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("compact postgres: %w", err)
+	}
+	go compactEvery(postgresCompactInterval, s.Compact, s.stop, s.done)
+	return s, nil
+}
+
+// migrate applies the migrations the database has not seen yet in one
+// transaction, under an advisory lock.
+func (s *PostgresStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER     PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(postgresMigrations) {
+		return fmt.Errorf("schema version %d is newer than this server's %d", version, len(postgresMigrations))
+	}
+	for v := version + 1; v <= len(postgresMigrations); v++ {
+		if _, err := tx.ExecContext(ctx, postgresMigrations[v-1]); err != nil {
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES ($1, now())`, v); err != nil {
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// setupTimescale turns the time-series tables into hypertables, migrating
+// any rows they already hold, and sets their retention policies.
+func (s *PostgresStore) setupTimescale(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+		return err
+	}
+	retention := fmt.Sprintf("%d seconds", int64(s.retention/time.Second))
+	for _, table := range postgresTimeSeriesTables {
+		if _, err := s.db.ExecContext(ctx, `SELECT create_hypertable($1::regclass, 'ts', if_not_exists => TRUE, migrate_data => TRUE)`, table); err != nil {
+			return fmt.Errorf("hypertable %s: %w", table, err)
+		}
+		if _, err := s.db.ExecContext(ctx, `SELECT remove_retention_policy($1::regclass, if_exists => TRUE)`, table); err != nil {
+			return fmt.Errorf("retention policy %s: %w", table, err)
+		}
+		if _, err := s.db.ExecContext(ctx, `SELECT add_retention_policy($1::regclass, $2::interval)`, table, retention); err != nil {
+			return fmt.Errorf("retention policy %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version of the database.
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Compact deletes heartbeats, kernel events and causal chains older than
+// the retention at now.
+func (s *PostgresStore) Compact(ctx context.Context, now time.Time) error {
+	cutoff := now.Add(-s.retention)
+	for _, table := range postgresTimeSeriesTables {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE ts < $1`, cutoff); err != nil {
+			return fmt.Errorf("compact %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// Close stops compaction and closes the connection pool.
+func (s *PostgresStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return s.db.Close()
+}
+
+func (s *PostgresStore) Save(ctx context.Context, event Heartbeat) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO heartbeats
+		(ts, node_name, namespace, status, ebpf_pid, ebpf_comm, holder_identity, lease_duration_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		event.Timestamp, event.NodeName, event.Namespace, event.Status, int64(event.EbpfPID), event.EbpfComm,
+		event.HolderIdentity, event.LeaseDurationSeconds)
+	return err
+}
+
+const heartbeatSelect = `SELECT ts, node_name, namespace, status, ebpf_pid, ebpf_comm, holder_identity, lease_duration_seconds FROM heartbeats`
+
+func (s *PostgresStore) GetByTimeRange(ctx context.Context, from, to time.Time) ([]Heartbeat, error) {
+	return s.queryHeartbeats(ctx, heartbeatSelect+` WHERE ts BETWEEN $1 AND $2 ORDER BY ts, id`, from, to)
+}
+
+func (s *PostgresStore) GetLatestByNode(ctx context.Context, nodeName string) (*Heartbeat, error) {
+	hbs, err := s.queryHeartbeats(ctx, heartbeatSelect+` WHERE node_name = $1 ORDER BY ts DESC, id DESC LIMIT 1`, nodeName)
+	if err != nil || len(hbs) == 0 {
+		return nil, err
+	}
+	return &hbs[0], nil
+}
+
+// QueryHeartbeats orders node names byte by byte, as cursors compare them,
+// whatever the database's collation.
+func (s *PostgresStore) QueryHeartbeats(ctx context.Context, q HeartbeatQuery) (HeartbeatPage, error) {
+	c, err := q.cursor()
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	from, to := q.bounds(c)
+	where, args := sqlHeartbeatFilter(q, from, to, nil, postgresPlaceholder)
+	if c != nil {
+		// ts is at least the cursor's already
+		args = append(args, time.Unix(0, c.Timestamp), c.NodeName)
+		where += fmt.Sprintf(` AND (ts > $%d OR node_name COLLATE "C" >= $%d)`, len(args)-1, len(args))
+	}
+	args = append(args, q.need(c))
+	hbs, err := s.queryHeartbeats(ctx, heartbeatSelect+` WHERE `+where+
+		fmt.Sprintf(` ORDER BY ts, node_name COLLATE "C", id LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	return pageHeartbeats(hbs, q, c), nil
+}
+
+// DownsampleHeartbeats buckets heartbeats in SQL. Gaps are computed over
+// the filtered heartbeats, before grouping, so a gap that spans buckets
+// counts in the later one.
+func (s *PostgresStore) DownsampleHeartbeats(ctx context.Context, q HeartbeatQuery, step time.Duration) ([]HeartbeatBucket, error) {
+	from, to := q.bounds(nil)
+	where, args := sqlHeartbeatFilter(q, from, to, []any{step.Microseconds()}, postgresPlaceholder)
+	rows, err := s.db.QueryContext(ctx, `SELECT node_name, bucket * $1, COUNT(*), MIN(ts), MAX(ts), MIN(gap), MAX(gap)
+		FROM (
+			SELECT node_name, ts,
+				floor(EXTRACT(EPOCH FROM ts) * 1000000 / $1)::bigint AS bucket,
+				EXTRACT(EPOCH FROM ts - LAG(ts) OVER (PARTITION BY node_name ORDER BY ts, id))::float8 AS gap
+			FROM heartbeats WHERE `+where+`
+		) gaps
+		GROUP BY node_name, bucket ORDER BY bucket, node_name COLLATE "C"`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []HeartbeatBucket{}
+	for rows.Next() {
+		var b HeartbeatBucket
+		var start int64
+		var minGap, maxGap sql.NullFloat64
+		if err := rows.Scan(&b.NodeName, &start, &b.Count, &b.First, &b.Last, &minGap, &maxGap); err != nil {
+			return nil, err
+		}
+		b.Start = time.UnixMicro(start).UTC()
+		b.MinGapSeconds, b.MaxGapSeconds = minGap.Float64, maxGap.Float64
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+func postgresPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (s *PostgresStore) queryHeartbeats(ctx context.Context, query string, args ...any) ([]Heartbeat, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []Heartbeat
+	for rows.Next() {
+		var hb Heartbeat
+		var pid int64
+		if err := rows.Scan(&hb.Timestamp, &hb.NodeName, &hb.Namespace, &hb.Status, &pid, &hb.EbpfComm,
+			&hb.HolderIdentity, &hb.LeaseDurationSeconds); err != nil {
+			return nil, err
+		}
+		hb.EbpfPID = uint32(pid)
+		result = append(result, hb)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStore) Nodes(ctx context.Context) ([]string, error) {
+	return queryNodeNames(ctx, s.db, sqlNodesQuery+` ORDER BY node_name COLLATE "C"`)
+}
+
+func (s *PostgresStore) HasNode(ctx context.Context, nodeName string) (bool, error) {
+	var ok bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM heartbeats WHERE node_name = $1)
+		OR EXISTS (SELECT 1 FROM kernel_events WHERE node_name = $1)`, nodeName).Scan(&ok)
+	return ok, err
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *PostgresStore) SaveKernelEvent(ctx context.Context, event EnrichedEvent) error {
+	attrs, err := kernelEventAttrs(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO kernel_events
+		(ts, node_name, event_type, pid, ppid, comm, cgroup_id, namespace, pod_name, container_name, host_level, attrs)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		event.Timestamp, event.NodeName, event.EventType, int64(event.PID), int64(event.PPID), event.Comm,
+		strconv.FormatUint(event.CgroupID, 10), event.Namespace, event.PodName, event.ContainerName, event.HostLevel, attrs)
+	return err
+}
+
+// kernelEventAttrs returns the JSON of the event's fields that have no
+// column of their own.
+func kernelEventAttrs(event EnrichedEvent) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("marshal kernel event: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("marshal kernel event: %w", err)
+	}
+	for _, name := range kernelEventColumns {
+		delete(fields, name)
+	}
+	data, err = json.Marshal(fields)
+	return string(data), err
+}
+
+const kernelEventSelect = `SELECT ts, node_name, event_type, pid, ppid, comm, cgroup_id::TEXT, namespace, pod_name, container_name, host_level, attrs FROM kernel_events`
+
+func (s *PostgresStore) GetKernelEvents(ctx context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error) {
+	return s.queryKernelEvents(ctx, kernelEventSelect+` WHERE node_name = $1 AND ts BETWEEN $2 AND $3 ORDER BY ts, id`,
+		nodeName, from, to)
+}
+
+func (s *PostgresStore) GetKernelEventsByType(ctx context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error) {
+	return s.queryKernelEvents(ctx, kernelEventSelect+` WHERE node_name = $1 AND event_type = $2 AND ts BETWEEN $3 AND $4 ORDER BY ts, id`,
+		nodeName, eventType, from, to)
+}
+
+func (s *PostgresStore) CountKernelEvents(ctx context.Context, nodeName string, from, to time.Time) (map[string]map[string]int, error) {
+	query, args := `SELECT node_name, event_type, COUNT(*) FROM kernel_events WHERE ts BETWEEN $1 AND $2`, []any{from, to}
+	if nodeName != "" {
+		query, args = query+` AND node_name = $3`, append(args, nodeName)
+	}
+	return queryKernelEventCounts(ctx, s.db, query+` GROUP BY node_name, event_type`, args...)
+}
+
+func (s *PostgresStore) queryKernelEvents(ctx context.Context, query string, args ...any) ([]EnrichedEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []EnrichedEvent
+	for rows.Next() {
+		var e EnrichedEvent
+		var ts time.Time
+		var nodeName, eventType, comm, cgroupID, namespace, podName, containerName string
+		var pid, ppid int64
+		var hostLevel bool
+		var attrs []byte
+		if err := rows.Scan(&ts, &nodeName, &eventType, &pid, &ppid, &comm, &cgroupID, &namespace, &podName,
+			&containerName, &hostLevel, &attrs); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(attrs, &e); err != nil {
+			return nil, fmt.Errorf("decode kernel event: %w", err)
+		}
+		e.Timestamp, e.NodeName, e.EventType, e.Comm = ts, nodeName, eventType, comm
+		e.PID, e.PPID = uint32(pid), uint32(ppid)
+		if e.CgroupID, err = strconv.ParseUint(cgroupID, 10, 64); err != nil {
+			return nil, fmt.Errorf("decode kernel event: %w", err)
+		}
+		e.Namespace, e.PodName, e.ContainerName, e.HostLevel = namespace, podName, containerName, hostLevel
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStore) SaveCausalChain(ctx context.Context, chain CausalChain) error {
+	events, err := json.Marshal(chain.Events)
+	if err != nil {
+		return fmt.Errorf("marshal causal chain: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO causal_chains (ts, node_name, summary, root_cause, events)
+		VALUES ($1, $2, $3, $4, $5)`,
+		chain.Timestamp, chain.NodeName, chain.Summary, chain.RootCause, string(events))
+	return err
+}
+
+func (s *PostgresStore) GetCausalChains(ctx context.Context, nodeName string, from, to time.Time) ([]CausalChain, error) {
+	return s.queryCausalChains(ctx, causalChainSelect+` WHERE node_name = $1 AND ts BETWEEN $2 AND $3 ORDER BY ts, id`,
+		nodeName, from, to)
+}
+
+func (s *PostgresStore) GetLatestCausalChain(ctx context.Context, nodeName string) (*CausalChain, error) {
+	chains, err := s.queryCausalChains(ctx, causalChainSelect+` WHERE node_name = $1 ORDER BY ts DESC, id DESC LIMIT 1`, nodeName)
+	if err != nil || len(chains) == 0 {
+		return nil, err
+	}
+	return &chains[0], nil
+}
+
+const causalChainSelect = `SELECT ts, node_name, summary, root_cause, events FROM causal_chains`
+
+// queryCausalChains runs a query selecting causalChainSelect's columns.
+func (s *PostgresStore) queryCausalChains(ctx context.Context, query string, args ...any) ([]CausalChain, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []CausalChain
+	for rows.Next() {
+		var c CausalChain
+		var events []byte
+		if err := rows.Scan(&c.Timestamp, &c.NodeName, &c.Summary, &c.RootCause, &events); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(events, &c.Events); err != nil {
+			return nil, fmt.Errorf("decode causal chain: %w", err)
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStore) SaveSilence(ctx context.Context, silence Silence) error {
+	data, err := json.Marshal(silence)
+	if err != nil {
+		return fmt.Errorf("marshal silence: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO silences (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, silence.ID, string(data))
+	return err
+}
+
+func (s *PostgresStore) GetSilences(ctx context.Context) ([]Silence, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM silences`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []Silence{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var silence Silence
+		if err := json.Unmarshal(data, &silence); err != nil {
+			return nil, fmt.Errorf("decode silence: %w", err)
+		}
+		result = append(result, silence)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStore) DeleteSilence(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM silences WHERE id = $1`, id)
+	return err
+}
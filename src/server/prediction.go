@@ -228,6 +228,28 @@ func (pe *PredictionEngine) RecordOutcome(nodeName string, predictionTime time.T
 	}
 }
 
+// Predictions returns the predictions made so far, oldest first.
+func (pe *PredictionEngine) Predictions() []Prediction {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	out := make([]Prediction, len(pe.predictions))
+	copy(out, pe.predictions)
+	return out
+}
+
+// Latest returns the node's most recent prediction, or nil if none was made.
+func (pe *PredictionEngine) Latest(nodeName string) *Prediction {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	for i := len(pe.predictions) - 1; i >= 0; i-- {
+		if pe.predictions[i].NodeName == nodeName {
+			p := pe.predictions[i]
+			return &p
+		}
+	}
+	return nil
+}
+
 // Accuracy computes prediction accuracy metrics from recorded outcomes.
 func (pe *PredictionEngine) Accuracy() AccuracyMetrics {
 	pe.mu.Lock()
@@ -303,15 +303,8 @@ func TestProperty8_AnomalyDetectionSeverity(t *testing.T) {
 
 		det := NewAnomalyDetector(memStore, warningS, criticalS)
 
-		// Create incoming event with the specified gap
-		incoming := Heartbeat{
-			NodeName:  nodeName,
-			Namespace: namespace,
-			Timestamp: baseTime.Add(time.Duration(gapS) * time.Second),
-			Status:    "Ready",
-		}
-
-		alert := det.Evaluate(incoming)
+		// The gap is still open gapS seconds after the baseline
+		alert := det.OpenGap(nodeName, baseTime.Add(time.Duration(gapS)*time.Second))
 
 		warningThreshold := time.Duration(warningS) * time.Second
 		criticalThreshold := time.Duration(criticalS) * time.Second
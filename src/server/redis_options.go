@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisOptions configure how a RedisStore connects. Addrs is one address,
+// the Sentinel addresses when MasterName is set, or the seed nodes of a
+// cluster when Cluster is set.
+//
+// A redis:// or rediss:// URL replaces Addrs, DB and TLS; its username and
+// password are used unless Username, Password or PasswordFile are set. A URL
+// names a single server, so it cannot be combined with Sentinel or cluster
+// mode. Zero pool sizes and timeouts keep the go-redis defaults.
+type RedisOptions struct {
+	URL          string
+	Addrs        []string
+	MasterName   string
+	Cluster      bool
+	DB           int
+	Username     string
+	Password     string
+	PasswordFile string // read on connect, with trailing newlines removed
+
+	TLS         bool // implied by any of the files below
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Trimming, see RedisStore
+	TTL    time.Duration
+	MaxLen int
+}
+
+// RedisOptions returns the Redis connection and trimming configuration.
+func (c Config) RedisOptions() RedisOptions {
+	opts := RedisOptions{
+		URL:          c.RedisURL,
+		Addrs:        strings.Split(c.RedisAddr, ","),
+		MasterName:   c.RedisMasterName,
+		DB:           c.RedisDB,
+		Username:     c.RedisUsername,
+		Password:     c.RedisPassword,
+		PasswordFile: c.RedisPasswordFile,
+		TLS:          c.RedisTLS,
+		TLSCAFile:    c.RedisTLSCAFile,
+		TLSCertFile:  c.RedisTLSCertFile,
+		TLSKeyFile:   c.RedisTLSKeyFile,
+		PoolSize:     c.RedisPoolSize,
+		MinIdleConns: c.RedisMinIdleConns,
+		DialTimeout:  time.Duration(c.RedisDialTimeoutS) * time.Second,
+		ReadTimeout:  time.Duration(c.RedisReadTimeoutS) * time.Second,
+		WriteTimeout: time.Duration(c.RedisWriteTimeoutS) * time.Second,
+		TTL:          time.Duration(c.RedisTTLS) * time.Second,
+		MaxLen:       c.RedisMaxLen,
+	}
+	if len(c.RedisClusterAddrs) > 0 {
+		opts.Addrs = c.RedisClusterAddrs
+		opts.Cluster = true
+	}
+	return opts
+}
+
+// target describes the server or servers the options connect to, for logs
+// and errors. It never includes credentials.
+func (o RedisOptions) target() string {
+	addrs := strings.Join(o.Addrs, ",")
+	switch {
+	case o.Cluster:
+		return "cluster " + addrs
+	case o.MasterName != "":
+		return fmt.Sprintf("master %s via sentinels %s", o.MasterName, addrs)
+	}
+	return addrs
+}
+
+// newRedisClient builds a client for the options: a cluster client, a
+// Sentinel failover client or a single-server client. It does not connect.
+func newRedisClient(opts RedisOptions) (redis.UniversalClient, RedisOptions, error) {
+	if opts.URL != "" {
+		if opts.Cluster || opts.MasterName != "" {
+			return nil, opts, errors.New("a URL names a single server and cannot be used with Sentinel or cluster mode")
+		}
+		u, err := redis.ParseURL(opts.URL)
+		if err != nil {
+			// The URL may hold a password, so it is left out
+			return nil, opts, fmt.Errorf("parse URL: %w", err)
+		}
+		opts.Addrs = []string{u.Addr}
+		opts.DB = u.DB
+		opts.TLS = opts.TLS || u.TLSConfig != nil
+		if opts.Username == "" {
+			opts.Username = u.Username
+		}
+		if opts.Password == "" && opts.PasswordFile == "" {
+			opts.Password = u.Password
+		}
+	}
+	switch {
+	case len(opts.Addrs) == 0 || opts.Addrs[0] == "":
+		return nil, opts, errors.New("no address")
+	case opts.Cluster && opts.MasterName != "":
+		return nil, opts, errors.New("Sentinel and cluster mode are exclusive")
+	case opts.Cluster && opts.DB != 0:
+		return nil, opts, fmt.Errorf("a cluster has no database %d, only 0", opts.DB)
+	case !opts.Cluster && opts.MasterName == "" && len(opts.Addrs) > 1:
+		return nil, opts, fmt.Errorf("%d addresses, but neither a Sentinel master name nor cluster mode", len(opts.Addrs))
+	}
+	if opts.PasswordFile != "" {
+		data, err := os.ReadFile(opts.PasswordFile)
+		if err != nil {
+			return nil, opts, fmt.Errorf("read password: %w", err)
+		}
+		opts.Password = strings.TrimRight(string(data), "\r\n")
+	}
+
+	universal := &redis.UniversalOptions{
+		Addrs:        opts.Addrs,
+		DB:           opts.DB,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		MasterName:   opts.MasterName,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+	}
+	opts.TLS = opts.TLS || opts.TLSCAFile != "" || opts.TLSCertFile != "" || opts.TLSKeyFile != ""
+	if opts.TLS {
+		tlsConfig, err := opts.tlsConfig()
+		if err != nil {
+			return nil, opts, err
+		}
+		universal.Dialer = tlsDialer(tlsConfig, opts.DialTimeout)
+	}
+	switch {
+	case opts.Cluster:
+		return redis.NewClusterClient(universal.Cluster()), opts, nil
+	case opts.MasterName != "":
+		return redis.NewFailoverClient(universal.Failover()), opts, nil
+	}
+	return redis.NewClient(universal.Simple()), opts, nil
+}
+
+func (o RedisOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.TLSCAFile != "" {
+		pem, err := os.ReadFile(o.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read TLS CA: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in %s", o.TLSCAFile)
+		}
+	}
+	if o.TLSCertFile != "" || o.TLSKeyFile != "" {
+		if o.TLSCertFile == "" || o.TLSKeyFile == "" {
+			return nil, errors.New("a TLS client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.TLSCertFile, o.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// tlsDialer dials TLS connections verified against the host dialed, so
+// cluster nodes and Sentinels that the client discovers are verified too.
+func tlsDialer(config *tls.Config, timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if timeout == 0 {
+		timeout = 5 * time.Second // the go-redis default
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c := config.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			c.ServerName = host
+		}
+		dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: timeout, KeepAlive: 5 * time.Minute}, Config: c}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// explainRedisError says what a failed connection most likely means, for a
+// client that uses TLS or not.
+func explainRedisError(err error, usesTLS bool) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		recordHeader     tls.RecordHeaderError
+		netErr           net.Error
+		opErr            *net.OpError
+	)
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "NOAUTH"), strings.HasPrefix(msg, "WRONGPASS"),
+		strings.Contains(msg, "invalid password"), strings.Contains(msg, "invalid username-password pair"):
+		return "authentication failed"
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalid):
+		return "the server's TLS certificate was not accepted"
+	case errors.As(err, &recordHeader):
+		return "the server does not speak TLS"
+	case (errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)) && usesTLS:
+		return "the server closed the connection; it may not speak TLS"
+	case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+		return "the server closed the connection; it may require TLS"
+	case strings.Contains(msg, "cluster support disabled"):
+		return "the server is not a cluster"
+	case strings.Contains(msg, "all sentinels") || strings.Contains(msg, "No such master"):
+		return "no Sentinel knows the master"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "cannot connect"
+	}
+	return "unavailable"
+}
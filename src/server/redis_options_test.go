@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestTLSServerConfig returns a TLS config for a server at 127.0.0.1 and
+// the path of the PEM file of the CA that signed its certificate.
+func newTestTLSServerConfig(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	caKey, serverKey := newKey(), newKey()
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "earthworm test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "redis"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &serverKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}}}, caFile
+}
+
+func TestConfigRedisOptions(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.RedisAddr = "sentinel-0:26379,sentinel-1:26379"
+	cfg.RedisMasterName = "earthworm"
+	cfg.RedisReadTimeoutS = 2
+	opts := cfg.RedisOptions()
+	if len(opts.Addrs) != 2 || opts.Cluster || opts.ReadTimeout != 2*time.Second || opts.TTL != defaultTTL {
+		t.Errorf("RedisOptions = %+v, want two Sentinels, a 2s read timeout and the default TTL", opts)
+	}
+	if target := opts.target(); target != "master earthworm via sentinels sentinel-0:26379,sentinel-1:26379" {
+		t.Errorf("target = %q", target)
+	}
+
+	cfg.RedisMasterName = ""
+	cfg.RedisClusterAddrs = []string{"redis-0:6379", "redis-1:6379"}
+	if opts = cfg.RedisOptions(); !opts.Cluster || opts.Addrs[0] != "redis-0:6379" || opts.target() != "cluster redis-0:6379,redis-1:6379" {
+		t.Errorf("RedisOptions = %+v, want the cluster seeds", opts)
+	}
+}
+
+func TestNewRedisClient_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	for _, tc := range []struct {
+		name string
+		opts RedisOptions
+		want string
+	}{
+		{"no address", RedisOptions{}, "no address"},
+		{"URL in cluster mode", RedisOptions{URL: "redis://redis:6379", Cluster: true}, "single server"},
+		{"bad URL", RedisOptions{URL: "http://redis:6379"}, "parse URL"},
+		{"Sentinel cluster", RedisOptions{Addrs: []string{"a:1"}, MasterName: "m", Cluster: true}, "exclusive"},
+		{"cluster database", RedisOptions{Addrs: []string{"a:1"}, Cluster: true, DB: 2}, "database 2"},
+		{"several servers", RedisOptions{Addrs: []string{"a:1", "b:1"}}, "2 addresses"},
+		{"missing password file", RedisOptions{Addrs: []string{"a:1"}, PasswordFile: filepath.Join(dir, "missing")}, "read password"},
+		{"CA without certificates", RedisOptions{Addrs: []string{"a:1"}, TLSCAFile: notPEM}, "no PEM certificates"},
+		{"certificate without key", RedisOptions{Addrs: []string{"a:1"}, TLSCertFile: notPEM}, "both"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := newRedisClient(tc.opts)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRedisStore_Auth(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireUserAuth("earthworm", "s3cret")
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600)
+
+	for _, opts := range []RedisOptions{
+		{URL: "redis://earthworm:s3cret@" + mr.Addr() + "/0"},
+		{Addrs: []string{mr.Addr()}, Username: "earthworm", PasswordFile: passwordFile},
+		{URL: "redis://earthworm:wrong@" + mr.Addr(), PasswordFile: passwordFile},
+	} {
+		s, err := NewRedisStore(opts)
+		if err != nil {
+			t.Errorf("NewRedisStore: %v", err)
+			continue
+		}
+		s.Close()
+	}
+
+	_, err := NewRedisStore(RedisOptions{URL: "redis://earthworm:wrong@" + mr.Addr()})
+	if err == nil || !strings.Contains(err.Error(), "authentication failed") || strings.Contains(err.Error(), "wrong") {
+		t.Errorf("err = %v, want an authentication failure without the password", err)
+	}
+}
+
+func TestRedisStore_TLS(t *testing.T) {
+	serverConfig, caFile := newTestTLSServerConfig(t)
+	mr := miniredis.NewMiniRedis()
+	if err := mr.StartTLS(serverConfig); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+
+	s, err := NewRedisStore(RedisOptions{Addrs: []string{mr.Addr()}, TLSCAFile: caFile, TTL: defaultTTL})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	defer s.Close()
+	if err := s.Save(context.Background(), Heartbeat{NodeName: "node-a", Timestamp: time.Now()}); err != nil {
+		t.Errorf("Save over TLS: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts RedisOptions
+		want string
+	}{
+		{"unknown CA", RedisOptions{Addrs: []string{mr.Addr()}, TLS: true}, "certificate was not accepted"},
+		{"plain to TLS", RedisOptions{Addrs: []string{mr.Addr()}, ReadTimeout: time.Second}, "may require TLS"},
+		{"TLS to plain", RedisOptions{Addrs: []string{miniredis.RunT(t).Addr()}, TLSCAFile: caFile}, "not speak TLS"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewRedisStore(tc.opts)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRedisStore_Unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	_, err = NewRedisStore(RedisOptions{Addrs: []string{addr}})
+	if err == nil || !strings.Contains(err.Error(), "redis "+addr+": cannot connect") {
+		t.Errorf("err = %v, want one naming the address it cannot connect to", err)
+	}
+}
@@ -2,8 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -11,26 +19,99 @@ import (
 
 const defaultTTL = 7 * 24 * time.Hour // 7 days
 
-// RedisStore implements the Store interface using Redis sorted sets.
+// redisSchemaVersion is the key layout RedisStore reads and writes. Version
+// 1 was one sorted set per node and record type, members were bare JSON, and
+// range reads SCANned every heartbeat key.
+const redisSchemaVersion = 2
+
+// Key layout, version 2. Every key of a node carries the node name as a hash
+// tag, so in a cluster one node's keys share a slot:
+//
+//	earthworm:nodes                    sorted set of node names, scored by newest heartbeat
+//	earthworm:ke_nodes                 sorted set of node names, scored by newest kernel event
+//	earthworm:hb:{node}                heartbeats, scored by Unix milliseconds
+//	earthworm:ke:{node}:<type>         kernel events of one type
+//	earthworm:ke_types:{node}          set of the node's kernel event types
+//	earthworm:cc:{node}                causal chains
+//	earthworm:schema                   layout version
+//	silences                           hash of silence ID to JSON, without TTL
+//
+// Sorted set members are "<id>|<json>"; the ID keeps identical records from
+// collapsing into one member.
+const (
+	redisNodesKey       = "earthworm:nodes"
+	redisKernelNodesKey = "earthworm:ke_nodes"
+	redisSchemaKey      = "earthworm:schema"
+	redisMigrateLockKey = "earthworm:migrate_lock"
+)
+
+// RedisStore implements the Store interface using Redis sorted sets. Each
+// write trims its sorted set to the TTL, measured back from the record
+// written, and to maxLen members when maxLen is positive.
 type RedisStore struct {
-	client *redis.Client
+	client redis.UniversalClient
+	target string
+	tls    bool
 	ttl    time.Duration
+	maxLen int
+
+	// instance and seq make member IDs unique across servers
+	instance string
+	seq      atomic.Uint64
 }
 
-// NewRedisStore creates a new Redis-backed store.
-func NewRedisStore(addr string) *RedisStore {
-	client := redis.NewClient(&redis.Options{
-		Addr: addr,
-	})
-	return &RedisStore{client: client, ttl: defaultTTL}
+// NewRedisStore connects to Redis, a Sentinel-managed master or a cluster
+// and migrates data written in the previous key layout.
+func NewRedisStore(opts RedisOptions) (*RedisStore, error) {
+	client, opts, err := newRedisClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("redis %s: %w", opts.target(), err)
+	}
+	instance := make([]byte, 4)
+	rand.Read(instance)
+	r := &RedisStore{client: client, target: opts.target(), tls: opts.TLS, ttl: opts.TTL, maxLen: opts.MaxLen, instance: hex.EncodeToString(instance)}
+	ctx := context.Background()
+	if err := r.Ping(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if err := r.migrate(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("migrate redis %s: %w", r.target, err)
+	}
+	return r, nil
 }
 
 func (r *RedisStore) heartbeatKey(nodeName string) string {
-	return fmt.Sprintf("heartbeat:%s", nodeName)
+	return fmt.Sprintf("earthworm:hb:{%s}", nodeName)
 }
 
-func (r *RedisStore) latestKey(nodeName string) string {
-	return fmt.Sprintf("heartbeat:latest:%s", nodeName)
+func (r *RedisStore) kernelEventKey(nodeName, eventType string) string {
+	return fmt.Sprintf("earthworm:ke:{%s}:%s", nodeName, eventType)
+}
+
+func (r *RedisStore) kernelEventTypesKey(nodeName string) string {
+	return fmt.Sprintf("earthworm:ke_types:{%s}", nodeName)
+}
+
+func (r *RedisStore) causalChainKey(nodeName string) string {
+	return fmt.Sprintf("earthworm:cc:{%s}", nodeName)
+}
+
+// member returns a new sorted set member holding data.
+func (r *RedisStore) member(data []byte) string {
+	return r.instance + "-" + strconv.FormatUint(r.seq.Add(1), 36) + "|" + string(data)
+}
+
+// add queues adding member to the sorted set key and trimming the set.
+func (r *RedisStore) add(ctx context.Context, pipe redis.Pipeliner, key string, ts time.Time, member string) {
+	score := ts.UnixMilli()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(score), Member: member})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%d", score-r.ttl.Milliseconds()))
+	if r.maxLen > 0 {
+		pipe.ZRemRangeByRank(ctx, key, 0, int64(-r.maxLen-1))
+	}
+	pipe.Expire(ctx, key, r.ttl)
 }
 
 func (r *RedisStore) Save(ctx context.Context, event Heartbeat) error {
@@ -38,97 +119,211 @@ func (r *RedisStore) Save(ctx context.Context, event Heartbeat) error {
 	if err != nil {
 		return fmt.Errorf("marshal heartbeat: %w", err)
 	}
-
-	score := float64(event.Timestamp.UnixMilli())
-	key := r.heartbeatKey(event.NodeName)
-
 	pipe := r.client.Pipeline()
-	pipe.ZAdd(ctx, key, &redis.Z{Score: score, Member: string(data)})
-	pipe.Expire(ctx, key, r.ttl)
-
-	// Store latest for fast anomaly lookups
-	latestKey := r.latestKey(event.NodeName)
-	pipe.Set(ctx, latestKey, string(data), r.ttl)
-
+	r.queueHeartbeat(ctx, pipe, event, r.member(data))
 	_, err = pipe.Exec(ctx)
 	return err
 }
 
+func (r *RedisStore) queueHeartbeat(ctx context.Context, pipe redis.Pipeliner, event Heartbeat, member string) {
+	score := event.Timestamp.UnixMilli()
+	r.add(ctx, pipe, r.heartbeatKey(event.NodeName), event.Timestamp, member)
+	// The registry keeps each node's newest heartbeat, so range reads skip
+	// nodes that have been silent since before the range
+	pipe.ZAddArgs(ctx, redisNodesKey, redis.ZAddArgs{GT: true, Members: []redis.Z{{Score: float64(score), Member: event.NodeName}}})
+	pipe.ZRemRangeByScore(ctx, redisNodesKey, "-inf", fmt.Sprintf("(%d", score-r.ttl.Milliseconds()))
+}
+
 func (r *RedisStore) GetByTimeRange(ctx context.Context, from, to time.Time) ([]Heartbeat, error) {
-	// Scan all heartbeat keys
-	keys, err := r.scanKeys(ctx, "heartbeat:*")
+	nodes, err := r.client.ZRangeByScore(ctx, redisNodesKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
 	if err != nil {
 		return nil, err
 	}
+	keys := make([]string, len(nodes))
+	for i, node := range nodes {
+		keys[i] = r.heartbeatKey(node)
+	}
+	return redisRangeMany[Heartbeat](ctx, r.client, keys, from, to, func(hb Heartbeat) time.Time { return hb.Timestamp })
+}
 
-	fromScore := float64(from.UnixMilli())
-	toScore := float64(to.UnixMilli())
-	var result []Heartbeat
+func (r *RedisStore) GetLatestByNode(ctx context.Context, nodeName string) (*Heartbeat, error) {
+	// The highest score is the newest heartbeat, whatever order they were saved in
+	members, err := r.client.ZRevRange(ctx, r.heartbeatKey(nodeName), 0, 0).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	hb, err := redisDecode[Heartbeat](members[0])
+	if err != nil {
+		return nil, err
+	}
+	return &hb, nil
+}
 
-	for _, key := range keys {
-		// Skip latest keys
-		if len(key) > 17 && key[:17] == "heartbeat:latest:" {
-			continue
+// QueryHeartbeats reads each node's heartbeats in pipelined rounds of need
+// members. A node that runs out, or has enough selected heartbeats, drops
+// out; later rounds are only needed when filters skip members.
+func (r *RedisStore) QueryHeartbeats(ctx context.Context, q HeartbeatQuery) (HeartbeatPage, error) {
+	c, err := q.cursor()
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	from, to := q.bounds(c)
+	nodes, err := r.queryNodes(ctx, q, from)
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	need := q.need(c)
+	type progress struct {
+		key      string
+		offset   int64
+		selected int
+		// Members of the last score read, which may continue in the next round
+		lastScore float64
+		atScore   int64
+	}
+	active := make([]*progress, len(nodes))
+	for i, node := range nodes {
+		active[i] = &progress{key: r.heartbeatKey(node)}
+	}
+	var candidates []Heartbeat
+	for len(active) > 0 {
+		pipe := r.client.Pipeline()
+		cmds := make([]*redis.ZSliceCmd, len(active))
+		for i, p := range active {
+			cmds[i] = pipe.ZRangeByScoreWithScores(ctx, p.key, &redis.ZRangeBy{
+				Min:    strconv.FormatInt(from.UnixMilli(), 10),
+				Max:    strconv.FormatInt(to.UnixMilli(), 10),
+				Offset: p.offset,
+				Count:  int64(need),
+			})
 		}
-		members, err := r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
-			Min: fmt.Sprintf("%f", fromScore),
-			Max: fmt.Sprintf("%f", toScore),
-		}).Result()
-		if err != nil {
-			return nil, err
+		if _, err := pipe.Exec(ctx); err != nil {
+			return HeartbeatPage{}, err
 		}
-		for _, m := range members {
-			var hb Heartbeat
-			if err := json.Unmarshal([]byte(m), &hb); err == nil {
-				result = append(result, hb)
+		var next, full []*progress
+		for i, p := range active {
+			members := cmds[i].Val()
+			for _, z := range members {
+				if z.Score != p.lastScore {
+					p.lastScore, p.atScore = z.Score, 0
+				}
+				p.atScore++
+				hb, err := redisDecode[Heartbeat](z.Member.(string))
+				if err == nil && q.selects(hb, c) {
+					candidates = append(candidates, hb)
+					p.selected++
+				}
+			}
+			p.offset += int64(len(members))
+			switch {
+			case len(members) < need:
+			case p.selected < need:
+				next = append(next, p)
+			default:
+				full = append(full, p)
+			}
+		}
+		// Scores are milliseconds, so members of a node's last score are not
+		// in timestamp order; read the rest of them too
+		if len(full) > 0 {
+			pipe := r.client.Pipeline()
+			rest := make([]*redis.StringSliceCmd, len(full))
+			for i, p := range full {
+				score := strconv.FormatFloat(p.lastScore, 'f', -1, 64)
+				rest[i] = pipe.ZRangeByScore(ctx, p.key, &redis.ZRangeBy{Min: score, Max: score, Offset: p.atScore, Count: -1})
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return HeartbeatPage{}, err
+			}
+			for _, cmd := range rest {
+				for _, m := range cmd.Val() {
+					if hb, err := redisDecode[Heartbeat](m); err == nil && q.selects(hb, c) {
+						candidates = append(candidates, hb)
+					}
+				}
 			}
 		}
+		active = next
 	}
-	return result, nil
+	return pageHeartbeats(candidates, q, c), nil
 }
 
-func (r *RedisStore) GetLatestByNode(ctx context.Context, nodeName string) (*Heartbeat, error) {
-	data, err := r.client.Get(ctx, r.latestKey(nodeName)).Result()
-	if err == redis.Nil {
-		return nil, nil
-	}
+func (r *RedisStore) DownsampleHeartbeats(ctx context.Context, q HeartbeatQuery, step time.Duration) ([]HeartbeatBucket, error) {
+	from, to := q.bounds(nil)
+	nodes, err := r.queryNodes(ctx, q, from)
 	if err != nil {
 		return nil, err
 	}
-	var hb Heartbeat
-	if err := json.Unmarshal([]byte(data), &hb); err != nil {
+	keys := make([]string, len(nodes))
+	for i, node := range nodes {
+		keys[i] = r.heartbeatKey(node)
+	}
+	hbs, err := redisRangeMany[Heartbeat](ctx, r.client, keys, from, to, func(hb Heartbeat) time.Time { return hb.Timestamp })
+	if err != nil {
 		return nil, err
 	}
-	return &hb, nil
+	return downsampleHeartbeats(hbs, q, step), nil
 }
 
-func (r *RedisStore) Ping(ctx context.Context) error {
-	return r.client.Ping(ctx).Err()
+// queryNodes returns the nodes whose heartbeats q may select: its node, or
+// every node with a heartbeat since from.
+func (r *RedisStore) queryNodes(ctx context.Context, q HeartbeatQuery, from time.Time) ([]string, error) {
+	if q.NodeName != "" {
+		return []string{q.NodeName}, nil
+	}
+	return r.client.ZRangeByScore(ctx, redisNodesKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
 }
 
-func (r *RedisStore) scanKeys(ctx context.Context, pattern string) ([]string, error) {
-	var keys []string
-	var cursor uint64
-	for {
-		batch, nextCursor, err := r.client.Scan(ctx, cursor, pattern, 100).Result()
-		if err != nil {
-			return nil, err
-		}
-		keys = append(keys, batch...)
-		cursor = nextCursor
-		if cursor == 0 {
-			break
+func (r *RedisStore) Nodes(ctx context.Context) ([]string, error) {
+	pipe := r.client.Pipeline()
+	heartbeatNodes := pipe.ZRange(ctx, redisNodesKey, 0, -1)
+	kernelNodes := pipe.ZRange(ctx, redisKernelNodesKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, node := range append(heartbeatNodes.Val(), kernelNodes.Val()...) {
+		if !seen[node] {
+			seen[node] = true
+			result = append(result, node)
 		}
 	}
-	return keys, nil
+	sort.Strings(result)
+	return result, nil
+}
+
+// HasNode checks the node sets Nodes reads, so it agrees with Nodes.
+func (r *RedisStore) HasNode(ctx context.Context, nodeName string) (bool, error) {
+	pipe := r.client.Pipeline()
+	heartbeats := pipe.ZScore(ctx, redisNodesKey, nodeName)
+	events := pipe.ZScore(ctx, redisKernelNodesKey, nodeName)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, err
+	}
+	return heartbeats.Err() == nil || events.Err() == nil, nil
 }
 
-func (r *RedisStore) kernelEventKey(nodeName string) string {
-	return fmt.Sprintf("kernel_event:%s", nodeName)
+// Ping checks Redis answers, and says what most likely went wrong if not.
+func (r *RedisStore) Ping(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis %s: %s: %w", r.target, explainRedisError(err, r.tls), err)
+	}
+	return nil
 }
 
-func (r *RedisStore) causalChainKey(nodeName string) string {
-	return fmt.Sprintf("causal_chain:%s", nodeName)
+// Close closes the connections to Redis.
+func (r *RedisStore) Close() error {
+	return r.client.Close()
 }
 
 func (r *RedisStore) SaveKernelEvent(ctx context.Context, event EnrichedEvent) error {
@@ -136,44 +331,92 @@ func (r *RedisStore) SaveKernelEvent(ctx context.Context, event EnrichedEvent) e
 	if err != nil {
 		return fmt.Errorf("marshal kernel event: %w", err)
 	}
-	score := float64(event.Timestamp.UnixMilli())
-	key := r.kernelEventKey(event.NodeName)
-
 	pipe := r.client.Pipeline()
-	pipe.ZAdd(ctx, key, &redis.Z{Score: score, Member: string(data)})
-	pipe.Expire(ctx, key, r.ttl)
+	r.queueKernelEvent(ctx, pipe, event, r.member(data))
 	_, err = pipe.Exec(ctx)
 	return err
 }
 
+func (r *RedisStore) queueKernelEvent(ctx context.Context, pipe redis.Pipeliner, event EnrichedEvent, member string) {
+	typesKey := r.kernelEventTypesKey(event.NodeName)
+	r.add(ctx, pipe, r.kernelEventKey(event.NodeName, event.EventType), event.Timestamp, member)
+	pipe.SAdd(ctx, typesKey, event.EventType)
+	pipe.Expire(ctx, typesKey, r.ttl)
+	score := event.Timestamp.UnixMilli()
+	pipe.ZAddArgs(ctx, redisKernelNodesKey, redis.ZAddArgs{GT: true, Members: []redis.Z{{Score: float64(score), Member: event.NodeName}}})
+	pipe.ZRemRangeByScore(ctx, redisKernelNodesKey, "-inf", fmt.Sprintf("(%d", score-r.ttl.Milliseconds()))
+}
+
 func (r *RedisStore) GetKernelEvents(ctx context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error) {
-	key := r.kernelEventKey(nodeName)
-	members, err := r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
-		Min: fmt.Sprintf("%f", float64(from.UnixMilli())),
-		Max: fmt.Sprintf("%f", float64(to.UnixMilli())),
-	}).Result()
+	types, err := r.client.SMembers(ctx, r.kernelEventTypesKey(nodeName)).Result()
 	if err != nil {
 		return nil, err
 	}
-	var result []EnrichedEvent
-	for _, m := range members {
-		var e EnrichedEvent
-		if err := json.Unmarshal([]byte(m), &e); err == nil {
-			result = append(result, e)
-		}
+	keys := make([]string, len(types))
+	for i, eventType := range types {
+		keys[i] = r.kernelEventKey(nodeName, eventType)
 	}
-	return result, nil
+	return redisRangeMany[EnrichedEvent](ctx, r.client, keys, from, to, func(e EnrichedEvent) time.Time { return e.Timestamp })
 }
 
 func (r *RedisStore) GetKernelEventsByType(ctx context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error) {
-	events, err := r.GetKernelEvents(ctx, nodeName, from, to)
-	if err != nil {
+	return redisRangeMany[EnrichedEvent](ctx, r.client, []string{r.kernelEventKey(nodeName, eventType)}, from, to,
+		func(e EnrichedEvent) time.Time { return e.Timestamp })
+}
+
+// CountKernelEvents counts each node's sorted sets by score, in a round
+// trip for the nodes with events since from, one for their event types and
+// one for the counts.
+func (r *RedisStore) CountKernelEvents(ctx context.Context, nodeName string, from, to time.Time) (map[string]map[string]int, error) {
+	nodes := []string{nodeName}
+	if nodeName == "" {
+		var err error
+		nodes, err = r.client.ZRangeByScore(ctx, redisKernelNodesKey, &redis.ZRangeBy{
+			Min: strconv.FormatInt(from.UnixMilli(), 10),
+			Max: "+inf",
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string]map[string]int)
+	if len(nodes) == 0 {
+		return result, nil
+	}
+
+	pipe := r.client.Pipeline()
+	typeCmds := make([]*redis.StringSliceCmd, len(nodes))
+	for i, node := range nodes {
+		typeCmds[i] = pipe.SMembers(ctx, r.kernelEventTypesKey(node))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return nil, err
 	}
-	var result []EnrichedEvent
-	for _, e := range events {
-		if e.EventType == eventType {
-			result = append(result, e)
+
+	type count struct {
+		node, eventType string
+		cmd             *redis.IntCmd
+	}
+	var counts []count
+	lo, hi := strconv.FormatInt(from.UnixMilli(), 10), strconv.FormatInt(to.UnixMilli(), 10)
+	pipe = r.client.Pipeline()
+	for i, node := range nodes {
+		for _, eventType := range typeCmds[i].Val() {
+			counts = append(counts, count{node, eventType, pipe.ZCount(ctx, r.kernelEventKey(node, eventType), lo, hi)})
+		}
+	}
+	if len(counts) == 0 {
+		return result, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		if n := int(c.cmd.Val()); n > 0 {
+			if result[c.node] == nil {
+				result[c.node] = make(map[string]int)
+			}
+			result[c.node][c.eventType] = n
 		}
 	}
 	return result, nil
@@ -184,31 +427,226 @@ func (r *RedisStore) SaveCausalChain(ctx context.Context, chain CausalChain) err
 	if err != nil {
 		return fmt.Errorf("marshal causal chain: %w", err)
 	}
-	score := float64(chain.Timestamp.UnixMilli())
-	key := r.causalChainKey(chain.NodeName)
-
 	pipe := r.client.Pipeline()
-	pipe.ZAdd(ctx, key, &redis.Z{Score: score, Member: string(data)})
-	pipe.Expire(ctx, key, r.ttl)
+	r.add(ctx, pipe, r.causalChainKey(chain.NodeName), chain.Timestamp, r.member(data))
 	_, err = pipe.Exec(ctx)
 	return err
 }
 
 func (r *RedisStore) GetCausalChains(ctx context.Context, nodeName string, from, to time.Time) ([]CausalChain, error) {
-	key := r.causalChainKey(nodeName)
-	members, err := r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
-		Min: fmt.Sprintf("%f", float64(from.UnixMilli())),
-		Max: fmt.Sprintf("%f", float64(to.UnixMilli())),
-	}).Result()
+	return redisRangeMany[CausalChain](ctx, r.client, []string{r.causalChainKey(nodeName)}, from, to,
+		func(c CausalChain) time.Time { return c.Timestamp })
+}
+
+func (r *RedisStore) GetLatestCausalChain(ctx context.Context, nodeName string) (*CausalChain, error) {
+	members, err := r.client.ZRevRange(ctx, r.causalChainKey(nodeName), 0, 0).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	chain, err := redisDecode[CausalChain](members[0])
+	if err != nil {
+		return nil, err
+	}
+	return &chain, nil
+}
+
+// silencesKey is a hash of silence ID → JSON. Silences are not subject to the
+// store TTL; expired ones are purged by Silences.
+const silencesKey = "silences"
+
+func (r *RedisStore) SaveSilence(ctx context.Context, silence Silence) error {
+	data, err := json.Marshal(silence)
+	if err != nil {
+		return fmt.Errorf("marshal silence: %w", err)
+	}
+	return r.client.HSet(ctx, silencesKey, silence.ID, string(data)).Err()
+}
+
+func (r *RedisStore) GetSilences(ctx context.Context) ([]Silence, error) {
+	fields, err := r.client.HGetAll(ctx, silencesKey).Result()
 	if err != nil {
 		return nil, err
 	}
-	var result []CausalChain
-	for _, m := range members {
-		var c CausalChain
-		if err := json.Unmarshal([]byte(m), &c); err == nil {
-			result = append(result, c)
+	result := make([]Silence, 0, len(fields))
+	for _, v := range fields {
+		var s Silence
+		if err := json.Unmarshal([]byte(v), &s); err == nil {
+			result = append(result, s)
 		}
 	}
 	return result, nil
 }
+
+func (r *RedisStore) DeleteSilence(ctx context.Context, id string) error {
+	return r.client.HDel(ctx, silencesKey, id).Err()
+}
+
+// redisRangeMany reads the members of the sorted sets keys between from and
+// to in one pipeline and merges them by timestamp. Members that do not
+// decode are skipped.
+func redisRangeMany[T any](ctx context.Context, client redis.UniversalClient, keys []string, from, to time.Time, timestamp func(T) time.Time) ([]T, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	by := &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.UnixMilli(), 10),
+		Max: strconv.FormatInt(to.UnixMilli(), 10),
+	}
+	pipe := client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.ZRangeByScore(ctx, key, by)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	var result []T
+	for _, cmd := range cmds {
+		for _, m := range cmd.Val() {
+			if v, err := redisDecode[T](m); err == nil {
+				result = append(result, v)
+			}
+		}
+	}
+	// Each set is sorted; merge them into one timeline
+	if len(keys) > 1 {
+		sort.SliceStable(result, func(i, j int) bool { return timestamp(result[i]).Before(timestamp(result[j])) })
+	}
+	return result, nil
+}
+
+// redisDecode decodes the JSON of a sorted set member.
+func redisDecode[T any](member string) (T, error) {
+	var v T
+	_, data, ok := strings.Cut(member, "|")
+	if !ok {
+		return v, fmt.Errorf("member without an ID: %.40q", member)
+	}
+	err := json.Unmarshal([]byte(data), &v)
+	return v, err
+}
+
+// migrate moves data in the version 1 layout to the current one, once. One
+// server migrates while others start without waiting; the legacy keys are
+// deleted as they are moved. A migration that was interrupted is resumed on
+// the next start; a legacy member's ID is fixed, so moving it twice stores
+// it once.
+func (r *RedisStore) migrate(ctx context.Context) error {
+	version, err := r.client.Get(ctx, redisSchemaKey).Int()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if version > redisSchemaVersion {
+		return fmt.Errorf("key layout version %d is newer than this server's %d", version, redisSchemaVersion)
+	}
+	if version == redisSchemaVersion {
+		return nil
+	}
+	locked, err := r.client.SetNX(ctx, redisMigrateLockKey, r.instance, 10*time.Minute).Result()
+	if err != nil {
+		return err
+	}
+	if !locked {
+		log.Printf("Redis store: another server is migrating to key layout %d", redisSchemaVersion)
+		return nil
+	}
+	defer r.client.Del(ctx, redisMigrateLockKey)
+
+	moved := 0
+	for _, legacy := range []struct {
+		pattern string
+		queue   func(pipe redis.Pipeliner, member string) error
+	}{
+		{"heartbeat:*", func(pipe redis.Pipeliner, member string) error {
+			var hb Heartbeat
+			err := json.Unmarshal([]byte(member), &hb)
+			if err == nil {
+				r.queueHeartbeat(ctx, pipe, hb, "legacy|"+member)
+			}
+			return err
+		}},
+		{"kernel_event:*", func(pipe redis.Pipeliner, member string) error {
+			var e EnrichedEvent
+			err := json.Unmarshal([]byte(member), &e)
+			if err == nil {
+				r.queueKernelEvent(ctx, pipe, e, "legacy|"+member)
+			}
+			return err
+		}},
+		{"causal_chain:*", func(pipe redis.Pipeliner, member string) error {
+			var c CausalChain
+			err := json.Unmarshal([]byte(member), &c)
+			if err == nil {
+				r.add(ctx, pipe, r.causalChainKey(c.NodeName), c.Timestamp, "legacy|"+member)
+			}
+			return err
+		}},
+	} {
+		keys, err := r.scanKeys(ctx, legacy.pattern)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			pipe := r.client.Pipeline()
+			// The latest keys only duplicate the newest heartbeat
+			if !strings.HasPrefix(key, "heartbeat:latest:") {
+				members, err := r.client.ZRange(ctx, key, 0, -1).Result()
+				if err != nil {
+					return fmt.Errorf("read %s: %w", key, err)
+				}
+				for _, m := range members {
+					// Members that never decoded were unreadable before too
+					if legacy.queue(pipe, m) == nil {
+						moved++
+					}
+				}
+			}
+			pipe.Del(ctx, key)
+			if _, err := pipe.Exec(ctx); err != nil {
+				return fmt.Errorf("move %s: %w", key, err)
+			}
+		}
+	}
+	if err := r.client.Set(ctx, redisSchemaKey, redisSchemaVersion, 0).Err(); err != nil {
+		return err
+	}
+	if moved > 0 {
+		log.Printf("Redis store: moved %d records to key layout %d", moved, redisSchemaVersion)
+	}
+	return nil
+}
+
+// scanKeys returns the keys matching pattern, on every master of a cluster.
+func (r *RedisStore) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(ctx, r.client, pattern)
+	}
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		masterKeys, err := scanKeys(ctx, master, pattern)
+		mu.Lock()
+		keys = append(keys, masterKeys...)
+		mu.Unlock()
+		return err
+	})
+	return keys, err
+}
+
+func scanKeys(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, nextCursor, err := client.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisStore(t *testing.T, mr *miniredis.Miniredis, ttl time.Duration, maxLen int) *RedisStore {
+	t.Helper()
+	s, err := NewRedisStore(RedisOptions{Addrs: []string{mr.Addr()}, TTL: ttl, MaxLen: maxLen})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestRedisStore_IdenticalRecords verifies identical records are kept apart
+// rather than collapsing into one sorted set member.
+func TestRedisStore_IdenticalRecords(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStore(t, miniredis.RunT(t), defaultTTL, 0)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: now, Status: "Ready"})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "syscall", Timestamp: now, Comm: "containerd"})
+	}
+	hbs, _ := s.GetByTimeRange(ctx, now, now)
+	events, _ := s.GetKernelEvents(ctx, "node-a", now, now)
+	if len(hbs) != 3 || len(events) != 3 {
+		t.Errorf("%d heartbeats and %d events, want 3 each", len(hbs), len(events))
+	}
+}
+
+// TestRedisStore_Trimming verifies each write trims its sorted set to the
+// TTL and the maximum length, and drops silent nodes from the registry.
+func TestRedisStore_Trimming(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	s := newTestRedisStore(t, mr, time.Hour, 3)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	s.Save(ctx, Heartbeat{NodeName: "node-old", Timestamp: now.Add(-2 * time.Hour)})
+	for i := 0; i < 5; i++ {
+		s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	hbs, _ := s.GetByTimeRange(ctx, now.Add(-3*time.Hour), now.Add(time.Minute))
+	if len(hbs) != 3 || !hbs[0].Timestamp.Equal(now.Add(2*time.Second)) {
+		t.Errorf("heartbeats = %+v, want the newest 3 of node-a", hbs)
+	}
+	if nodes, _ := mr.ZMembers(redisNodesKey); len(nodes) != 1 || nodes[0] != "node-a" {
+		t.Errorf("registry = %v, want only node-a", nodes)
+	}
+	if ttl := mr.TTL(s.heartbeatKey("node-a")); ttl != time.Hour {
+		t.Errorf("heartbeat key TTL = %v, want 1h", ttl)
+	}
+
+	s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: now.Add(90 * time.Minute)})
+	if hbs, _ := s.GetByTimeRange(ctx, now.Add(-time.Hour), now.Add(2*time.Hour)); len(hbs) != 1 {
+		t.Errorf("%d heartbeats after an hour and a half, want 1", len(hbs))
+	}
+}
+
+// TestRedisStore_EventTypeIndex verifies kernel events of one type are read
+// from a sorted set of their own.
+func TestRedisStore_EventTypeIndex(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	s := newTestRedisStore(t, mr, defaultTTL, 0)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	for i, typ := range []string{"syscall", "network", "syscall"} {
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: typ, Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	if members, _ := mr.ZMembers(s.kernelEventKey("node-a", "syscall")); len(members) != 2 {
+		t.Errorf("%d syscall members, want 2", len(members))
+	}
+	if types, _ := mr.SMembers(s.kernelEventTypesKey("node-a")); len(types) != 2 {
+		t.Errorf("types = %v, want syscall and network", types)
+	}
+}
+
+// TestRedisStore_Migrate verifies data in the version 1 layout is moved on
+// startup once, and a newer layout is refused.
+func TestRedisStore_Migrate(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	score := float64(now.UnixMilli())
+	marshal := func(v any) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	latest := marshal(Heartbeat{NodeName: "node-a", Timestamp: now.Add(time.Second), Status: "Ready"})
+	mr.ZAdd("heartbeat:node-a", score, marshal(Heartbeat{NodeName: "node-a", Timestamp: now, Status: "Ready"}))
+	mr.ZAdd("heartbeat:node-a", score+1000, latest)
+	mr.Set("heartbeat:latest:node-a", latest)
+	mr.ZAdd("heartbeat:node-b", score, marshal(Heartbeat{NodeName: "node-b", Timestamp: now}))
+	mr.ZAdd("kernel_event:node-a", score, marshal(EnrichedEvent{NodeName: "node-a", EventType: "process", Timestamp: now, CriticalExit: true}))
+	mr.ZAdd("causal_chain:node-a", score, marshal(CausalChain{NodeName: "node-a", Timestamp: now, Summary: "kubelet exited"}))
+	mr.ZAdd("causal_chain:node-a", score, "not json")
+	mr.HSet(silencesKey, "s1", marshal(Silence{ID: "s1"}))
+
+	s := newTestRedisStore(t, mr, defaultTTL, 0)
+	check := func() {
+		t.Helper()
+		hbs, _ := s.GetByTimeRange(ctx, now, now.Add(time.Second))
+		events, _ := s.GetKernelEventsByType(ctx, "node-a", "process", now, now)
+		chains, _ := s.GetCausalChains(ctx, "node-a", now, now)
+		silences, _ := s.GetSilences(ctx)
+		if len(hbs) != 3 || len(events) != 1 || !events[0].CriticalExit || len(chains) != 1 || len(silences) != 1 {
+			t.Errorf("after migration: %d heartbeats, %+v, %+v, %d silences", len(hbs), events, chains, len(silences))
+		}
+		if hb, _ := s.GetLatestByNode(ctx, "node-a"); hb == nil || !hb.Timestamp.Equal(now.Add(time.Second)) {
+			t.Errorf("latest of node-a = %+v", hb)
+		}
+	}
+	check()
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "earthworm:") && key != silencesKey {
+			t.Errorf("legacy key %s left behind", key)
+		}
+	}
+	if v, _ := mr.Get(redisSchemaKey); v != "2" {
+		t.Errorf("schema = %q, want 2", v)
+	}
+
+	// Starting again, or after an interrupted migration, stores nothing twice
+	mr.Del(redisSchemaKey)
+	mr.ZAdd("heartbeat:node-b", score, marshal(Heartbeat{NodeName: "node-b", Timestamp: now}))
+	s = newTestRedisStore(t, mr, defaultTTL, 0)
+	check()
+
+	mr.Set(redisSchemaKey, "3")
+	if _, err := NewRedisStore(RedisOptions{Addrs: []string{mr.Addr()}, TTL: defaultTTL}); err == nil {
+		t.Error("opened a newer key layout")
+	}
+}
+
+// TestRedisStore_MigrateLocked verifies a server leaves the migration to the
+// one holding the lock.
+func TestRedisStore_MigrateLocked(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.ZAdd("heartbeat:node-a", 1, `{"nodeName":"node-a"}`)
+	mr.Set(redisMigrateLockKey, "other")
+
+	newTestRedisStore(t, mr, defaultTTL, 0)
+	if !mr.Exists("heartbeat:node-a") || mr.Exists(redisSchemaKey) {
+		t.Error("migrated while another server held the lock")
+	}
+}
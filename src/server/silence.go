@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"earthworm/src/kubernetes"
+)
+
+// Silence matcher names.
+const (
+	matcherNode      = "node"
+	matcherNamespace = "namespace"
+	matcherSeverity  = "severity"
+	matcherRootCause = "rootCause"
+)
+
+// Silence states, derived from the time window.
+const (
+	silenceStatePending = "pending"
+	silenceStateActive  = "active"
+	silenceStateExpired = "expired"
+)
+
+// Expired silences are kept for silenceRetention after they end, so that
+// recent ones can still be listed, and purged every silencePurgeInterval.
+const (
+	silenceRetention     = 5 * 24 * time.Hour
+	silencePurgeInterval = time.Hour
+)
+
+// Silence errors.
+var (
+	ErrSilenceNotFound = errors.New("silence not found")
+	ErrInvalidSilence  = errors.New("invalid silence")
+)
+
+// SilenceMatcher matches one alert field against a glob pattern.
+type SilenceMatcher struct {
+	Name  string `json:"name"`  // node, namespace, severity or rootCause
+	Value string `json:"value"` // glob, e.g. "worker-*"
+}
+
+// Silence suppresses notifications for matching alerts during a time window.
+// An alert matches when every matcher matches.
+type Silence struct {
+	ID        string           `json:"id"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+	CreatedAt time.Time        `json:"createdAt"`
+	State     string           `json:"state,omitempty"` // set when listed
+}
+
+// stateAt returns the silence's state at now.
+func (s Silence) stateAt(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return silenceStatePending
+	case now.Before(s.EndsAt):
+		return silenceStateActive
+	default:
+		return silenceStateExpired
+	}
+}
+
+// Matches reports whether every matcher matches the alert.
+func (s Silence) Matches(a Alert) bool {
+	for _, m := range s.Matchers {
+		var field string
+		switch m.Name {
+		case matcherNode:
+			field = a.NodeName
+		case matcherNamespace:
+			field = a.Namespace
+		case matcherSeverity:
+			field = a.Severity
+		case matcherRootCause:
+			field = a.RootCause
+		}
+		if ok, _ := path.Match(m.Value, field); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks a silence submitted through the API.
+func (s Silence) validate(now time.Time) error {
+	if len(s.Matchers) == 0 {
+		return errors.New("at least one matcher is required")
+	}
+	for _, m := range s.Matchers {
+		switch m.Name {
+		case matcherNode, matcherNamespace, matcherSeverity, matcherRootCause:
+		default:
+			return fmt.Errorf("unknown matcher name %q", m.Name)
+		}
+		if m.Value == "" {
+			return fmt.Errorf("matcher %q has an empty value", m.Name)
+		}
+		if _, err := path.Match(m.Value, ""); err != nil {
+			return fmt.Errorf("matcher %q: invalid pattern %q", m.Name, m.Value)
+		}
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+	if !s.EndsAt.After(now) {
+		return errors.New("endsAt must be in the future")
+	}
+	if s.CreatedBy == "" {
+		return errors.New("createdBy is required")
+	}
+	if s.Comment == "" {
+		return errors.New("comment is required")
+	}
+	return nil
+}
+
+// rejectNamespaces returns an error if the silence matches on namespace.
+// Alerts about heartbeats from node Leases are all in kube-node-lease, so with
+// -lease-watch such a matcher would silence every node or none.
+func (s Silence) rejectNamespaces() error {
+	for _, m := range s.Matchers {
+		if m.Name == matcherNamespace {
+			return fmt.Errorf("%w: matches on namespace, but with -lease-watch every alert is in %s; match on node instead",
+				ErrInvalidSilence, kubernetes.NodeLeaseNamespace)
+		}
+	}
+	return nil
+}
+
+// Silences caches the silences persisted in the Store.
+type Silences struct {
+	store Store
+
+	mu       sync.RWMutex
+	silences map[string]Silence
+}
+
+// NewSilences creates an empty silence set backed by store.
+func NewSilences(store Store) *Silences {
+	return &Silences{store: store, silences: make(map[string]Silence)}
+}
+
+// Load replaces the cache with the silences in the store.
+func (s *Silences) Load(ctx context.Context) error {
+	list, err := s.store.GetSilences(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences = make(map[string]Silence, len(list))
+	for _, sil := range list {
+		s.silences[sil.ID] = sil
+	}
+	return nil
+}
+
+// Create validates, persists and returns a new silence. A zero StartsAt
+// means now.
+func (s *Silences) Create(ctx context.Context, sil Silence, now time.Time) (Silence, error) {
+	if sil.StartsAt.IsZero() {
+		sil.StartsAt = now
+	}
+	if err := sil.validate(now); err != nil {
+		return Silence{}, fmt.Errorf("%w: %v", ErrInvalidSilence, err)
+	}
+	id, err := newSilenceID()
+	if err != nil {
+		return Silence{}, err
+	}
+	sil.ID = id
+	sil.CreatedAt = now
+	sil.State = ""
+	if err := s.store.SaveSilence(ctx, sil); err != nil {
+		return Silence{}, fmt.Errorf("save silence: %w", err)
+	}
+	s.mu.Lock()
+	s.silences[sil.ID] = sil
+	s.mu.Unlock()
+	sil.State = sil.stateAt(now)
+	return sil, nil
+}
+
+// Expire ends a silence at now. Expiring an expired silence is a no-op.
+func (s *Silences) Expire(ctx context.Context, id string, now time.Time) (Silence, error) {
+	s.mu.RLock()
+	sil, ok := s.silences[id]
+	s.mu.RUnlock()
+	if !ok {
+		return Silence{}, ErrSilenceNotFound
+	}
+	if sil.stateAt(now) != silenceStateExpired {
+		if now.Before(sil.StartsAt) {
+			sil.StartsAt = now
+		}
+		sil.EndsAt = now
+		if err := s.store.SaveSilence(ctx, sil); err != nil {
+			return Silence{}, fmt.Errorf("save silence: %w", err)
+		}
+		s.mu.Lock()
+		s.silences[id] = sil
+		s.mu.Unlock()
+	}
+	sil.State = silenceStateExpired
+	return sil, nil
+}
+
+// Purge deletes the silences that ended silenceRetention or longer before now
+// from the store and the cache. It returns how many were purged.
+func (s *Silences) Purge(ctx context.Context, now time.Time) (int, error) {
+	s.mu.RLock()
+	var ids []string
+	for id, sil := range s.silences {
+		if now.Sub(sil.EndsAt) >= silenceRetention {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	for i, id := range ids {
+		if err := s.store.DeleteSilence(ctx, id); err != nil {
+			return i, fmt.Errorf("delete silence: %w", err)
+		}
+		s.mu.Lock()
+		delete(s.silences, id)
+		s.mu.Unlock()
+	}
+	return len(ids), nil
+}
+
+// Run purges expired silences at once and then every interval until ctx is
+// cancelled.
+func (s *Silences) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = silencePurgeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	now := time.Now()
+	for {
+		if n, err := s.Purge(ctx, now); err != nil {
+			log.Printf("Failed to purge expired silences: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d expired silences", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+// List returns all silences with their state at now, newest first.
+func (s *Silences) List(now time.Time) []Silence {
+	s.mu.RLock()
+	out := make([]Silence, 0, len(s.silences))
+	for _, sil := range s.silences {
+		sil.State = sil.stateAt(now)
+		out = append(out, sil)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Silencing returns the IDs of the silences active at now that match the
+// alert, sorted.
+func (s *Silences) Silencing(a Alert, now time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id, sil := range s.silences {
+		if sil.stateAt(now) == silenceStateActive && sil.Matches(a) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func newSilenceID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate silence ID: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// silencesHandler serves GET /api/silences (list) and POST /api/silences (create).
+// check, if not nil, further restricts the silences a POST may create.
+func silencesHandler(s *Silences, check func(Silence) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(s.List(time.Now()))
+		case http.MethodPost:
+			var sil Silence
+			if err := json.NewDecoder(r.Body).Decode(&sil); err != nil {
+				writeJSONError(w, fmt.Sprintf("Invalid JSON: %s", err.Error()), http.StatusBadRequest)
+				return
+			}
+			if check != nil {
+				if err := check(sil); err != nil {
+					writeJSONError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			created, err := s.Create(r.Context(), sil, time.Now())
+			switch {
+			case errors.Is(err, ErrInvalidSilence):
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			case err != nil:
+				writeJSONError(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+		default:
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// silenceHandler serves DELETE /api/silences/{id}, which expires the silence.
+func silenceHandler(s *Silences) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		sil, err := s.Expire(r.Context(), r.PathValue("id"), time.Now())
+		switch {
+		case errors.Is(err, ErrSilenceNotFound):
+			writeJSONError(w, "Silence not found", http.StatusNotFound)
+			return
+		case err != nil:
+			writeJSONError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sil)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSilenceMatches(t *testing.T) {
+	alert := Alert{NodeName: "worker-07", Namespace: "kube-node-lease", Severity: "critical", RootCause: "oom_kill"}
+	tests := []struct {
+		name     string
+		matchers []SilenceMatcher
+		want     bool
+	}{
+		{"node glob", []SilenceMatcher{{Name: matcherNode, Value: "worker-*"}}, true},
+		{"node mismatch", []SilenceMatcher{{Name: matcherNode, Value: "infra-*"}}, false},
+		{"all fields", []SilenceMatcher{
+			{Name: matcherNode, Value: "worker-0?"},
+			{Name: matcherNamespace, Value: "kube-node-lease"},
+			{Name: matcherSeverity, Value: "critical"},
+			{Name: matcherRootCause, Value: "oom_*"},
+		}, true},
+		{"one matcher fails", []SilenceMatcher{
+			{Name: matcherNode, Value: "worker-*"},
+			{Name: matcherSeverity, Value: "warning"},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Silence{Matchers: tt.matchers}).Matches(alert); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func validSilence(now time.Time) Silence {
+	return Silence{
+		Matchers:  []SilenceMatcher{{Name: matcherNode, Value: "worker-*"}},
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "alice",
+		Comment:   "draining workers for kernel upgrade",
+	}
+}
+
+func TestSilencesCreateValidation(t *testing.T) {
+	s := NewSilences(NewMemoryStore())
+	now := time.Now()
+	for name, mutate := range map[string]func(*Silence){
+		"no matchers":     func(sil *Silence) { sil.Matchers = nil },
+		"unknown matcher": func(sil *Silence) { sil.Matchers = []SilenceMatcher{{Name: "pod", Value: "x"}} },
+		"empty value":     func(sil *Silence) { sil.Matchers[0].Value = "" },
+		"bad pattern":     func(sil *Silence) { sil.Matchers[0].Value = "[worker" },
+		"ends in past":    func(sil *Silence) { sil.StartsAt = now.Add(-2 * time.Hour); sil.EndsAt = now.Add(-time.Hour) },
+		"ends before":     func(sil *Silence) { sil.StartsAt = now.Add(2 * time.Hour) },
+		"no creator":      func(sil *Silence) { sil.CreatedBy = "" },
+		"no comment":      func(sil *Silence) { sil.Comment = "" },
+	} {
+		sil := validSilence(now)
+		mutate(&sil)
+		if _, err := s.Create(context.Background(), sil, now); !errors.Is(err, ErrInvalidSilence) {
+			t.Errorf("%s: err = %v, want ErrInvalidSilence", name, err)
+		}
+	}
+}
+
+// TestSilencesPersistence verifies silences survive a restart through the
+// Store and that expiring one ends it.
+func TestSilencesPersistence(t *testing.T) {
+	st := NewMemoryStore()
+	s := NewSilences(st)
+	now := time.Now()
+
+	created, err := s.Create(context.Background(), validSilence(now), now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.ID == "" || created.State != silenceStateActive || !created.StartsAt.Equal(now) {
+		t.Fatalf("created = %+v", created)
+	}
+	pending, err := s.Create(context.Background(), Silence{
+		Matchers:  []SilenceMatcher{{Name: matcherSeverity, Value: "warning"}},
+		StartsAt:  now.Add(time.Hour),
+		EndsAt:    now.Add(2 * time.Hour),
+		CreatedBy: "bob",
+		Comment:   "maintenance window",
+	}, now.Add(time.Second))
+	if err != nil || pending.State != silenceStatePending {
+		t.Fatalf("pending = %+v, err = %v", pending, err)
+	}
+
+	reloaded := NewSilences(st)
+	if err := reloaded.Load(context.Background()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	list := reloaded.List(now.Add(time.Minute))
+	if len(list) != 2 || list[0].ID != pending.ID || list[1].ID != created.ID {
+		t.Fatalf("List = %+v, want pending then created", list)
+	}
+
+	alert := Alert{NodeName: "worker-01", Severity: "critical"}
+	if ids := reloaded.Silencing(alert, now.Add(time.Minute)); len(ids) != 1 || ids[0] != created.ID {
+		t.Fatalf("Silencing = %v", ids)
+	}
+
+	expired, err := reloaded.Expire(context.Background(), created.ID, now.Add(2*time.Minute))
+	if err != nil || expired.State != silenceStateExpired {
+		t.Fatalf("Expire = %+v, %v", expired, err)
+	}
+	if ids := reloaded.Silencing(alert, now.Add(3*time.Minute)); len(ids) != 0 {
+		t.Fatalf("expired silence still matches: %v", ids)
+	}
+	stored, _ := st.GetSilences(context.Background())
+	for _, sil := range stored {
+		if sil.ID == created.ID && !sil.EndsAt.Equal(now.Add(2*time.Minute)) {
+			t.Errorf("stored EndsAt = %v, expiry not persisted", sil.EndsAt)
+		}
+	}
+	if _, err := reloaded.Expire(context.Background(), "missing", now); !errors.Is(err, ErrSilenceNotFound) {
+		t.Errorf("Expire(missing) err = %v", err)
+	}
+}
+
+func TestSilencesPurge(t *testing.T) {
+	st := NewMemoryStore()
+	s := NewSilences(st)
+	now := time.Now()
+
+	old, err := s.Create(context.Background(), validSilence(now), now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.Expire(context.Background(), old.ID, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Expire: %v", err)
+	}
+	recent, err := s.Create(context.Background(), validSilence(now.Add(time.Hour)), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// The first silence ended a minute in, so it is due first
+	purgeAt := now.Add(time.Minute + silenceRetention)
+	if n, err := s.Purge(context.Background(), purgeAt.Add(-time.Second)); err != nil || n != 0 {
+		t.Fatalf("Purge within retention = %d, %v, want nothing purged", n, err)
+	}
+	if n, err := s.Purge(context.Background(), purgeAt); err != nil || n != 1 {
+		t.Fatalf("Purge = %d, %v, want 1", n, err)
+	}
+	if list := s.List(purgeAt); len(list) != 1 || list[0].ID != recent.ID {
+		t.Errorf("List after purge = %+v, want only the recent silence", list)
+	}
+	if stored, _ := st.GetSilences(context.Background()); len(stored) != 1 || stored[0].ID != recent.ID {
+		t.Errorf("stored silences after purge = %+v, want only the recent silence", stored)
+	}
+
+	failing := NewSilences(&errorStore{saveErr: errors.New("disk full")})
+	failing.silences[old.ID] = old
+	if _, err := failing.Purge(context.Background(), purgeAt.Add(silenceRetention)); err == nil {
+		t.Error("Purge succeeded although the store failed")
+	}
+	if len(failing.List(purgeAt)) != 1 {
+		t.Error("silence dropped from the cache although the store failed to delete it")
+	}
+}
+
+// TestAlertManagerHonorsSilences models a planned drain: alerts for silenced
+// nodes stay visible but are not notified until the silence ends.
+func TestAlertManagerHonorsSilences(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	s := NewSilences(NewMemoryStore())
+	m.SetSilences(s)
+
+	now := time.Now()
+	sil, err := s.Create(context.Background(), validSilence(now), now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	m.Fire(gapAlert("worker-01", "critical", 45, now), now)
+	m.Fire(gapAlert("infra-01", "critical", 45, now), now)
+	m.Flush(now.Add(30 * time.Second))
+
+	got := rec.take()
+	if len(got) != 1 || got[0].NodeName != "infra-01" {
+		t.Fatalf("notified %+v, want only infra-01", got)
+	}
+	list := m.Alerts()
+	if len(list.Active) != 2 {
+		t.Fatalf("Active = %+v, want both alerts listed", list.Active)
+	}
+	for _, a := range list.Active {
+		silenced := len(a.SilencedBy) == 1 && a.SilencedBy[0] == sil.ID
+		if silenced != (a.NodeName == "worker-01") {
+			t.Errorf("%s SilencedBy = %v", a.NodeName, a.SilencedBy)
+		}
+	}
+
+	// Once the silence ends the held alert goes out after the group wait
+	expireAt := now.Add(time.Minute)
+	if _, err := s.Expire(context.Background(), sil.ID, expireAt); err != nil {
+		t.Fatalf("Expire: %v", err)
+	}
+	m.Flush(expireAt)
+	m.Flush(expireAt.Add(time.Minute))
+	got = rec.take()
+	if len(got) != 1 || got[0].NodeName != "worker-01" {
+		t.Fatalf("after expiry notified %+v, want worker-01", got)
+	}
+}
+
+// TestAlertManagerSilencedByFollowsFlush runs the manager on a simulated clock
+// a day behind: the alert list must show the silence that held the
+// notification back, even though it ended long before the wall-clock time.
+func TestAlertManagerSilencedByFollowsFlush(t *testing.T) {
+	rec := &notifyRecorder{}
+	m := NewAlertManager(testAlertTimings(), rec.notify)
+	s := NewSilences(NewMemoryStore())
+	m.SetSilences(s)
+
+	now := time.Now().Add(-24 * time.Hour)
+	sil, err := s.Create(context.Background(), validSilence(now), now)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	m.Fire(gapAlert("worker-01", "critical", 45, now), now)
+	m.Flush(now.Add(30 * time.Second))
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("notified %+v, want none while silenced", got)
+	}
+
+	list := m.Alerts()
+	if len(list.Active) != 1 || !slices.Equal(list.Active[0].SilencedBy, []string{sil.ID}) {
+		t.Fatalf("Active = %+v, want worker-01 silenced by %s", list.Active, sil.ID)
+	}
+}
+
+func TestSilencesHandlers(t *testing.T) {
+	s := NewSilences(NewMemoryStore())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/silences", silencesHandler(s, nil))
+	mux.HandleFunc("/api/silences/{id}", silenceHandler(s))
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/silences", validSilence(time.Now()))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST = %d: %s", rec.Code, rec.Body)
+	}
+	var created Silence
+	json.NewDecoder(rec.Body).Decode(&created)
+
+	rec = do(http.MethodPost, "/api/silences", Silence{CreatedBy: "x", Comment: "y", EndsAt: time.Now().Add(time.Hour)})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "matcher") {
+		t.Errorf("invalid POST = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodGet, "/api/silences", nil)
+	var list []Silence
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("GET = %d %+v (%v)", rec.Code, list, err)
+	}
+
+	rec = do(http.MethodDelete, "/api/silences/"+created.ID, nil)
+	var expired Silence
+	json.NewDecoder(rec.Body).Decode(&expired)
+	if rec.Code != http.StatusOK || expired.State != silenceStateExpired {
+		t.Errorf("DELETE = %d %+v", rec.Code, expired)
+	}
+	if rec := do(http.MethodDelete, "/api/silences/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE missing = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/api/silences", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT = %d, want 405", rec.Code)
+	}
+}
+
+func TestSilencesHandlerStoreError(t *testing.T) {
+	s := NewSilences(&errorStore{saveErr: errors.New("disk full")})
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(validSilence(time.Now()))
+	rec := httptest.NewRecorder()
+	silencesHandler(s, nil)(rec, httptest.NewRequest(http.MethodPost, "/api/silences", &buf))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+// TestSilencesHandlerCheck models -lease-watch, where silences matching on
+// namespace are refused.
+func TestSilencesHandlerCheck(t *testing.T) {
+	s := NewSilences(NewMemoryStore())
+	handler := silencesHandler(s, Silence.rejectNamespaces)
+	post := func(sil Silence) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(sil)
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/silences", &buf))
+		return rec
+	}
+
+	sil := validSilence(time.Now())
+	sil.Matchers = append(sil.Matchers, SilenceMatcher{Name: matcherNamespace, Value: "batch"})
+	if rec := post(sil); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "match on node") {
+		t.Errorf("namespace POST = %d: %s, want 400 steering to node", rec.Code, rec.Body)
+	}
+	if list := s.List(time.Now()); len(list) != 0 {
+		t.Errorf("refused silence was created: %+v", list)
+	}
+	if rec := post(validSilence(time.Now())); rec.Code != http.StatusCreated {
+		t.Errorf("node POST = %d: %s", rec.Code, rec.Body)
+	}
+}
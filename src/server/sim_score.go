@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"earthworm/src/kubernetes"
+)
+
+// scoreMatchGrace is how long after a node resumes a detection still counts
+// toward its NotReady period, for sweeps and renewals that land up to an
+// interval and its jitter after it.
+const scoreMatchGrace = 15 * time.Second
+
+// Classification of a detection without a causal chain, or whose chain found
+// no cause.
+const scoreUnclassified = "unclassified"
+
+// scoreAlertTimings notify every gap alert as soon as it fires, escalates or
+// resolves, and never repeat it.
+var scoreAlertTimings = AlertTimings{RepeatInterval: math.MaxInt64}
+
+// rootCauseClasses maps the kind of a causal chain's root cause to the
+// simulated cause it identifies.
+var rootCauseClasses = map[string]kubernetes.NotReadyCause{
+	"critical_exit":            kubernetes.CauseKubeletRestart,
+	"oom_kill":                 kubernetes.CauseOOMKill,
+	"filesystem_io_bottleneck": kubernetes.CauseSlowDisk,
+	"dns_timeout":              kubernetes.CauseDNSOutage,
+	"network_degradation":      kubernetes.CauseNetworkPartition,
+}
+
+// slowSyscallClasses maps the process behind a slow_syscall root cause to
+// the simulated cause it identifies: a starved kubelet is throttled, a
+// stuck containerd is hung.
+var slowSyscallClasses = map[string]kubernetes.NotReadyCause{
+	"kubelet":    kubernetes.CauseCPUThrottling,
+	"containerd": kubernetes.CauseContainerdHang,
+}
+
+// ScoreReport scores what the detection pipeline found in a simulation
+// against the simulation's ground truth. Durations are in seconds.
+type ScoreReport struct {
+	Seed         int64   `json:"seed"`
+	Nodes        int     `json:"nodes"`
+	Duration     float64 `json:"durationSeconds"`
+	DetectorMode string  `json:"detectorMode"`
+
+	// Events are the true NotReady periods. A detection is a critical gap
+	// alert or a NotReady transition; all those within one event count as
+	// one detection of it, and those outside any as false positives, again
+	// once per burst. Warning alerts are only counted.
+	Events         int     `json:"events"`
+	Detected       int     `json:"detected"`
+	FalsePositives int     `json:"falsePositives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	Warnings       int     `json:"warnings"`
+
+	Causes       map[string]*CauseScore `json:"causes"`
+	TimeToDetect DurationSummary        `json:"timeToDetect"`
+	RootCause    RootCauseScore         `json:"rootCause"`
+	Predictions  PredictionScore        `json:"predictions"`
+}
+
+// CauseScore scores detection of one cause. Precision is over the
+// detections whose causal chain named the cause.
+type CauseScore struct {
+	Events       int             `json:"events"`
+	Detected     int             `json:"detected"`
+	Recall       float64         `json:"recall"`
+	Attributed   int             `json:"attributed"`
+	Correct      int             `json:"correct"`
+	Precision    float64         `json:"precision"`
+	TimeToDetect DurationSummary `json:"timeToDetect"`
+}
+
+// RootCauseScore compares the root cause of each detected event's causal
+// chain with its true cause.
+type RootCauseScore struct {
+	Classified int                       `json:"classified"` // detected events the chain named a cause for
+	Correct    int                       `json:"correct"`
+	Accuracy   float64                   `json:"accuracy"`  // correct over detected events
+	Confusion  map[string]map[string]int `json:"confusion"` // true cause → classified cause → events
+}
+
+// PredictionScore scores failure predictions. A prediction counts toward an
+// event on its node made from defaultLookbackWindow before it starts until
+// it ends; lead time is from the first such prediction to the start, and is
+// negative for predictions made once the node was already down.
+type PredictionScore struct {
+	Predictions      int             `json:"predictions"`
+	Predicted        int             `json:"predictedEvents"`
+	FalsePredictions int             `json:"falsePredictions"`
+	LeadTime         DurationSummary `json:"leadTime"`
+}
+
+// DurationSummary summarises a distribution of durations in seconds.
+type DurationSummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	Max   float64 `json:"max"`
+}
+
+func summarizeDurations(ds []time.Duration) DurationSummary {
+	if len(ds) == 0 {
+		return DurationSummary{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	at := func(q float64) float64 {
+		return ds[int(math.Ceil(q*float64(len(ds))))-1].Seconds()
+	}
+	return DurationSummary{
+		Count: len(ds),
+		Min:   ds[0].Seconds(),
+		Mean:  math.Round(sum.Seconds()/float64(len(ds))*1000) / 1000,
+		P50:   at(0.5),
+		P90:   at(0.9),
+		Max:   ds[len(ds)-1].Seconds(),
+	}
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(d)*10000) / 10000
+}
+
+// classifyRootCause returns the simulated cause a causal chain's root cause
+// identifies, or scoreUnclassified.
+func classifyRootCause(rootCause string) string {
+	kind, detail, _ := strings.Cut(rootCause, ":")
+	if cause, ok := rootCauseClasses[kind]; ok {
+		return string(cause)
+	}
+	if kind == "slow_syscall" {
+		comm, _, _ := strings.Cut(strings.TrimSpace(detail), " ")
+		if cause, ok := slowSyscallClasses[comm]; ok {
+			return string(cause)
+		}
+	}
+	return scoreUnclassified
+}
+
+// scoreSignal is one observation of a node being down: a gap alert or a
+// NotReady transition, at the simulated time it was raised.
+type scoreSignal struct {
+	node      string
+	at        time.Time
+	rootCause string
+}
+
+// scoreSimulation plays engine as fast as possible through a fresh
+// in-memory pipeline — anomaly detector with cfg's thresholds, policy and
+// detector mode, alert manager, node state tracker, causal chain builder and
+// prediction engine — and scores what it detected against the simulation's
+// ground truth. It replaces the pipeline's globals.
+func scoreSimulation(ctx context.Context, engine *kubernetes.SimulationEngine) (ScoreReport, error) {
+	var signals []scoreSignal
+	store = NewMemoryStore()
+	hub = nil
+	outageDetector = nil
+	topoMap = nil
+	detector = NewAnomalyDetector(store, cfg.WarningThresholdS, cfg.CriticalThresholdS)
+	if cfg.ThresholdPolicy != "" {
+		policy, err := LoadThresholdPolicyConfig(cfg.ThresholdPolicy)
+		if err != nil {
+			return ScoreReport{}, err
+		}
+		thresholdPolicy = NewThresholdPolicy(detector.Thresholds(), nil)
+		if err := thresholdPolicy.Set(policy); err != nil {
+			return ScoreReport{}, fmt.Errorf("threshold policy in %s: %w", cfg.ThresholdPolicy, err)
+		}
+		detector.SetPolicy(thresholdPolicy)
+	}
+	adaptiveDetector = nil
+	if cfg.DetectorMode != detectorModeThreshold && cfg.DetectorMode != "" {
+		adaptiveDetector = NewAdaptiveDetector(cfg.AdaptiveConfig())
+	}
+	var warnings int
+	dispatcher = NewAlertDispatcher("", func(a Alert) {
+		switch {
+		case a.Type != alertTypeHeartbeatGap:
+		case a.Severity == "critical":
+			signals = append(signals, scoreSignal{node: a.NodeName, at: a.Timestamp, rootCause: a.RootCause})
+		default:
+			warnings++
+		}
+	})
+	alertManager = NewAlertManager(scoreAlertTimings, dispatcher.Dispatch)
+	chainBuilder = NewCausalChainBuilder(store, nil)
+	predEngine = NewPredictionEngine(store, nil)
+	nodeTracker = NewNodeStateTracker(detector, chainBuilder, nil)
+
+	// Transitions and predictions carry wall-clock or backdated timestamps,
+	// so each is timed by the tick it first appears in
+	type prediction struct {
+		node string
+		at   time.Time
+	}
+	var predictions []prediction
+	lastTransition := make(map[string]time.Time)
+	for {
+		tick, ok := engine.Step()
+		if !ok {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return ScoreReport{}, err
+		}
+		ingestSimTick(ctx, tick)
+
+		for _, node := range nodeTracker.Nodes() {
+			transitions, _ := nodeTracker.Transitions(node)
+			for _, tr := range transitions {
+				if !tr.Timestamp.After(lastTransition[node]) {
+					continue
+				}
+				lastTransition[node] = tr.Timestamp
+				if tr.To == statusNotReady {
+					signals = append(signals, scoreSignal{node: node, at: tick.Time, rootCause: tr.RootCause})
+				}
+			}
+		}
+		all := predEngine.Predictions()
+		for _, p := range all[len(predictions):] {
+			predictions = append(predictions, prediction{node: p.NodeName, at: tick.Time})
+		}
+	}
+
+	truth := engine.GroundTruth()
+	report := ScoreReport{
+		Nodes:        len(engine.Nodes()),
+		DetectorMode: cfg.DetectorMode,
+		Events:       len(truth),
+		Causes:       make(map[string]*CauseScore),
+		RootCause:    RootCauseScore{Confusion: make(map[string]map[string]int)},
+		Warnings:     warnings,
+		Predictions:  PredictionScore{Predictions: len(predictions)},
+	}
+	causeScore := func(cause string) *CauseScore {
+		cs, ok := report.Causes[cause]
+		if !ok {
+			cs = &CauseScore{}
+			report.Causes[cause] = cs
+		}
+		return cs
+	}
+
+	// Match every signal and prediction to the event on its node it falls in
+	matchEvent := func(node string, at time.Time, before, after time.Duration) int {
+		for i, e := range truth {
+			if e.NodeName == node && !at.Before(e.Start.Add(-before)) && !at.After(e.End.Add(after)) {
+				return i
+			}
+		}
+		return -1
+	}
+	firstSignal := make(map[int]scoreSignal)
+	rootCauses := make(map[int]string)
+	lastFalse := make(map[string]time.Time)
+	var detectionTimes, leadTimes []time.Duration
+	causeTimes := make(map[string][]time.Duration)
+	sort.SliceStable(signals, func(i, j int) bool { return signals[i].at.Before(signals[j].at) })
+	for _, s := range signals {
+		i := matchEvent(s.node, s.at, 0, scoreMatchGrace)
+		if i < 0 {
+			last, ok := lastFalse[s.node]
+			lastFalse[s.node] = s.at
+			if !ok || s.at.Sub(last) > scoreMatchGrace {
+				report.FalsePositives++
+				causeScore(classifyRootCause(s.rootCause)).Attributed++
+			}
+			continue
+		}
+		if first, ok := firstSignal[i]; !ok || s.at.Before(first.at) {
+			firstSignal[i] = s
+		}
+		if _, ok := rootCauses[i]; !ok && s.rootCause != "" {
+			rootCauses[i] = s.rootCause
+		}
+	}
+	firstPrediction := make(map[int]time.Time)
+	for _, p := range predictions {
+		i := matchEvent(p.node, p.at, defaultLookbackWindow, 0)
+		if i < 0 {
+			report.Predictions.FalsePredictions++
+			continue
+		}
+		if first, ok := firstPrediction[i]; !ok || p.at.Before(first) {
+			firstPrediction[i] = p.at
+		}
+	}
+
+	for i, e := range truth {
+		cause := string(e.Cause)
+		cs := causeScore(cause)
+		cs.Events++
+		if at, ok := firstPrediction[i]; ok {
+			report.Predictions.Predicted++
+			leadTimes = append(leadTimes, e.Start.Sub(at))
+		}
+		first, ok := firstSignal[i]
+		if !ok {
+			continue
+		}
+		report.Detected++
+		cs.Detected++
+		ttd := first.at.Sub(e.Start)
+		detectionTimes = append(detectionTimes, ttd)
+		causeTimes[cause] = append(causeTimes[cause], ttd)
+
+		classified := classifyRootCause(rootCauses[i])
+		causeScore(classified).Attributed++
+		if classified != scoreUnclassified {
+			report.RootCause.Classified++
+		}
+		if classified == cause {
+			cs.Correct++
+			report.RootCause.Correct++
+		}
+		if report.RootCause.Confusion[cause] == nil {
+			report.RootCause.Confusion[cause] = make(map[string]int)
+		}
+		report.RootCause.Confusion[cause][classified]++
+	}
+
+	for cause, cs := range report.Causes {
+		cs.Recall = ratio(cs.Detected, cs.Events)
+		cs.Precision = ratio(cs.Correct, cs.Attributed)
+		cs.TimeToDetect = summarizeDurations(causeTimes[cause])
+	}
+	report.Precision = ratio(report.Detected, report.Detected+report.FalsePositives)
+	report.Recall = ratio(report.Detected, report.Events)
+	report.TimeToDetect = summarizeDurations(detectionTimes)
+	report.RootCause.Accuracy = ratio(report.RootCause.Correct, report.Detected)
+	report.Predictions.LeadTime = summarizeDurations(leadTimes)
+	return report, nil
+}
+
+// writeScoreReport scores the simulation described by simConfig and writes
+// the report as JSON to path, or to stdout when path is "-".
+func writeScoreReport(path string, simConfig kubernetes.SimulationConfig) error {
+	engine, err := kubernetes.NewSimulationEngine(simConfig)
+	if err != nil {
+		return err
+	}
+	report, err := scoreSimulation(context.Background(), engine)
+	if err != nil {
+		return err
+	}
+	report.Seed = simConfig.Seed
+	report.Duration = simConfig.Duration.Seconds()
+
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
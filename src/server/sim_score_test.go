@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"earthworm/src/kubernetes"
+)
+
+func TestSummarizeDurations(t *testing.T) {
+	var ds []time.Duration
+	for i := 10; i >= 1; i-- {
+		ds = append(ds, time.Duration(i)*time.Second)
+	}
+	got := summarizeDurations(ds)
+	want := DurationSummary{Count: 10, Min: 1, Mean: 5.5, P50: 5, P90: 9, Max: 10}
+	if got != want {
+		t.Errorf("summarizeDurations = %+v, want %+v", got, want)
+	}
+	if got := summarizeDurations(nil); got != (DurationSummary{}) {
+		t.Errorf("empty = %+v", got)
+	}
+}
+
+func TestClassifyRootCause(t *testing.T) {
+	for rootCause, want := range map[string]string{
+		"critical_exit: kubelet (pid=42, exit_code=1)":               "kubelet_restart",
+		"oom_kill: kubelet (killed_pid=7)":                           "oom_kill",
+		"network_degradation: high RTT 600000us":                     "network_partition",
+		"filesystem_io_bottleneck: /var/lib/x (latency=200000000ns)": "slow_disk",
+		"dns_timeout: api.cluster.local":                             "dns_outage",
+		"slow_syscall: kubelet (latency=1200000000ns)":               "cpu_throttling",
+		"slow_syscall: containerd (latency=2000000000ns)":            "containerd_hang",
+		"slow_syscall: etcd (latency=2000000000ns)":                  scoreUnclassified,
+		"unknown_cause": scoreUnclassified,
+		"":              scoreUnclassified,
+	} {
+		if got := classifyRootCause(rootCause); got != want {
+			t.Errorf("classifyRootCause(%q) = %q, want %q", rootCause, got, want)
+		}
+	}
+}
+
+// TestScoreSimulation scores a partition of stable nodes: every partitioned
+// node is detected once the critical threshold passes, with a causal chain
+// that blames the network, and nothing else is.
+func TestScoreSimulation(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origCfg, origManager, origTracker, origAdaptive, origOutage, origPolicy := cfg, alertManager, nodeTracker, adaptiveDetector, outageDetector, thresholdPolicy
+	defer func() {
+		cfg, alertManager, nodeTracker, adaptiveDetector, outageDetector, thresholdPolicy = origCfg, origManager, origTracker, origAdaptive, origOutage, origPolicy
+	}()
+	cfg = LoadConfig()
+
+	simConfig := kubernetes.SimulationConfig{
+		NodeCount:         10,
+		Duration:          6 * time.Minute,
+		Seed:              7,
+		NamespaceRatios:   map[string]float64{"default": 1},
+		NamespaceProfiles: map[string]kubernetes.NodeHealthProfile{"default": kubernetes.ProfileStable},
+		Scenarios: []kubernetes.ScenarioConfig{
+			{Type: "network_partition", TriggerAt: 2 * time.Minute, NodeCount: 4, Duration: 2 * time.Minute},
+		},
+	}
+	file := filepath.Join(t.TempDir(), "score.json")
+	if err := writeScoreReport(file, simConfig); err != nil {
+		t.Fatalf("writeScoreReport: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report ScoreReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+
+	if report.Seed != 7 || report.Nodes != 10 || report.Duration != 360 {
+		t.Errorf("run = seed %d, %d nodes, %vs", report.Seed, report.Nodes, report.Duration)
+	}
+	if report.Events != 4 || report.Detected != 4 || report.FalsePositives != 0 || report.Precision != 1 || report.Recall != 1 {
+		t.Errorf("detection = %d/%d events, %d false positives", report.Detected, report.Events, report.FalsePositives)
+	}
+	partition := report.Causes["network_partition"]
+	if partition == nil || partition.Recall != 1 || partition.Precision != 1 || partition.Correct != 4 {
+		t.Errorf("network_partition = %+v", partition)
+	}
+	if ttd := report.TimeToDetect; ttd.Count != 4 || ttd.Min < 30 || ttd.Max > 45 {
+		t.Errorf("time to detect = %+v, want 30-45s", ttd)
+	}
+	if rc := report.RootCause; rc.Accuracy != 1 || rc.Confusion["network_partition"]["network_partition"] != 4 {
+		t.Errorf("root cause = %+v", rc)
+	}
+	if p := report.Predictions; p.FalsePredictions != 0 || p.Predicted > 4 {
+		t.Errorf("predictions = %+v", p)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"earthworm/src/kubernetes"
+)
+
+// heartbeatFromSimLease converts a simulated lease renewal into a Heartbeat.
+func heartbeatFromSimLease(l kubernetes.LeaseEvent) Heartbeat {
+	return Heartbeat{
+		NodeName:  l.NodeName,
+		Namespace: l.Namespace,
+		Timestamp: l.Timestamp,
+		Status:    "Ready",
+	}
+}
+
+// kernelEventFromSim converts a simulated eBPF event into the EnrichedEvent
+// the agent would report for it: lease writes are syscalls, a kubelet exit
+// is a critical process exit, an OOM reaper kill is memory pressure, events
+// with a NetEventType are network events and extended events keep their
+// EventType and fields.
+func kernelEventFromSim(e kubernetes.SimEbpfEvent) EnrichedEvent {
+	event := EnrichedEvent{
+		Timestamp:   e.Timestamp,
+		PID:         e.PID,
+		PPID:        e.PPID,
+		Comm:        e.Comm,
+		EventType:   "syscall",
+		ReturnValue: e.ReturnValue,
+		LatencyNs:   e.LatencyNs,
+		SlowSyscall: e.SlowSyscall,
+		Namespace:   e.Namespace,
+		NodeName:    e.NodeName,
+		HostLevel:   true,
+	}
+	switch {
+	case e.EventType != "":
+		event.EventType = e.EventType
+		event.FilePath = e.FilePath
+		event.IOLatencyNs = e.IOLatencyNs
+		event.BytesXfer = e.BytesXfer
+		event.SlowIO = e.SlowIO
+		event.IOOpType = e.IOOpType
+		event.Domain = e.Domain
+		event.DNSLatencyNs = e.DNSLatencyNs
+		event.TimedOut = e.TimedOut
+		event.CPUUsageNs = e.CPUUsageNs
+		event.MemoryUsageBytes = e.MemoryUsageBytes
+		event.MemoryLimitBytes = e.MemoryLimitBytes
+		event.MemoryPressure = e.MemoryPressure
+		event.AuditDstAddr = e.AuditDstAddr
+		event.AuditDstPort = e.AuditDstPort
+		event.AuditProtocol = e.AuditProtocol
+	case e.NetEventType != "":
+		event.EventType = "network"
+		event.NetEventType = e.NetEventType
+		event.RTTUs = e.RTTUs
+	case e.Syscall == "exit":
+		event.EventType = "process"
+		event.ExitCode = 1
+		event.CriticalExit = e.Comm == "kubelet"
+	case e.Syscall == "fork":
+		event.EventType = "process"
+		event.ChildPID = e.PID
+		event.PID = e.PPID
+	case e.Syscall == "kill" && e.Comm == "oom_reaper":
+		event.EventType = "memory_pressure"
+		event.OOMSubType = "oom_kill"
+		event.KilledPID = e.PID
+		event.KilledComm = "kubelet"
+	}
+	return event
+}
+
+// runSimulationSource plays engine at the given time compression and feeds
+// every tick through ingestSimTick. Blocks until the simulation ends or ctx
+// is cancelled.
+func runSimulationSource(ctx context.Context, engine *kubernetes.SimulationEngine, compression float64) error {
+	for tick := range engine.Stream(ctx, compression) {
+		ingestSimTick(ctx, tick)
+	}
+	return ctx.Err()
+}
+
+// ingestSimTick feeds a tick's eBPF events and lease renewals through
+// ingestKernelEvent and ingestHeartbeatAt, kernel events first so causal chains
+// can see them. Heartbeat and outage sweeps run every defaultSweepInterval
+// of simulated time and the AlertManager flushes every tick, so nodes that
+// stop renewing go NotReady and alert on the simulation's clock rather than
+// the wall clock, whatever the replay speed.
+func ingestSimTick(ctx context.Context, tick kubernetes.SimTick) {
+	for _, e := range tick.Ebpf {
+		if err := ingestKernelEvent(ctx, kernelEventFromSim(e)); err != nil {
+			log.Printf("Failed to ingest simulated kernel event for %s: %v", e.NodeName, err)
+		}
+	}
+	for _, l := range tick.Leases {
+		if err := ingestHeartbeatAt(ctx, heartbeatFromSimLease(l), tick.Time); err != nil {
+			log.Printf("Failed to ingest simulated heartbeat for %s: %v", l.NodeName, err)
+		}
+	}
+	if tick.Elapsed%defaultSweepInterval == 0 {
+		sweepHeartbeats(tick.Time)
+		if outageDetector != nil && alertManager != nil {
+			outageDetector.Sweep(alertManager, tick.Time)
+		}
+	}
+	if alertManager != nil {
+		alertManager.Flush(tick.Time)
+	}
+}
+
+// simulationConfig returns the simulation in file, or without one a
+// simulation of nodes over duration with a rolling deployment half an hour
+// in when it fits. A zero seed is replaced with a time-based one so that
+// every engine built from the config plays the same simulation.
+func simulationConfig(file string, nodes int, duration time.Duration, seed int64) (kubernetes.SimulationConfig, error) {
+	simConfig := kubernetes.SimulationConfig{
+		NodeCount: nodes,
+		Duration:  duration,
+		Seed:      seed,
+	}
+	if file != "" {
+		loaded, err := kubernetes.LoadSimulationConfig(file)
+		if err != nil {
+			return simConfig, err
+		}
+		simConfig = loaded
+	} else if duration > 30*time.Minute {
+		simConfig.Scenarios = []kubernetes.ScenarioConfig{
+			{
+				Type:             "rolling_deployment",
+				TriggerAt:        30 * time.Minute,
+				NodeCount:        5,
+				StaggerInterval:  30 * time.Second,
+				ReplacementDelay: 15 * time.Second,
+			},
+		}
+	}
+	if simConfig.Seed == 0 {
+		simConfig.Seed = time.Now().UnixNano()
+	}
+	return simConfig, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"earthworm/src/kubernetes"
+)
+
+func TestKernelEventFromSim(t *testing.T) {
+	ts := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	sim := func(comm, syscall, netEventType string) kubernetes.SimEbpfEvent {
+		return kubernetes.SimEbpfEvent{Timestamp: ts, PID: 42, PPID: 1, Comm: comm, Syscall: syscall,
+			NodeName: "node-01", Namespace: "default", NetEventType: netEventType, RTTUs: 600_000}
+	}
+
+	if e := kernelEventFromSim(sim("kubelet", "write", "")); e.EventType != "syscall" || e.NodeName != "node-01" || !e.Timestamp.Equal(ts) {
+		t.Errorf("write = %+v", e)
+	}
+	if e := kernelEventFromSim(sim("kubelet", "exit", "")); e.EventType != "process" || !e.CriticalExit || e.ExitCode == 0 {
+		t.Errorf("kubelet exit = %+v", e)
+	}
+	if e := kernelEventFromSim(sim("kubelet", "fork", "")); e.EventType != "process" || e.ChildPID != 42 || e.PID != 1 {
+		t.Errorf("fork = %+v", e)
+	}
+	if e := kernelEventFromSim(sim("oom_reaper", "kill", "")); e.EventType != "memory_pressure" || e.OOMSubType != "oom_kill" || e.KilledPID != 42 {
+		t.Errorf("oom kill = %+v", e)
+	}
+	if e := kernelEventFromSim(sim("kubelet", "tcp_rcv_established", "rtt_high")); e.EventType != "network" || e.NetEventType != "rtt_high" || e.RTTUs != 600_000 {
+		t.Errorf("rtt_high = %+v", e)
+	}
+
+	dns := sim("kubelet", "udp_sendmsg", "")
+	dns.EventType, dns.Domain, dns.DNSLatencyNs, dns.TimedOut = "dns_resolution", "api.cluster.local", 5_000_000_000, true
+	if e := kernelEventFromSim(dns); e.EventType != "dns_resolution" || e.Domain != "api.cluster.local" || !e.TimedOut || e.DNSLatencyNs == 0 {
+		t.Errorf("dns timeout = %+v", e)
+	}
+	futex := sim("containerd", "futex", "")
+	futex.LatencyNs, futex.SlowSyscall = 2_000_000_000, true
+	if e := kernelEventFromSim(futex); e.EventType != "syscall" || !e.SlowSyscall || e.LatencyNs != 2_000_000_000 {
+		t.Errorf("slow futex = %+v", e)
+	}
+}
+
+// TestSimulatedCauseSignatures checks that the kernel events the simulator
+// produces for each cause are what the pipeline needs to explain and
+// predict it: the window a causal chain would look at names the cause, and
+// between them the causes exercise every root cause and prediction pattern.
+func TestSimulatedCauseSignatures(t *testing.T) {
+	engine, err := kubernetes.NewSimulationEngine(kubernetes.SimulationConfig{
+		NodeCount:         200,
+		Duration:          time.Hour,
+		Seed:              11,
+		NamespaceRatios:   map[string]float64{"default": 1},
+		NamespaceProfiles: map[string]kubernetes.NodeHealthProfile{"default": kubernetes.ProfileVolatile},
+		Scenarios: []kubernetes.ScenarioConfig{
+			{Type: "network_partition", TriggerAt: 10 * time.Minute, NodeCount: 3, Duration: time.Minute},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := engine.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(map[string][]EnrichedEvent)
+	for _, node := range engine.Nodes() {
+		for _, e := range node.EbpfEvents {
+			events[node.Name] = append(events[node.Name], kernelEventFromSim(e))
+		}
+	}
+
+	wantPatterns := map[kubernetes.NotReadyCause][]string{
+		kubernetes.CauseKubeletRestart:   {"critical_exit"},
+		kubernetes.CauseOOMKill:          {"memory_pressure_escalation"},
+		kubernetes.CauseSlowDisk:         {"filesystem_io_degradation"},
+		kubernetes.CauseDNSOutage:        {"dns_resolution_degradation"},
+		kubernetes.CauseCPUThrottling:    {"syscall_latency_trend"},
+		kubernetes.CauseContainerdHang:   {"syscall_latency_trend"},
+		kubernetes.CauseNetworkPartition: {"retransmit_spike", "high_rtt"},
+		kubernetes.CauseNetworkBlip:      nil,
+		kubernetes.CauseDiskPressure:     nil,
+	}
+	causes := make(map[kubernetes.NotReadyCause]bool)
+	rootCauses := make(map[string]bool)
+	patterns := make(map[string]bool)
+	pe := NewPredictionEngine(nil, nil)
+	for _, truth := range result.GroundTruth {
+		if truth.Cause == kubernetes.CauseDrain {
+			continue
+		}
+		causes[truth.Cause] = true
+		// The node is detected NotReady 30–45s into the event
+		from, to := truth.Start.Add(-defaultLookbackWindow), truth.Start.Add(45*time.Second)
+		var window []EnrichedEvent
+		for _, e := range events[truth.NodeName] {
+			if !e.Timestamp.Before(from) && !e.Timestamp.After(to) {
+				window = append(window, e)
+			}
+		}
+
+		rootCause := detectRootCause(window)
+		kind, _, _ := strings.Cut(rootCause, ":")
+		rootCauses[kind] = true
+		want := string(truth.Cause)
+		if wantPatterns[truth.Cause] == nil {
+			want = scoreUnclassified
+		}
+		if got := classifyRootCause(rootCause); got != want {
+			t.Errorf("%s %s at %v: root cause %q", truth.NodeName, truth.Cause, truth.Start, rootCause)
+		}
+
+		found := make(map[string]bool)
+		if pred := pe.Analyze(truth.NodeName, window); pred != nil {
+			for _, p := range pred.Patterns {
+				found[p] = true
+				patterns[p] = true
+			}
+		}
+		for _, p := range wantPatterns[truth.Cause] {
+			if !found[p] {
+				t.Errorf("%s %s at %v: no %s prediction", truth.NodeName, truth.Cause, truth.Start, p)
+			}
+		}
+	}
+
+	for cause := range wantPatterns {
+		if !causes[cause] {
+			t.Errorf("the simulation has no %s event", cause)
+		}
+	}
+	for _, kind := range []string{"critical_exit", "oom_kill", "filesystem_io_bottleneck", "dns_timeout", "slow_syscall", "network_degradation", "unknown_cause"} {
+		if !rootCauses[kind] {
+			t.Errorf("no event explained by %s", kind)
+		}
+	}
+	for _, p := range []string{"syscall_latency_trend", "retransmit_spike", "critical_exit", "high_rtt", "filesystem_io_degradation", "memory_pressure_escalation", "dns_resolution_degradation"} {
+		if !patterns[p] {
+			t.Errorf("no event predicted by %s", p)
+		}
+	}
+}
+
+// TestSimulationSource_NetworkPartition replays a partition through the full
+// ingestion pipeline as fast as possible. Every simulated lease is stored,
+// and each partitioned node goes NotReady on the simulated clock with a
+// causal chain that blames the network, and its gap alert fires and resolves
+// on that clock too.
+func TestSimulationSource_NetworkPartition(t *testing.T) {
+	cleanup := setupIntegrationGlobals()
+	defer cleanup()
+	origTracker := nodeTracker
+	nodeTracker = NewNodeStateTracker(detector, chainBuilder, hub)
+	defer func() { nodeTracker = origTracker }()
+	origAlertManager := alertManager
+	alertManager = NewAlertManager(testAlertTimings(), func(Alert) {})
+	defer func() { alertManager = origAlertManager }()
+
+	engine, err := kubernetes.NewSimulationEngine(kubernetes.SimulationConfig{
+		NodeCount:         10,
+		Duration:          6 * time.Minute,
+		Seed:              7,
+		NamespaceRatios:   map[string]float64{"default": 1},
+		NamespaceProfiles: map[string]kubernetes.NodeHealthProfile{"default": kubernetes.ProfileStable},
+		Scenarios: []kubernetes.ScenarioConfig{
+			{Type: "network_partition", TriggerAt: 2 * time.Minute, NodeCount: 4, Duration: 2 * time.Minute},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := runSimulationSource(ctx, engine, 0); err != nil {
+		t.Fatalf("runSimulationSource: %v", err)
+	}
+
+	var leases int
+	var partitioned []*kubernetes.SimNode
+	for _, node := range engine.Nodes() {
+		leases += len(node.LeaseHistory)
+		if len(node.TransitionHistory) > 0 && node.TransitionHistory[0].Cause == kubernetes.CauseNetworkPartition {
+			partitioned = append(partitioned, node)
+		}
+	}
+	hbs, _ := store.GetByTimeRange(ctx, time.Time{}, time.Now().Add(24*time.Hour))
+	if len(hbs) != leases {
+		t.Errorf("stored %d heartbeats, simulation renewed %d leases", len(hbs), leases)
+	}
+	if len(partitioned) != 4 {
+		t.Fatalf("%d nodes partitioned, want 4", len(partitioned))
+	}
+
+	for _, node := range partitioned {
+		start := node.TransitionHistory[0].Timestamp
+		transitions, _ := nodeTracker.Transitions(node.Name)
+		var down *NodeTransition
+		for i := range transitions {
+			if transitions[i].To == statusNotReady {
+				down = &transitions[i]
+				break
+			}
+		}
+		if down == nil {
+			t.Errorf("%s: no NotReady transition in %+v", node.Name, transitions)
+			continue
+		}
+		if lag := down.Timestamp.Sub(start); lag < 30*time.Second || lag > time.Minute {
+			t.Errorf("%s: NotReady %v after the partition started", node.Name, lag)
+		}
+		if !strings.HasPrefix(down.RootCause, "network_degradation") {
+			t.Errorf("%s: root cause %q, want network_degradation", node.Name, down.RootCause)
+		}
+		if status, _ := nodeTracker.Status(node.Name); status != statusReady {
+			t.Errorf("%s: status %q after the partition healed", node.Name, status)
+		}
+	}
+
+	resolved := make(map[string]Alert)
+	for _, a := range alertManager.Alerts().Resolved {
+		if a.Type == alertTypeHeartbeatGap {
+			resolved[a.NodeName] = a
+		}
+	}
+	for _, node := range partitioned {
+		start := node.TransitionHistory[0].Timestamp
+		a, ok := resolved[node.Name]
+		if !ok {
+			t.Errorf("%s: no resolved gap alert", node.Name)
+			continue
+		}
+		if a.StartsAt.Before(start) || a.EndsAt == nil || a.EndsAt.Sub(start) < time.Minute || a.EndsAt.Sub(start) > 3*time.Minute {
+			t.Errorf("%s: gap alert from %v to %v, want it within the partition from %v", node.Name, a.StartsAt, a.EndsAt, start)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteCompactInterval is how often SQLiteStore deletes data older than its
+// retention.
+const sqliteCompactInterval = 10 * time.Minute
+
+// sqliteMigrations are the schema versions of a SQLite store, in order.
+// Version N is sqliteMigrations[N-1]; released migrations must never change.
+// Records are stored as JSON alongside the columns they are looked up by,
+// with timestamps in Unix nanoseconds.
+var sqliteMigrations = []string{
+	`CREATE TABLE heartbeats (
+		id        INTEGER PRIMARY KEY,
+		node_name TEXT    NOT NULL,
+		ts        INTEGER NOT NULL,
+		data      TEXT    NOT NULL
+	);
+	CREATE INDEX heartbeats_ts ON heartbeats (ts);
+	CREATE INDEX heartbeats_node_ts ON heartbeats (node_name, ts);
+
+	CREATE TABLE kernel_events (
+		id         INTEGER PRIMARY KEY,
+		node_name  TEXT    NOT NULL,
+		event_type TEXT    NOT NULL,
+		ts         INTEGER NOT NULL,
+		data       TEXT    NOT NULL
+	);
+	CREATE INDEX kernel_events_ts ON kernel_events (ts);
+	CREATE INDEX kernel_events_node_ts ON kernel_events (node_name, ts);
+	CREATE INDEX kernel_events_node_type_ts ON kernel_events (node_name, event_type, ts);
+
+	CREATE TABLE causal_chains (
+		id        INTEGER PRIMARY KEY,
+		node_name TEXT    NOT NULL,
+		ts        INTEGER NOT NULL,
+		data      TEXT    NOT NULL
+	);
+	CREATE INDEX causal_chains_ts ON causal_chains (ts);
+	CREATE INDEX causal_chains_node_ts ON causal_chains (node_name, ts);
+
+	CREATE TABLE silences (
+		id   TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	);`,
+
+	// Heartbeat queries filter on namespace and status
+	`ALTER TABLE heartbeats ADD COLUMN namespace TEXT NOT NULL DEFAULT '';
+	ALTER TABLE heartbeats ADD COLUMN status TEXT NOT NULL DEFAULT '';
+	UPDATE heartbeats SET
+		namespace = COALESCE(json_extract(data, '$.namespace'), ''),
+		status = COALESCE(json_extract(data, '$.status'), '');`,
+}
+
+// SQLiteStore implements the Store interface in a single SQLite database
+// file. Heartbeats, kernel events and causal chains older than the retention
+// are compacted away in the background; silences are kept.
+type SQLiteStore struct {
+	db        *sql.DB
+	retention time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewSQLiteStore opens or creates the database at path, brings its schema up
+// to date and starts compacting data older than retention. Use ":memory:"
+// for a throwaway database.
+func NewSQLiteStore(path string, retention time.Duration) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	// SQLite allows one writer at a time; a single connection also keeps
+	// an in-memory database alive and shared.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{
+		db:        db,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	ctx := context.Background()
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", path, err)
+	}
+	if err := s.Compact(ctx, time.Now()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("compact %s: %w", path, err)
+	}
+	go compactEvery(sqliteCompactInterval, s.Compact, s.stop, s.done)
+	return s, nil
+}
+
+// migrate applies the migrations the database has not seen yet, each in its
+// own transaction.
+func (s *SQLiteStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("schema version %d is newer than this server's %d", version, len(sqliteMigrations))
+	}
+	for v := version + 1; v <= len(sqliteMigrations); v++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, v, time.Now().UnixNano()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("version %d: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version of the database.
+func (s *SQLiteStore) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Compact deletes heartbeats, kernel events and causal chains older than
+// the retention at now.
+func (s *SQLiteStore) Compact(ctx context.Context, now time.Time) error {
+	cutoff := now.Add(-s.retention).UnixNano()
+	for _, table := range []string{"heartbeats", "kernel_events", "causal_chains"} {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE ts < ?`, cutoff); err != nil {
+			return fmt.Errorf("compact %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// Close stops compaction and closes the database.
+func (s *SQLiteStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, event Heartbeat) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal heartbeat: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO heartbeats (node_name, namespace, status, ts, data) VALUES (?, ?, ?, ?, ?)`,
+		event.NodeName, event.Namespace, event.Status, event.Timestamp.UnixNano(), string(data))
+	return err
+}
+
+func (s *SQLiteStore) GetByTimeRange(ctx context.Context, from, to time.Time) ([]Heartbeat, error) {
+	return sqliteQuery[Heartbeat](ctx, s.db, `SELECT data FROM heartbeats WHERE ts BETWEEN ? AND ? ORDER BY ts, id`,
+		from.UnixNano(), to.UnixNano())
+}
+
+func (s *SQLiteStore) GetLatestByNode(ctx context.Context, nodeName string) (*Heartbeat, error) {
+	hbs, err := sqliteQuery[Heartbeat](ctx, s.db, `SELECT data FROM heartbeats WHERE node_name = ? ORDER BY ts DESC, id DESC LIMIT 1`,
+		nodeName)
+	if err != nil || len(hbs) == 0 {
+		return nil, err
+	}
+	return &hbs[0], nil
+}
+
+func (s *SQLiteStore) QueryHeartbeats(ctx context.Context, q HeartbeatQuery) (HeartbeatPage, error) {
+	c, err := q.cursor()
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	from, to := q.bounds(c)
+	where, args := sqlHeartbeatFilter(q, from.UnixNano(), to.UnixNano(), nil, sqlitePlaceholder)
+	if c != nil {
+		// ts is at least the cursor's already
+		where += ` AND (ts > ? OR node_name >= ?)`
+		args = append(args, c.Timestamp, c.NodeName)
+	}
+	hbs, err := sqliteQuery[Heartbeat](ctx, s.db, `SELECT data FROM heartbeats WHERE `+where+` ORDER BY ts, node_name, id LIMIT ?`,
+		append(args, q.need(c))...)
+	if err != nil {
+		return HeartbeatPage{}, err
+	}
+	return pageHeartbeats(hbs, q, c), nil
+}
+
+// DownsampleHeartbeats buckets heartbeats in SQL. Gaps are computed over
+// the filtered heartbeats, before grouping, so a gap that spans buckets
+// counts in the later one.
+func (s *SQLiteStore) DownsampleHeartbeats(ctx context.Context, q HeartbeatQuery, step time.Duration) ([]HeartbeatBucket, error) {
+	from, to := q.bounds(nil)
+	where, args := sqlHeartbeatFilter(q, from.UnixNano(), to.UnixNano(), []any{int64(step)}, sqlitePlaceholder)
+	rows, err := s.db.QueryContext(ctx, `SELECT node_name, ts - ts % ? AS start, COUNT(*), MIN(ts), MAX(ts), MIN(gap), MAX(gap)
+		FROM (
+			SELECT node_name, ts, ts - LAG(ts) OVER (PARTITION BY node_name ORDER BY ts, id) AS gap
+			FROM heartbeats WHERE `+where+`
+		)
+		GROUP BY node_name, start ORDER BY start, node_name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []HeartbeatBucket{}
+	for rows.Next() {
+		var b HeartbeatBucket
+		var start, first, last int64
+		var minGap, maxGap sql.NullInt64
+		if err := rows.Scan(&b.NodeName, &start, &b.Count, &first, &last, &minGap, &maxGap); err != nil {
+			return nil, err
+		}
+		b.Start, b.First, b.Last = time.Unix(0, start).UTC(), time.Unix(0, first).UTC(), time.Unix(0, last).UTC()
+		b.MinGapSeconds = time.Duration(minGap.Int64).Seconds()
+		b.MaxGapSeconds = time.Duration(maxGap.Int64).Seconds()
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// sqlitePlaceholder returns ?; arguments are bound in order.
+func sqlitePlaceholder(int) string {
+	return "?"
+}
+
+func (s *SQLiteStore) Nodes(ctx context.Context) ([]string, error) {
+	return queryNodeNames(ctx, s.db, sqlNodesQuery+` ORDER BY node_name`)
+}
+
+func (s *SQLiteStore) HasNode(ctx context.Context, nodeName string) (bool, error) {
+	var ok bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM heartbeats WHERE node_name = ?)
+		OR EXISTS (SELECT 1 FROM kernel_events WHERE node_name = ?)`, nodeName, nodeName).Scan(&ok)
+	return ok, err
+}
+
+// sqlNodesQuery selects the distinct node names of heartbeats and kernel
+// events. Each table's names are walked through its (node_name, ts) index
+// one name at a time, so the query costs a lookup per node rather than a
+// read of every row.
+const sqlNodesQuery = `WITH RECURSIVE
+	heartbeat_nodes(node_name) AS (
+		SELECT MIN(node_name) FROM heartbeats
+		UNION ALL
+		SELECT (SELECT MIN(node_name) FROM heartbeats WHERE node_name > h.node_name)
+		FROM heartbeat_nodes h WHERE h.node_name IS NOT NULL
+	),
+	kernel_event_nodes(node_name) AS (
+		SELECT MIN(node_name) FROM kernel_events
+		UNION ALL
+		SELECT (SELECT MIN(node_name) FROM kernel_events WHERE node_name > k.node_name)
+		FROM kernel_event_nodes k WHERE k.node_name IS NOT NULL
+	)
+	SELECT node_name FROM heartbeat_nodes WHERE node_name IS NOT NULL
+	UNION
+	SELECT node_name FROM kernel_event_nodes WHERE node_name IS NOT NULL`
+
+// queryNodeNames runs a query selecting node names.
+func queryNodeNames(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []string{}
+	for rows.Next() {
+		var node string
+		if err := rows.Scan(&node); err != nil {
+			return nil, err
+		}
+		result = append(result, node)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStore) SaveKernelEvent(ctx context.Context, event EnrichedEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal kernel event: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO kernel_events (node_name, event_type, ts, data) VALUES (?, ?, ?, ?)`,
+		event.NodeName, event.EventType, event.Timestamp.UnixNano(), string(data))
+	return err
+}
+
+func (s *SQLiteStore) GetKernelEvents(ctx context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error) {
+	return sqliteQuery[EnrichedEvent](ctx, s.db, `SELECT data FROM kernel_events WHERE node_name = ? AND ts BETWEEN ? AND ? ORDER BY ts, id`,
+		nodeName, from.UnixNano(), to.UnixNano())
+}
+
+func (s *SQLiteStore) GetKernelEventsByType(ctx context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error) {
+	return sqliteQuery[EnrichedEvent](ctx, s.db, `SELECT data FROM kernel_events WHERE node_name = ? AND event_type = ? AND ts BETWEEN ? AND ? ORDER BY ts, id`,
+		nodeName, eventType, from.UnixNano(), to.UnixNano())
+}
+
+func (s *SQLiteStore) CountKernelEvents(ctx context.Context, nodeName string, from, to time.Time) (map[string]map[string]int, error) {
+	query, args := `SELECT node_name, event_type, COUNT(*) FROM kernel_events WHERE ts BETWEEN ? AND ?`, []any{from.UnixNano(), to.UnixNano()}
+	if nodeName != "" {
+		query, args = query+` AND node_name = ?`, append(args, nodeName)
+	}
+	return queryKernelEventCounts(ctx, s.db, query+` GROUP BY node_name, event_type`, args...)
+}
+
+// queryKernelEventCounts runs a query selecting node names, event types and
+// counts.
+func queryKernelEventCounts(ctx context.Context, db *sql.DB, query string, args ...any) (map[string]map[string]int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]map[string]int)
+	for rows.Next() {
+		var node, eventType string
+		var n int
+		if err := rows.Scan(&node, &eventType, &n); err != nil {
+			return nil, err
+		}
+		if result[node] == nil {
+			result[node] = make(map[string]int)
+		}
+		result[node][eventType] = n
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLiteStore) SaveCausalChain(ctx context.Context, chain CausalChain) error {
+	data, err := json.Marshal(chain)
+	if err != nil {
+		return fmt.Errorf("marshal causal chain: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO causal_chains (node_name, ts, data) VALUES (?, ?, ?)`,
+		chain.NodeName, chain.Timestamp.UnixNano(), string(data))
+	return err
+}
+
+func (s *SQLiteStore) GetCausalChains(ctx context.Context, nodeName string, from, to time.Time) ([]CausalChain, error) {
+	return sqliteQuery[CausalChain](ctx, s.db, `SELECT data FROM causal_chains WHERE node_name = ? AND ts BETWEEN ? AND ? ORDER BY ts, id`,
+		nodeName, from.UnixNano(), to.UnixNano())
+}
+
+func (s *SQLiteStore) GetLatestCausalChain(ctx context.Context, nodeName string) (*CausalChain, error) {
+	chains, err := sqliteQuery[CausalChain](ctx, s.db, `SELECT data FROM causal_chains WHERE node_name = ? ORDER BY ts DESC, id DESC LIMIT 1`,
+		nodeName)
+	if err != nil || len(chains) == 0 {
+		return nil, err
+	}
+	return &chains[0], nil
+}
+
+func (s *SQLiteStore) SaveSilence(ctx context.Context, silence Silence) error {
+	data, err := json.Marshal(silence)
+	if err != nil {
+		return fmt.Errorf("marshal silence: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO silences (id, data) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data`,
+		silence.ID, string(data))
+	return err
+}
+
+func (s *SQLiteStore) GetSilences(ctx context.Context) ([]Silence, error) {
+	result, err := sqliteQuery[Silence](ctx, s.db, `SELECT data FROM silences`)
+	if result == nil && err == nil {
+		result = []Silence{}
+	}
+	return result, err
+}
+
+func (s *SQLiteStore) DeleteSilence(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM silences WHERE id = ?`, id)
+	return err
+}
+
+// sqliteQuery runs a query selecting one JSON column and decodes each row
+// into a T.
+func sqliteQuery[T any](ctx context.Context, db *sql.DB, query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []T
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var v T
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			return nil, fmt.Errorf("decode %T: %w", v, err)
+		}
+		result = append(result, v)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLiteStore(t, ":memory:")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := s.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	for i, node := range []string{"node-a", "node-b", "node-a"} {
+		hb := Heartbeat{NodeName: node, Namespace: "default", Timestamp: now.Add(time.Duration(i) * time.Second), Status: "Ready"}
+		if err := s.Save(ctx, hb); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	hbs, err := s.GetByTimeRange(ctx, now, now.Add(time.Second))
+	if err != nil || len(hbs) != 2 || hbs[0].NodeName != "node-a" || hbs[1].NodeName != "node-b" {
+		t.Errorf("GetByTimeRange = %+v, %v", hbs, err)
+	}
+	latest, err := s.GetLatestByNode(ctx, "node-a")
+	if err != nil || latest == nil || !latest.Timestamp.Equal(now.Add(2*time.Second)) {
+		t.Errorf("GetLatestByNode = %+v, %v", latest, err)
+	}
+	if latest, err := s.GetLatestByNode(ctx, "node-z"); err != nil || latest != nil {
+		t.Errorf("GetLatestByNode(unknown) = %+v, %v", latest, err)
+	}
+
+	for i, typ := range []string{"syscall", "network", "syscall"} {
+		e := EnrichedEvent{NodeName: "node-a", EventType: typ, Timestamp: now.Add(time.Duration(i) * time.Second), PID: uint32(i + 1)}
+		if err := s.SaveKernelEvent(ctx, e); err != nil {
+			t.Fatalf("SaveKernelEvent: %v", err)
+		}
+	}
+	events, err := s.GetKernelEvents(ctx, "node-a", now, now.Add(2*time.Second))
+	if err != nil || len(events) != 3 || events[2].PID != 3 {
+		t.Errorf("GetKernelEvents = %+v, %v", events, err)
+	}
+	events, err = s.GetKernelEventsByType(ctx, "node-a", "syscall", now.Add(time.Second), now.Add(2*time.Second))
+	if err != nil || len(events) != 1 || events[0].PID != 3 {
+		t.Errorf("GetKernelEventsByType = %+v, %v", events, err)
+	}
+
+	chain := CausalChain{NodeName: "node-a", Timestamp: now, Summary: "kubelet exited"}
+	if err := s.SaveCausalChain(ctx, chain); err != nil {
+		t.Fatalf("SaveCausalChain: %v", err)
+	}
+	chains, err := s.GetCausalChains(ctx, "node-a", now.Add(-time.Minute), now)
+	if err != nil || len(chains) != 1 || chains[0].Summary != "kubelet exited" {
+		t.Errorf("GetCausalChains = %+v, %v", chains, err)
+	}
+
+	if silences, err := s.GetSilences(ctx); err != nil || silences == nil || len(silences) != 0 {
+		t.Errorf("GetSilences on an empty store = %#v, %v", silences, err)
+	}
+	for _, comment := range []string{"maintenance", "extended"} {
+		if err := s.SaveSilence(ctx, Silence{ID: "s1", Comment: comment}); err != nil {
+			t.Fatalf("SaveSilence: %v", err)
+		}
+	}
+	if silences, err := s.GetSilences(ctx); err != nil || len(silences) != 1 || silences[0].Comment != "extended" {
+		t.Errorf("GetSilences = %+v, %v", silences, err)
+	}
+}
+
+// TestSQLiteStore_Compact verifies compaction deletes records older than the
+// retention and keeps newer ones and silences.
+func TestSQLiteStore_Compact(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLiteStore(t, ":memory:")
+	now := time.Now().UTC()
+
+	for _, ts := range []time.Time{now.Add(-2 * time.Hour), now} {
+		s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: ts})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "syscall", Timestamp: ts})
+		s.SaveCausalChain(ctx, CausalChain{NodeName: "node-a", Timestamp: ts})
+	}
+	s.SaveSilence(ctx, Silence{ID: "s1"})
+
+	if err := s.Compact(ctx, now); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	from := now.Add(-24 * time.Hour)
+	if hbs, _ := s.GetByTimeRange(ctx, from, now); len(hbs) != 1 {
+		t.Errorf("%d heartbeats after compaction, want 1", len(hbs))
+	}
+	if events, _ := s.GetKernelEvents(ctx, "node-a", from, now); len(events) != 1 {
+		t.Errorf("%d kernel events after compaction, want 1", len(events))
+	}
+	if chains, _ := s.GetCausalChains(ctx, "node-a", from, now); len(chains) != 1 {
+		t.Errorf("%d causal chains after compaction, want 1", len(chains))
+	}
+	if silences, _ := s.GetSilences(ctx); len(silences) != 1 {
+		t.Errorf("%d silences after compaction, want 1", len(silences))
+	}
+}
+
+// TestSQLiteStore_Reopen verifies data survives a restart, migrations are
+// applied once, and a database from a newer server is refused.
+func TestSQLiteStore_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "earthworm.db")
+	now := time.Now().UTC()
+
+	s, err := NewSQLiteStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	if err := s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: now}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	s.Close()
+
+	s = newTestSQLiteStore(t, path)
+	if version, err := s.SchemaVersion(ctx); err != nil || version != len(sqliteMigrations) {
+		t.Errorf("SchemaVersion = %d, %v, want %d", version, err, len(sqliteMigrations))
+	}
+	if latest, err := s.GetLatestByNode(ctx, "node-a"); err != nil || latest == nil {
+		t.Errorf("heartbeat lost on reopen: %+v, %v", latest, err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, 0)`, len(sqliteMigrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := NewSQLiteStore(path, time.Hour); err == nil {
+		t.Error("opened a database with a newer schema")
+	}
+}
+
+// TestSQLiteStore_MigrateHeartbeatColumns verifies heartbeats saved before
+// the namespace and status columns existed can be filtered on them.
+func TestSQLiteStore_MigrateHeartbeatColumns(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "earthworm.db")
+	now := time.Now().UTC()
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(Heartbeat{NodeName: "node-a", Namespace: "kube-node-lease", Status: "NotReady", Timestamp: now})
+	for _, stmt := range []string{
+		sqliteMigrations[0],
+		`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at INTEGER NOT NULL)`,
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (1, 0)`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO heartbeats (node_name, ts, data) VALUES (?, ?, ?)`, "node-a", now.UnixNano(), string(data)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s := newTestSQLiteStore(t, path)
+	page, err := s.QueryHeartbeats(ctx, HeartbeatQuery{Namespace: "kube-node-lease", Status: "NotReady"})
+	if err != nil || len(page.Heartbeats) != 1 {
+		t.Errorf("QueryHeartbeats = %+v, %v, want the heartbeat saved at version 1", page, err)
+	}
+}
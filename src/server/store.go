@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"sync"
+	"log"
 	"time"
 )
 
@@ -14,121 +14,69 @@ type Heartbeat struct {
 	Status    string    `json:"status"`
 	EbpfPID   uint32    `json:"ebpfPid,omitempty"`
 	EbpfComm  string    `json:"ebpfComm,omitempty"`
+
+	// Lease-derived fields (populated when heartbeats come from kube-node-lease)
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int32  `json:"leaseDurationSeconds,omitempty"`
 }
 
 // Store defines the interface for heartbeat and kernel event persistence.
+// Time ranges are inclusive at both ends and results are ordered by
+// timestamp, oldest first. GetLatestByNode returns the heartbeat with the
+// greatest timestamp, whatever order heartbeats were saved in, or nil if the
+// node has none. store_conformance_test.go checks every backend against this.
 type Store interface {
 	Save(ctx context.Context, event Heartbeat) error
 	GetByTimeRange(ctx context.Context, from, to time.Time) ([]Heartbeat, error)
 	GetLatestByNode(ctx context.Context, nodeName string) (*Heartbeat, error)
 	Ping(ctx context.Context) error
 
-	// Kernel event methods
+	// QueryHeartbeats returns a page of the heartbeats q selects, or
+	// ErrInvalidCursor. DownsampleHeartbeats summarizes them per node and
+	// step instead, ignoring q's limit and cursor.
+	QueryHeartbeats(ctx context.Context, q HeartbeatQuery) (HeartbeatPage, error)
+	DownsampleHeartbeats(ctx context.Context, q HeartbeatQuery, step time.Duration) ([]HeartbeatBucket, error)
+
+	// Nodes returns the names of the nodes with heartbeats or kernel
+	// events, sorted. HasNode reports whether one node has either.
+	Nodes(ctx context.Context) ([]string, error)
+	HasNode(ctx context.Context, nodeName string) (bool, error)
+
+	// Kernel event methods. CountKernelEvents returns how many events of
+	// each type each node has in the range, node name first, for every node
+	// or only nodeName when it is set.
 	SaveKernelEvent(ctx context.Context, event EnrichedEvent) error
 	GetKernelEvents(ctx context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error)
 	GetKernelEventsByType(ctx context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error)
+	CountKernelEvents(ctx context.Context, nodeName string, from, to time.Time) (map[string]map[string]int, error)
 
-	// Causal chain methods
+	// Causal chain methods. GetLatestCausalChain returns the chain with the
+	// greatest timestamp, or nil if the node has none.
 	SaveCausalChain(ctx context.Context, chain CausalChain) error
 	GetCausalChains(ctx context.Context, nodeName string, from, to time.Time) ([]CausalChain, error)
-}
-
-// MemoryStore is an in-memory implementation of the Store interface.
-type MemoryStore struct {
-	mu           sync.Mutex
-	heartbeats   []Heartbeat
-	kernelEvents []EnrichedEvent
-	causalChains []CausalChain
-}
-
-// NewMemoryStore creates a new in-memory store.
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{}
-}
+	GetLatestCausalChain(ctx context.Context, nodeName string) (*CausalChain, error)
 
-func (m *MemoryStore) Save(_ context.Context, event Heartbeat) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.heartbeats = append(m.heartbeats, event)
-	return nil
+	// Silence methods. SaveSilence creates or replaces the silence with the same ID;
+	// DeleteSilence of an unknown ID is a no-op.
+	SaveSilence(ctx context.Context, silence Silence) error
+	GetSilences(ctx context.Context) ([]Silence, error)
+	DeleteSilence(ctx context.Context, id string) error
 }
 
-func (m *MemoryStore) GetByTimeRange(_ context.Context, from, to time.Time) ([]Heartbeat, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	var result []Heartbeat
-	for _, hb := range m.heartbeats {
-		if !hb.Timestamp.Before(from) && !hb.Timestamp.After(to) {
-			result = append(result, hb)
-		}
-	}
-	return result, nil
-}
-
-func (m *MemoryStore) GetLatestByNode(_ context.Context, nodeName string) (*Heartbeat, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	var latest *Heartbeat
-	for i := range m.heartbeats {
-		if m.heartbeats[i].NodeName == nodeName {
-			if latest == nil || m.heartbeats[i].Timestamp.After(latest.Timestamp) {
-				latest = &m.heartbeats[i]
+// compactEvery runs a store's retention compaction every interval until stop
+// is closed, then closes done.
+func compactEvery(interval time.Duration, compact func(context.Context, time.Time) error, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := compact(context.Background(), now); err != nil {
+				log.Printf("Store compaction failed: %v", err)
 			}
 		}
 	}
-	return latest, nil
-}
-
-func (m *MemoryStore) Ping(_ context.Context) error {
-	return nil
-}
-
-func (m *MemoryStore) SaveKernelEvent(_ context.Context, event EnrichedEvent) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.kernelEvents = append(m.kernelEvents, event)
-	return nil
-}
-
-func (m *MemoryStore) GetKernelEvents(_ context.Context, nodeName string, from, to time.Time) ([]EnrichedEvent, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	var result []EnrichedEvent
-	for _, e := range m.kernelEvents {
-		if e.NodeName == nodeName && !e.Timestamp.Before(from) && !e.Timestamp.After(to) {
-			result = append(result, e)
-		}
-	}
-	return result, nil
-}
-
-func (m *MemoryStore) GetKernelEventsByType(_ context.Context, nodeName string, eventType string, from, to time.Time) ([]EnrichedEvent, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	var result []EnrichedEvent
-	for _, e := range m.kernelEvents {
-		if e.NodeName == nodeName && e.EventType == eventType && !e.Timestamp.Before(from) && !e.Timestamp.After(to) {
-			result = append(result, e)
-		}
-	}
-	return result, nil
-}
-
-func (m *MemoryStore) SaveCausalChain(_ context.Context, chain CausalChain) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.causalChains = append(m.causalChains, chain)
-	return nil
-}
-
-func (m *MemoryStore) GetCausalChains(_ context.Context, nodeName string, from, to time.Time) ([]CausalChain, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	var result []CausalChain
-	for _, c := range m.causalChains {
-		if c.NodeName == nodeName && !c.Timestamp.Before(from) && !c.Timestamp.After(to) {
-			result = append(result, c)
-		}
-	}
-	return result, nil
 }
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// storeBackends are the Store implementations the conformance suite runs
+// against. Each constructor returns an empty store that lives until the end
+// of the test. A new backend only needs an entry here.
+var storeBackends = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store {
+		return NewMemoryStore()
+	},
+	"memory-bounded": func(t *testing.T) Store {
+		s := NewBoundedMemoryStore(defaultMemoryMaxAge, defaultMemoryMaxEntries, defaultMemoryMaxTotalEntries)
+		t.Cleanup(func() { s.Close() })
+		return s
+	},
+	"instrumented": func(t *testing.T) Store {
+		return newInstrumentedStore(NewMemoryStore())
+	},
+	"redis": func(t *testing.T) Store {
+		return newTestRedisStore(t, miniredis.RunT(t), defaultTTL, 0)
+	},
+	"redis-cluster": func(t *testing.T) Store {
+		s, err := NewRedisStore(RedisOptions{Addrs: []string{miniredis.RunT(t).Addr()}, Cluster: true, TTL: defaultTTL})
+		if err != nil {
+			t.Fatalf("NewRedisStore: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	},
+	"sqlite": func(t *testing.T) Store {
+		return newTestSQLiteStore(t, ":memory:")
+	},
+	"postgres": func(t *testing.T) Store {
+		s, _ := newTestPostgresStore(t)
+		return s
+	},
+}
+
+// TestStoreConformance runs every contract test against every backend.
+func TestStoreConformance(t *testing.T) {
+	for name, newStore := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			testStoreConformance(t, newStore)
+		})
+	}
+}
+
+// testStoreConformance is the contract every Store must meet. Timestamps are
+// whole milliseconds, the precision every backend keeps, and no two records
+// of a node share one.
+func testStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+	ctx := context.Background()
+
+	t.Run("Ping", func(t *testing.T) {
+		if err := newStore(t).Ping(ctx); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+
+	t.Run("HeartbeatsOrderedByTimestamp", func(t *testing.T) {
+		s := newStore(t)
+		// Saved out of order, across nodes
+		for _, hb := range []Heartbeat{
+			{NodeName: "node-b", Timestamp: at(3)},
+			{NodeName: "node-a", Timestamp: at(1)},
+			{NodeName: "node-a", Timestamp: at(4)},
+			{NodeName: "node-c", Timestamp: at(2)},
+		} {
+			hb.Namespace, hb.Status = "default", "Ready"
+			if err := s.Save(ctx, hb); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+		hbs, err := s.GetByTimeRange(ctx, at(0), at(10))
+		if err != nil {
+			t.Fatalf("GetByTimeRange: %v", err)
+		}
+		var got []string
+		for _, hb := range hbs {
+			got = append(got, fmt.Sprintf("%s@%d", hb.NodeName, hb.Timestamp.Sub(base)/time.Second))
+		}
+		if want := "[node-a@1 node-c@2 node-b@3 node-a@4]"; fmt.Sprint(got) != want {
+			t.Errorf("GetByTimeRange = %v, want %s", got, want)
+		}
+	})
+
+	t.Run("InclusiveTimeBounds", func(t *testing.T) {
+		s := newStore(t)
+		for i := 1; i <= 5; i++ {
+			s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: at(i)})
+			s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "syscall", Timestamp: at(i)})
+			s.SaveCausalChain(ctx, CausalChain{NodeName: "node-a", Timestamp: at(i)})
+		}
+		hbs, _ := s.GetByTimeRange(ctx, at(2), at(4))
+		events, _ := s.GetKernelEvents(ctx, "node-a", at(2), at(4))
+		byType, _ := s.GetKernelEventsByType(ctx, "node-a", "syscall", at(2), at(4))
+		chains, _ := s.GetCausalChains(ctx, "node-a", at(2), at(4))
+		if len(hbs) != 3 || len(events) != 3 || len(byType) != 3 || len(chains) != 3 {
+			t.Errorf("[2s, 4s] holds %d heartbeats, %d events, %d by type, %d chains, want 3 each",
+				len(hbs), len(events), len(byType), len(chains))
+		}
+		if len(hbs) == 3 && (!hbs[0].Timestamp.Equal(at(2)) || !hbs[2].Timestamp.Equal(at(4))) {
+			t.Errorf("bounds = %v .. %v", hbs[0].Timestamp, hbs[2].Timestamp)
+		}
+		between := base.Add(2500 * time.Millisecond)
+		if hbs, _ := s.GetByTimeRange(ctx, between, between); len(hbs) != 0 {
+			t.Errorf("empty range holds %d heartbeats", len(hbs))
+		}
+		if hbs, _ := s.GetByTimeRange(ctx, at(3), at(3)); len(hbs) != 1 {
+			t.Errorf("single-instant range holds %d heartbeats, want 1", len(hbs))
+		}
+	})
+
+	t.Run("PerNodeIsolation", func(t *testing.T) {
+		s := newStore(t)
+		// node-1 is a prefix of node-10
+		for i, node := range []string{"node-1", "node-10", "node-1"} {
+			s.Save(ctx, Heartbeat{NodeName: node, Timestamp: at(i)})
+			s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: node, EventType: "network", Timestamp: at(i)})
+			s.SaveCausalChain(ctx, CausalChain{NodeName: node, Timestamp: at(i)})
+		}
+		for node, want := range map[string]int{"node-1": 2, "node-10": 1, "node-2": 0} {
+			events, err := s.GetKernelEvents(ctx, node, at(0), at(10))
+			byType, _ := s.GetKernelEventsByType(ctx, node, "network", at(0), at(10))
+			chains, _ := s.GetCausalChains(ctx, node, at(0), at(10))
+			if err != nil || len(events) != want || len(byType) != want || len(chains) != want {
+				t.Errorf("%s: %d events, %d by type, %d chains (%v), want %d each", node, len(events), len(byType), len(chains), err, want)
+			}
+			for _, e := range events {
+				if e.NodeName != node {
+					t.Errorf("%s: got an event of %s", node, e.NodeName)
+				}
+			}
+		}
+		if latest, _ := s.GetLatestByNode(ctx, "node-10"); latest == nil || !latest.Timestamp.Equal(at(1)) {
+			t.Errorf("latest of node-10 = %+v", latest)
+		}
+	})
+
+	t.Run("LatestByNode", func(t *testing.T) {
+		s := newStore(t)
+		if latest, err := s.GetLatestByNode(ctx, "node-a"); err != nil || latest != nil {
+			t.Errorf("latest of an unknown node = %+v, %v, want nil", latest, err)
+		}
+		// The latest is the newest heartbeat, not the last saved
+		for _, seconds := range []int{1, 5, 3} {
+			if err := s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: at(seconds), Status: fmt.Sprint(seconds)}); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+		latest, err := s.GetLatestByNode(ctx, "node-a")
+		if err != nil || latest == nil || !latest.Timestamp.Equal(at(5)) || latest.Status != "5" {
+			t.Errorf("latest = %+v, %v, want the heartbeat at 5s", latest, err)
+		}
+	})
+
+	t.Run("Nodes", func(t *testing.T) {
+		s := newStore(t)
+		if nodes, err := s.Nodes(ctx); err != nil || len(nodes) != 0 {
+			t.Errorf("nodes of an empty store = %v, %v, want none", nodes, err)
+		}
+		// node-c only has kernel events; node-a has both
+		s.Save(ctx, Heartbeat{NodeName: "node-b", Timestamp: at(1)})
+		s.Save(ctx, Heartbeat{NodeName: "node-a", Timestamp: at(2)})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-c", EventType: "syscall", Timestamp: at(3)})
+		s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: "network", Timestamp: at(4)})
+		nodes, err := s.Nodes(ctx)
+		if err != nil || fmt.Sprint(nodes) != "[node-a node-b node-c]" {
+			t.Errorf("nodes = %v, %v, want [node-a node-b node-c]", nodes, err)
+		}
+		for node, want := range map[string]bool{"node-a": true, "node-b": true, "node-c": true, "node-d": false, "node": false} {
+			if ok, err := s.HasNode(ctx, node); err != nil || ok != want {
+				t.Errorf("HasNode(%s) = %v, %v, want %v", node, ok, err, want)
+			}
+		}
+	})
+
+	t.Run("QueryHeartbeatsFilters", func(t *testing.T) {
+		s := newStore(t)
+		for i, hb := range []Heartbeat{
+			{NodeName: "node-a", Namespace: "kube-node-lease", Status: "Ready"},
+			{NodeName: "node-b", Namespace: "kube-node-lease", Status: "NotReady"},
+			{NodeName: "node-a", Namespace: "kube-node-lease", Status: "NotReady"},
+			{NodeName: "node-b", Namespace: "default", Status: "Ready"},
+		} {
+			hb.Timestamp = at(i + 1)
+			s.Save(ctx, hb)
+		}
+		for _, tc := range []struct {
+			q    HeartbeatQuery
+			want string
+		}{
+			{HeartbeatQuery{}, "[node-a@1 node-b@2 node-a@3 node-b@4]"},
+			{HeartbeatQuery{NodeName: "node-b"}, "[node-b@2 node-b@4]"},
+			{HeartbeatQuery{Namespace: "default"}, "[node-b@4]"},
+			{HeartbeatQuery{Status: "NotReady", NodeName: "node-a"}, "[node-a@3]"},
+			{HeartbeatQuery{From: at(2), To: at(3)}, "[node-b@2 node-a@3]"},
+			{HeartbeatQuery{To: at(1)}, "[node-a@1]"},
+			{HeartbeatQuery{NodeName: "node-c"}, "[]"},
+		} {
+			page, err := s.QueryHeartbeats(ctx, tc.q)
+			if err != nil {
+				t.Fatalf("QueryHeartbeats(%+v): %v", tc.q, err)
+			}
+			got := []string{}
+			for _, hb := range page.Heartbeats {
+				got = append(got, fmt.Sprintf("%s@%d", hb.NodeName, hb.Timestamp.Sub(base)/time.Second))
+			}
+			if fmt.Sprint(got) != tc.want || page.NextCursor != "" {
+				t.Errorf("QueryHeartbeats(%+v) = %v, cursor %q, want %s", tc.q, got, page.NextCursor, tc.want)
+			}
+		}
+		if _, err := s.QueryHeartbeats(ctx, HeartbeatQuery{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("err = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("QueryHeartbeatsPages", func(t *testing.T) {
+		s := newStore(t)
+		// Unlike elsewhere in the suite, one node has several heartbeats at
+		// once, so pages must split them by count
+		saved := []Heartbeat{
+			{NodeName: "node-b", Timestamp: at(1)},
+			{NodeName: "node-a", Timestamp: at(1)},
+			{NodeName: "node-a", Timestamp: at(1)},
+			{NodeName: "node-a", Timestamp: at(1)},
+			{NodeName: "node-c", Timestamp: at(2)},
+			{NodeName: "node-a", Timestamp: at(2)},
+			{NodeName: "node-a", Timestamp: at(4)},
+			{NodeName: "node-b", Timestamp: at(3)},
+		}
+		for i, hb := range saved {
+			hb.EbpfPID = uint32(i + 1)
+			s.Save(ctx, hb)
+		}
+		if page, _ := s.QueryHeartbeats(ctx, HeartbeatQuery{Limit: len(saved)}); len(page.Heartbeats) != len(saved) || page.NextCursor != "" {
+			t.Errorf("a page of exactly %d holds %d heartbeats, cursor %q", len(saved), len(page.Heartbeats), page.NextCursor)
+		}
+
+		for _, limit := range []int{1, 2, 3} {
+			var got []string
+			seen := map[uint32]bool{}
+			q := HeartbeatQuery{Limit: limit}
+			for pages := 0; ; pages++ {
+				if pages > len(saved) {
+					t.Fatalf("limit %d: more pages than heartbeats", limit)
+				}
+				page, err := s.QueryHeartbeats(ctx, q)
+				if err != nil {
+					t.Fatalf("limit %d: QueryHeartbeats: %v", limit, err)
+				}
+				if len(page.Heartbeats) > limit || (page.NextCursor != "" && len(page.Heartbeats) != limit) {
+					t.Fatalf("limit %d: page of %d, cursor %q", limit, len(page.Heartbeats), page.NextCursor)
+				}
+				for _, hb := range page.Heartbeats {
+					if seen[hb.EbpfPID] {
+						t.Errorf("limit %d: heartbeat %d returned twice", limit, hb.EbpfPID)
+					}
+					seen[hb.EbpfPID] = true
+					got = append(got, fmt.Sprintf("%s@%d", hb.NodeName, hb.Timestamp.Sub(base)/time.Second))
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			if want := "[node-a@1 node-a@1 node-a@1 node-b@1 node-a@2 node-c@2 node-b@3 node-a@4]"; fmt.Sprint(got) != want {
+				t.Errorf("limit %d: pages = %v, want %s", limit, got, want)
+			}
+		}
+	})
+
+	t.Run("DownsampleHeartbeats", func(t *testing.T) {
+		s := newStore(t)
+		for _, seconds := range []int{0, 10, 25, 40} {
+			s.Save(ctx, Heartbeat{NodeName: "node-a", Namespace: "kube-node-lease", Timestamp: at(seconds)})
+		}
+		s.Save(ctx, Heartbeat{NodeName: "node-b", Namespace: "kube-node-lease", Timestamp: at(5)})
+		s.Save(ctx, Heartbeat{NodeName: "node-c", Namespace: "default", Timestamp: at(5)})
+
+		buckets, err := s.DownsampleHeartbeats(ctx, HeartbeatQuery{Namespace: "kube-node-lease", From: at(0), To: at(60)}, 30*time.Second)
+		if err != nil {
+			t.Fatalf("DownsampleHeartbeats: %v", err)
+		}
+		var got []string
+		for _, b := range buckets {
+			got = append(got, fmt.Sprintf("%s@%d n=%d %d..%d gaps=%g..%g", b.NodeName, b.Start.Sub(base)/time.Second, b.Count,
+				b.First.Sub(base)/time.Second, b.Last.Sub(base)/time.Second, b.MinGapSeconds, b.MaxGapSeconds))
+		}
+		if want := "[node-a@0 n=3 0..25 gaps=10..15 node-b@0 n=1 5..5 gaps=0..0 node-a@30 n=1 40..40 gaps=15..15]"; fmt.Sprint(got) != want {
+			t.Errorf("buckets = %v, want %s", got, want)
+		}
+		if buckets, err := s.DownsampleHeartbeats(ctx, HeartbeatQuery{NodeName: "node-d"}, time.Minute); err != nil || buckets == nil || len(buckets) != 0 {
+			t.Errorf("buckets of an unknown node = %#v, %v, want an empty slice", buckets, err)
+		}
+	})
+
+	t.Run("HeartbeatRoundTrip", func(t *testing.T) {
+		s := newStore(t)
+		hb := Heartbeat{NodeName: "node-a", Namespace: "kube-system", Timestamp: at(1), Status: "Ready", EbpfPID: 42,
+			EbpfComm: "kubelet", HolderIdentity: "node-a", LeaseDurationSeconds: 40}
+		s.Save(ctx, hb)
+		latest, err := s.GetLatestByNode(ctx, "node-a")
+		if err != nil || latest == nil {
+			t.Fatalf("latest = %+v, %v", latest, err)
+		}
+		if !latest.Timestamp.Equal(hb.Timestamp) {
+			t.Errorf("timestamp = %v, want %v", latest.Timestamp, hb.Timestamp)
+		}
+		latest.Timestamp = hb.Timestamp
+		if *latest != hb {
+			t.Errorf("heartbeat = %+v, want %+v", *latest, hb)
+		}
+	})
+
+	t.Run("KernelEventsByType", func(t *testing.T) {
+		s := newStore(t)
+		for i, typ := range []string{"syscall", "filesystem_io", "network", "filesystem_io"} {
+			s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "node-a", EventType: typ, Timestamp: at(i), PID: uint32(i + 1),
+				FilePath: "/var/lib/containerd", IOLatencyNs: uint64(i) * 1000, SlowIO: true})
+		}
+		events, err := s.GetKernelEventsByType(ctx, "node-a", "filesystem_io", at(0), at(10))
+		if err != nil || len(events) != 2 || events[0].PID != 2 || events[1].PID != 4 {
+			t.Fatalf("filesystem_io events = %+v, %v", events, err)
+		}
+		if e := events[1]; e.FilePath != "/var/lib/containerd" || e.IOLatencyNs != 3000 || !e.SlowIO {
+			t.Errorf("event = %+v", e)
+		}
+		if events, _ := s.GetKernelEventsByType(ctx, "node-a", "dns_resolution", at(0), at(10)); len(events) != 0 {
+			t.Errorf("%d dns_resolution events, want 0", len(events))
+		}
+	})
+
+	t.Run("CountKernelEvents", func(t *testing.T) {
+		s := newStore(t)
+		if counts, err := s.CountKernelEvents(ctx, "", at(0), at(10)); err != nil || len(counts) != 0 {
+			t.Errorf("counts of an empty store = %v, %v, want none", counts, err)
+		}
+		for i, e := range []EnrichedEvent{
+			{NodeName: "node-a", EventType: "syscall"},
+			{NodeName: "node-a", EventType: "network"},
+			{NodeName: "node-b", EventType: "syscall"},
+			{NodeName: "node-a", EventType: "syscall"},
+			{NodeName: "node-a", EventType: "syscall"},
+			{NodeName: "node-c", EventType: "process"},
+		} {
+			e.Timestamp = at(i)
+			s.SaveKernelEvent(ctx, e)
+		}
+		// Bounds are inclusive; node-c's only event is out of range
+		for _, tc := range []struct {
+			node string
+			want string
+		}{
+			{"", "map[node-a:map[network:1 syscall:2] node-b:map[syscall:1]]"},
+			{"node-a", "map[node-a:map[network:1 syscall:2]]"},
+			{"node-c", "map[]"},
+		} {
+			counts, err := s.CountKernelEvents(ctx, tc.node, at(1), at(4))
+			if err != nil || fmt.Sprint(counts) != tc.want {
+				t.Errorf("CountKernelEvents(%q) = %v, %v, want %s", tc.node, counts, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("CausalChainRoundTrip", func(t *testing.T) {
+		s := newStore(t)
+		chain := CausalChain{
+			NodeName:  "node-a",
+			Timestamp: at(30),
+			Summary:   "kubelet exited 2s before the lease gap",
+			RootCause: "critical_exit: kubelet (pid=42, exit_code=1)",
+			Events: []EnrichedEvent{
+				{NodeName: "node-a", Timestamp: at(28), EventType: "process", PID: 42, Comm: "kubelet", CriticalExit: true, ExitCode: 1},
+				{NodeName: "node-a", Timestamp: at(29), EventType: "network", NetEventType: "rtt_high", RTTUs: 600_000},
+			},
+		}
+		if err := s.SaveCausalChain(ctx, chain); err != nil {
+			t.Fatalf("SaveCausalChain: %v", err)
+		}
+		chains, err := s.GetCausalChains(ctx, "node-a", at(0), at(60))
+		if err != nil || len(chains) != 1 {
+			t.Fatalf("chains = %+v, %v", chains, err)
+		}
+		got := chains[0]
+		if !got.Timestamp.Equal(chain.Timestamp) || got.Summary != chain.Summary || got.RootCause != chain.RootCause || len(got.Events) != 2 {
+			t.Fatalf("chain = %+v, want %+v", got, chain)
+		}
+		for i, e := range got.Events {
+			want := chain.Events[i]
+			if !e.Timestamp.Equal(want.Timestamp) {
+				t.Errorf("event %d at %v, want %v", i, e.Timestamp, want.Timestamp)
+			}
+			e.Timestamp = want.Timestamp
+			if e != want {
+				t.Errorf("event %d = %+v, want %+v", i, e, want)
+			}
+		}
+	})
+
+	t.Run("LatestCausalChain", func(t *testing.T) {
+		s := newStore(t)
+		if latest, err := s.GetLatestCausalChain(ctx, "node-a"); err != nil || latest != nil {
+			t.Errorf("latest chain of an unknown node = %+v, %v, want nil", latest, err)
+		}
+		// Saved out of order, across nodes
+		for i, c := range []CausalChain{
+			{NodeName: "node-a", RootCause: "memory_pressure"},
+			{NodeName: "node-a", RootCause: "network_degradation"},
+			{NodeName: "node-b", RootCause: "critical_exit"},
+			{NodeName: "node-a", RootCause: "disk_io_degradation"},
+		} {
+			c.Timestamp = at([]int{2, 5, 9, 3}[i])
+			s.SaveCausalChain(ctx, c)
+		}
+		latest, err := s.GetLatestCausalChain(ctx, "node-a")
+		if err != nil || latest == nil || latest.RootCause != "network_degradation" || !latest.Timestamp.Equal(at(5)) {
+			t.Errorf("latest chain of node-a = %+v, %v, want network_degradation at %v", latest, err, at(5))
+		}
+	})
+
+	t.Run("Silences", func(t *testing.T) {
+		s := newStore(t)
+		silences, err := s.GetSilences(ctx)
+		if err != nil || silences == nil || len(silences) != 0 {
+			t.Errorf("silences of an empty store = %#v, %v, want an empty slice", silences, err)
+		}
+		s.SaveSilence(ctx, Silence{ID: "s1", Comment: "maintenance", StartsAt: at(0), EndsAt: at(3600)})
+		s.SaveSilence(ctx, Silence{ID: "s2", Comment: "upgrade"})
+		s.SaveSilence(ctx, Silence{ID: "s1", Comment: "extended", StartsAt: at(0), EndsAt: at(7200)})
+		silences, _ = s.GetSilences(ctx)
+		byID := make(map[string]Silence)
+		for _, silence := range silences {
+			byID[silence.ID] = silence
+		}
+		if len(silences) != 2 || byID["s1"].Comment != "extended" || !byID["s1"].EndsAt.Equal(at(7200)) {
+			t.Errorf("silences = %+v, want s1 replaced and s2", silences)
+		}
+
+		if err := s.DeleteSilence(ctx, "s2"); err != nil {
+			t.Fatalf("DeleteSilence: %v", err)
+		}
+		if err := s.DeleteSilence(ctx, "unknown"); err != nil {
+			t.Errorf("DeleteSilence of an unknown ID: %v", err)
+		}
+		silences, _ = s.GetSilences(ctx)
+		if len(silences) != 1 || silences[0].ID != "s1" {
+			t.Errorf("silences after deleting s2 = %+v, want s1", silences)
+		}
+	})
+
+	t.Run("ConcurrentWriters", func(t *testing.T) {
+		s := newStore(t)
+		const writers, perWriter = 8, 25
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				node := fmt.Sprintf("node-%d", w)
+				for i := 0; i < perWriter; i++ {
+					ts := at(i).Add(time.Duration(w) * time.Millisecond)
+					if err := s.Save(ctx, Heartbeat{NodeName: node, Timestamp: ts}); err != nil {
+						errs <- err
+						return
+					}
+					// Every writer also appends to one shared node
+					if err := s.SaveKernelEvent(ctx, EnrichedEvent{NodeName: "shared", EventType: "syscall", Timestamp: ts, PID: uint32(w*perWriter + i + 1)}); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("concurrent write: %v", err)
+		}
+
+		hbs, _ := s.GetByTimeRange(ctx, at(0), at(perWriter))
+		events, _ := s.GetKernelEvents(ctx, "shared", at(0), at(perWriter))
+		if len(hbs) != writers*perWriter || len(events) != writers*perWriter {
+			t.Errorf("%d heartbeats and %d shared events, want %d each", len(hbs), len(events), writers*perWriter)
+		}
+		for i := 1; i < len(events); i++ {
+			if events[i].Timestamp.Before(events[i-1].Timestamp) {
+				t.Fatalf("shared events out of order at %d", i)
+			}
+		}
+		for w := 0; w < writers; w++ {
+			latest, _ := s.GetLatestByNode(ctx, fmt.Sprintf("node-%d", w))
+			if want := at(perWriter - 1).Add(time.Duration(w) * time.Millisecond); latest == nil || !latest.Timestamp.Equal(want) {
+				t.Errorf("latest of node-%d = %+v, want %v", w, latest, want)
+			}
+		}
+	})
+}
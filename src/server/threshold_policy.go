@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"earthworm/src/kubernetes"
+)
+
+// ErrInvalidThresholdPolicy is returned for policies that fail validation.
+var ErrInvalidThresholdPolicy = errors.New("invalid threshold policy")
+
+// Thresholds are the heartbeat gaps beyond which a node's alert is a warning
+// or critical.
+type Thresholds struct {
+	Warning  time.Duration
+	Critical time.Duration
+}
+
+// severityFor maps a heartbeat gap to "warning", "critical", or "" when normal.
+func (t Thresholds) severityFor(gap time.Duration) string {
+	switch {
+	case gap > t.Critical:
+		return "critical"
+	case gap > t.Warning:
+		return "warning"
+	default:
+		return ""
+	}
+}
+
+// ThresholdRule overrides the global thresholds for matching nodes. Empty
+// match fields match everything; a zero threshold keeps the global one.
+type ThresholdRule struct {
+	Name string `yaml:"name" json:"name"`
+	// NodeSelector is a Kubernetes label selector, e.g.
+	// "node-role.kubernetes.io/control-plane" or "pool in (spot,preemptible)".
+	// It never matches a node whose labels are unknown.
+	NodeSelector    string   `yaml:"nodeSelector,omitempty" json:"nodeSelector,omitempty"`
+	NodePattern     string   `yaml:"nodePattern,omitempty" json:"nodePattern,omitempty"` // glob, e.g. "cp-*"
+	Namespaces      []string `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+	WarningSeconds  int      `yaml:"warningSeconds,omitempty" json:"warningSeconds,omitempty"`
+	CriticalSeconds int      `yaml:"criticalSeconds,omitempty" json:"criticalSeconds,omitempty"`
+}
+
+// ThresholdPolicyConfig is the YAML file named by EARTHWORM_THRESHOLD_POLICY
+// and the body of PUT /api/thresholds.
+type ThresholdPolicyConfig struct {
+	Rules []ThresholdRule `yaml:"rules" json:"rules"`
+}
+
+// LoadThresholdPolicyConfig reads and parses a threshold policy file.
+func LoadThresholdPolicyConfig(filename string) (ThresholdPolicyConfig, error) {
+	var c ThresholdPolicyConfig
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// rejectNamespaces returns an error for the first rule that matches on
+// namespaces. Heartbeats from node Leases are all in kube-node-lease, so with
+// -lease-watch such a rule would match every node or none.
+func (c ThresholdPolicyConfig) rejectNamespaces() error {
+	for _, rule := range c.Rules {
+		if len(rule.Namespaces) > 0 {
+			return fmt.Errorf("%w: rule %q matches on namespaces, but with -lease-watch every heartbeat is in %s; match on nodeSelector instead",
+				ErrInvalidThresholdPolicy, rule.Name, kubernetes.NodeLeaseNamespace)
+		}
+	}
+	return nil
+}
+
+// ThresholdResolution reports which thresholds apply to a node.
+type ThresholdResolution struct {
+	Node            string            `json:"node"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Rule            string            `json:"rule"` // matching rule, "" when the global thresholds apply
+	WarningSeconds  int               `json:"warningSeconds"`
+	CriticalSeconds int               `json:"criticalSeconds"`
+}
+
+// Thresholds returns the resolved thresholds.
+func (r ThresholdResolution) Thresholds() Thresholds {
+	return Thresholds{
+		Warning:  time.Duration(r.WarningSeconds) * time.Second,
+		Critical: time.Duration(r.CriticalSeconds) * time.Second,
+	}
+}
+
+// ThresholdPolicy resolves per-node thresholds from an ordered list of rules.
+// The first matching rule wins; nodes matching none use the global thresholds.
+type ThresholdPolicy struct {
+	defaults Thresholds
+	labels   func(nodeName string) map[string]string
+
+	mu        sync.RWMutex
+	config    ThresholdPolicyConfig
+	selectors []labels.Selector // parallel to config.Rules, nil for rules without a selector
+}
+
+// NewThresholdPolicy creates a policy with no rules. nodeLabels looks up a
+// node's labels and may be nil when labels are unavailable.
+func NewThresholdPolicy(defaults Thresholds, nodeLabels func(nodeName string) map[string]string) *ThresholdPolicy {
+	return &ThresholdPolicy{defaults: defaults, labels: nodeLabels, config: ThresholdPolicyConfig{Rules: []ThresholdRule{}}}
+}
+
+// Set validates c and replaces the policy's rules with it.
+func (p *ThresholdPolicy) Set(c ThresholdPolicyConfig) error {
+	selectors := make([]labels.Selector, len(c.Rules))
+	names := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("%w: rule %d has no name", ErrInvalidThresholdPolicy, i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("%w: duplicate rule %q", ErrInvalidThresholdPolicy, rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.NodeSelector != "" {
+			sel, err := labels.Parse(rule.NodeSelector)
+			if err != nil {
+				return fmt.Errorf("%w: rule %q: invalid nodeSelector: %v", ErrInvalidThresholdPolicy, rule.Name, err)
+			}
+			selectors[i] = sel
+		}
+		if _, err := path.Match(rule.NodePattern, ""); err != nil {
+			return fmt.Errorf("%w: rule %q: invalid nodePattern %q", ErrInvalidThresholdPolicy, rule.Name, rule.NodePattern)
+		}
+		if rule.WarningSeconds < 0 || rule.CriticalSeconds < 0 {
+			return fmt.Errorf("%w: rule %q: thresholds must not be negative", ErrInvalidThresholdPolicy, rule.Name)
+		}
+		if t := p.apply(rule); t.Warning >= t.Critical {
+			return fmt.Errorf("%w: rule %q: warning threshold %s must be below critical threshold %s",
+				ErrInvalidThresholdPolicy, rule.Name, t.Warning, t.Critical)
+		}
+	}
+	if c.Rules == nil {
+		c.Rules = []ThresholdRule{}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = c
+	p.selectors = selectors
+	return nil
+}
+
+// Config returns the current rules.
+func (p *ThresholdPolicy) Config() ThresholdPolicyConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return ThresholdPolicyConfig{Rules: slices.Clone(p.config.Rules)}
+}
+
+// Defaults returns the global thresholds.
+func (p *ThresholdPolicy) Defaults() Thresholds {
+	return p.defaults
+}
+
+// Resolve returns the thresholds for a node's heartbeats in namespace and the
+// rule they came from.
+func (p *ThresholdPolicy) Resolve(nodeName, namespace string) ThresholdResolution {
+	var nodeLabels map[string]string
+	if p.labels != nil {
+		nodeLabels = p.labels(nodeName)
+	}
+	res := ThresholdResolution{Node: nodeName, Namespace: namespace, Labels: nodeLabels}
+
+	t := p.defaults
+	p.mu.RLock()
+	for i, rule := range p.config.Rules {
+		if p.matches(rule, p.selectors[i], nodeName, namespace, nodeLabels) {
+			res.Rule = rule.Name
+			t = p.apply(rule)
+			break
+		}
+	}
+	p.mu.RUnlock()
+
+	res.WarningSeconds = int(t.Warning / time.Second)
+	res.CriticalSeconds = int(t.Critical / time.Second)
+	return res
+}
+
+// matches reports whether rule applies to the node.
+func (p *ThresholdPolicy) matches(rule ThresholdRule, sel labels.Selector, nodeName, namespace string, nodeLabels map[string]string) bool {
+	if sel != nil && (nodeLabels == nil || !sel.Matches(labels.Set(nodeLabels))) {
+		return false
+	}
+	if rule.NodePattern != "" {
+		if ok, _ := path.Match(rule.NodePattern, nodeName); !ok {
+			return false
+		}
+	}
+	if len(rule.Namespaces) > 0 && !slices.Contains(rule.Namespaces, namespace) {
+		return false
+	}
+	return true
+}
+
+// apply returns the global thresholds overridden by the rule's.
+func (p *ThresholdPolicy) apply(rule ThresholdRule) Thresholds {
+	t := p.defaults
+	if rule.WarningSeconds > 0 {
+		t.Warning = time.Duration(rule.WarningSeconds) * time.Second
+	}
+	if rule.CriticalSeconds > 0 {
+		t.Critical = time.Duration(rule.CriticalSeconds) * time.Second
+	}
+	return t
+}
+
+// thresholdPolicyView is the response body of GET and PUT /api/thresholds.
+type thresholdPolicyView struct {
+	Defaults struct {
+		WarningSeconds  int `json:"warningSeconds"`
+		CriticalSeconds int `json:"criticalSeconds"`
+	} `json:"defaults"`
+	Rules []ThresholdRule `json:"rules"`
+}
+
+func (p *ThresholdPolicy) view() thresholdPolicyView {
+	var v thresholdPolicyView
+	v.Defaults.WarningSeconds = int(p.defaults.Warning / time.Second)
+	v.Defaults.CriticalSeconds = int(p.defaults.Critical / time.Second)
+	v.Rules = p.Config().Rules
+	return v
+}
+
+// thresholdsHandler serves GET /api/thresholds and PUT /api/thresholds, which
+// replaces the rules. Rules set through the API last until the next restart.
+// check, if not nil, further restricts the rules a PUT may set.
+func thresholdsHandler(p *ThresholdPolicy, check func(ThresholdPolicyConfig) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var c ThresholdPolicyConfig
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				writeJSONError(w, fmt.Sprintf("Invalid JSON: %s", err.Error()), http.StatusBadRequest)
+				return
+			}
+			if check != nil {
+				if err := check(c); err != nil {
+					writeJSONError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if err := p.Set(c); err != nil {
+				writeJSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.view())
+	}
+}
+
+// thresholdResolveHandler serves GET /api/thresholds/resolve?node=&namespace=,
+// a dry run reporting which rule and thresholds apply to a node.
+func thresholdResolveHandler(p *ThresholdPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		node := r.URL.Query().Get("node")
+		if node == "" {
+			writeJSONError(w, "node is required", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Resolve(node, r.URL.Query().Get("namespace")))
+	}
+}
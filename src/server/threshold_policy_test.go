@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNodeLabels = map[string]map[string]string{
+	"cp-01":    {"node-role.kubernetes.io/control-plane": ""},
+	"spot-a1":  {"pool": "spot"},
+	"worker-1": {"pool": "general"},
+}
+
+func testThresholdPolicy(t *testing.T) *ThresholdPolicy {
+	t.Helper()
+	p := NewThresholdPolicy(Thresholds{Warning: 10 * time.Second, Critical: 40 * time.Second},
+		func(node string) map[string]string { return testNodeLabels[node] })
+	err := p.Set(ThresholdPolicyConfig{Rules: []ThresholdRule{
+		{Name: "control-plane", NodeSelector: "node-role.kubernetes.io/control-plane", WarningSeconds: 5, CriticalSeconds: 15},
+		{Name: "spot", NodeSelector: "pool in (spot,preemptible)", CriticalSeconds: 120},
+		{Name: "legacy", NodePattern: "legacy-*", WarningSeconds: 30, CriticalSeconds: 90},
+		{Name: "batch", Namespaces: []string{"batch"}, WarningSeconds: 60, CriticalSeconds: 300},
+	}})
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	return p
+}
+
+func TestThresholdPolicyResolve(t *testing.T) {
+	p := testThresholdPolicy(t)
+	tests := []struct {
+		node, namespace   string
+		rule              string
+		warning, critical int
+	}{
+		{"cp-01", "kube-node-lease", "control-plane", 5, 15},
+		{"spot-a1", "kube-node-lease", "spot", 10, 120}, // warning inherited
+		{"legacy-07", "kube-node-lease", "legacy", 30, 90},
+		{"worker-1", "batch", "batch", 60, 300},
+		{"cp-01", "batch", "control-plane", 5, 15}, // first match wins
+		{"worker-1", "kube-node-lease", "", 10, 40},
+		{"unlabelled", "default", "", 10, 40},
+	}
+	for _, tt := range tests {
+		res := p.Resolve(tt.node, tt.namespace)
+		if res.Rule != tt.rule || res.WarningSeconds != tt.warning || res.CriticalSeconds != tt.critical {
+			t.Errorf("Resolve(%s, %s) = %+v, want rule %q %d/%d", tt.node, tt.namespace, res, tt.rule, tt.warning, tt.critical)
+		}
+	}
+}
+
+// TestThresholdPolicySelectorNeedsLabels verifies a selector does not match a
+// node whose labels are unknown, even when it is negative.
+func TestThresholdPolicySelectorNeedsLabels(t *testing.T) {
+	p := NewThresholdPolicy(Thresholds{Warning: 10 * time.Second, Critical: 40 * time.Second}, nil)
+	if err := p.Set(ThresholdPolicyConfig{Rules: []ThresholdRule{{Name: "not-spot", NodeSelector: "pool!=spot", CriticalSeconds: 20}}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if res := p.Resolve("worker-1", "default"); res.Rule != "" {
+		t.Errorf("Resolve = %+v, want global thresholds", res)
+	}
+}
+
+func TestThresholdPolicySetValidation(t *testing.T) {
+	p := testThresholdPolicy(t)
+	before := p.Config()
+	for name, rule := range map[string]ThresholdRule{
+		"no name":                   {CriticalSeconds: 20},
+		"bad selector":              {Name: "x", NodeSelector: "pool in spot"},
+		"bad pattern":               {Name: "x", NodePattern: "[cp"},
+		"negative":                  {Name: "x", WarningSeconds: -1},
+		"warning above crit":        {Name: "x", WarningSeconds: 30, CriticalSeconds: 20},
+		"warning above global crit": {Name: "x", WarningSeconds: 50},
+	} {
+		if err := p.Set(ThresholdPolicyConfig{Rules: []ThresholdRule{rule}}); !errors.Is(err, ErrInvalidThresholdPolicy) {
+			t.Errorf("%s: err = %v, want ErrInvalidThresholdPolicy", name, err)
+		}
+	}
+	dup := ThresholdPolicyConfig{Rules: []ThresholdRule{{Name: "a"}, {Name: "a"}}}
+	if err := p.Set(dup); !errors.Is(err, ErrInvalidThresholdPolicy) {
+		t.Errorf("duplicate names: err = %v", err)
+	}
+	if after := p.Config(); len(after.Rules) != len(before.Rules) {
+		t.Errorf("rejected policy replaced the rules: %+v", after)
+	}
+}
+
+func TestThresholdPolicyConfigRejectNamespaces(t *testing.T) {
+	c := ThresholdPolicyConfig{Rules: []ThresholdRule{{Name: "cp", NodeSelector: "node-role.kubernetes.io/control-plane", CriticalSeconds: 20}}}
+	if err := c.rejectNamespaces(); err != nil {
+		t.Errorf("rejectNamespaces without namespace matchers: %v", err)
+	}
+	c.Rules = append(c.Rules, ThresholdRule{Name: "system", Namespaces: []string{"kube-system"}, CriticalSeconds: 20})
+	err := c.rejectNamespaces()
+	if !errors.Is(err, ErrInvalidThresholdPolicy) || !strings.Contains(err.Error(), `rule "system"`) || !strings.Contains(err.Error(), "nodeSelector") {
+		t.Errorf("rejectNamespaces = %v, want ErrInvalidThresholdPolicy naming the rule and steering to nodeSelector", err)
+	}
+}
+
+func TestLoadThresholdPolicyConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "thresholds.yaml")
+	os.WriteFile(file, []byte(`
+rules:
+  - name: control-plane
+    nodeSelector: node-role.kubernetes.io/control-plane
+    warningSeconds: 5
+    criticalSeconds: 15
+  - name: batch
+    namespaces: [batch]
+    criticalSeconds: 300
+`), 0o644)
+	c, err := LoadThresholdPolicyConfig(file)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(c.Rules) != 2 || c.Rules[0].NodeSelector != "node-role.kubernetes.io/control-plane" ||
+		c.Rules[1].Namespaces[0] != "batch" || c.Rules[1].CriticalSeconds != 300 {
+		t.Fatalf("config = %+v", c)
+	}
+}
+
+// TestAnomalyDetectorPerNodeThresholds verifies the same gap is critical on a
+// control-plane node, normal on a legacy node and a warning elsewhere.
+func TestAnomalyDetectorPerNodeThresholds(t *testing.T) {
+	ms := NewMemoryStore()
+	det := NewAnomalyDetector(ms, 10, 40)
+	det.SetPolicy(testThresholdPolicy(t))
+
+	ctx := context.Background()
+	base := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	gap := 20 * time.Second
+	want := map[string]string{"cp-01": "critical", "spot-a1": "warning", "worker-1": "warning", "legacy-01": ""}
+	for node, severity := range want {
+		ms.Save(ctx, Heartbeat{NodeName: node, Namespace: "kube-node-lease", Timestamp: base, Status: "Ready"})
+		alert := det.OpenGap(node, base.Add(gap))
+		got := ""
+		if alert != nil {
+			got = alert.Severity
+		}
+		if got != severity {
+			t.Errorf("%s: severity = %q, want %q", node, got, severity)
+		}
+		if _, sev, _ := det.CheckGap(node, base.Add(gap)); sev != severity {
+			t.Errorf("%s: CheckGap severity = %q, want %q", node, sev, severity)
+		}
+	}
+	if at := det.OverdueAt("spot-a1", "kube-node-lease", base); !at.Equal(base.Add(120 * time.Second)) {
+		t.Errorf("OverdueAt(spot-a1) = %v, want +120s", at)
+	}
+}
+
+func TestThresholdHandlers(t *testing.T) {
+	p := testThresholdPolicy(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/thresholds", thresholdsHandler(p, nil))
+	mux.HandleFunc("/api/thresholds/resolve", thresholdResolveHandler(p))
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	var view thresholdPolicyView
+	rec := do(http.MethodGet, "/api/thresholds", "")
+	if err := json.NewDecoder(rec.Body).Decode(&view); err != nil || len(view.Rules) != 4 || view.Defaults.CriticalSeconds != 40 {
+		t.Fatalf("GET = %d %+v (%v)", rec.Code, view, err)
+	}
+
+	var res ThresholdResolution
+	rec = do(http.MethodGet, "/api/thresholds/resolve?node=spot-a1&namespace=kube-node-lease", "")
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil || res.Rule != "spot" || res.CriticalSeconds != 120 || res.Labels["pool"] != "spot" {
+		t.Fatalf("resolve = %d %+v (%v)", rec.Code, res, err)
+	}
+	if rec := do(http.MethodGet, "/api/thresholds/resolve", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("resolve without node = %d, want 400", rec.Code)
+	}
+
+	rec = do(http.MethodPut, "/api/thresholds", `{"rules": [{"name": "spot", "nodePattern": "spot-*", "criticalSeconds": 300}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", rec.Code, rec.Body)
+	}
+	if res := p.Resolve("spot-a1", ""); res.CriticalSeconds != 300 {
+		t.Errorf("after PUT resolved %+v", res)
+	}
+	rec = do(http.MethodPut, "/api/thresholds", `{"rules": [{"name": "bad", "warningSeconds": 90}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "critical") {
+		t.Errorf("invalid PUT = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/thresholds", "{}"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", rec.Code)
+	}
+}
+
+// TestThresholdHandlerCheck models -lease-watch, where a PUT matching on
+// namespaces is refused and leaves the rules as they were.
+func TestThresholdHandlerCheck(t *testing.T) {
+	p := testThresholdPolicy(t)
+	before := p.Config()
+	handler := thresholdsHandler(p, ThresholdPolicyConfig.rejectNamespaces)
+
+	rec := httptest.NewRecorder()
+	body := `{"rules": [{"name": "system", "namespaces": ["kube-system"], "criticalSeconds": 20}]}`
+	handler(rec, httptest.NewRequest(http.MethodPut, "/api/thresholds", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "nodeSelector") {
+		t.Errorf("PUT = %d: %s, want 400 steering to nodeSelector", rec.Code, rec.Body)
+	}
+	if after := p.Config(); len(after.Rules) != len(before.Rules) || after.Rules[0].Name != before.Rules[0].Name {
+		t.Errorf("refused PUT replaced the rules: %+v", after)
+	}
+}
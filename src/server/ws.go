@@ -71,6 +71,13 @@ func (h *Hub) Run() {
 	}
 }
 
+// ClientCount returns the number of connected WebSocket clients.
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
 // BroadcastHeartbeat sends a heartbeat event to all connected clients.
 func (h *Hub) BroadcastHeartbeat(event Heartbeat) {
 	msg := WSMessage{Type: "heartbeat", Payload: event}
@@ -137,6 +144,17 @@ func (h *Hub) BroadcastTopologyUpdate(record ConnectionRecord) {
 	h.broadcast <- data
 }
 
+// BroadcastNodeTransition sends a Ready/NotReady transition to all connected clients.
+func (h *Hub) BroadcastNodeTransition(transition NodeTransition) {
+	msg := WSMessage{Type: "node_transition", Payload: transition}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal node_transition WS message: %v", err)
+		return
+	}
+	h.broadcast <- data
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }